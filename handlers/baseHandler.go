@@ -11,6 +11,7 @@ import (
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/hmmftg/requestCore"
+	"github.com/hmmftg/requestCore/libAuthz"
 	"github.com/hmmftg/requestCore/libContext"
 	"github.com/hmmftg/requestCore/libLogger"
 	"github.com/hmmftg/requestCore/libRequest"
@@ -31,6 +32,11 @@ type HandlerParameters[Req, Resp any] struct {
 	LogArrays       []string
 	LogTags         []string
 	Persistence     RequestPersister[Req, Resp]
+	// Authorization: the caller needs one of Roles and all of
+	// Permissions, checked with libAuthz.Check before the request is
+	// parsed.
+	Roles       []string
+	Permissions []string
 	// Tracing parameters
 	EnableTracing   bool
 	TracingSpanName string
@@ -226,6 +232,16 @@ func BaseHandler[Req any, Resp any, Handler HandlerInterface[Req, Resp]](
 			}
 		}()
 
+		requirement := libAuthz.Requirement{Roles: params.Roles, Permissions: params.Permissions}
+		if !requirement.IsZero() {
+			decision, errAuthz := libAuthz.Check(w.Ctx, w.Parser, params.Title, requirement)
+			webFramework.AddLog(w, webFramework.HandlerLogTag, slog.Any("authorization", decision))
+			if errAuthz != nil {
+				respondError(core, &trx, errAuthz)
+				return
+			}
+		}
+
 		if simulation {
 			resp, header, errParse := libRequest.ParseRequest[Resp](
 				trx.W,
@@ -8,6 +8,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/hmmftg/requestCore/libAuthz"
 	"github.com/hmmftg/requestCore/libError"
 	"github.com/hmmftg/requestCore/libRequest"
 	"github.com/hmmftg/requestCore/status"
@@ -51,6 +52,7 @@ type testHandlerType[Req testReq, Resp testResp] struct {
 	InitErr         error
 	HandlerErr      error
 	PanicInHandler  bool
+	Roles           []string
 }
 
 func (h testHandlerType[Req, Resp]) Parameters() HandlerParameters[Req, Resp] {
@@ -67,6 +69,7 @@ func (h testHandlerType[Req, Resp]) Parameters() HandlerParameters[Req, Resp] {
 		LogTags:         nil,
 		EnableTracing:   false,
 		TracingSpanName: "",
+		Roles:           h.Roles,
 	}
 }
 func (h testHandlerType[Req, Resp]) Initializer(_ HandlerRequest[Req, Resp]) error {
@@ -309,3 +312,51 @@ func TestBaseHandlerNoPersistence(t *testing.T) {
 		Silent:  true,
 	})
 }
+
+func TestBaseHandlerRoles(t *testing.T) {
+	a, err := libAuthz.New(libAuthz.Config{Resolver: libAuthz.HeaderResolver("User-Roles")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	libAuthz.SetDefault(a)
+	t.Cleanup(func() { libAuthz.SetDefault(nil) })
+
+	testCases := []testingtools.TestCase{
+		{
+			Name:      "Allowed",
+			URL:       "/",
+			Header:    testingtools.Header{{Key: "User-Roles", Value: "teller"}},
+			Request:   testReq{ID: "1"},
+			Status:    200,
+			CheckBody: []string{"result", `"a"`},
+		},
+		{
+			Name:      "Denied",
+			URL:       "/",
+			Header:    testingtools.Header{{Key: "User-Roles", Value: "auditor"}},
+			Request:   testReq{ID: "1"},
+			Status:    403,
+			CheckBody: []string{"ACCESS"},
+		},
+	}
+
+	handler := BaseHandler(
+		testEnv(t).Interface,
+		testHandlerType[testReq, testResp]{
+			Title:        "test",
+			Path:         "/path/to/api",
+			Mode:         libRequest.JSON,
+			VerifyHeader: true,
+			Roles:        []string{"teller"},
+		},
+		false,
+	)
+	gin.SetMode(gin.ReleaseMode)
+	testingtools.TestAPI(t, testCases, &testingtools.TestOptions{
+		Path:    "/",
+		Name:    "check handler roles",
+		Method:  "POST",
+		Handler: handler,
+		Silent:  true,
+	})
+}
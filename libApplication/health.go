@@ -0,0 +1,75 @@
+package initiator
+
+import (
+	"context"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/hmmftg/requestCore/libContext"
+	"github.com/hmmftg/requestCore/libHealth"
+	"github.com/hmmftg/requestCore/libParams"
+	"github.com/hmmftg/requestCore/libTracing"
+)
+
+// Health probe paths registered by App.ServeHealth under its prefix.
+const (
+	LivenessPath  = "/livez"
+	ReadinessPath = "/readyz"
+	HealthPath    = "/healthz"
+)
+
+// NewHealthChecker creates a checker with a ping check for each database
+// in dbNames, a reachability check for each configured remote API and,
+// when global tracing is initialized, an exporter check.
+func NewHealthChecker[T any](wsParams *libParams.ApplicationParams[T], dbNames []string) *libHealth.Checker {
+	apiNames := make([]string, 0, len(wsParams.RemoteAPIs))
+	for name := range wsParams.RemoteAPIs {
+		apiNames = append(apiNames, name)
+	}
+	sort.Strings(apiNames)
+
+	checker := libHealth.New()
+	checks := libHealth.ParamChecks(wsParams, dbNames, apiNames)
+	if tm := libTracing.GetGlobalTracingManager(); tm != nil {
+		checks = append(checks, libHealth.TracingExporter(tm))
+	}
+	// Names come from distinct map keys, so registration cannot fail.
+	_ = checker.Register(checks...)
+	return checker
+}
+
+// ServeHealth serves the liveness, readiness and detailed health endpoints
+// of App.Health under prefix ("/" for the engine root). It is opt-in, so
+// services that already serve one of the paths keep working; call it
+// once, before StartApp.
+func (a *App[T]) ServeHealth(prefix string) {
+	AddHealthRoutes(a.Engine, prefix, a.Health)
+}
+
+// AddHealthRoutes serves the liveness, readiness and detailed health
+// endpoints of checker under prefix on routes.
+func AddHealthRoutes(routes gin.IRoutes, prefix string, checker *libHealth.Checker) {
+	probe := func(name string, report func(context.Context) libHealth.Report) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			start := time.Now()
+			finalize := libContext.AddWebHandlerLogs(c, name, "health-handler")
+			r := report(c.Request.Context())
+			defer finalize(start, r.HTTPStatus())
+			c.Header("Cache-Control", "no-store")
+			c.String(r.HTTPStatus(), r.Text())
+		}
+	}
+	routes.GET(path.Join("/", prefix, LivenessPath), probe("Liveness", checker.Live))
+	routes.GET(path.Join("/", prefix, ReadinessPath), probe("Readiness", checker.Ready))
+	routes.GET(path.Join("/", prefix, HealthPath), func(c *gin.Context) {
+		start := time.Now()
+		finalize := libContext.AddWebHandlerLogs(c, "Health", "health-handler")
+		r := checker.Health(c.Request.Context())
+		defer finalize(start, r.HTTPStatus())
+		c.Header("Cache-Control", "no-store")
+		c.JSON(r.HTTPStatus(), r)
+	})
+}
@@ -18,10 +18,13 @@ import (
 
 	"github.com/hmmftg/requestCore"
 	"github.com/hmmftg/requestCore/libApplication/metrics"
+	"github.com/hmmftg/requestCore/libAuthz"
 	"github.com/hmmftg/requestCore/libCallApi"
 	"github.com/hmmftg/requestCore/libContext"
 	gininitiator "github.com/hmmftg/requestCore/libGin/initiator"
+	"github.com/hmmftg/requestCore/libHealth"
 	"github.com/hmmftg/requestCore/libParams"
+	"github.com/hmmftg/requestCore/libShutdown"
 	"github.com/hmmftg/requestCore/swagger"
 	"github.com/hmmftg/requestCore/webFramework"
 
@@ -30,6 +33,8 @@ import (
 )
 
 // Application defines the interface that a requestCore-based service must implement.
+// AddRoutes receives the role map of the libAuthz.RolesParamGroup parameter
+// group, each role's comma-separated permissions, for libAuthz.Config.RoleMap.
 type Application[T any] interface {
 	AddRoutes(
 		model *requestCore.RequestCoreModel,
@@ -63,6 +68,15 @@ type App[T any] struct {
 	Params   *libParams.ApplicationParams[T]
 	Engine   *gin.Engine
 	Model    requestCore.RequestCoreInterface
+	// Health backs /livez, /readyz and /healthz once ServeHealth is
+	// called. Register application-specific checks on it;
+	// SetDraining(true) fails readiness ahead of shutdown.
+	Health *libHealth.Checker
+	// Shutdown runs on SIGINT or SIGTERM in StartApp: readiness fails for
+	// NetworkParams.DrainDelay, the server drains, spans are flushed and
+	// the databases from GetDbList are closed. Add hooks for workers,
+	// schedulers and buffered loggers with Shutdown.Add.
+	Shutdown *libShutdown.Manager
 }
 
 // InitializeApp initializes and returns a fully configured App from the given Application instance.
@@ -132,10 +146,13 @@ func InitializeApp[T any](app Application[T]) *App[T] {
 		wsParams.RemoteAPIs[id] = api
 	}
 
+	health := NewHealthChecker(wsParams, app.GetDbList())
+	shutdown := NewShutdownManager(wsParams.GetNetwork(app.Name()), health, wsParams, app.GetDbList())
+
 	app.AddRoutes(
 		model,
 		wsParams,
-		nil,
+		wsParams.ParameterGroups[libAuthz.RolesParamGroup].Params,
 		root,
 	)
 	if wsParams.Logging.UseSlog {
@@ -154,10 +171,15 @@ func InitializeApp[T any](app Application[T]) *App[T] {
 		})
 	}
 
-	return &App[T]{app, wsParams, engine, model}
+	return &App[T]{app, wsParams, engine, model, health, shutdown}
 }
 
-// StartApp starts the HTTP/HTTPS server for the given application.
+// StartApp starts the HTTP/HTTPS server for the given application and
+// runs App.Shutdown on SIGINT or SIGTERM.
 func StartApp[T any](app App[T]) {
-	Listen(app.Params.GetNetwork(app.Instance.Name()), app.Engine)
+	manager := app.Shutdown
+	if manager == nil {
+		manager = libShutdown.New(nil)
+	}
+	ListenWithShutdown(app.Params.GetNetwork(app.Instance.Name()), app.Engine, manager)
 }
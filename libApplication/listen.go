@@ -1,34 +1,116 @@
 package initiator
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/hmmftg/requestCore/libHealth"
 	"github.com/hmmftg/requestCore/libParams"
+	"github.com/hmmftg/requestCore/libShutdown"
 	"github.com/hmmftg/requestCore/webFramework"
 )
 
-// Listen starts the Gin engine on the configured HTTP or HTTPS port.
+// DefaultShutdownTimeout bounds the shutdown sequence when
+// NetworkParams.ShutdownTimeout is not set.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// ShutdownSignals trigger graceful shutdown in Listen and StartApp.
+var ShutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// Listen starts the Gin engine on the configured HTTP or HTTPS port and
+// shuts it down gracefully on ShutdownSignals.
 func Listen(netParams *libParams.NetworkParams, app *gin.Engine) {
-	listenLog := ""
+	ListenWithShutdown(netParams, app, libShutdown.New(nil))
+}
+
+// ListenWithShutdown starts the Gin engine like Listen. On one of
+// ShutdownSignals it registers the server in libShutdown.PhaseHTTP and
+// runs manager, bounded by NetworkParams.ShutdownTimeout. A failure to
+// listen is fatal, as before.
+func ListenWithShutdown(netParams *libParams.NetworkParams, app *gin.Engine, manager *libShutdown.Manager) {
+	ctx, stop := signal.NotifyContext(context.Background(), ShutdownSignals...)
+	defer stop()
+
+	server := &http.Server{Handler: app} // #nosec G112 -- timeouts are left to the reverse proxy, as with gin.Run
+	listen := func() error { return server.ListenAndServe() }
 	if len(netParams.TLSPort) > 0 {
-		listenLog = fmt.Sprintf("About to tls listen on %s", netParams.TLSPort)
-		webFramework.AddStartUpLog(slog.String("listen", listenLog))
-		webFramework.CollectStartUpLogs()
-		errHTTP := app.RunTLS(":"+netParams.TLSPort, netParams.TLSCert, netParams.TLSKey)
-		if errHTTP != nil {
-			log.Fatal("Web server (HTTPS): ", errHTTP)
-		}
+		server.Addr = ":" + netParams.TLSPort
+		webFramework.AddStartUpLog(slog.String("listen", fmt.Sprintf("About to tls listen on %s", netParams.TLSPort)))
+		listen = func() error { return server.ListenAndServeTLS(netParams.TLSCert, netParams.TLSKey) }
 	} else {
-		listenLog = fmt.Sprintf("About to listen on %s", netParams.Port)
-		webFramework.AddStartUpLog(slog.String("listen", listenLog))
-		webFramework.CollectStartUpLogs()
-		errHTTP := app.Run(":" + netParams.Port)
-		if errHTTP != nil {
-			log.Fatal("Web server (HTTP): ", errHTTP)
+		server.Addr = ":" + netParams.Port
+		webFramework.AddStartUpLog(slog.String("listen", fmt.Sprintf("About to listen on %s", netParams.Port)))
+	}
+	webFramework.CollectStartUpLogs()
+
+	timeout := netParams.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	if err := serve(ctx, server, listen, manager, timeout); err != nil {
+		log.Fatal("Web server: ", err)
+	}
+}
+
+// serve runs listen until it fails or ctx is done, then runs manager with
+// server added to its HTTP phase. Shutdown errors are logged rather than
+// returned: the process is exiting either way.
+func serve(
+	ctx context.Context,
+	server *http.Server,
+	listen func() error,
+	manager *libShutdown.Manager,
+	timeout time.Duration,
+) error {
+	manager.Add(libShutdown.PhaseHTTP, "server", libShutdown.HTTPServer(server))
+
+	errs := make(chan error, 1)
+	go func() { errs <- listen() }()
+	select {
+	case err := <-errs:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
 		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down web server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := manager.Shutdown(shutdownCtx); err != nil {
+		log.Println("Shutdown:", err)
+	}
+	return nil
+}
+
+// NewShutdownManager creates the shutdown sequence of a v1 application:
+// readiness fails on checker for netParams.DrainDelay, pending spans are
+// exported and the connection pools of dbNames are closed. The HTTP
+// server is added by ListenWithShutdown.
+func NewShutdownManager(
+	netParams *libParams.NetworkParams,
+	checker *libHealth.Checker,
+	params libParams.ParamInterface,
+	dbNames []string,
+) *libShutdown.Manager {
+	var drainDelay time.Duration
+	if netParams != nil {
+		drainDelay = netParams.DrainDelay
 	}
+	manager := libShutdown.New(nil)
+	manager.Add(libShutdown.PhaseReadiness, "health", libShutdown.Readiness(checker, drainDelay))
+	manager.Add(libShutdown.PhaseFlush, "tracing", libShutdown.Tracing())
+	manager.Add(libShutdown.PhaseClose, "databases", libShutdown.Databases(params, dbNames))
+	return manager
 }
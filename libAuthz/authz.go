@@ -0,0 +1,304 @@
+// Package libAuthz provides role-based authorization for requestCore
+// handlers.
+//
+// Handlers declare a Requirement: roles the caller needs one of and
+// permissions the caller needs all of (HandlerParameters.Roles and
+// Permissions in v1, Endpoint.WithRoles and WithPermissions in v2). An
+// Authorizer resolves the caller's roles with a Resolver (a header, JWT
+// claims, a database lookup, optionally cached) and expands them into
+// permissions with the role map the application receives in AddRoutes,
+// read from the RolesParamGroup parameter group:
+//
+//	parameterGroups:
+//	  roles:
+//	    params:
+//	      teller: accounts.read, transfers.create
+//	      auditor: accounts.read, reports.read
+//	      admin: "*"
+//
+// Every decision is passed to the Authorizer's audit function, which logs
+// it by default, and denied requests fail with a 403 libError whose
+// ACCESS_DENIED code is localized through the application's error
+// descriptions like any other error code.
+package libAuthz
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hmmftg/requestCore/libError"
+	"github.com/hmmftg/requestCore/status"
+	"github.com/hmmftg/requestCore/webFramework"
+)
+
+const (
+	// RolesParamGroup is the parameter group holding the role map: each
+	// parameter names a role and lists its comma-separated permissions.
+	RolesParamGroup = "roles"
+
+	// AllPermissions grants every permission when listed for a role.
+	AllPermissions = "*"
+
+	// AccessDenied is the error code of denied requests. Seed it in the
+	// error descriptions to localize the 403 response.
+	AccessDenied = "ACCESS_DENIED"
+
+	// PrincipalKey is the local storage key for the request's resolved
+	// *Principal on the request parser.
+	PrincipalKey = "_authz_principal"
+
+	// DefaultUserHeader is the request header identifying the caller.
+	DefaultUserHeader = "User-Id"
+)
+
+// Requirement is the access a handler requires.
+type Requirement struct {
+	// Roles lists roles of which the caller needs at least one.
+	Roles []string `json:"roles,omitempty"`
+	// Permissions lists permissions the caller needs all of.
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// IsZero reports whether the requirement allows everyone.
+func (r Requirement) IsZero() bool {
+	return len(r.Roles) == 0 && len(r.Permissions) == 0
+}
+
+// Policy maps roles to the permissions they grant.
+type Policy map[string][]string
+
+// NewPolicy parses a role map of comma-separated permissions per role,
+// such as the one passed to AddRoutes.
+func NewPolicy(roleMap map[string]string) Policy {
+	policy := make(Policy, len(roleMap))
+	for role, list := range roleMap {
+		policy[strings.TrimSpace(role)] = splitList(list)
+	}
+	return policy
+}
+
+// Permissions returns the sorted, distinct permissions granted by roles.
+func (p Policy) Permissions(roles []string) []string {
+	var permissions []string
+	for _, role := range roles {
+		for _, permission := range p[role] {
+			if !slices.Contains(permissions, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
+// Principal is the caller of a request with its resolved roles.
+type Principal struct {
+	User        string
+	Roles       []string
+	Permissions []string
+
+	authorizer *Authorizer
+}
+
+// HasRole reports whether the principal has role.
+func (p *Principal) HasRole(role string) bool {
+	return p != nil && slices.Contains(p.Roles, role)
+}
+
+// HasPermission reports whether the principal's roles grant permission.
+func (p *Principal) HasPermission(permission string) bool {
+	return p != nil && (slices.Contains(p.Permissions, permission) || slices.Contains(p.Permissions, AllPermissions))
+}
+
+// check returns why the principal does not meet req, or "" if it does.
+func (p *Principal) check(req Requirement) string {
+	if p == nil || p.User == "" {
+		return "anonymous caller"
+	}
+	if len(req.Roles) > 0 && !slices.ContainsFunc(req.Roles, p.HasRole) {
+		return "missing role"
+	}
+	for _, permission := range req.Permissions {
+		if !p.HasPermission(permission) {
+			return "missing permission " + permission
+		}
+	}
+	return ""
+}
+
+// Decision is the audit record of an authorization check.
+type Decision struct {
+	Time        time.Time   `json:"time"`
+	Handler     string      `json:"handler"`
+	Method      string      `json:"method"`
+	Path        string      `json:"path"`
+	User        string      `json:"user"`
+	Roles       []string    `json:"roles,omitempty"`
+	Requirement Requirement `json:"requirement"`
+	Allowed     bool        `json:"allowed"`
+	Reason      string      `json:"reason,omitempty"`
+}
+
+// LogValue implements slog.LogValuer.
+func (d Decision) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("handler", d.Handler),
+		slog.String("method", d.Method),
+		slog.String("path", d.Path),
+		slog.String("user", d.User),
+		slog.Any("roles", d.Roles),
+		slog.Any("required-roles", d.Requirement.Roles),
+		slog.Any("required-permissions", d.Requirement.Permissions),
+		slog.Bool("allowed", d.Allowed),
+		slog.String("reason", d.Reason),
+	)
+}
+
+// LogDecision is the default audit function. It logs grants at info and
+// denials at warn level.
+func LogDecision(d Decision) {
+	level := slog.LevelInfo
+	if !d.Allowed {
+		level = slog.LevelWarn
+	}
+	slog.Log(context.Background(), level, "authorization decision", slog.Any("decision", d))
+}
+
+// Config configures an Authorizer.
+type Config struct {
+	// RoleMap lists the comma-separated permissions of each role.
+	RoleMap map[string]string
+
+	// Resolver resolves the caller's roles. Required.
+	Resolver Resolver
+
+	// UserHeader is the request header identifying the caller.
+	// Default: DefaultUserHeader.
+	UserHeader string
+
+	// Audit receives every decision, for example to store it.
+	// Default: LogDecision.
+	Audit func(Decision)
+
+	// Clock is the clock source for deterministic testing.
+	// If nil, time.Now is used.
+	Clock func() time.Time
+}
+
+// Authorizer resolves principals and checks requirements. It is safe for
+// concurrent use.
+type Authorizer struct {
+	config Config
+	policy Policy
+}
+
+// New creates an Authorizer.
+func New(config Config) (*Authorizer, error) {
+	if config.Resolver == nil {
+		return nil, fmt.Errorf("libAuthz: Config.Resolver is required")
+	}
+	if config.UserHeader == "" {
+		config.UserHeader = DefaultUserHeader
+	}
+	if config.Audit == nil {
+		config.Audit = LogDecision
+	}
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+	return &Authorizer{config: config, policy: NewPolicy(config.RoleMap)}, nil
+}
+
+// Principal returns the caller of the request, resolving it on first use
+// and keeping it in the parser's locals for the rest of the request.
+// Resolver failures are returned as a 500 libError.
+func (a *Authorizer) Principal(ctx context.Context, parser webFramework.RequestParser) (*Principal, error) {
+	if p, ok := parser.GetLocal(PrincipalKey).(*Principal); ok && p.authorizer == a {
+		return p, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	p := &Principal{User: parser.GetHeaderValue(a.config.UserHeader), authorizer: a}
+	if p.User != "" {
+		roles, err := a.config.Resolver.Roles(ctx, parser, p.User)
+		if err != nil {
+			return nil, libError.NewWithDescription(status.InternalServerError, "AUTHZ_UNAVAILABLE",
+				"resolve roles of %s: %v", p.User, err)
+		}
+		p.Roles = roles
+		p.Permissions = a.policy.Permissions(roles)
+	}
+	parser.SetLocal(PrincipalKey, p)
+	return p, nil
+}
+
+// Authorize checks req for the caller of the request and audits the
+// decision under handler. A denied request fails with a 403 libError
+// coded AccessDenied.
+func (a *Authorizer) Authorize(ctx context.Context, parser webFramework.RequestParser, handler string, req Requirement) (Decision, error) {
+	p, err := a.Principal(ctx, parser)
+	if err != nil {
+		return Decision{}, err
+	}
+	return a.decide(parser, p, handler, req)
+}
+
+func (a *Authorizer) decide(parser webFramework.RequestParser, p *Principal, handler string, req Requirement) (Decision, error) {
+	reason := p.check(req)
+	d := Decision{
+		Time:        a.config.Clock(),
+		Handler:     handler,
+		Method:      parser.GetMethod(),
+		Path:        parser.GetPath(),
+		User:        p.User,
+		Roles:       p.Roles,
+		Requirement: req,
+		Allowed:     reason == "",
+		Reason:      reason,
+	}
+	a.config.Audit(d)
+	if !d.Allowed {
+		return d, libError.NewWithDescription(status.Forbidden, AccessDenied, "%s: %s", handler, reason)
+	}
+	return d, nil
+}
+
+var defaultAuthorizer atomic.Pointer[Authorizer]
+
+// SetDefault sets the Authorizer Check uses for requests whose principal
+// was not resolved by a middleware.
+func SetDefault(a *Authorizer) {
+	defaultAuthorizer.Store(a)
+}
+
+// Default returns the Authorizer set with SetDefault, or nil.
+func Default() *Authorizer {
+	return defaultAuthorizer.Load()
+}
+
+// Check enforces req for a handler. It uses the Authorizer that resolved
+// the request's principal, such as the v2 authz middleware, or else the
+// default Authorizer. A zero requirement always passes without an audit
+// record. A non-zero requirement without any Authorizer is denied, so a
+// missing setup fails closed.
+func Check(ctx context.Context, parser webFramework.RequestParser, handler string, req Requirement) (Decision, error) {
+	if req.IsZero() {
+		return Decision{Allowed: true}, nil
+	}
+	if p, ok := parser.GetLocal(PrincipalKey).(*Principal); ok && p.authorizer != nil {
+		return p.authorizer.decide(parser, p, handler, req)
+	}
+	if a := Default(); a != nil {
+		return a.Authorize(ctx, parser, handler, req)
+	}
+	slog.Error("libAuthz: no authorizer configured", slog.String("handler", handler))
+	return Decision{Handler: handler, Requirement: req, Reason: "no authorizer"},
+		libError.NewWithDescription(status.Forbidden, AccessDenied, "%s: no authorizer configured", handler)
+}
@@ -0,0 +1,201 @@
+package libAuthz_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/hmmftg/requestCore/libAuthz"
+	"github.com/hmmftg/requestCore/libError"
+	"github.com/hmmftg/requestCore/libQuery"
+	"github.com/hmmftg/requestCore/status"
+	"github.com/hmmftg/requestCore/webFramework"
+)
+
+var roleMap = map[string]string{
+	"teller":  "accounts.read, transfers.create",
+	"auditor": "accounts.read,reports.read",
+	"admin":   "*",
+}
+
+func request(headers map[string]string) webFramework.RequestParser {
+	return webFramework.FakeParser{
+		Method:    "POST",
+		Path:      "/transfers",
+		ReqHeader: headers,
+		Locals:    map[string]any{},
+	}
+}
+
+func newAuthorizer(t *testing.T, audit *[]libAuthz.Decision) *libAuthz.Authorizer {
+	t.Helper()
+	a, err := libAuthz.New(libAuthz.Config{
+		RoleMap:  roleMap,
+		Resolver: libAuthz.HeaderResolver("User-Roles"),
+		Audit:    func(d libAuthz.Decision) { *audit = append(*audit, d) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestAuthorizer(t *testing.T) {
+	var audit []libAuthz.Decision
+	a := newAuthorizer(t, &audit)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		req     libAuthz.Requirement
+		allowed bool
+		reason  string
+	}{
+		{"role", map[string]string{"User-Id": "u1", "User-Roles": "auditor, teller"},
+			libAuthz.Requirement{Roles: []string{"teller", "admin"}}, true, ""},
+		{"permissions", map[string]string{"User-Id": "u1", "User-Roles": "teller"},
+			libAuthz.Requirement{Permissions: []string{"accounts.read", "transfers.create"}}, true, ""},
+		{"wildcard", map[string]string{"User-Id": "u1", "User-Roles": "admin"},
+			libAuthz.Requirement{Roles: []string{"admin"}, Permissions: []string{"reports.read"}}, true, ""},
+		{"missing role", map[string]string{"User-Id": "u1", "User-Roles": "auditor"},
+			libAuthz.Requirement{Roles: []string{"teller"}}, false, "missing role"},
+		{"missing permission", map[string]string{"User-Id": "u1", "User-Roles": "auditor"},
+			libAuthz.Requirement{Permissions: []string{"accounts.read", "transfers.create"}}, false, "missing permission transfers.create"},
+		{"anonymous", map[string]string{"User-Roles": "admin"},
+			libAuthz.Requirement{Roles: []string{"admin"}}, false, "anonymous caller"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit = nil
+			d, err := a.Authorize(context.Background(), request(tt.headers), "create-transfer", tt.req)
+			if d.Allowed != tt.allowed || d.Reason != tt.reason || (err == nil) != tt.allowed {
+				t.Fatalf("unexpected decision %+v: %v", d, err)
+			}
+			if len(audit) != 1 || audit[0].Handler != "create-transfer" || audit[0].Path != "/transfers" || audit[0].Allowed != tt.allowed {
+				t.Fatalf("unexpected audit %+v", audit)
+			}
+			if err != nil {
+				var libErr libError.ErrorData
+				if !errors.As(err, &libErr) || libErr.Action().Status != status.Forbidden || libErr.Action().Description != libAuthz.AccessDenied {
+					t.Fatalf("expected 403 %s, got %v", libAuthz.AccessDenied, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	var audit []libAuthz.Decision
+	a := newAuthorizer(t, &audit)
+	req := libAuthz.Requirement{Roles: []string{"teller"}}
+	headers := map[string]string{"User-Id": "u1", "User-Roles": "teller"}
+
+	if d, err := libAuthz.Check(context.Background(), request(headers), "open", libAuthz.Requirement{}); err != nil || !d.Allowed || len(audit) != 0 {
+		t.Fatalf("expected a zero requirement to pass unaudited, got %+v %v", d, err)
+	}
+
+	// Without a resolved principal or default authorizer, Check fails
+	// closed.
+	if _, err := libAuthz.Check(context.Background(), request(headers), "transfer", req); err == nil {
+		t.Fatal("expected denial without an authorizer")
+	}
+
+	// A principal resolved by a middleware is used as is.
+	parser := request(headers)
+	if _, err := a.Principal(context.Background(), parser); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := libAuthz.Check(context.Background(), parser, "transfer", req); err != nil || len(audit) != 1 {
+		t.Fatalf("expected the resolved principal to be allowed, got %v", err)
+	}
+
+	libAuthz.SetDefault(a)
+	t.Cleanup(func() { libAuthz.SetDefault(nil) })
+	if _, err := libAuthz.Check(context.Background(), request(headers), "transfer", req); err != nil || len(audit) != 2 {
+		t.Fatalf("expected the default authorizer to be used, got %v", err)
+	}
+}
+
+func TestCachedResolver(t *testing.T) {
+	calls := 0
+	inner := libAuthz.ResolverFunc(func(_ context.Context, _ webFramework.RequestParser, user string) ([]string, error) {
+		calls++
+		if user == "broken" {
+			return nil, errors.New("db down")
+		}
+		return []string{"teller"}, nil
+	})
+	now := time.Unix(1_700_000_000, 0)
+	cached := libAuthz.NewCachedResolver(inner, time.Minute, func() time.Time { return now })
+	parser := request(nil)
+
+	for range 3 {
+		if roles, err := cached.Roles(context.Background(), parser, "u1"); err != nil || len(roles) != 1 {
+			t.Fatalf("unexpected roles %v: %v", roles, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected one lookup, got %d", calls)
+	}
+	now = now.Add(time.Minute)
+	_, _ = cached.Roles(context.Background(), parser, "u1")
+	cached.Invalidate("u1")
+	_, _ = cached.Roles(context.Background(), parser, "u1")
+	if calls != 3 {
+		t.Fatalf("expected lookups after expiry and invalidation, got %d", calls)
+	}
+
+	for range 2 {
+		if _, err := cached.Roles(context.Background(), parser, "broken"); err == nil {
+			t.Fatal("expected the error to be returned")
+		}
+	}
+	if calls != 5 {
+		t.Fatalf("expected errors not to be cached, got %d lookups", calls)
+	}
+}
+
+func TestSQLResolver(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectPrepare("SELECT role").ExpectQuery().WithArgs("u1").WillReturnRows(
+		sqlmock.NewRows([]string{"role"}).AddRow("teller").AddRow(" auditor "))
+	mock.ExpectPrepare("SELECT role").ExpectQuery().WithArgs("u2").WillReturnError(errors.New("db down"))
+
+	resolver := libAuthz.Chain(
+		libAuthz.HeaderResolver("User-Roles"),
+		libAuthz.SQLResolver(libQuery.QueryRunnerModel{DB: db}, libQuery.QueryCommand{
+			Name:    "user-roles",
+			Command: "SELECT role FROM user_roles WHERE user_id=$1",
+		}),
+	)
+	a, err := libAuthz.New(libAuthz.Config{RoleMap: roleMap, Resolver: resolver, Audit: func(libAuthz.Decision) {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := a.Principal(context.Background(), request(map[string]string{"User-Id": "u1"}))
+	if err != nil || strings.Join(p.Roles, ",") != "teller,auditor" ||
+		strings.Join(p.Permissions, ",") != "accounts.read,reports.read,transfers.create" {
+		t.Fatalf("unexpected principal %+v: %v", p, err)
+	}
+	p, err = a.Principal(context.Background(), request(map[string]string{"User-Id": "u3", "User-Roles": "admin"}))
+	if err != nil || !p.HasPermission("anything") {
+		t.Fatalf("expected header roles to take precedence, got %+v: %v", p, err)
+	}
+	_, err = a.Principal(context.Background(), request(map[string]string{"User-Id": "u2"}))
+	var libErr libError.ErrorData
+	if !errors.As(err, &libErr) || libErr.Action().Status != status.InternalServerError {
+		t.Fatalf("expected a 500 for a failed lookup, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
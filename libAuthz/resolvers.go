@@ -0,0 +1,163 @@
+package libAuthz
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hmmftg/requestCore/libQuery"
+	"github.com/hmmftg/requestCore/webFramework"
+)
+
+// Resolver resolves the roles of a request's caller. Implementations
+// must be safe for concurrent use.
+type Resolver interface {
+	// Roles returns the roles of user, the non-empty value of the
+	// Authorizer's user header.
+	Roles(ctx context.Context, parser webFramework.RequestParser, user string) ([]string, error)
+}
+
+// ResolverFunc adapts a function to Resolver.
+type ResolverFunc func(ctx context.Context, parser webFramework.RequestParser, user string) ([]string, error)
+
+// Roles implements Resolver.
+func (f ResolverFunc) Roles(ctx context.Context, parser webFramework.RequestParser, user string) ([]string, error) {
+	return f(ctx, parser, user)
+}
+
+// HeaderResolver reads comma-separated roles from a request header. Use
+// it only behind a gateway that sets the header and strips it from
+// client requests.
+func HeaderResolver(name string) Resolver {
+	return ResolverFunc(func(_ context.Context, parser webFramework.RequestParser, _ string) ([]string, error) {
+		return splitList(parser.GetHeaderValue(name)), nil
+	})
+}
+
+// Chain returns a Resolver that asks each resolver in turn and returns
+// the first non-empty roles, for example JWT claims falling back to a
+// database lookup.
+func Chain(resolvers ...Resolver) Resolver {
+	return ResolverFunc(func(ctx context.Context, parser webFramework.RequestParser, user string) ([]string, error) {
+		for _, r := range resolvers {
+			roles, err := r.Roles(ctx, parser, user)
+			if err != nil || len(roles) > 0 {
+				return roles, err
+			}
+		}
+		return nil, nil
+	})
+}
+
+// roleRow is a row of an SQLResolver query.
+type roleRow struct {
+	Role string `db:"role"`
+}
+
+// SQLResolver looks up roles in the database with a query taking the user
+// as its only argument and returning a "role" column, for example:
+//
+//	libQuery.QueryCommand{
+//	    Name:    "user-roles",
+//	    Command: "SELECT role_name AS role FROM user_roles WHERE user_id=$1",
+//	    CommandMap: map[libQuery.DBMode]string{
+//	        libQuery.Oracle: "SELECT role_name AS role FROM user_roles WHERE user_id=:1",
+//	    },
+//	}
+//
+// Wrap it in a CachedResolver to avoid a query per request.
+func SQLResolver(core libQuery.QueryRunnerInterface, command libQuery.QueryCommand) Resolver {
+	return ResolverFunc(func(ctx context.Context, _ webFramework.RequestParser, user string) ([]string, error) {
+		rows, err := libQuery.QueryRows[roleRow](ctx, core, command.GetCommand(core.GetDbMode()), user)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		var roles []string
+		for rows.Next() {
+			if role := strings.TrimSpace(rows.Row().Role); role != "" {
+				roles = append(roles, role)
+			}
+		}
+		return roles, rows.Err()
+	})
+}
+
+// CachedResolver caches the roles another Resolver returns per user, so
+// role changes take effect after at most TTL. Errors are not cached.
+type CachedResolver struct {
+	resolver Resolver
+	ttl      time.Duration
+	clock    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cachedRoles
+}
+
+type cachedRoles struct {
+	roles   []string
+	expires time.Time
+}
+
+// maxCachedUsers bounds the cache; expired entries are dropped when it is
+// reached, and the whole cache if none has expired.
+const maxCachedUsers = 10000
+
+// NewCachedResolver caches resolver's roles for ttl. clock may be nil to
+// use time.Now.
+func NewCachedResolver(resolver Resolver, ttl time.Duration, clock func() time.Time) *CachedResolver {
+	if clock == nil {
+		clock = time.Now
+	}
+	return &CachedResolver{resolver: resolver, ttl: ttl, clock: clock, entries: make(map[string]cachedRoles)}
+}
+
+// Roles implements Resolver.
+func (c *CachedResolver) Roles(ctx context.Context, parser webFramework.RequestParser, user string) ([]string, error) {
+	now := c.clock()
+	c.mu.Lock()
+	entry, ok := c.entries[user]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.roles, nil
+	}
+
+	roles, err := c.resolver.Roles(ctx, parser, user)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCachedUsers {
+		for u, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, u)
+			}
+		}
+		if len(c.entries) >= maxCachedUsers {
+			clear(c.entries)
+		}
+	}
+	c.entries[user] = cachedRoles{roles: roles, expires: now.Add(c.ttl)}
+	return roles, nil
+}
+
+// Invalidate drops the cached roles of user, for example after changing
+// them.
+func (c *CachedResolver) Invalidate(user string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, user)
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
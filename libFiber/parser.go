@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"os"
 
 	"github.com/hmmftg/requestCore/libQuery"
 	"github.com/hmmftg/requestCore/webFramework"
@@ -198,8 +199,15 @@ func (c FiberParser) SaveFile(
 	return nil
 }
 
-// FileAttachment sends a file as an HTTP attachment with the given filename.
+// FileAttachment sends the file at path as an HTTP attachment named
+// fileName, like the Gin and net/http parsers. For compatibility with
+// earlier releases, a path that is not a file is treated as a prefix of
+// fileName and the joined file is sent as is.
 func (c FiberParser) FileAttachment(path, fileName string) {
+	if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+		_ = c.Ctx.Download(path, fileName)
+		return
+	}
 	file := fmt.Sprintf("%s%s", path, fileName)
 	_ = c.Ctx.SendFile(file, true)
 }
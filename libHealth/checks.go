@@ -0,0 +1,131 @@
+package libHealth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/hmmftg/requestCore/libCallApi"
+	"github.com/hmmftg/requestCore/libParams"
+	"github.com/hmmftg/requestCore/libTracing"
+)
+
+// DefaultSaturationThreshold is the worker queue fill ratio at which
+// Saturation fails.
+const DefaultSaturationThreshold = 0.9
+
+// Database checks db with PingContext. db is resolved on every run, so
+// the check can be registered before the connection is opened.
+func Database(name string, db func() *sql.DB) Check {
+	return Check{
+		Name: "db:" + name,
+		Func: func(ctx context.Context) error {
+			conn := db()
+			if conn == nil {
+				return errors.New("database not initialized")
+			}
+			return conn.PingContext(ctx)
+		},
+	}
+}
+
+// RemoteAPI checks that the API's domain answers HTTP requests. Any
+// response counts as reachable, since the domain root of most APIs is not
+// a valid endpoint. The check is Optional: an unreachable partner usually
+// affects every replica alike, and failing readiness on all of them would
+// turn a partial outage into a full one.
+func RemoteAPI(name string, api func() *libCallApi.RemoteAPI) Check {
+	return Check{
+		Name:     "api:" + name,
+		Optional: true,
+		Func: func(ctx context.Context) error {
+			remote := api()
+			if remote == nil || remote.Domain == "" {
+				return errors.New("remote api not configured")
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, remote.Domain, nil)
+			if err != nil {
+				return err
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			return resp.Body.Close()
+		},
+	}
+}
+
+// ParamChecks returns a Database check for each of dbNames and a RemoteAPI
+// check for each of apiNames, reading the connections from params when
+// they run.
+func ParamChecks(params libParams.ParamInterface, dbNames, apiNames []string) []Check {
+	checks := make([]Check, 0, len(dbNames)+len(apiNames))
+	for _, name := range dbNames {
+		checks = append(checks, Database(name, func() *sql.DB {
+			if db := params.GetDB(name); db != nil {
+				return db.Db
+			}
+			return nil
+		}))
+	}
+	for _, name := range apiNames {
+		checks = append(checks, RemoteAPI(name, func() *libCallApi.RemoteAPI {
+			return params.GetRemoteAPI(name)
+		}))
+	}
+	return checks
+}
+
+// Saturation fails when usage returns a fill ratio of at least threshold,
+// for example a worker queue that is about to reject jobs. A threshold of
+// 0 uses DefaultSaturationThreshold; a capacity of 0 always passes.
+func Saturation(name string, threshold float64, usage func() (used, capacity int)) Check {
+	if threshold <= 0 {
+		threshold = DefaultSaturationThreshold
+	}
+	return Check{
+		Name:     name,
+		CacheTTL: -1,
+		Func: func(context.Context) error {
+			used, capacity := usage()
+			if capacity <= 0 {
+				return nil
+			}
+			if ratio := float64(used) / float64(capacity); ratio >= threshold {
+				return fmt.Errorf("saturated: %d of %d in use", used, capacity)
+			}
+			return nil
+		},
+	}
+}
+
+// TracingExporter fails when the tracing exporter was shut down or its
+// last export failed. Tracing only affects observability, so the check is
+// Optional.
+func TracingExporter(tm *libTracing.TracingManager) Check {
+	return Check{
+		Name:     "tracing",
+		Optional: true,
+		CacheTTL: -1,
+		Func: func(context.Context) error {
+			if tm == nil {
+				return nil
+			}
+			state := tm.ExporterState()
+			switch {
+			case !state.Enabled:
+				return nil
+			case state.ShutDown:
+				return fmt.Errorf("%s exporter shut down", state.Exporter)
+			case state.LastError != nil:
+				return fmt.Errorf("%s exporter: %w", state.Exporter, state.LastError)
+			}
+			return nil
+		},
+	}
+}
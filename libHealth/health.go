@@ -0,0 +1,299 @@
+// Package libHealth runs dependency checks for liveness, readiness and
+// detailed health endpoints.
+//
+// A Checker holds named checks. Readiness fails when a required check
+// fails or while the application drains connections during shutdown, so
+// load balancers stop routing to the instance before it closes them.
+// Results are cached per check to keep frequent probes cheap.
+package libHealth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status is the outcome of a check or of a whole report.
+type Status string
+
+const (
+	// StatusPass means the check succeeded.
+	StatusPass Status = "pass"
+	// StatusWarn means an optional check failed.
+	StatusWarn Status = "warn"
+	// StatusFail means a required check failed.
+	StatusFail Status = "fail"
+)
+
+const (
+	// DefaultTimeout bounds a check without its own Timeout.
+	DefaultTimeout = 2 * time.Second
+
+	// DefaultCacheTTL is how long a check result is reused when the
+	// check does not set CacheTTL.
+	DefaultCacheTTL = time.Second
+)
+
+// Check is a named dependency check.
+type Check struct {
+	// Name identifies the check in reports, for example "db:main".
+	Name string
+
+	// Func runs the check. A nil error means the dependency is healthy.
+	Func func(ctx context.Context) error
+
+	// Timeout bounds Func. Defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	// CacheTTL is how long a result is reused before Func runs again.
+	// Defaults to DefaultCacheTTL; a negative value disables caching.
+	CacheTTL time.Duration
+
+	// Liveness includes the check in liveness reports. Only checks whose
+	// failure requires a restart belong there; most dependencies should
+	// only affect readiness.
+	Liveness bool
+
+	// Optional reports a failure as StatusWarn without failing readiness.
+	Optional bool
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Status    Status        `json:"status"`
+	Latency   time.Duration `json:"-"`
+	LatencyMs float64       `json:"latencyMs"`
+	Error     string        `json:"error,omitempty"`
+	CheckedAt time.Time     `json:"checkedAt"`
+	Cached    bool          `json:"cached,omitempty"`
+}
+
+// Report is the outcome of a set of checks.
+type Report struct {
+	Status   Status            `json:"status"`
+	Draining bool              `json:"draining,omitempty"`
+	Checks   map[string]Result `json:"checks,omitempty"`
+}
+
+// HTTPStatus returns 200 unless the report failed, in which case it
+// returns 503.
+func (r Report) HTTPStatus() int {
+	if r.Status == StatusFail {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// Text summarizes the report for probe endpoints: "ok", "draining", or
+// the names of the failing checks.
+func (r Report) Text() string {
+	if r.Draining {
+		return "draining\n"
+	}
+	if r.Status != StatusFail {
+		return "ok\n"
+	}
+	var failing []string
+	for name, result := range r.Checks {
+		if result.Status == StatusFail {
+			failing = append(failing, name)
+		}
+	}
+	sort.Strings(failing)
+	return "failing: " + strings.Join(failing, ", ") + "\n"
+}
+
+// JSON encodes the report.
+func (r Report) JSON() ([]byte, error) {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("libHealth: encode report: %w", err)
+	}
+	return body, nil
+}
+
+// Checker runs registered checks. It is safe for concurrent use.
+type Checker struct {
+	mu       sync.RWMutex
+	checks   []*entry
+	draining atomic.Bool
+}
+
+type entry struct {
+	check Check
+
+	mu     sync.Mutex
+	result Result
+	valid  bool
+}
+
+// New creates an empty Checker.
+func New() *Checker {
+	return &Checker{}
+}
+
+// Register adds checks. Names must be unique and every check needs a Func.
+func (c *Checker) Register(checks ...Check) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, check := range checks {
+		if check.Name == "" || check.Func == nil {
+			return errors.New("libHealth: check needs a name and a function")
+		}
+		for _, e := range c.checks {
+			if e.check.Name == check.Name {
+				return fmt.Errorf("libHealth: check %q already registered", check.Name)
+			}
+		}
+		if check.Timeout <= 0 {
+			check.Timeout = DefaultTimeout
+		}
+		if check.CacheTTL == 0 {
+			check.CacheTTL = DefaultCacheTTL
+		}
+		c.checks = append(c.checks, &entry{check: check})
+	}
+	return nil
+}
+
+// SetDraining marks the application as draining. While draining,
+// readiness fails regardless of the checks.
+func (c *Checker) SetDraining(draining bool) {
+	c.draining.Store(draining)
+}
+
+// Draining reports whether SetDraining(true) is in effect.
+func (c *Checker) Draining() bool {
+	return c.draining.Load()
+}
+
+// Live runs the liveness checks. Liveness is unaffected by draining.
+func (c *Checker) Live(ctx context.Context) Report {
+	return c.run(ctx, func(check Check) bool { return check.Liveness }, false)
+}
+
+// Ready runs every check and fails while draining. A draining instance
+// skips the checks, since its state no longer matters to the caller.
+func (c *Checker) Ready(ctx context.Context) Report {
+	if c.Draining() {
+		return Report{Status: StatusFail, Draining: true}
+	}
+	return c.run(ctx, func(Check) bool { return true }, false)
+}
+
+// Health runs every check and reports each result.
+func (c *Checker) Health(ctx context.Context) Report {
+	report := c.run(ctx, func(Check) bool { return true }, true)
+	if c.Draining() {
+		report.Status = StatusFail
+		report.Draining = true
+	}
+	return report
+}
+
+// run executes the selected checks concurrently. Per-check results are
+// only kept in the report when detailed is set or a check did not pass.
+func (c *Checker) run(ctx context.Context, selected func(Check) bool, detailed bool) Report {
+	c.mu.RLock()
+	var entries []*entry
+	for _, e := range c.checks {
+		if selected(e.check) {
+			entries = append(entries, e)
+		}
+	}
+	c.mu.RUnlock()
+
+	results := make([]Result, len(entries))
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = e.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusPass}
+	for i, e := range entries {
+		result := results[i]
+		switch result.Status {
+		case StatusFail:
+			report.Status = StatusFail
+		case StatusWarn:
+			if report.Status == StatusPass {
+				report.Status = StatusWarn
+			}
+		}
+		if detailed || result.Status != StatusPass {
+			if report.Checks == nil {
+				report.Checks = make(map[string]Result)
+			}
+			report.Checks[e.check.Name] = result
+		}
+	}
+	return report
+}
+
+// run returns the cached result or runs the check. Concurrent callers
+// wait for a single run instead of each probing the dependency. The
+// check is bounded by its Timeout only, not by the cancellation of the
+// probe that triggered it, since its result is shared with other probes.
+func (e *entry) run(ctx context.Context) Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.valid && e.check.CacheTTL > 0 && time.Since(e.result.CheckedAt) < e.check.CacheTTL {
+		cached := e.result
+		cached.Cached = true
+		return cached
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), e.check.Timeout)
+	defer cancel()
+	start := time.Now()
+	err := runCheck(ctx, e.check.Func)
+	latency := time.Since(start)
+
+	result := Result{
+		Status:    StatusPass,
+		Latency:   latency,
+		LatencyMs: float64(latency.Microseconds()) / 1000,
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		if e.check.Optional {
+			result.Status = StatusWarn
+		}
+		result.Error = err.Error()
+	}
+	e.result, e.valid = result, true
+	return result
+}
+
+// runCheck runs fn and returns when it finishes or ctx expires, whichever
+// comes first, so a check ignoring its context cannot stall a probe.
+func runCheck(ctx context.Context, fn func(context.Context) error) (err error) {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("check panicked: %v", r)
+			}
+		}()
+		done <- fn(ctx)
+	}()
+	select {
+	case err = <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("check timed out: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,140 @@
+package libHealth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/hmmftg/requestCore/libCallApi"
+	"github.com/hmmftg/requestCore/libTracing"
+)
+
+func TestChecker_Reports(t *testing.T) {
+	c := New()
+	var calls atomic.Int32
+	dbErr := errors.New("connection refused")
+	if err := c.Register(
+		Check{Name: "db:main", Func: func(context.Context) error { calls.Add(1); return dbErr }, CacheTTL: time.Hour},
+		Check{Name: "api:partner", Func: func(context.Context) error { return errors.New("unreachable") }, Optional: true},
+		Check{Name: "deadlock", Func: func(context.Context) error { return nil }, Liveness: true},
+	); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := c.Register(Check{Name: "db:main", Func: func(context.Context) error { return nil }}); err == nil {
+		t.Fatal("expected duplicate name error")
+	}
+
+	ctx := context.Background()
+	if live := c.Live(ctx); live.Status != StatusPass || live.HTTPStatus() != http.StatusOK || live.Text() != "ok\n" {
+		t.Fatalf("unexpected liveness: %+v", live)
+	}
+
+	ready := c.Ready(ctx)
+	if ready.HTTPStatus() != http.StatusServiceUnavailable || ready.Text() != "failing: db:main\n" {
+		t.Fatalf("unexpected readiness: %+v", ready)
+	}
+	if _, ok := ready.Checks["deadlock"]; ok {
+		t.Fatal("readiness should only list checks that did not pass")
+	}
+
+	health := c.Health(ctx)
+	if len(health.Checks) != 3 || health.Checks["api:partner"].Status != StatusWarn {
+		t.Fatalf("unexpected health report: %+v", health)
+	}
+	db := health.Checks["db:main"]
+	if db.Status != StatusFail || db.Error != "connection refused" || !db.Cached || calls.Load() != 1 {
+		t.Fatalf("expected cached db failure, got %+v after %d calls", db, calls.Load())
+	}
+
+	c.SetDraining(true)
+	if ready := c.Ready(ctx); !ready.Draining || ready.HTTPStatus() != http.StatusServiceUnavailable || ready.Text() != "draining\n" {
+		t.Fatalf("expected draining readiness, got %+v", ready)
+	}
+	if live := c.Live(ctx); live.Status != StatusPass {
+		t.Fatal("draining must not fail liveness")
+	}
+}
+
+func TestChecker_Timeout(t *testing.T) {
+	c := New()
+	block := make(chan struct{})
+	defer close(block)
+	_ = c.Register(Check{
+		Name:    "slow",
+		Timeout: 20 * time.Millisecond,
+		Func:    func(context.Context) error { <-block; return nil },
+	})
+	report := c.Health(context.Background())
+	if r := report.Checks["slow"]; r.Status != StatusFail || r.Latency < 20*time.Millisecond {
+		t.Fatalf("expected timeout failure, got %+v", r)
+	}
+}
+
+func TestChecker_CancelledProbe(t *testing.T) {
+	c := New()
+	_ = c.Register(Check{
+		Name:     "db:main",
+		CacheTTL: time.Hour,
+		Func:     func(ctx context.Context) error { return ctx.Err() },
+	})
+	// A probe whose caller hung up must not fail, and cache a failure
+	// for, the probes that follow.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if ready := c.Ready(ctx); ready.Status != StatusPass {
+		t.Fatalf("expected the check to ignore the probe's cancellation, got %+v", ready)
+	}
+	if ready := c.Ready(context.Background()); ready.Status != StatusPass {
+		t.Fatalf("expected a cached pass, got %+v", ready)
+	}
+}
+
+func TestBuiltinChecks(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectPing()
+	mock.ExpectPing().WillReturnError(errors.New("down"))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	used := 95
+	tm, err := libTracing.NewTracingManager(&libTracing.TracingConfig{Enabled: false})
+	if err != nil {
+		t.Fatalf("NewTracingManager: %v", err)
+	}
+	ctx := context.Background()
+	checks := []struct {
+		check Check
+		want  bool
+	}{
+		{Database("main", func() *sql.DB { return db }), true},
+		{Database("main", func() *sql.DB { return db }), false},
+		{Database("missing", func() *sql.DB { return nil }), false},
+		{RemoteAPI("partner", func() *libCallApi.RemoteAPI { return &libCallApi.RemoteAPI{Domain: server.URL} }), true},
+		{RemoteAPI("partner", func() *libCallApi.RemoteAPI { return &libCallApi.RemoteAPI{} }), false},
+		{Saturation("workers", 0, func() (int, int) { return used, 100 }), false},
+		{Saturation("workers", 0.99, func() (int, int) { return used, 100 }), true},
+		{TracingExporter(tm), true},
+	}
+	for i, tt := range checks {
+		if err := tt.check.Func(ctx); (err == nil) != tt.want {
+			t.Errorf("check %d (%s): got %v, want pass=%v", i, tt.check.Name, err, tt.want)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"time"
 
@@ -44,16 +45,15 @@ func (c NetHTTPParser) GetPath() string {
 	return c.Request.URL.Path
 }
 
-// GetHeader populates the target struct with header values from the request.
+// GetHeader populates the target from the request headers. The User-Id,
+// Program, Module and Method headers are applied through the target's
+// setters, as before; when the target is a pointer to a struct, its
+// string fields are then also bound from the headers named by their
+// `header` tags, as the Gin and Fiber parsers do.
 func (c NetHTTPParser) GetHeader(target webFramework.HeaderInterface) error {
-	// Parse headers into target struct
-	// This is a simplified implementation - you might want to use a more sophisticated header parsing
-	// For now, we'll manually map common headers
 	if target == nil {
 		return nil
 	}
-
-	// Example implementation - you can expand this based on your HeaderInterface
 	if user := c.Request.Header.Get("User-Id"); user != "" {
 		target.SetUser(user)
 	}
@@ -67,6 +67,21 @@ func (c NetHTTPParser) GetHeader(target webFramework.HeaderInterface) error {
 		target.SetMethod(method)
 	}
 
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	v = v.Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := field.Tag.Get("header")
+		if name == "" || name == "-" || field.Type.Kind() != reflect.String || !field.IsExported() {
+			continue
+		}
+		if value := c.Request.Header.Get(name); value != "" {
+			v.Field(i).SetString(value)
+		}
+	}
 	return nil
 }
 
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/hmmftg/requestCore/libRequest"
 )
 
 func TestNetHTTPParser(t *testing.T) {
@@ -38,6 +40,41 @@ func TestNetHTTPParser(t *testing.T) {
 	}
 }
 
+func TestNetHTTPParser_GetHeader(t *testing.T) {
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Request-Id", "req-0000000001")
+	req.Header.Set("Program-Id", "web")
+	req.Header.Set("User-Id", "u-42")
+	req.Header.Set("Branch-Id", "0101")
+	req.Header.Set("Bank-Id", "17")
+	req.Header.Set("Person-Id", "p-1")
+
+	var header libRequest.RequestHeader
+	if err := InitContext(req, httptest.NewRecorder()).GetHeader(&header); err != nil {
+		t.Fatal(err)
+	}
+	want := libRequest.RequestHeader{RequestID: "req-0000000001", Program: "web", User: "u-42", Branch: "0101", Bank: "17", Person: "p-1"}
+	if header != want {
+		t.Errorf("Expected %+v, got %+v", want, header)
+	}
+}
+
+func TestNetHTTPParser_GetHeaderLegacyNames(t *testing.T) {
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Program", "web")
+	req.Header.Set("Module", "cards")
+	req.Header.Set("Method", "issue")
+	req.Header.Set("Module-Id", "loans")
+
+	var header libRequest.RequestHeader
+	if err := InitContext(req, httptest.NewRecorder()).GetHeader(&header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Program != "web" || header.Module != "loans" || header.Method != "issue" {
+		t.Errorf("Expected the old header names with tagged ones taking precedence, got %+v", header)
+	}
+}
+
 func TestJSONResponse(t *testing.T) {
 	req := httptest.NewRequest("GET", "/test", nil)
 	w := httptest.NewRecorder()
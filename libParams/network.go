@@ -1,5 +1,7 @@
 package libParams
 
+import "time"
+
 // NetworkParams holds network-related configuration parameters.
 type NetworkParams struct {
 	Port       string `yaml:"port"`
@@ -9,6 +11,9 @@ type NetworkParams struct {
 	TLSPort string `yaml:"tlsPort"`
 	TLSKey  string `yaml:"tlsKey"`
 	TLSCert string `yaml:"tlsCert"`
+	///////////////////// Shutdown /////////////////////////////////////
+	DrainDelay      time.Duration `yaml:"drainDelay"`      // Time readiness fails before the server stops
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"` // Bound of the whole shutdown sequence
 }
 
 // GetNetwork returns the network parameters for the given name.
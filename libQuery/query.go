@@ -1,10 +1,9 @@
 package libQuery
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 	"net/http"
-	"slices"
 
 	"github.com/hmmftg/requestCore/libError"
 	"github.com/hmmftg/requestCore/status"
@@ -39,88 +38,19 @@ func GetQuery[R any](query string, core QueryRunnerInterface, args ...any) ([]R,
 
 // QueryToStruct executes a SQL query and scans the results into a slice of the target type.
 func QueryToStruct[Target any](q QueryRunnerInterface, querySQL string, args ...any) ([]Target, error) {
-	stmt, err := q.NewStatement(querySQL)
+	rows, err := QueryRows[Target](context.Background(), q, querySQL, args...)
 	if err != nil {
-		return nil, errors.Join(err,
-			libError.NewWithDescription(
-				status.InternalServerError,
-				"UNABLE_TO_INITIALIZE_STATEMENT",
-				"queryRunner[prepare](%s,%v)", querySQL, args,
-			))
-	}
-	defer func() { _ = stmt.Close() }()
-	rows, err := stmt.Query(args...)
-	if err != nil {
-		return nil, errors.Join(err,
-			libError.NewWithDescription(
-				status.InternalServerError,
-				"UNABLE_TO_QUERY_STATEMENT",
-				"queryRunner[query](%s,%v)", querySQL, args,
-			))
+		return nil, err
 	}
 	defer func() { _ = rows.Close() }()
 
-	columnTypes, err := rows.ColumnTypes()
-
-	if err != nil {
-		return nil, errors.Join(err,
-			libError.NewWithDescription(
-				status.InternalServerError,
-				"UNABLE_TO_GET_COLUMN_TYPES",
-				"queryRunner[ColumnTypes](%s,%v)", querySQL, args,
-			))
-	}
-
-	count := len(columnTypes)
-
-	baseArgs := make([]any, count)
-
-	for i := range columnTypes {
-		baseArgs[i] = new(sql.Null[any])
-	}
-
 	finalRows := make([]Target, 0)
-
 	for rows.Next() {
-		scanArgs := slices.Clone(baseArgs)
-		err := rows.Scan(scanArgs...)
-
-		if err != nil {
-			return nil, errors.Join(err,
-				libError.NewWithDescription(
-					status.InternalServerError,
-					"UNABLE_TO_GET_SCAN_ROW",
-					"queryRunner[Scan](%s,%v)", querySQL, scanArgs,
-				))
-		}
-
-		masterData := map[string]any{}
-
-		for i, v := range columnTypes {
-			masterData[v.Name()] = scanArgs[i].(*sql.Null[any]).V
-		}
-
-		parsed, err := ParseMap[Target](masterData)
-		if parsed == nil {
-			return nil, errors.Join(err,
-				libError.NewWithDescription(
-					status.InternalServerError,
-					"UNABLE_TO_GET_SCAN_ROW",
-					"queryRunner[parse](%s,%v)", querySQL, masterData,
-				))
-		}
-		finalRows = append(finalRows, *parsed)
+		finalRows = append(finalRows, rows.Row())
 	}
-
 	if err := rows.Err(); err != nil {
-		return nil, errors.Join(err,
-			libError.NewWithDescription(
-				status.InternalServerError,
-				"UNABLE_TO_QUERY_STATEMENT",
-				"queryRunner[rows.Err](%s,%v)", querySQL, args,
-			))
+		return nil, err
 	}
-	//resp, _ := json.Marshal(finalRows)
 	return finalRows, nil
 }
 
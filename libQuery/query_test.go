@@ -1,6 +1,7 @@
 package libQuery_test
 
 import (
+	"context"
 	"database/sql/driver"
 	"testing"
 	"time"
@@ -227,3 +228,37 @@ func TestQueryToStruct(t *testing.T) {
 		}
 	}
 }
+
+func TestQueryRows(t *testing.T) {
+	db, mockDb, _ := sqlmock.New(
+		sqlmock.ValueConverterOption(testingtools.CustomMockConverter{}))
+	mockDb.ExpectPrepare("query").ExpectQuery().WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name"}).
+			AddRow(1, "first").
+			AddRow(2, "second").
+			AddRow(3, "third").
+			RowError(2, driver.ErrBadConn))
+	mockDb.ExpectClose()
+	q := libQuery.QueryRunnerModel{DB: db}
+
+	rows, err := libQuery.QueryRows[SimpleTestOutput](context.Background(), q, "query")
+	assert.NilError(t, err)
+
+	var got []SimpleTestOutput
+	for rows.Next() {
+		got = append(got, rows.Row())
+	}
+	assert.DeepEqual(t, got, []SimpleTestOutput{{ID: 1, Name: "first"}, {ID: 2, Name: "second"}})
+	assert.ErrorContains(t, rows.Err(), "UNABLE_TO_QUERY_STATEMENT")
+	assert.NilError(t, rows.Close())
+	assert.NilError(t, rows.Close())
+}
+
+func TestQueryRows_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	q := getQueryMock(nil, []string{"id"}, 1)
+
+	_, err := libQuery.QueryRows[SimpleTestOutput](ctx, q, "query")
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,143 @@
+package libQuery
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/hmmftg/requestCore/libError"
+	"github.com/hmmftg/requestCore/status"
+)
+
+// RowIterator reads a query's rows one at a time, so large results can be
+// streamed instead of loaded into memory like QueryToStruct does. Use it
+// like sql.Rows:
+//
+//	rows, err := libQuery.QueryRows[Tx](ctx, db, sql, args...)
+//	if err != nil {
+//		return err
+//	}
+//	defer rows.Close()
+//	for rows.Next() {
+//		tx := rows.Row()
+//		...
+//	}
+//	return rows.Err()
+type RowIterator[Target any] struct {
+	stmt     *sql.Stmt
+	rows     *sql.Rows
+	columns  []*sql.ColumnType
+	scanArgs []any
+	querySQL string
+	args     []any
+	row      Target
+	err      error
+}
+
+// QueryRows executes a SQL query and returns an iterator over its rows
+// parsed into Target. Cancelling ctx aborts the query and ends the
+// iteration with ctx's error. The iterator must be closed.
+func QueryRows[Target any](ctx context.Context, q QueryRunnerInterface, querySQL string, args ...any) (*RowIterator[Target], error) {
+	stmt, err := q.NewStatement(querySQL)
+	if err != nil {
+		return nil, errors.Join(err,
+			libError.NewWithDescription(
+				status.InternalServerError,
+				"UNABLE_TO_INITIALIZE_STATEMENT",
+				"queryRunner[prepare](%s,%v)", querySQL, args,
+			))
+	}
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		_ = stmt.Close()
+		return nil, errors.Join(err,
+			libError.NewWithDescription(
+				status.InternalServerError,
+				"UNABLE_TO_QUERY_STATEMENT",
+				"queryRunner[query](%s,%v)", querySQL, args,
+			))
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		_ = rows.Close()
+		_ = stmt.Close()
+		return nil, errors.Join(err,
+			libError.NewWithDescription(
+				status.InternalServerError,
+				"UNABLE_TO_GET_COLUMN_TYPES",
+				"queryRunner[ColumnTypes](%s,%v)", querySQL, args,
+			))
+	}
+	scanArgs := make([]any, len(columnTypes))
+	for i := range columnTypes {
+		scanArgs[i] = new(sql.Null[any])
+	}
+	return &RowIterator[Target]{
+		stmt:     stmt,
+		rows:     rows,
+		columns:  columnTypes,
+		scanArgs: scanArgs,
+		querySQL: querySQL,
+		args:     args,
+	}, nil
+}
+
+// Next advances to the next row, returning false when the rows are
+// exhausted or reading or parsing a row failed; Err tells them apart.
+func (it *RowIterator[Target]) Next() bool {
+	if it.err != nil || !it.rows.Next() {
+		return false
+	}
+	if err := it.rows.Scan(it.scanArgs...); err != nil {
+		it.err = errors.Join(err,
+			libError.NewWithDescription(
+				status.InternalServerError,
+				"UNABLE_TO_GET_SCAN_ROW",
+				"queryRunner[Scan](%s,%v)", it.querySQL, it.scanArgs,
+			))
+		return false
+	}
+	masterData := make(map[string]any, len(it.columns))
+	for i, v := range it.columns {
+		masterData[v.Name()] = it.scanArgs[i].(*sql.Null[any]).V
+	}
+	parsed, err := ParseMap[Target](masterData)
+	if parsed == nil {
+		it.err = errors.Join(err,
+			libError.NewWithDescription(
+				status.InternalServerError,
+				"UNABLE_TO_GET_SCAN_ROW",
+				"queryRunner[parse](%s,%v)", it.querySQL, masterData,
+			))
+		return false
+	}
+	it.row = *parsed
+	return true
+}
+
+// Row returns the row read by the last call to Next.
+func (it *RowIterator[Target]) Row() Target {
+	return it.row
+}
+
+// Err returns the error that ended the iteration, if any.
+func (it *RowIterator[Target]) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	if err := it.rows.Err(); err != nil {
+		return errors.Join(err,
+			libError.NewWithDescription(
+				status.InternalServerError,
+				"UNABLE_TO_QUERY_STATEMENT",
+				"queryRunner[rows.Err](%s,%v)", it.querySQL, it.args,
+			))
+	}
+	return nil
+}
+
+// Close releases the rows and the prepared statement. It is safe to call
+// more than once.
+func (it *RowIterator[Target]) Close() error {
+	return errors.Join(it.rows.Close(), it.stmt.Close())
+}
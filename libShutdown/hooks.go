@@ -0,0 +1,95 @@
+package libShutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hmmftg/requestCore/libHealth"
+	"github.com/hmmftg/requestCore/libParams"
+	"github.com/hmmftg/requestCore/libTracing"
+)
+
+// Readiness returns a PhaseReadiness hook that fails checker's readiness
+// and keeps serving for delay, or until ctx expires, so load balancers
+// observe the failing probe before connections close.
+func Readiness(checker *libHealth.Checker, delay time.Duration) Hook {
+	return func(ctx context.Context) error {
+		if checker != nil {
+			checker.SetDraining(true)
+		}
+		if delay <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		return nil
+	}
+}
+
+// HTTPServer returns a PhaseHTTP hook that stops server from accepting
+// connections and waits for in-flight requests. http.ErrServerClosed is
+// not an error: the server was already shut down.
+func HTTPServer(server *http.Server) Hook {
+	return func(ctx context.Context) error {
+		if err := server.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
+
+// Tracing returns a PhaseFlush hook that exports pending spans and shuts
+// down the global tracing manager.
+func Tracing() Hook {
+	return libTracing.ShutdownTracing
+}
+
+// Writer returns a PhaseFlush hook that flushes w when it buffers output,
+// that is when it implements Flush() error or Sync() error (bufio.Writer,
+// os.File, zap-style loggers). Other writers are left alone; the Splunk
+// logger, for example, sends every event synchronously.
+func Writer(w io.Writer) Hook {
+	return func(context.Context) error {
+		switch f := w.(type) {
+		case interface{ Flush() error }:
+			return f.Flush()
+		case interface{ Sync() error }:
+			return f.Sync()
+		}
+		return nil
+	}
+}
+
+// Databases returns a PhaseClose hook that closes the connection pools of
+// the named databases in params. Names without an open connection are
+// skipped.
+func Databases(params libParams.ParamInterface, names []string) Hook {
+	return func(context.Context) error {
+		var errs []error
+		for _, name := range names {
+			db := params.GetDB(name)
+			if db == nil {
+				continue
+			}
+			conn := db.Db
+			if conn == nil && db.Orm != nil {
+				conn, _ = db.Orm.DB()
+			}
+			if conn == nil {
+				continue
+			}
+			if err := conn.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("db %s: %w", name, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
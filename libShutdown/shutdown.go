@@ -0,0 +1,211 @@
+// Package libShutdown runs application shutdown in ordered phases.
+//
+// A Manager holds hooks grouped by Phase. Shutdown runs the phases in
+// order: readiness is failed first so load balancers stop routing, then
+// the HTTP server drains in-flight requests, background workers drain,
+// logs and spans are flushed, and finally databases and other resources
+// are closed. Hooks within a phase run concurrently; each phase can be
+// bounded by its own timeout on top of the caller's context.
+package libShutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Phase is one step of the shutdown sequence.
+type Phase int
+
+const (
+	// PhaseReadiness fails readiness probes and waits for load
+	// balancers to stop routing new traffic.
+	PhaseReadiness Phase = iota
+	// PhaseHTTP stops accepting connections and drains in-flight
+	// requests.
+	PhaseHTTP
+	// PhaseWorkers drains background job workers and schedulers.
+	PhaseWorkers
+	// PhaseFlush flushes buffered logs and exports pending spans.
+	PhaseFlush
+	// PhaseClose closes databases and other shared resources.
+	PhaseClose
+)
+
+// Phases lists every phase in execution order.
+var Phases = []Phase{PhaseReadiness, PhaseHTTP, PhaseWorkers, PhaseFlush, PhaseClose}
+
+var phaseNames = map[Phase]string{
+	PhaseReadiness: "readiness",
+	PhaseHTTP:      "http",
+	PhaseWorkers:   "workers",
+	PhaseFlush:     "flush",
+	PhaseClose:     "close",
+}
+
+// String returns the phase name used in errors and logs.
+func (p Phase) String() string {
+	if name, ok := phaseNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("phase(%d)", int(p))
+}
+
+// Hook runs during a phase. ctx expires when the phase timeout or the
+// overall shutdown deadline passes.
+type Hook func(ctx context.Context) error
+
+// Timeouts bounds individual phases. A phase without an entry is only
+// bounded by the context passed to Shutdown.
+type Timeouts map[Phase]time.Duration
+
+// Manager runs registered hooks phase by phase. It is safe for concurrent
+// use; Shutdown runs the hooks once.
+type Manager struct {
+	mu       sync.Mutex
+	hooks    map[Phase][]namedHook
+	timeouts Timeouts
+
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+type namedHook struct {
+	name string
+	fn   Hook
+}
+
+// New creates a Manager with the given phase timeouts.
+func New(timeouts Timeouts) *Manager {
+	m := &Manager{
+		hooks:    make(map[Phase][]namedHook),
+		timeouts: make(Timeouts, len(timeouts)),
+		done:     make(chan struct{}),
+	}
+	for phase, d := range timeouts {
+		m.timeouts[phase] = d
+	}
+	return m
+}
+
+// Add registers hook under name for phase. Hooks added after Shutdown
+// started are ignored.
+func (m *Manager) Add(phase Phase, name string, hook Hook) {
+	if hook == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks[phase] = append(m.hooks[phase], namedHook{name: name, fn: hook})
+}
+
+// SetTimeout bounds phase by d. A zero d removes the bound.
+func (m *Manager) SetTimeout(phase Phase, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if d <= 0 {
+		delete(m.timeouts, phase)
+		return
+	}
+	m.timeouts[phase] = d
+}
+
+// Shutdown runs every phase in order and returns the joined hook errors.
+// A failing or timed-out phase does not stop later phases, so resources
+// are still closed when draining overruns its budget.
+//
+// Once ctx expires, each remaining hook is started but not waited for.
+// Only the first call runs the hooks; later and concurrent calls wait for
+// it (or for their own ctx) and return its result.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	first := false
+	m.once.Do(func() { first = true })
+	if first {
+		m.err = m.run(ctx)
+		close(m.done)
+		return m.err
+	}
+	select {
+	case <-m.done:
+		return m.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Done is closed once Shutdown has run every phase.
+func (m *Manager) Done() <-chan struct{} {
+	return m.done
+}
+
+func (m *Manager) run(ctx context.Context) error {
+	m.mu.Lock()
+	hooks := make(map[Phase][]namedHook, len(m.hooks))
+	for phase, list := range m.hooks {
+		hooks[phase] = list
+	}
+	m.hooks = make(map[Phase][]namedHook)
+	timeouts := make(Timeouts, len(m.timeouts))
+	for phase, d := range m.timeouts {
+		timeouts[phase] = d
+	}
+	m.mu.Unlock()
+
+	var errs []error
+	for _, phase := range Phases {
+		if len(hooks[phase]) == 0 {
+			continue
+		}
+		if err := runPhase(ctx, phase, timeouts[phase], hooks[phase]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runPhase runs the hooks of one phase concurrently and waits for all of
+// them or for the phase deadline.
+func runPhase(ctx context.Context, phase Phase, timeout time.Duration, hooks []namedHook) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	errs := make([]error, len(hooks))
+	var wg sync.WaitGroup
+	for i, hook := range hooks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := runHook(ctx, hook.fn); err != nil {
+				errs[i] = fmt.Errorf("shutdown %s: %s: %w", phase, hook.name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// runHook returns when fn finishes or ctx expires, whichever comes first,
+// so a hook ignoring its context cannot hold up later phases.
+func runHook(ctx context.Context, fn Hook) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("hook panicked: %v", r)
+			}
+		}()
+		done <- fn(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
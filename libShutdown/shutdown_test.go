@@ -0,0 +1,103 @@
+package libShutdown
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/hmmftg/requestCore/libHealth"
+	"github.com/hmmftg/requestCore/libParams"
+)
+
+func TestManager_Order(t *testing.T) {
+	m := New(Timeouts{PhaseWorkers: 20 * time.Millisecond})
+	var mu sync.Mutex
+	var order []string
+	record := func(name string, err error) Hook {
+		return func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			return err
+		}
+	}
+	m.Add(PhaseClose, "db", record("db", nil))
+	m.Add(PhaseFlush, "tracing", record("tracing", errors.New("exporter down")))
+	m.Add(PhaseWorkers, "stuck", func(ctx context.Context) error { select {} })
+	m.Add(PhaseHTTP, "server", record("server", nil))
+	m.Add(PhaseReadiness, "health", record("health", nil))
+
+	err := m.Shutdown(context.Background())
+	if got := strings.Join(order, ","); got != "health,server,tracing,db" {
+		t.Fatalf("unexpected order %q", got)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) ||
+		!strings.Contains(err.Error(), "shutdown workers: stuck") ||
+		!strings.Contains(err.Error(), "shutdown flush: tracing: exporter down") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m.Add(PhaseClose, "late", record("late", nil))
+	if again := m.Shutdown(context.Background()); again != err {
+		t.Fatalf("second Shutdown returned %v, want %v", again, err)
+	}
+	if len(order) != 4 {
+		t.Fatalf("hooks ran twice: %v", order)
+	}
+	select {
+	case <-m.Done():
+	default:
+		t.Fatal("Done not closed")
+	}
+}
+
+func TestManager_Panic(t *testing.T) {
+	m := New(nil)
+	m.Add(PhaseFlush, "logger", func(context.Context) error { panic("boom") })
+	if err := m.Shutdown(context.Background()); err == nil || !strings.Contains(err.Error(), "panicked: boom") {
+		t.Fatalf("expected panic error, got %v", err)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	checker := libHealth.New()
+	if err := Readiness(checker, time.Hour)(canceled()); err != nil || !checker.Draining() {
+		t.Fatalf("Readiness: err=%v draining=%v", err, checker.Draining())
+	}
+
+	var out bytes.Buffer
+	w := bufio.NewWriter(&out)
+	_, _ = w.WriteString("pending")
+	if err := Writer(w)(context.Background()); err != nil || out.String() != "pending" {
+		t.Fatalf("Writer: err=%v out=%q", err, out.String())
+	}
+	if err := Writer(&out)(context.Background()); err != nil {
+		t.Fatalf("Writer on unbuffered writer: %v", err)
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	mock.ExpectClose()
+	params := libParams.ApplicationParams[any]{DB: map[string]libParams.DbParams{"main": {Db: db}}}
+	if err := Databases(params, []string{"main", "missing"})(context.Background()); err != nil {
+		t.Fatalf("Databases: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func canceled() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
@@ -0,0 +1,71 @@
+package libTracing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// ExporterState describes the span exporter of a TracingManager for
+// health checks.
+type ExporterState struct {
+	// Exporter is the configured exporter name ("otlp", "zipkin", ...).
+	Exporter string
+
+	// Enabled is false when tracing is disabled and nothing is exported.
+	Enabled bool
+
+	// LastExport is when a batch was last exported successfully.
+	LastExport time.Time
+
+	// LastError is the error of the last failed export, cleared by the
+	// next successful one.
+	LastError error
+
+	// LastErrorAt is when LastError occurred.
+	LastErrorAt time.Time
+
+	// ShutDown reports whether the exporter has been shut down.
+	ShutDown bool
+}
+
+// stateExporter records the outcome of each export for ExporterState.
+type stateExporter struct {
+	sdktrace.SpanExporter
+
+	mu    sync.Mutex
+	state ExporterState
+}
+
+func (e *stateExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil {
+		e.state.LastError = err
+		e.state.LastErrorAt = time.Now()
+	} else {
+		e.state.LastError = nil
+		e.state.LastExport = time.Now()
+	}
+	return err
+}
+
+func (e *stateExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	e.state.ShutDown = true
+	e.mu.Unlock()
+	return e.SpanExporter.Shutdown(ctx)
+}
+
+// ExporterState returns the current state of the span exporter.
+func (tm *TracingManager) ExporterState() ExporterState {
+	if tm.exporter == nil {
+		return ExporterState{Exporter: tm.config.Exporter}
+	}
+	tm.exporter.mu.Lock()
+	defer tm.exporter.mu.Unlock()
+	return tm.exporter.state
+}
@@ -26,6 +26,7 @@ type TracingManager struct {
 	config         *TracingConfig
 	tracer         trace.Tracer
 	tracerProvider *sdktrace.TracerProvider
+	exporter       *stateExporter
 	shutdown       func(context.Context) error
 }
 
@@ -101,9 +102,15 @@ func NewTracingManager(config *TracingConfig) (*TracingManager, error) {
 		return nil, fmt.Errorf("unsupported exporter: %s", config.Exporter)
 	}
 
+	// Track export outcomes for health checks
+	tracked := &stateExporter{
+		SpanExporter: exporter,
+		state:        ExporterState{Exporter: config.Exporter, Enabled: true},
+	}
+
 	// Create tracer provider
 	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
+		sdktrace.WithBatcher(tracked),
 		sdktrace.WithResource(res),
 		sdktrace.WithSampler(sdktrace.TraceIDRatioBased(config.SamplingRatio)),
 	)
@@ -121,6 +128,7 @@ func NewTracingManager(config *TracingConfig) (*TracingManager, error) {
 		config:         config,
 		tracer:         tp.Tracer(config.ServiceName),
 		tracerProvider: tp,
+		exporter:       tracked,
 		shutdown:       tp.Shutdown,
 	}, nil
 }
@@ -22,8 +22,26 @@ const (
 	BadRequest StatusCode = http.StatusBadRequest
 	// DuplicateRequest is the status code for duplicate/too many requests (HTTP 429).
 	DuplicateRequest StatusCode = http.StatusTooManyRequests
+	// Unauthorized is the status code for requests without valid credentials (HTTP 401).
+	Unauthorized StatusCode = http.StatusUnauthorized
+	// Forbidden is the status code for requests the server refuses to authorize (HTTP 403).
+	Forbidden StatusCode = http.StatusForbidden
 	// NotFound is the status code for not found errors (HTTP 404).
 	NotFound StatusCode = http.StatusNotFound
+	// NotAcceptable is the status code for unsatisfiable Accept headers (HTTP 406).
+	NotAcceptable StatusCode = http.StatusNotAcceptable
+	// Conflict is the status code for requests conflicting with the resource state (HTTP 409).
+	Conflict StatusCode = http.StatusConflict
+	// PayloadTooLarge is the status code for oversized request bodies (HTTP 413).
+	PayloadTooLarge StatusCode = http.StatusRequestEntityTooLarge
+	// UnsupportedMediaType is the status code for unsupported request encodings (HTTP 415).
+	UnsupportedMediaType StatusCode = http.StatusUnsupportedMediaType
+	// PreconditionFailed is the status code for failed If-Match/If-Unmodified-Since checks (HTTP 412).
+	PreconditionFailed StatusCode = http.StatusPreconditionFailed
+	// PreconditionRequired is the status code for unconditional writes that require a precondition (HTTP 428).
+	PreconditionRequired StatusCode = http.StatusPreconditionRequired
+	// UpgradeRequired is the status code for plain requests to WebSocket endpoints (HTTP 426).
+	UpgradeRequired StatusCode = http.StatusUpgradeRequired
 )
 
 // String returns a formatted string representation of the status code (e.g. "200-OK").
@@ -8,15 +8,20 @@ package app
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/hmmftg/requestCore"
+	"github.com/hmmftg/requestCore/libHealth"
+	"github.com/hmmftg/requestCore/libShutdown"
 	"github.com/hmmftg/requestCore/response"
 
+	"github.com/hmmftg/requestCore/v2/handlers"
+	"github.com/hmmftg/requestCore/v2/openapi"
 	"github.com/hmmftg/requestCore/v2/renderers"
 	v2response "github.com/hmmftg/requestCore/v2/response"
 	"github.com/hmmftg/requestCore/v2/routing"
@@ -53,9 +58,19 @@ type Config struct {
 	SessionStore session.Store
 
 	// SessionSecret is the secret used for signing session cookies.
-	// Required if SessionStore is not NoOpStore.
+	// Required if SessionStore is a cookie-based store; NoOpStore and
+	// server-side stores (SQLStore, MemoryStore) do not need it.
 	SessionSecret string
 
+	// SessionIdleTimeout expires sessions unused for this long.
+	// Default: 0 (disabled).
+	SessionIdleTimeout time.Duration
+
+	// SessionAbsoluteTimeout expires sessions this long after creation,
+	// regardless of activity.
+	// Default: 0 (disabled).
+	SessionAbsoluteTimeout time.Duration
+
 	// LegacyCore is the v1 RequestCoreInterface for infrastructure access.
 	// May be nil for pure v2 applications.
 	LegacyCore requestCore.RequestCoreInterface
@@ -74,6 +89,44 @@ type Config struct {
 	// MethodNotAllowed is the handler for disallowed methods.
 	// If nil, a default 405 JSON response is used.
 	MethodNotAllowed routing.Handler
+
+	// OpenAPI describes the API in the generated OpenAPI document.
+	// See App.ServeOpenAPI.
+	OpenAPI openapi.Info
+
+	// SwaggerUIAssets is the base URL of the swagger-ui-dist files
+	// (swagger-ui.css and swagger-ui-bundle.js) the Swagger UI page of
+	// ServeOpenAPI loads. Serve a vetted copy yourself, for example with
+	// a static route, on air-gapped deployments or to avoid running
+	// scripts from a CDN.
+	// Default: DefaultSwaggerUIAssets.
+	SwaggerUIAssets string
+
+	// HealthChecks are registered on App.Health in addition to the worker
+	// queue saturation check, for example libHealth.ParamChecks for the
+	// databases and remote APIs of LegacyCore.
+	HealthChecks []libHealth.Check
+
+	// WorkerSaturation is the queue fill ratio at which the worker check
+	// fails readiness.
+	// Default: libHealth.DefaultSaturationThreshold.
+	WorkerSaturation float64
+
+	// DrainDelay is how long Shutdown keeps serving with readiness
+	// failing before it stops the server, giving load balancers time to
+	// observe the failing probe.
+	// Default: 0 (no delay).
+	DrainDelay time.Duration
+
+	// ShutdownTimeouts bounds individual shutdown phases on top of the
+	// context passed to Shutdown, for example so a slow worker drain
+	// cannot consume the time needed to flush spans.
+	// Default: phases are only bounded by the Shutdown context.
+	ShutdownTimeouts libShutdown.Timeouts
+
+	// ShutdownDatabases names the LegacyCore databases whose connection
+	// pools are closed in the last shutdown phase.
+	ShutdownDatabases []string
 }
 
 // App is the v2 application instance. It composes the router,
@@ -87,6 +140,22 @@ type App struct {
 	Sessions    *session.Manager
 	Middlewares []routing.Middleware
 
+	// OpenAPI collects every handlers.Endpoint registered through Router
+	// (including resources) into an OpenAPI 3.1 document.
+	OpenAPI *openapi.Generator
+
+	// Health runs the checks served by ServeHealth.
+	Health *libHealth.Checker
+
+	// shutdown runs the ordered shutdown phases. See Shutdown.
+	shutdown *libShutdown.Manager
+
+	// routes records every route registered through Router.
+	routes *routing.Recorder
+
+	// swaggerUIAssets is the resolved Config.SwaggerUIAssets.
+	swaggerUIAssets string
+
 	// nativeServer holds the underlying framework server (e.g. *http.Server,
 	// *fiber.App). Set by Start to enable graceful shutdown.
 	// Protected by serverMu to prevent data races between Start and Shutdown.
@@ -128,8 +197,17 @@ func Bootstrap(config Config) (*App, error) {
 	// Create worker pool
 	worker := workers.NewInProcessWorker(config.WorkerConfig)
 
+	health, err := newHealthChecker(config, worker)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create session manager
-	sessionMgr := session.NewManager(config.SessionStore)
+	sessionMgr := session.NewManagerWithConfig(session.ManagerConfig{
+		Store:           config.SessionStore,
+		IdleTimeout:     config.SessionIdleTimeout,
+		AbsoluteTimeout: config.SessionAbsoluteTimeout,
+	})
 
 	// Create router based on framework
 	router, err := createRouter(config.Framework)
@@ -148,6 +226,31 @@ func Bootstrap(config Config) (*App, error) {
 		router = &middlewareRouter{router: router, middlewares: config.Middlewares}
 	}
 
+	// Record routes registered at any group depth for introspection and
+	// the OpenAPI document.
+	globalNames := make([]string, len(config.Middlewares))
+	for i, mw := range config.Middlewares {
+		globalNames[i] = routing.MiddlewareName(mw)
+	}
+	recorder := routing.NewRecorder(router, globalNames...)
+	spec := openapi.NewGenerator(config.OpenAPI)
+	spec.SetSource(func() []openapi.Route {
+		var routes []openapi.Route
+		for _, route := range recorder.Routes() {
+			if endpoint, ok := route.Metadata.(*handlers.Endpoint); ok {
+				routes = append(routes, openapi.Route{
+					Method:     route.Method,
+					Pattern:    route.Pattern,
+					Endpoint:   endpoint,
+					Version:    route.Version,
+					Deprecated: route.Deprecated,
+				})
+			}
+		}
+		return routes
+	})
+	router = recorder
+
 	// Set not found / method not allowed handlers. Defaults provide
 	// JSON error responses consistent with the v2 response format.
 	if config.NotFound != nil {
@@ -161,7 +264,7 @@ func Bootstrap(config Config) (*App, error) {
 		router.MethodNotAllowed(defaultMethodNotAllowed(respHandler))
 	}
 
-	return &App{
+	app := &App{
 		Router:           router,
 		RespHandler:      respHandler,
 		Registry:         registry,
@@ -169,8 +272,30 @@ func Bootstrap(config Config) (*App, error) {
 		Worker:           worker,
 		Sessions:         sessionMgr,
 		Middlewares:      config.Middlewares,
+		OpenAPI:          spec,
+		Health:           health,
+		shutdown:         libShutdown.New(config.ShutdownTimeouts),
+		routes:           recorder,
 		serverRegistered: make(chan struct{}),
-	}, nil
+		swaggerUIAssets:  strings.TrimSuffix(config.SwaggerUIAssets, "/"),
+	}
+	if app.swaggerUIAssets == "" {
+		app.swaggerUIAssets = DefaultSwaggerUIAssets
+	}
+	app.registerShutdownHooks(config)
+	return app, nil
+}
+
+// registerShutdownHooks adds the built-in hooks of every shutdown phase.
+func (a *App) registerShutdownHooks(config Config) {
+	a.shutdown.Add(libShutdown.PhaseReadiness, "health", libShutdown.Readiness(a.Health, config.DrainDelay))
+	a.shutdown.Add(libShutdown.PhaseHTTP, "server", a.stopServer)
+	a.shutdown.Add(libShutdown.PhaseWorkers, "workers", a.Worker.Shutdown)
+	a.shutdown.Add(libShutdown.PhaseFlush, "tracing", libShutdown.Tracing())
+	if config.LegacyCore != nil && len(config.ShutdownDatabases) > 0 {
+		a.shutdown.Add(libShutdown.PhaseClose, "databases",
+			libShutdown.Databases(config.LegacyCore.Params(), config.ShutdownDatabases))
+	}
 }
 
 // wireErrorHandler installs the v2 response handler on routers that
@@ -202,18 +327,23 @@ func validateConfig(config Config) error {
 	if config.WorkerConfig.QueueSize < 0 {
 		return fmt.Errorf("app: WorkerConfig.QueueSize must be >= 0, got %d", config.WorkerConfig.QueueSize)
 	}
+	if config.SessionIdleTimeout < 0 || config.SessionAbsoluteTimeout < 0 {
+		return fmt.Errorf("app: session timeouts must be >= 0")
+	}
 
-	// SessionSecret is documented as required when SessionStore is not
-	// NoOpStore. If a non-NoOp store is provided without a secret, the
+	// SessionSecret is documented as required when SessionStore is
+	// cookie-based. If such a store is provided without a secret, the
 	// CookieStore cannot sign tokens. We warn by returning an error since
 	// this is a configuration mistake that would cause runtime failures.
 	if config.SessionSecret != "" && config.SessionStore == nil {
 		// Secret provided but no store — this is fine, NoOpStore will be
 		// used and the secret is ignored. Not an error.
 	}
-	if config.SessionStore != nil {
-		if _, isNoOp := config.SessionStore.(session.NoOpStore); !isNoOp && config.SessionSecret == "" {
-			return fmt.Errorf("app: SessionSecret is required when SessionStore is not NoOpStore")
+	switch config.SessionStore.(type) {
+	case nil, session.NoOpStore, *session.SQLStore, *session.MemoryStore:
+	default:
+		if config.SessionSecret == "" {
+			return fmt.Errorf("app: SessionSecret is required for cookie-based session stores")
 		}
 	}
 
@@ -340,6 +470,14 @@ func (a *App) Register(prefix string, middlewares ...routing.Middleware) routing
 	return group
 }
 
+// RegisterWorkerAdmin registers the worker pool admin endpoints
+// (GET <prefix>/stats and GET <prefix>/failures) under prefix. It is
+// optional; pass authentication middleware, since the endpoints expose
+// job names, error text, and request IDs.
+func (a *App) RegisterWorkerAdmin(prefix string, middlewares ...routing.Middleware) error {
+	return workers.RegisterAdminRoutes(a.Register(prefix, middlewares...), a.Worker)
+}
+
 // Start starts the HTTP server on the given address.
 // For Gin and chi/net/http, this uses http.Server.
 // For Fiber, this uses fiber.App.Listen.
@@ -352,6 +490,9 @@ func (a *App) Start(addr string) error {
 //
 // The error handler registry is frozen before the server starts so
 // that route handlers cannot modify error handlers at runtime.
+//
+// When the RoutesEnv environment variable is set, the route table is
+// printed to stdout and StartWithContext returns without serving.
 func (a *App) StartWithContext(ctx context.Context, addr string) error {
 	// Freeze the registry so no new error handlers can be registered
 	// after startup. This prevents accidental mutation during serving.
@@ -359,6 +500,13 @@ func (a *App) StartWithContext(ctx context.Context, addr string) error {
 		a.Registry.Freeze()
 	}
 
+	// In route listing mode (see RoutesEnv) print the route table
+	// instead of serving.
+	if routeListingMode() {
+		a.serverRegOnce.Do(func() { close(a.serverRegistered) })
+		return a.printRoutes(os.Stdout)
+	}
+
 	native := a.Router.Native()
 
 	switch server := native.(type) {
@@ -401,21 +549,40 @@ func (a *App) StartWithContext(ctx context.Context, addr string) error {
 	}
 }
 
-// Shutdown gracefully shuts down the application: it stops the HTTP
-// server, then shuts down the worker pool. Both operations are bounded
-// by the given context.
+// OnShutdown adds hook to phase of the shutdown sequence, for example
+// stopping a scheduler in PhaseWorkers, flushing a buffered log writer in
+// PhaseFlush (see libShutdown.Writer) or closing a cache client in
+// PhaseClose. Hooks of the same phase run concurrently.
+func (a *App) OnShutdown(phase libShutdown.Phase, name string, hook libShutdown.Hook) {
+	a.shutdown.Add(phase, name, hook)
+}
+
+// Shutdown gracefully shuts down the application in order:
+//
+//  1. readiness fails and the server keeps serving for Config.DrainDelay;
+//  2. the HTTP server stops accepting and drains in-flight requests;
+//  3. the worker pool drains;
+//  4. pending spans are exported, along with OnShutdown flush hooks;
+//  5. Config.ShutdownDatabases and OnShutdown close hooks are closed.
+//
+// Each phase is bounded by ctx and its Config.ShutdownTimeouts entry. A
+// failing phase does not stop later ones; the errors are joined. Only the
+// first call runs the sequence, later calls return its result.
 //
 // Shutdown is safe to call concurrently with StartWithContext. It waits
 // deterministically for StartWithContext to register the server (via a
-// closed channel) before proceeding, eliminating the prior polling race.
-//
-// http.ErrServerClosed is treated as a clean termination (return nil)
-// because it indicates the server was already shut down, typically by
-// a concurrent StartWithContext context cancellation.
+// closed channel) before stopping it.
 func (a *App) Shutdown(ctx context.Context) error {
+	return a.shutdown.Shutdown(ctx)
+}
+
+// stopServer is the PhaseHTTP hook. http.ErrServerClosed is treated as a
+// clean termination because it indicates the server was already shut
+// down, typically by a concurrent StartWithContext context cancellation.
+func (a *App) stopServer(ctx context.Context) error {
 	// Wait deterministically for StartWithContext to register the server.
-	// This replaces the previous polling loop and closes the race window
-	// where Shutdown sees nil nativeServer because Start hasn't stored it yet.
+	// This closes the race window where Shutdown sees nil nativeServer
+	// because Start hasn't stored it yet.
 	if a.serverRegistered != nil {
 		select {
 		case <-a.serverRegistered:
@@ -427,30 +594,14 @@ func (a *App) Shutdown(ctx context.Context) error {
 	server := a.nativeServer
 	a.serverMu.Unlock()
 
-	var httpErr error
-	if server != nil {
-		if httpServer, ok := server.(*http.Server); ok {
-			httpErr = httpServer.Shutdown(ctx)
-			// http.ErrServerClosed is expected when StartWithContext's
-			// context-driven shutdown races with an explicit Shutdown call.
-			if errors.Is(httpErr, http.ErrServerClosed) {
-				httpErr = nil
-			}
-		} else {
-			// Fiber shutdown
-			httpErr = shutdownFiber(server, ctx)
-		}
-	}
-
-	// Shut down the worker pool after the HTTP server stops accepting
-	// new requests, so in-flight jobs can complete within the context.
-	workerErr := a.Worker.Shutdown(ctx)
-
-	// Return the first non-nil error, preferring the HTTP error.
-	if httpErr != nil {
-		return httpErr
+	switch server := server.(type) {
+	case nil:
+		return nil
+	case *http.Server:
+		return libShutdown.HTTPServer(server)(ctx)
+	default:
+		return shutdownFiber(server, ctx)
 	}
-	return workerErr
 }
 
 // Close stops the worker pool and releases resources.
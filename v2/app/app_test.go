@@ -4,11 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/hmmftg/requestCore/libShutdown"
 	"github.com/hmmftg/requestCore/response"
 	"github.com/hmmftg/requestCore/v2/renderers"
 	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
@@ -348,3 +351,98 @@ func TestApp_ShutdownStopsWorker(t *testing.T) {
 		t.Fatalf("expected ErrShutdown from worker after Shutdown, got %v", err)
 	}
 }
+
+// TestApp_ShutdownPhases verifies that OnShutdown hooks run after the
+// server and worker pool stopped, and that phase timeouts bound them.
+func TestApp_ShutdownPhases(t *testing.T) {
+	app, err := Bootstrap(Config{
+		Framework:        FrameworkChi,
+		ShutdownTimeouts: libShutdown.Timeouts{libShutdown.PhaseClose: 20 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("Bootstrap: %v", err)
+	}
+
+	var flushed bool
+	app.OnShutdown(libShutdown.PhaseFlush, "logs", func(ctx context.Context) error {
+		err := app.Worker.Submit(ctx, workers.Job{Name: "late", Handler: func(*workers.JobContext) error { return nil }})
+		if !app.Health.Draining() || !errors.Is(err, workers.ErrShutdown) {
+			return fmt.Errorf("flush ran early: draining=%v submit=%v", app.Health.Draining(), err)
+		}
+		flushed = true
+		return nil
+	})
+	app.OnShutdown(libShutdown.PhaseClose, "cache", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = app.StartWithContext(ctx, ":0")
+	}()
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	err = app.Shutdown(shutdownCtx)
+	if !flushed {
+		t.Fatalf("flush hook failed: %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "shutdown close: cache") {
+		t.Fatalf("expected close phase timeout, got %v", err)
+	}
+	if again := app.Shutdown(shutdownCtx); again != err {
+		t.Fatalf("second Shutdown returned %v, want %v", again, err)
+	}
+}
+
+func TestApp_RegisterWorkerAdmin(t *testing.T) {
+	app, err := Bootstrap(Config{
+		Framework: FrameworkChi,
+	})
+	if err != nil {
+		t.Fatalf("Bootstrap: %v", err)
+	}
+	defer app.Close()
+
+	if err := app.RegisterWorkerAdmin("/admin/workers"); err != nil {
+		t.Fatalf("RegisterWorkerAdmin: %v", err)
+	}
+	if err := app.Worker.Submit(context.Background(), workers.Job{
+		Name:    "admin-failing",
+		Handler: func(*workers.JobContext) error { return errors.New("boom") },
+	}); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	server := httptest.NewServer(app.Router.Native().(http.Handler))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/admin/workers/stats")
+	if err != nil {
+		t.Fatalf("HTTP request: %v", err)
+	}
+	var stats workers.Stats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("decode stats: %v", err)
+	}
+	resp.Body.Close()
+	if stats.Submitted != 1 || stats.Failed != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	resp, err = http.Get(server.URL + "/admin/workers/failures")
+	if err != nil {
+		t.Fatalf("HTTP request: %v", err)
+	}
+	defer resp.Body.Close()
+	var failures []workers.Failure
+	if err := json.NewDecoder(resp.Body).Decode(&failures); err != nil {
+		t.Fatalf("decode failures: %v", err)
+	}
+	if len(failures) != 1 || failures[0].Job != "admin-failing" || failures[0].Error != "boom" {
+		t.Fatalf("unexpected failures: %+v", failures)
+	}
+}
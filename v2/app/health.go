@@ -0,0 +1,80 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hmmftg/requestCore/libHealth"
+
+	"github.com/hmmftg/requestCore/v2/routing"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+	"github.com/hmmftg/requestCore/v2/workers"
+)
+
+// Health probe paths relative to the prefix passed to ServeHealth.
+const (
+	LivenessPath  = "/livez"
+	ReadinessPath = "/readyz"
+	HealthPath    = "/healthz"
+)
+
+// WorkersCheckName names the worker queue saturation check registered by
+// Bootstrap.
+const WorkersCheckName = "workers"
+
+// ServeHealth registers the probe endpoints of App.Health under prefix:
+//
+//	GET <prefix>/livez    liveness checks; "ok" or the failing checks
+//	GET <prefix>/readyz   every required check; fails while draining
+//	GET <prefix>/healthz  JSON report with per-check status and latency
+//
+// Probes answer 200 or 503. Readiness fails as soon as Shutdown starts,
+// so Kubernetes stops routing to the instance before connections close.
+func (a *App) ServeHealth(prefix string, middlewares ...routing.Middleware) error {
+	var group routing.RouteGroup = a.Router
+	if len(middlewares) > 0 {
+		group = a.Router.With(middlewares...)
+	}
+	probe := func(report func(context.Context) libHealth.Report) routing.Handler {
+		return func(ctx *v2wf.RequestContext) error {
+			r := report(ctx.Context)
+			ctx.Parser.SetRespHeader("Cache-Control", "no-store")
+			return ctx.Parser.SendResponse(r.HTTPStatus(), "text/plain; charset=utf-8", []byte(r.Text()))
+		}
+	}
+	if err := group.Get(routing.JoinPath(prefix, LivenessPath), probe(a.Health.Live)); err != nil {
+		return fmt.Errorf("app: register liveness probe: %w", err)
+	}
+	if err := group.Get(routing.JoinPath(prefix, ReadinessPath), probe(a.Health.Ready)); err != nil {
+		return fmt.Errorf("app: register readiness probe: %w", err)
+	}
+	if err := group.Get(routing.JoinPath(prefix, HealthPath), func(ctx *v2wf.RequestContext) error {
+		r := a.Health.Health(ctx.Context)
+		body, err := r.JSON()
+		if err != nil {
+			return err
+		}
+		ctx.Parser.SetRespHeader("Cache-Control", "no-store")
+		return ctx.Parser.SendResponse(r.HTTPStatus(), "application/json", body)
+	}); err != nil {
+		return fmt.Errorf("app: register health report: %w", err)
+	}
+	return nil
+}
+
+// newHealthChecker creates the App health checker with the worker queue
+// saturation check and the configured checks.
+func newHealthChecker(config Config, worker workers.Worker) (*libHealth.Checker, error) {
+	checker := libHealth.New()
+	saturation := libHealth.Saturation(WorkersCheckName, config.WorkerSaturation, func() (int, int) {
+		stats := worker.Stats()
+		return stats.QueueDepth, stats.QueueCapacity
+	})
+	if err := checker.Register(saturation); err != nil {
+		return nil, err
+	}
+	if err := checker.Register(config.HealthChecks...); err != nil {
+		return nil, fmt.Errorf("app: register health checks: %w", err)
+	}
+	return checker, nil
+}
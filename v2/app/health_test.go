@@ -0,0 +1,106 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hmmftg/requestCore/libHealth"
+)
+
+func TestApp_ServeHealth(t *testing.T) {
+	for _, framework := range []Framework{FrameworkGin, FrameworkFiber, FrameworkChi, FrameworkNetHTTP} {
+		t.Run(string(framework), func(t *testing.T) {
+			healthy := true
+			app, err := Bootstrap(Config{
+				Framework: framework,
+				HealthChecks: []libHealth.Check{{
+					Name:     "db:main",
+					CacheTTL: -1,
+					Func: func(context.Context) error {
+						if !healthy {
+							return errors.New("connection refused")
+						}
+						return nil
+					},
+				}},
+			})
+			if err != nil {
+				t.Fatalf("Bootstrap: %v", err)
+			}
+			defer app.Close()
+			if err := app.ServeHealth("/"); err != nil {
+				t.Fatalf("ServeHealth: %v", err)
+			}
+
+			get := func(path string) (int, string) {
+				t.Helper()
+				resp := serveApp(t, app, httptest.NewRequest(http.MethodGet, path, nil))
+				defer resp.Body.Close()
+				body, _ := io.ReadAll(resp.Body)
+				return resp.StatusCode, string(body)
+			}
+
+			if status, body := get("/readyz"); status != http.StatusOK || body != "ok\n" {
+				t.Fatalf("unexpected readiness %d %q", status, body)
+			}
+			healthy = false
+			if status, body := get("/readyz"); status != http.StatusServiceUnavailable || body != "failing: db:main\n" {
+				t.Fatalf("unexpected failing readiness %d %q", status, body)
+			}
+			if status, _ := get("/livez"); status != http.StatusOK {
+				t.Fatalf("a dependency failure must not fail liveness, got %d", status)
+			}
+
+			status, body := get("/healthz")
+			var report libHealth.Report
+			if err := json.Unmarshal([]byte(body), &report); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if status != http.StatusServiceUnavailable || report.Checks[WorkersCheckName].Status != libHealth.StatusPass ||
+				report.Checks["db:main"].Error != "connection refused" {
+				t.Fatalf("unexpected health report %d: %s", status, body)
+			}
+		})
+	}
+}
+
+func TestApp_ShutdownFailsReadiness(t *testing.T) {
+	app, err := Bootstrap(Config{Framework: FrameworkChi, DrainDelay: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Bootstrap: %v", err)
+	}
+	if err := app.ServeHealth("/"); err != nil {
+		t.Fatalf("ServeHealth: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// The server never started, so Shutdown returns at the deadline.
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		_ = app.Shutdown(ctx)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for !app.Health.Draining() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	resp := serveApp(t, app, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected readiness to fail while draining, got %d", resp.StatusCode)
+	}
+	select {
+	case <-done:
+		t.Fatal("Shutdown returned before the drain delay")
+	default:
+	}
+	<-done
+}
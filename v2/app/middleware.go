@@ -17,6 +17,12 @@ const DefaultSessionCookieName = "session"
 // Session and Flash fields on the v2 RequestContext for handler access.
 // The session is saved via a before-commit hook, not after the handler
 // returns, ensuring cookies are set before the response body is written.
+//
+// Idle and absolute timeouts configured on the Manager (see
+// Config.SessionIdleTimeout and Config.SessionAbsoluteTimeout) are
+// enforced when the session is loaded: an expired session is deleted from
+// the store, the handler receives a fresh session, and its cookie replaces
+// the stale one.
 func SessionMiddleware(mgr *session.Manager, cookieName string) routing.Middleware {
 	if cookieName == "" {
 		cookieName = DefaultSessionCookieName
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hmmftg/requestCore/v2/routing"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+// OpenAPIDocumentPath is the path of the JSON document relative to the
+// prefix passed to ServeOpenAPI.
+const OpenAPIDocumentPath = "/openapi.json"
+
+// swaggerUIVersion pins the Swagger UI assets loaded from the CDN.
+const swaggerUIVersion = "5.17.14"
+
+// DefaultSwaggerUIAssets is the swagger-ui-dist location Swagger UI
+// loads its stylesheet and script from unless Config.SwaggerUIAssets
+// is set.
+const DefaultSwaggerUIAssets = "https://unpkg.com/swagger-ui-dist@" + swaggerUIVersion
+
+var swaggerUITemplate = template.Must(template.New("swagger-ui").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.Assets}}/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="{{.Assets}}/swagger-ui-bundle.js" crossorigin></script>
+<script>
+window.onload = function () {
+  window.ui = SwaggerUIBundle({ url: {{.SpecURL}}, dom_id: "#swagger-ui" });
+};
+</script>
+</body>
+</html>
+`))
+
+// ServeOpenAPI registers the generated OpenAPI document and Swagger UI
+// under prefix on every supported framework:
+//
+//	GET <prefix>/openapi.json  OpenAPI 3.1 document
+//	GET <prefix>               Swagger UI
+//
+// The document covers every handlers.Endpoint registered through the App
+// router, including resource operations, and is rebuilt when endpoints
+// are added. Routes registered with plain handlers are not documented.
+// Routes served by several API versions of a routing.Versioned group are
+// documented for the latest version; request openapi.json?version=v1
+// for the document of another version. The page loads Swagger UI from
+// Config.SwaggerUIAssets.
+func (a *App) ServeOpenAPI(prefix string, middlewares ...routing.Middleware) error {
+	prefix = "/" + strings.Trim(prefix, "/")
+	specURL := routing.JoinPath(prefix, OpenAPIDocumentPath)
+	var group routing.RouteGroup = a.Router
+	if len(middlewares) > 0 {
+		group = a.Router.With(middlewares...)
+	}
+
+	if err := group.Get(specURL, func(ctx *v2wf.RequestContext) error {
+		var body []byte
+		var err error
+		query, _ := url.ParseQuery(ctx.Parser.GetRawURLQuery())
+		if version := query.Get("version"); version != "" {
+			body, err = a.OpenAPI.JSONVersion(version)
+		} else {
+			body, err = a.OpenAPI.JSON()
+		}
+		if err != nil {
+			return err
+		}
+		return ctx.Parser.SendResponse(http.StatusOK, "application/json", body)
+	}); err != nil {
+		return fmt.Errorf("app: register OpenAPI document: %w", err)
+	}
+
+	var page strings.Builder
+	if err := swaggerUITemplate.Execute(&page, map[string]string{
+		"Title":   a.OpenAPI.Document().Info.Title,
+		"Assets":  a.swaggerUIAssets,
+		"SpecURL": specURL,
+	}); err != nil {
+		return fmt.Errorf("app: render Swagger UI: %w", err)
+	}
+	html := []byte(page.String())
+	if err := group.Get(prefix, func(ctx *v2wf.RequestContext) error {
+		return ctx.Parser.SendResponse(http.StatusOK, "text/html; charset=utf-8", html)
+	}); err != nil {
+		return fmt.Errorf("app: register Swagger UI: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/hmmftg/requestCore/libRequest"
+
+	"github.com/hmmftg/requestCore/v2/handlers"
+	"github.com/hmmftg/requestCore/v2/openapi"
+	"github.com/hmmftg/requestCore/v2/resources"
+	"github.com/hmmftg/requestCore/v2/routing"
+)
+
+type widget struct {
+	ID   string `json:"id"`
+	Name string `json:"name" validate:"required"`
+}
+
+type widgetResource struct{}
+
+func (widgetResource) List() *handlers.Endpoint {
+	return handlers.NewEndpoint("List widgets", libRequest.NoBinding,
+		func(*struct{}, *handlers.HandlerRequest[struct{}, []widget]) ([]widget, error) { return nil, nil })
+}
+func (widgetResource) Show() *handlers.Endpoint { return nil }
+func (widgetResource) New() *handlers.Endpoint  { return nil }
+func (widgetResource) Create() *handlers.Endpoint {
+	return handlers.NewEndpoint("Create widget", libRequest.JSON,
+		func(req *widget, _ *handlers.HandlerRequest[widget, widget]) (widget, error) { return *req, nil })
+}
+func (widgetResource) Edit() *handlers.Endpoint    { return nil }
+func (widgetResource) Update() *handlers.Endpoint  { return nil }
+func (widgetResource) Destroy() *handlers.Endpoint { return nil }
+
+// serveApp sends req to the app's native router for any framework.
+func serveApp(t *testing.T, app *App, req *http.Request) *http.Response {
+	t.Helper()
+	switch native := app.Router.Native().(type) {
+	case *fiber.App:
+		resp, err := native.Test(req)
+		if err != nil {
+			t.Fatalf("fiber Test: %v", err)
+		}
+		return resp
+	case http.Handler:
+		w := httptest.NewRecorder()
+		native.ServeHTTP(w, req)
+		return w.Result()
+	default:
+		t.Fatalf("unexpected native router %T", native)
+		return nil
+	}
+}
+
+func TestApp_ServeOpenAPI(t *testing.T) {
+	for _, framework := range []Framework{FrameworkGin, FrameworkFiber, FrameworkChi, FrameworkNetHTTP} {
+		t.Run(string(framework), func(t *testing.T) {
+			app, err := Bootstrap(Config{
+				Framework: framework,
+				OpenAPI:   openapi.Info{Title: "Widgets", Version: "2.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("Bootstrap: %v", err)
+			}
+			defer app.Close()
+
+			api := app.Register("/api").Group("/v1")
+			if err := resources.Register(api, resources.Config[string]{
+				Path:        "/widgets",
+				Resource:    widgetResource{},
+				RespHandler: app.RespHandler,
+			}); err != nil {
+				t.Fatalf("resources.Register: %v", err)
+			}
+			if err := app.ServeOpenAPI("/docs"); err != nil {
+				t.Fatalf("ServeOpenAPI: %v", err)
+			}
+
+			resp := serveApp(t, app, httptest.NewRequest(http.MethodGet, "/docs/openapi.json", nil))
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected 200, got %d", resp.StatusCode)
+			}
+			var doc openapi.Document
+			if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			item := doc.Paths["/api/v1/widgets"]
+			if item == nil || (*item)["get"] == nil || (*item)["post"] == nil || len(doc.Paths) != 1 {
+				t.Fatalf("unexpected paths: %+v", doc.Paths)
+			}
+			if op := (*item)["post"]; op.Summary != "Create widget" || len(op.Tags) != 1 || op.Tags[0] != "widgets" {
+				t.Fatalf("unexpected create operation: %+v", op)
+			}
+
+			resp = serveApp(t, app, httptest.NewRequest(http.MethodGet, "/docs", nil))
+			defer resp.Body.Close()
+			page, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusOK || !strings.Contains(string(page), "/docs/openapi.json") ||
+				!strings.Contains(string(page), DefaultSwaggerUIAssets+"/swagger-ui-bundle.js") {
+				t.Fatalf("unexpected Swagger UI response %d: %s", resp.StatusCode, page)
+			}
+		})
+	}
+}
+
+func TestApp_ServeOpenAPI_LocalAssets(t *testing.T) {
+	app, err := Bootstrap(Config{Framework: FrameworkNetHTTP, SwaggerUIAssets: "/static/swagger-ui/"})
+	if err != nil {
+		t.Fatalf("Bootstrap: %v", err)
+	}
+	defer app.Close()
+	if err := app.ServeOpenAPI("/docs"); err != nil {
+		t.Fatalf("ServeOpenAPI: %v", err)
+	}
+
+	resp := serveApp(t, app, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	defer resp.Body.Close()
+	page, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(page), `href="/static/swagger-ui/swagger-ui.css"`) ||
+		!strings.Contains(string(page), `src="/static/swagger-ui/swagger-ui-bundle.js"`) || strings.Contains(string(page), "unpkg") {
+		t.Fatalf("expected local Swagger UI assets, got %s", page)
+	}
+}
+
+func TestApp_ServeOpenAPI_Versions(t *testing.T) {
+	for _, framework := range []Framework{FrameworkGin, FrameworkFiber, FrameworkChi, FrameworkNetHTTP} {
+		t.Run(string(framework), func(t *testing.T) {
+			app, err := Bootstrap(Config{Framework: framework})
+			if err != nil {
+				t.Fatalf("Bootstrap: %v", err)
+			}
+			defer app.Close()
+
+			api, err := routing.NewVersioned(app.Register("/api"), routing.VersionConfig{
+				Strategy: routing.VersionByHeader,
+				Versions: []string{"v1", "v2"},
+			})
+			if err != nil {
+				t.Fatalf("NewVersioned: %v", err)
+			}
+			_ = api.Deprecate("v1", routing.VersionPolicy{Deprecated: time.Now()})
+			if err := resources.Register(api.Version("v1"), resources.Config[string]{
+				Path:        "/widgets",
+				Resource:    widgetResource{},
+				RespHandler: app.RespHandler,
+			}); err != nil {
+				t.Fatalf("resources.Register: %v", err)
+			}
+			listV2 := handlers.NewEndpoint("List widgets v2", libRequest.NoBinding,
+				func(*struct{}, *handlers.HandlerRequest[struct{}, []widget]) ([]widget, error) { return nil, nil })
+			if err := handlers.RegisterEndpoint(api.Version("v2"), nil, app.RespHandler, "GET", "/widgets", listV2); err != nil {
+				t.Fatalf("register v2: %v", err)
+			}
+			if err := app.ServeOpenAPI("/docs"); err != nil {
+				t.Fatalf("ServeOpenAPI: %v", err)
+			}
+
+			document := func(query string) openapi.PathItem {
+				t.Helper()
+				resp := serveApp(t, app, httptest.NewRequest(http.MethodGet, "/docs/openapi.json"+query, nil))
+				defer resp.Body.Close()
+				var doc openapi.Document
+				if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				return *doc.Paths["/api/widgets"]
+			}
+			latest := document("")
+			if op := latest["get"]; op.Summary != "List widgets v2" || op.APIVersion != "v2" || op.Deprecated {
+				t.Fatalf("unexpected latest list operation: %+v", op)
+			}
+			if op := latest["post"]; op.APIVersion != "v2" || op.Summary != "Create widget" {
+				t.Fatalf("expected create to fall back to v1 in v2: %+v", op)
+			}
+			if op := document("?version=v1")["get"]; op.Summary != "List widgets" || !op.Deprecated {
+				t.Fatalf("unexpected v1 list operation: %+v", op)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/widgets", nil)
+			req.Header.Set(routing.DefaultVersionHeader, "v0")
+			resp := serveApp(t, app, req)
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("expected 400 for unsupported version, got %d", resp.StatusCode)
+			}
+		})
+	}
+}
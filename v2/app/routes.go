@@ -0,0 +1,99 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/hmmftg/requestCore/v2/handlers"
+	"github.com/hmmftg/requestCore/v2/routing"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+// RoutesEnv is the environment variable that switches StartWithContext
+// into route listing mode: instead of serving, the App writes its route
+// table to stdout as a single line prefixed with RoutesMarker. The
+// `requestcore routes` command sets it to list the routes of a project.
+const RoutesEnv = "REQUESTCORE_PRINT_ROUTES"
+
+// RoutesMarker prefixes the JSON route table written in route listing
+// mode.
+const RoutesMarker = "requestcore-routes: "
+
+// Route describes a registered route and, for typed endpoints, the
+// endpoint metadata.
+type Route struct {
+	routing.RouteInfo
+
+	Title    string   `json:"title,omitempty"`
+	Body     string   `json:"body,omitempty"`
+	Request  string   `json:"request,omitempty"`
+	Response string   `json:"response,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// Routes returns every route registered through the App router, sorted by
+// pattern, method and API version. Versioned routes are listed once per
+// version they serve.
+func (a *App) Routes() []Route {
+	if a.routes == nil {
+		return nil
+	}
+	infos := a.routes.Routes()
+	routes := make([]Route, len(infos))
+	for i, info := range infos {
+		routes[i] = Route{RouteInfo: info}
+		if e, ok := info.Metadata.(*handlers.Endpoint); ok {
+			routes[i].Title = e.Title
+			routes[i].Body = e.Body.String()
+			routes[i].Tags = e.Tags
+			routes[i].Roles = e.Roles
+			routes[i].Permissions = e.Permissions
+			routes[i].Deprecated = routes[i].Deprecated || e.Deprecated
+			if t := e.RequestType(); t != nil {
+				routes[i].Request = t.String()
+			}
+			if t := e.ResponseType(); t != nil {
+				routes[i].Response = t.String()
+			}
+		}
+	}
+	return routes
+}
+
+// RegisterRoutesDebug registers GET path returning the route table as
+// JSON. It is optional; pass authentication middleware outside
+// development, since the table reveals the full API surface.
+func (a *App) RegisterRoutesDebug(path string, middlewares ...routing.Middleware) error {
+	var group routing.RouteGroup = a.Router
+	if len(middlewares) > 0 {
+		group = a.Router.With(middlewares...)
+	}
+	return group.Get(path, func(ctx *v2wf.RequestContext) error {
+		body, err := json.Marshal(a.Routes())
+		if err != nil {
+			return err
+		}
+		return ctx.Parser.SendResponse(http.StatusOK, "application/json", body)
+	})
+}
+
+// printRoutes writes the route table in route listing mode.
+func (a *App) printRoutes(w io.Writer) error {
+	body, err := json.Marshal(a.Routes())
+	if err != nil {
+		return fmt.Errorf("app: encode routes: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", RoutesMarker, body)
+	return err
+}
+
+// routeListingMode reports whether RoutesEnv is set.
+func routeListingMode() bool {
+	return os.Getenv(RoutesEnv) != ""
+}
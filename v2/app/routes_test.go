@@ -0,0 +1,98 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hmmftg/requestCore/v2/resources"
+	"github.com/hmmftg/requestCore/v2/routing"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+func requestLogger(next routing.Handler) routing.Handler { return next }
+
+func TestApp_Routes(t *testing.T) {
+	for _, framework := range []Framework{FrameworkGin, FrameworkFiber, FrameworkChi, FrameworkNetHTTP} {
+		t.Run(string(framework), func(t *testing.T) {
+			app, err := Bootstrap(Config{
+				Framework:   framework,
+				Middlewares: []routing.Middleware{requestLogger},
+			})
+			if err != nil {
+				t.Fatalf("Bootstrap: %v", err)
+			}
+			defer app.Close()
+
+			api := app.Register("/api").With(FlashMiddleware())
+			if err := resources.Register(api, resources.Config[string]{
+				Path:        "/widgets",
+				Resource:    widgetResource{},
+				RespHandler: app.RespHandler,
+			}); err != nil {
+				t.Fatalf("resources.Register: %v", err)
+			}
+			if err := app.RegisterRoutesDebug("/debug/routes"); err != nil {
+				t.Fatalf("RegisterRoutesDebug: %v", err)
+			}
+
+			routes := app.Routes()
+			if len(routes) != 3 {
+				t.Fatalf("expected 3 routes, got %+v", routes)
+			}
+			create := routes[1]
+			if create.Method != "POST" || create.Pattern != "/api/widgets" || create.Title != "Create widget" {
+				t.Fatalf("unexpected create route: %+v", create)
+			}
+			if create.Body != "JSON" || create.Request != "app.widget" || create.Response != "app.widget" || create.Tags[0] != "widgets" {
+				t.Fatalf("unexpected endpoint metadata: %+v", create)
+			}
+			if mws := create.Middlewares; len(mws) != 2 || mws[0] != "app.requestLogger" || mws[1] != "app.FlashMiddleware" {
+				t.Fatalf("unexpected middleware chain: %v", mws)
+			}
+			if debug := routes[2]; debug.Pattern != "/debug/routes" || debug.Title != "" {
+				t.Fatalf("unexpected debug route: %+v", debug)
+			}
+
+			resp := serveApp(t, app, httptest.NewRequest(http.MethodGet, "/debug/routes", nil))
+			defer resp.Body.Close()
+			var served []Route
+			if err := json.NewDecoder(resp.Body).Decode(&served); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.StatusCode != http.StatusOK || len(served) != 3 || served[1].Title != "Create widget" {
+				t.Fatalf("unexpected debug response %d: %+v", resp.StatusCode, served)
+			}
+		})
+	}
+}
+
+func TestApp_StartRouteListing(t *testing.T) {
+	t.Setenv(RoutesEnv, "1")
+	app, err := Bootstrap(Config{Framework: FrameworkChi})
+	if err != nil {
+		t.Fatalf("Bootstrap: %v", err)
+	}
+	defer app.Close()
+	if err := app.Router.Get("/health", func(ctx *v2wf.RequestContext) error { return nil }); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := app.printRoutes(&out); err != nil {
+		t.Fatalf("printRoutes: %v", err)
+	}
+	line, ok := strings.CutPrefix(strings.TrimSpace(out.String()), RoutesMarker)
+	if !ok || !strings.Contains(line, `"pattern":"/health"`) {
+		t.Fatalf("unexpected route listing: %q", out.String())
+	}
+	if !routeListingMode() {
+		t.Fatal("expected route listing mode")
+	}
+	if err := app.Start(":0"); err != nil {
+		t.Fatalf("Start in route listing mode: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+// Package authz provides role-based authorization middleware for v2
+// routing on top of libAuthz.
+//
+// Middleware resolves the caller's roles once per request with a
+// libAuthz.Authorizer, so endpoints declaring Endpoint.WithRoles or
+// WithPermissions, and v1 handlers declaring HandlerParameters.Roles, are
+// checked against it. Require enforces a requirement on plain routes and
+// groups. ClaimsResolver reads the roles from the token verified by the
+// jwtauth middleware, which must run first.
+//
+// A denied request fails with a 403 libError coded libAuthz.AccessDenied
+// and is routed through the v2 response registry like any other handler
+// error, so its message is localized through the error descriptions.
+package authz
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hmmftg/requestCore/libAuthz"
+	"github.com/hmmftg/requestCore/webFramework"
+
+	"github.com/hmmftg/requestCore/v2/jwtauth"
+	"github.com/hmmftg/requestCore/v2/routing"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+// DefaultRolesClaim is the token claim ClaimsResolver reads by default.
+const DefaultRolesClaim = "roles"
+
+// Middleware resolves the caller of every request with a and keeps the
+// principal for the endpoints and Require checks further down the chain.
+// A resolver failure fails the request with a 500.
+func Middleware(a *libAuthz.Authorizer) routing.Middleware {
+	return func(next routing.Handler) routing.Handler {
+		return func(ctx *v2wf.RequestContext) error {
+			if ctx.Parser == nil {
+				return next(ctx)
+			}
+			if _, err := a.Principal(ctx.ContextOrBackground(), ctx.Parser); err != nil {
+				return err
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// Require denies requests whose caller does not meet req, auditing the
+// decision under name. It uses the authorizer of Middleware or else the
+// libAuthz default, and denies every request if neither is set.
+func Require(name string, req libAuthz.Requirement) routing.Middleware {
+	return func(next routing.Handler) routing.Handler {
+		return func(ctx *v2wf.RequestContext) error {
+			if ctx.Parser == nil {
+				return next(ctx)
+			}
+			if _, err := libAuthz.Check(ctx.ContextOrBackground(), ctx.Parser, name, req); err != nil {
+				return err
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// RequireRoles is Require with a requirement of any of roles.
+func RequireRoles(name string, roles ...string) routing.Middleware {
+	return Require(name, libAuthz.Requirement{Roles: roles})
+}
+
+// ClaimsResolver reads the caller's roles from claim of the token
+// verified by jwtauth.Middleware, either a list or a single
+// comma-separated string. An empty claim resolves to no roles; chain a
+// database resolver with libAuthz.Chain to fall back to it.
+func ClaimsResolver(claim string) libAuthz.Resolver {
+	if claim == "" {
+		claim = DefaultRolesClaim
+	}
+	return libAuthz.ResolverFunc(func(_ context.Context, parser webFramework.RequestParser, _ string) ([]string, error) {
+		claims, _ := parser.GetLocal(jwtauth.ClaimsKey).(*jwtauth.Claims)
+		if claims == nil {
+			return nil, nil
+		}
+		var roles []string
+		for _, value := range claims.Strings(claim) {
+			for _, role := range strings.Split(value, ",") {
+				if role = strings.TrimSpace(role); role != "" {
+					roles = append(roles, role)
+				}
+			}
+		}
+		return roles, nil
+	})
+}
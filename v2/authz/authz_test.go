@@ -0,0 +1,109 @@
+package authz_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hmmftg/requestCore/libAuthz"
+	"github.com/hmmftg/requestCore/response"
+
+	"github.com/hmmftg/requestCore/v2/authz"
+	"github.com/hmmftg/requestCore/v2/jwtauth"
+	v2libNetHttp "github.com/hmmftg/requestCore/v2/libNetHttp"
+	"github.com/hmmftg/requestCore/v2/renderers"
+	v2response "github.com/hmmftg/requestCore/v2/response"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+var (
+	now        = time.Unix(1_700_000_000, 0)
+	hmacSecret = []byte("0123456789abcdef0123456789abcdef")
+)
+
+// token returns an HS256 token for sub with the given roles claim.
+func token(sub string, roles any) string {
+	enc := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]any{"alg": jwtauth.HS256, "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]any{"sub": sub, "roles": roles, "exp": now.Add(time.Hour).Unix()})
+	signed := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	mac := hmac.New(sha256.New, hmacSecret)
+	mac.Write([]byte(signed))
+	return signed + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+// newServer verifies tokens, resolves roles from their claims and
+// protects GET /reports with a Require check.
+func newServer(t *testing.T, audit *[]libAuthz.Decision) http.Handler {
+	t.Helper()
+	jwt, err := jwtauth.Middleware(jwtauth.Config{
+		VerifierConfig: jwtauth.VerifierConfig{
+			Keys:  jwtauth.StaticKeys{{Algorithm: jwtauth.HS256, Key: hmacSecret}},
+			Clock: func() time.Time { return now },
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := libAuthz.New(libAuthz.Config{
+		RoleMap:  map[string]string{"auditor": "reports.read", "admin": libAuthz.AllPermissions},
+		Resolver: authz.ClaimsResolver(""),
+		Audit:    func(d libAuthz.Decision) { *audit = append(*audit, d) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := v2libNetHttp.NewRouter()
+	registry := v2response.NewRegistry(nil)
+	registry.SetFallback(v2response.LegacyFallback(response.WebHanlder{
+		MessageDesc: make(map[string]string),
+		ErrorDesc:   map[string]string{libAuthz.AccessDenied: "دسترسی غیرمجاز"},
+	}))
+	router.SetErrorHandler(v2response.NewHandler(registry, renderers.JSONRenderer{}, response.WebHanlder{}))
+	_ = router.With(jwt, authz.Middleware(a), authz.Require("reports", libAuthz.Requirement{Permissions: []string{"reports.read"}})).
+		Get("/reports", func(ctx *v2wf.RequestContext) error {
+			return ctx.Parser.SendResponse(http.StatusOK, "text/plain", []byte("ok"))
+		})
+	return router.Native().(http.Handler)
+}
+
+func TestRequire(t *testing.T) {
+	var audit []libAuthz.Decision
+	handler := newServer(t, &audit)
+
+	tests := []struct {
+		name   string
+		roles  any
+		status int
+	}{
+		{"list", []string{"clerk", "auditor"}, http.StatusOK},
+		{"comma-separated", "clerk, admin", http.StatusOK},
+		{"missing permission", []string{"clerk"}, http.StatusForbidden},
+		{"no roles", nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit = nil
+			req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+			req.Header.Set("Authorization", "Bearer "+token("u-42", tt.roles))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d: %s", tt.status, w.Code, w.Body)
+			}
+			if w.Code == http.StatusForbidden && !strings.Contains(w.Body.String(), "دسترسی غیرمجاز") {
+				t.Fatalf("expected the localized description, got %s", w.Body)
+			}
+			if len(audit) != 1 || audit[0].Handler != "reports" || audit[0].User != "u-42" || audit[0].Allowed != (tt.status == http.StatusOK) {
+				t.Fatalf("unexpected audit %+v", audit)
+			}
+		})
+	}
+}
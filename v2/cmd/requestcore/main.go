@@ -7,6 +7,7 @@
 //	requestcore generate resource <name>
 //	requestcore generate middleware <name>
 //	requestcore generate project <name>
+//	requestcore routes [-json] [-timeout 2m] [package]
 //	requestcore version
 package cmd
 
@@ -31,6 +32,11 @@ func Commands() []Command {
 			Description: "Print the requestcore v2 version",
 			Run:         runVersion,
 		},
+		{
+			Name:        "routes",
+			Description: "Run a v2 application up to App.Start and print its route table",
+			Run:         runRoutes,
+		},
 		{
 			Name:        "generate handler",
 			Description: "Generate a new v2 handler file",
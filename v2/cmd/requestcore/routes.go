@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hmmftg/requestCore/v2/app"
+)
+
+// defaultRoutesTimeout bounds building and running the program listed by
+// the routes command.
+const defaultRoutesTimeout = 2 * time.Minute
+
+// runRoutes builds and runs the project's main package in route listing
+// mode (see app.RoutesEnv) and prints its route table.
+//
+//	requestcore routes [-json] [-timeout 2m] [package]
+//
+// The package defaults to the current directory. The program must reach
+// App.Start or App.StartWithContext after registering its routes; it
+// returns at that point without serving. Everything main does before
+// that runs in full, including database connections and workers, so
+// programs that block on a dependency are killed after -timeout.
+// Programs can skip such side effects when app.RoutesEnv is set.
+func runRoutes(args []string) error {
+	fs := flag.NewFlagSet("routes", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the route table as JSON")
+	timeout := fs.Duration("timeout", defaultRoutesTimeout, "how long building and running the program may take")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	pkg := "."
+	if fs.NArg() > 0 {
+		pkg = fs.Arg(0)
+	}
+
+	routes, err := loadRoutes(pkg, *timeout)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(routes)
+	}
+	return printRouteTable(os.Stdout, routes)
+}
+
+// loadRoutes builds pkg, runs it with app.RoutesEnv set and decodes the
+// route table it prints. The program is built rather than started with
+// "go run" so that the timeout kills the program itself.
+func loadRoutes(pkg string, timeout time.Duration) ([]app.Route, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	dir, err := os.MkdirTemp("", "requestcore-routes")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	bin := filepath.Join(dir, "app")
+	if runtime.GOOS == "windows" {
+		bin += ".exe"
+	}
+
+	var stdout, stderr bytes.Buffer
+	build := exec.CommandContext(ctx, "go", "build", "-o", bin, pkg)
+	build.Stderr = &stderr
+	if err := build.Run(); err != nil {
+		return nil, fmt.Errorf("routes: build %s: %w\n%s", pkg, err, stderr.String())
+	}
+
+	cmd := exec.CommandContext(ctx, bin)
+	cmd.Env = append(os.Environ(), app.RoutesEnv+"=1")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Do not wait forever on output pipes held by leftover children.
+	cmd.WaitDelay = time.Second
+	runErr := cmd.Run()
+
+	routes, found, err := parseRoutes(&stdout)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("routes: %s did not print a route table within %s (does it block before App.Start?)\n%s",
+				pkg, timeout, stderr.String())
+		}
+		// Programs that treat Start's return as fatal exit non-zero
+		// after printing, so the exit status only matters when the
+		// table is missing.
+		return nil, fmt.Errorf("routes: %s did not print a route table (does it call App.Start?): %v\n%s",
+			pkg, runErr, stderr.String())
+	}
+	return routes, nil
+}
+
+// parseRoutes finds the app.RoutesMarker line in r and decodes it.
+func parseRoutes(r io.Reader) ([]app.Route, bool, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		payload, ok := strings.CutPrefix(scanner.Text(), app.RoutesMarker)
+		if !ok {
+			continue
+		}
+		var routes []app.Route
+		if err := json.Unmarshal([]byte(payload), &routes); err != nil {
+			return nil, true, fmt.Errorf("routes: decode route table: %w", err)
+		}
+		return routes, true, nil
+	}
+	return nil, false, scanner.Err()
+}
+
+// printRouteTable writes routes as an aligned table.
+func printRouteTable(w io.Writer, routes []app.Route) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATTERN\tVERSION\tENDPOINT\tMIDDLEWARE")
+	for _, r := range routes {
+		endpoint := r.Title
+		if r.Request != "" || r.Response != "" {
+			endpoint = strings.TrimSpace(fmt.Sprintf("%s %s -> %s", r.Title, r.Request, r.Response))
+		}
+		if r.Deprecated {
+			endpoint += " (deprecated)"
+		}
+		if endpoint == "" {
+			endpoint = "-"
+		}
+		version := r.Version
+		if r.Fallback != "" {
+			version += " (" + r.Fallback + ")"
+		}
+		if version == "" {
+			version = "-"
+		}
+		middleware := strings.Join(r.Middlewares, ", ")
+		if middleware == "" {
+			middleware = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Method, r.Pattern, version, endpoint, middleware)
+	}
+	return tw.Flush()
+}
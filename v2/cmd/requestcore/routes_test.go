@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hmmftg/requestCore/v2/app"
+)
+
+func TestParseRoutes_PrintTable(t *testing.T) {
+	out := "starting\n" + app.RoutesMarker +
+		`[{"method":"GET","pattern":"/health"},` +
+		`{"method":"POST","pattern":"/api/users","middlewares":["app.SessionMiddleware"],` +
+		`"version":"v2","fallback":"v1","title":"Create user","request":"model.User","response":"model.User","deprecated":true}]` + "\n"
+
+	routes, found, err := parseRoutes(strings.NewReader(out))
+	if err != nil || !found {
+		t.Fatalf("parseRoutes: found=%v err=%v", found, err)
+	}
+	if len(routes) != 2 || routes[1].Pattern != "/api/users" || routes[1].Middlewares[0] != "app.SessionMiddleware" {
+		t.Fatalf("unexpected routes: %+v", routes)
+	}
+
+	var table bytes.Buffer
+	if err := printRouteTable(&table, routes); err != nil {
+		t.Fatalf("printRouteTable: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "METHOD") {
+		t.Fatalf("unexpected table:\n%s", table.String())
+	}
+	if !strings.Contains(lines[1], "/health") || !strings.HasSuffix(lines[1], "-") {
+		t.Fatalf("unexpected health row: %q", lines[1])
+	}
+	if !strings.Contains(lines[2], "v2 (v1)  Create user model.User -> model.User (deprecated)") {
+		t.Fatalf("unexpected users row: %q", lines[2])
+	}
+
+	if _, found, _ := parseRoutes(strings.NewReader("no table\n")); found {
+		t.Fatal("expected no route table")
+	}
+}
+
+func TestLoadRoutes_Timeout(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module blocking\n\ngo 1.22\n",
+		"main.go": "package main\n\nimport \"time\"\n\nfunc main() { time.Sleep(time.Hour) }\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+
+	start := time.Now()
+	_, err := loadRoutes(".", 5*time.Second)
+	if err == nil || !strings.Contains(err.Error(), "within 5s") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("expected the program to be killed, took %s", elapsed)
+	}
+}
@@ -0,0 +1,269 @@
+// Package compression provides framework-neutral gzip and deflate
+// middleware for v2 routing.
+//
+// Middleware compresses response bodies written through
+// RequestParser.SendResponse, so it works the same for every adapter
+// (Gin, Fiber/fasthttp, chi and net/http) and for both success and
+// error responses. Bodies are compressed when the client's
+// Accept-Encoding allows it, the content type is in the allowlist and
+// the body reaches a minimum size; range requests, partial responses and
+// bodies that already carry a Content-Encoding are left alone.
+//
+// Decompress transparently decodes gzip and deflate request bodies
+// before the handler binds them, with a limit on the decompressed size
+// to stop zip bombs.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hmmftg/requestCore/v2/renderers"
+	"github.com/hmmftg/requestCore/v2/routing"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+// Supported content codings.
+const (
+	Gzip    = "gzip"
+	Deflate = "deflate"
+)
+
+// DefaultMinSize is the smallest response body compressed by default.
+// Below it the encoding overhead outweighs the savings.
+const DefaultMinSize = 1024
+
+// DefaultContentTypes is the default allowlist of compressible media
+// types. An entry "type/*" matches every subtype.
+var DefaultContentTypes = []string{
+	"text/*",
+	"application/json",
+	"application/problem+json",
+	"application/x-ndjson",
+	"application/xml",
+	"application/javascript",
+	"image/svg+xml",
+}
+
+// Config configures Middleware.
+type Config struct {
+	// Encodings lists the codings offered to clients, in order of server
+	// preference when the client accepts several equally.
+	// Default: gzip, deflate.
+	Encodings []string
+
+	// Level is the compression level, between gzip.HuffmanOnly and
+	// gzip.BestCompression.
+	// Default: gzip.DefaultCompression.
+	Level int
+
+	// MinSize is the smallest body, in bytes, that is compressed.
+	// Default: DefaultMinSize.
+	MinSize int
+
+	// ContentTypes is the allowlist of compressible media types.
+	// Default: DefaultContentTypes.
+	ContentTypes []string
+
+	// Skipper, if set, exempts any request for which it returns true.
+	Skipper func(*v2wf.RequestContext) bool
+}
+
+func (c Config) withDefaults() (Config, error) {
+	if len(c.Encodings) == 0 {
+		c.Encodings = []string{Gzip, Deflate}
+	}
+	for i, e := range c.Encodings {
+		e = strings.ToLower(e)
+		if e != Gzip && e != Deflate {
+			return c, fmt.Errorf("compression: unsupported encoding %q", e)
+		}
+		c.Encodings[i] = e
+	}
+	if c.Level == 0 {
+		c.Level = gzip.DefaultCompression
+	}
+	if c.Level < gzip.HuffmanOnly || c.Level > gzip.BestCompression {
+		return c, fmt.Errorf("compression: invalid level %d", c.Level)
+	}
+	if c.MinSize <= 0 {
+		c.MinSize = DefaultMinSize
+	}
+	if len(c.ContentTypes) == 0 {
+		c.ContentTypes = DefaultContentTypes
+	}
+	return c, nil
+}
+
+// Middleware returns a routing.Middleware that compresses response
+// bodies. It replaces the request's parser with one whose SendResponse
+// encodes the body, so it must run before any middleware or handler
+// that writes the response. Responses written through the legacy
+// SendJSONRespBody path are not compressed.
+func Middleware(config Config) (routing.Middleware, error) {
+	config, err := config.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	return func(next routing.Handler) routing.Handler {
+		return func(ctx *v2wf.RequestContext) error {
+			if ctx.Parser == nil || (config.Skipper != nil && config.Skipper(ctx)) {
+				return next(ctx)
+			}
+			if _, ok := ctx.Parser.(*parser); !ok {
+				p := &parser{RequestParser: ctx.Parser, req: ctx, config: &config, headers: map[string]string{}}
+				ctx.Parser = p
+				ctx.Legacy.Parser = p
+			}
+			return next(ctx)
+		}
+	}, nil
+}
+
+// parser wraps a RequestParser to compress the body in SendResponse. It
+// records the response headers that affect compression, since parsers
+// can set but not read response headers.
+type parser struct {
+	v2wf.RequestParser
+	req     *v2wf.RequestContext
+	config  *Config
+	headers map[string]string
+}
+
+// SetRespHeader implements RequestParser.
+func (p *parser) SetRespHeader(name, value string) {
+	p.headers[http.CanonicalHeaderKey(name)] = value
+	p.RequestParser.SetRespHeader(name, value)
+}
+
+// SendResponse implements RequestParser. The body is sent uncompressed
+// when encoding fails or does not make it smaller.
+func (p *parser) SendResponse(status int, contentType string, body []byte) error {
+	if encoding := p.encoding(status, contentType, body); encoding != "" {
+		compressed, err := encode(encoding, p.config.Level, body)
+		switch {
+		case err != nil:
+			slog.Error("compression: encode failed", slog.String("encoding", encoding), slog.Any("error", err))
+		case len(compressed) < len(body):
+			p.RequestParser.SetRespHeader("Content-Encoding", encoding)
+			if etag := p.headers["Etag"]; etag != "" && !strings.HasPrefix(etag, "W/") {
+				// The compressed representation is not byte-identical.
+				p.RequestParser.SetRespHeader("ETag", "W/"+etag)
+			}
+			body = compressed
+		}
+	}
+	return p.RequestParser.SendResponse(status, contentType, body)
+}
+
+// encoding returns the coding to compress the response with, or "".
+func (p *parser) encoding(status int, contentType string, body []byte) string {
+	if p.req.Committed() || len(body) < p.config.MinSize ||
+		status < http.StatusOK || status == http.StatusNoContent ||
+		status == http.StatusPartialContent || status == http.StatusNotModified {
+		return ""
+	}
+	if p.headers["Content-Encoding"] != "" || p.headers["Content-Range"] != "" ||
+		strings.Contains(strings.ToLower(p.headers["Cache-Control"]), "no-transform") ||
+		!compressible(contentType, p.config.ContentTypes) {
+		return ""
+	}
+	p.req.AddVary("Accept-Encoding")
+	if p.GetHeaderValue("Range") != "" {
+		return ""
+	}
+	return Negotiate(p.GetHeaderValue("Accept-Encoding"), p.config.Encodings...)
+}
+
+// compressible reports whether contentType matches the allowlist.
+func compressible(contentType string, allowed []string) bool {
+	mediaType := renderers.MediaType(contentType)
+	t, _, _ := strings.Cut(mediaType, "/")
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if a == mediaType || a == t+"/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Negotiate returns the coding of encodings the Accept-Encoding header
+// prefers, or "" if none is acceptable. Ties go to the earlier entry of
+// encodings; "*" covers codings the header does not name and q=0
+// excludes a coding.
+func Negotiate(acceptEncoding string, encodings ...string) string {
+	if strings.TrimSpace(acceptEncoding) == "" {
+		return ""
+	}
+	accepted := map[string]float64{}
+	wildcard := -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(strings.TrimSpace(key), "q") {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = min(max(v, 0), 1)
+				}
+			}
+		}
+		switch name {
+		case "*":
+			wildcard = q
+		case "x-gzip":
+			accepted[Gzip] = q
+		default:
+			accepted[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, e := range encodings {
+		q, ok := accepted[e]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = e, q
+		}
+	}
+	return best
+}
+
+// encode compresses body with the given coding.
+func encode(encoding string, level int, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch encoding {
+	case Gzip:
+		w, err = gzip.NewWriterLevel(&buf, level)
+	case Deflate:
+		w, err = zlib.NewWriterLevel(&buf, level)
+	default:
+		return nil, fmt.Errorf("compression: unsupported encoding %q", encoding)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,238 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	legacyLibNetHttp "github.com/hmmftg/requestCore/libNetHttp"
+	legacy "github.com/hmmftg/requestCore/webFramework"
+
+	v2response "github.com/hmmftg/requestCore/v2/response"
+	"github.com/hmmftg/requestCore/v2/routing"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+func newRequest(headers map[string]string) (*v2wf.RequestContext, *v2wf.FakeParserV2) {
+	parser := v2wf.NewFakeParserV2()
+	parser.Method = http.MethodGet
+	parser.Path = "/items"
+	for k, v := range headers {
+		parser.ReqHeader[k] = v
+	}
+	commit := &v2wf.CommitState{}
+	parser.SetCommitState(commit)
+	ctx := &v2wf.RequestContext{
+		Parser:  parser,
+		Context: context.Background(),
+		Legacy:  legacy.WebFramework{Parser: parser},
+	}
+	ctx.SetCommitState(commit)
+	return ctx, parser
+}
+
+func send(t *testing.T, mw routing.Middleware, ctx *v2wf.RequestContext, write func(*v2wf.RequestContext) error) {
+	t.Helper()
+	if err := mw(write)(ctx); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+}
+
+func sendJSON(body []byte) func(*v2wf.RequestContext) error {
+	return func(ctx *v2wf.RequestContext) error {
+		return ctx.Parser.SendResponse(http.StatusOK, "application/json", body)
+	}
+}
+
+func gunzip(t *testing.T, body []byte) []byte {
+	t.Helper()
+	r, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	return data
+}
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"gzip, deflate, br", Gzip},
+		{"deflate", Deflate},
+		{"deflate;q=1, gzip;q=0.5", Deflate},
+		{"br", ""},
+		{"*", Gzip},
+		{"*, gzip;q=0", Deflate},
+		{"gzip;q=0, deflate;q=0", ""},
+		{"x-gzip", Gzip},
+		{"identity", ""},
+	}
+	for _, c := range cases {
+		if got := Negotiate(c.accept, Gzip, Deflate); got != c.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", c.accept, got, c.want)
+		}
+	}
+}
+
+func TestMiddleware_Compresses(t *testing.T) {
+	mw, err := Middleware(Config{MinSize: 10})
+	if err != nil {
+		t.Fatalf("Middleware: %v", err)
+	}
+	payload := []byte(`{"items":"` + strings.Repeat("a", 500) + `"}`)
+
+	ctx, parser := newRequest(map[string]string{"Accept-Encoding": "gzip, deflate"})
+	send(t, mw, ctx, func(ctx *v2wf.RequestContext) error {
+		ctx.Parser.SetRespHeader("ETag", `"v1"`)
+		return ctx.Parser.SendResponse(http.StatusOK, "application/json; charset=utf-8", payload)
+	})
+	if parser.RespHeader["Content-Encoding"] != Gzip || parser.RespHeader["Vary"] != "Accept-Encoding" {
+		t.Fatalf("unexpected headers %v", parser.RespHeader)
+	}
+	if parser.RespHeader["ETag"] != `W/"v1"` {
+		t.Fatalf("expected weak ETag, got %q", parser.RespHeader["ETag"])
+	}
+	if !bytes.Equal(gunzip(t, parser.ResponseBody), payload) {
+		t.Fatal("gzip body does not round trip")
+	}
+	if !ctx.Committed() {
+		t.Fatal("expected response to be committed")
+	}
+
+	ctx, parser = newRequest(map[string]string{"Accept-Encoding": "deflate"})
+	send(t, mw, ctx, sendJSON(payload))
+	r, err := zlib.NewReader(bytes.NewReader(parser.ResponseBody))
+	if err != nil || parser.RespHeader["Content-Encoding"] != Deflate {
+		t.Fatalf("expected deflate body, got %v / %v", err, parser.RespHeader)
+	}
+	if data, _ := io.ReadAll(r); !bytes.Equal(data, payload) {
+		t.Fatal("deflate body does not round trip")
+	}
+}
+
+func TestMiddleware_Skips(t *testing.T) {
+	mw, _ := Middleware(Config{MinSize: 10})
+	payload := []byte(strings.Repeat("a", 500))
+
+	cases := map[string]struct {
+		headers     map[string]string
+		status      int
+		contentType string
+		body        []byte
+		respHeader  map[string]string
+		vary        string
+	}{
+		"no Accept-Encoding": {nil, http.StatusOK, "text/plain", payload, nil, "Accept-Encoding"},
+		"below min size":     {map[string]string{"Accept-Encoding": "gzip"}, http.StatusOK, "text/plain", []byte("tiny"), nil, ""},
+		"not allowlisted":    {map[string]string{"Accept-Encoding": "gzip"}, http.StatusOK, "image/png", payload, nil, ""},
+		"range request":      {map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-10"}, http.StatusOK, "text/plain", payload, nil, "Accept-Encoding"},
+		"partial content":    {map[string]string{"Accept-Encoding": "gzip"}, http.StatusPartialContent, "text/plain", payload, nil, ""},
+		"already encoded":    {map[string]string{"Accept-Encoding": "gzip"}, http.StatusOK, "text/plain", payload, map[string]string{"Content-Encoding": "br"}, ""},
+		"no-transform":       {map[string]string{"Accept-Encoding": "gzip"}, http.StatusOK, "text/plain", payload, map[string]string{"Cache-Control": "no-transform"}, ""},
+		"incompressible":     {map[string]string{"Accept-Encoding": "gzip"}, http.StatusOK, "text/plain", []byte("0123456789abcdef"), nil, "Accept-Encoding"},
+	}
+	for name, c := range cases {
+		ctx, parser := newRequest(c.headers)
+		send(t, mw, ctx, func(ctx *v2wf.RequestContext) error {
+			for k, v := range c.respHeader {
+				ctx.Parser.SetRespHeader(k, v)
+			}
+			return ctx.Parser.SendResponse(c.status, c.contentType, c.body)
+		})
+		if parser.RespHeader["Content-Encoding"] != c.respHeader["Content-Encoding"] || !bytes.Equal(parser.ResponseBody, c.body) {
+			t.Errorf("%s: expected uncompressed body, got headers %v", name, parser.RespHeader)
+		}
+		if parser.RespHeader["Vary"] != c.vary {
+			t.Errorf("%s: Vary = %q, want %q", name, parser.RespHeader["Vary"], c.vary)
+		}
+	}
+}
+
+func TestMiddleware_Config(t *testing.T) {
+	if _, err := Middleware(Config{Encodings: []string{"br"}}); err == nil {
+		t.Fatal("expected error for unsupported encoding")
+	}
+	if _, err := Middleware(Config{Level: 12}); err == nil {
+		t.Fatal("expected error for invalid level")
+	}
+
+	// Wrapping twice compresses once.
+	mw, _ := Middleware(Config{MinSize: 10})
+	ctx, parser := newRequest(map[string]string{"Accept-Encoding": "gzip"})
+	payload := []byte(strings.Repeat("a", 500))
+	send(t, mw, ctx, mw(sendJSON(payload)))
+	if !bytes.Equal(gunzip(t, parser.ResponseBody), payload) {
+		t.Fatal("expected a single gzip layer")
+	}
+}
+
+func netHTTPRequest(encoding string, body []byte) (*v2wf.RequestContext, *http.Request) {
+	req := httptest.NewRequest(http.MethodPost, "/items", bytes.NewReader(body))
+	req.Header.Set("Content-Encoding", encoding)
+	ctx, parser := newRequest(map[string]string{"Content-Encoding": encoding})
+	parser.Method = http.MethodPost
+	ctx.LegacyContext = legacyLibNetHttp.WithRequestResponse(context.Background(), req, httptest.NewRecorder())
+	return ctx, req
+}
+
+func TestDecompress(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	_, _ = zw.Write([]byte(`{"name":"a"}`))
+	_ = zw.Close()
+
+	ctx, req := netHTTPRequest("gzip", compressed.Bytes())
+	var got []byte
+	err := Decompress(DecompressConfig{})(func(*v2wf.RequestContext) error {
+		got, _ = io.ReadAll(req.Body)
+		return nil
+	})(ctx)
+	if err != nil || string(got) != `{"name":"a"}` {
+		t.Fatalf("unexpected body %q, err %v", got, err)
+	}
+	if req.Header.Get("Content-Encoding") != "" || req.ContentLength != int64(len(got)) {
+		t.Fatalf("expected Content-Encoding removed, got %v", req.Header)
+	}
+}
+
+func TestDecompress_Errors(t *testing.T) {
+	// A 1 MiB body of zeros compresses to about 1 KiB.
+	var bomb bytes.Buffer
+	zw := gzip.NewWriter(&bomb)
+	_, _ = zw.Write(make([]byte, 1<<20))
+	_ = zw.Close()
+
+	cases := map[string]struct {
+		encoding string
+		body     []byte
+		status   int
+	}{
+		"too large":   {"gzip", bomb.Bytes(), http.StatusRequestEntityTooLarge},
+		"unsupported": {"br", []byte("x"), http.StatusUnsupportedMediaType},
+		"corrupt":     {"deflate", []byte("not deflate"), http.StatusBadRequest},
+		"truncated":   {"gzip", bomb.Bytes()[:100], http.StatusBadRequest},
+	}
+	for name, c := range cases {
+		ctx, _ := netHTTPRequest(c.encoding, c.body)
+		called := false
+		err := Decompress(DecompressConfig{MaxSize: 64 << 10})(func(*v2wf.RequestContext) error {
+			called = true
+			return nil
+		})(ctx)
+		if called || v2response.DefaultStatusResolver(err) != c.status {
+			t.Errorf("%s: expected %d, got called=%v err=%v", name, c.status, called, err)
+		}
+	}
+}
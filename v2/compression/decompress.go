@@ -0,0 +1,117 @@
+package compression
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/hmmftg/requestCore/libError"
+	"github.com/hmmftg/requestCore/status"
+	"github.com/hmmftg/requestCore/v2/internal/native"
+	"github.com/hmmftg/requestCore/v2/routing"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+// DefaultMaxDecompressedSize is the default limit on a decompressed
+// request body: 10 MiB.
+const DefaultMaxDecompressedSize = 10 << 20
+
+// DecompressConfig configures Decompress.
+type DecompressConfig struct {
+	// MaxSize is the largest decompressed body, in bytes, a request may
+	// carry. Larger bodies fail with 413 (PAYLOAD_TOO_LARGE) without
+	// being fully inflated.
+	// Default: DefaultMaxDecompressedSize.
+	MaxSize int64
+
+	// Skipper, if set, exempts any request for which it returns true.
+	Skipper func(*v2wf.RequestContext) bool
+}
+
+// Decompress returns a routing.Middleware that decodes gzip and deflate
+// request bodies in place and removes their Content-Encoding header, so
+// handlers bind them as if they were sent uncompressed. Other codings
+// fail with 415 (UNSUPPORTED_ENCODING) and corrupt bodies with 400
+// (INVALID_ENCODING), through the response registry.
+func Decompress(config DecompressConfig) routing.Middleware {
+	if config.MaxSize <= 0 {
+		config.MaxSize = DefaultMaxDecompressedSize
+	}
+	return func(next routing.Handler) routing.Handler {
+		return func(ctx *v2wf.RequestContext) error {
+			if ctx.Parser == nil || (config.Skipper != nil && config.Skipper(ctx)) {
+				return next(ctx)
+			}
+			encoding := strings.ToLower(strings.TrimSpace(ctx.Parser.GetHeaderValue("Content-Encoding")))
+			if encoding == "" || encoding == "identity" {
+				return next(ctx)
+			}
+			req, ok := native.FromContext(ctx)
+			if !ok {
+				return unsupported(encoding)
+			}
+			decoded, err := decode(encoding, req.Body(), config.MaxSize)
+			if err != nil {
+				return err
+			}
+			req.DelHeader("Content-Encoding")
+			req.SetBody(decoded)
+			return next(ctx)
+		}
+	}
+}
+
+// decode inflates raw, reading at most limit+1 bytes of output.
+func decode(encoding string, raw io.Reader, limit int64) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	switch encoding {
+	case Gzip, "x-gzip":
+		r, err = gzip.NewReader(raw)
+	case Deflate:
+		r, err = zlib.NewReader(raw)
+	default:
+		return nil, unsupported(encoding)
+	}
+	if err != nil {
+		return nil, invalid(encoding, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, invalid(encoding, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, libError.NewWithDescription(
+			status.PayloadTooLarge,
+			"PAYLOAD_TOO_LARGE",
+			"decompressed request body exceeds %d bytes",
+			limit,
+		)
+	}
+	return data, nil
+}
+
+func unsupported(encoding string) error {
+	return libError.NewWithDescription(
+		status.UnsupportedMediaType,
+		"UNSUPPORTED_ENCODING",
+		"request content encoding %s is not supported",
+		encoding,
+	)
+}
+
+func invalid(encoding string, err error) error {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = errors.New("truncated body")
+	}
+	return libError.NewWithDescription(
+		status.BadRequest,
+		"INVALID_ENCODING",
+		"invalid %s request body: %v",
+		encoding,
+		err,
+	)
+}
@@ -0,0 +1,234 @@
+// Package conditional provides ETag and Last-Modified validators and
+// conditional request handling for v2 routing.
+//
+// Middleware attaches validators to successful responses: the version a
+// handler supplied with Set, or else, for GET and HEAD, a hash of the
+// rendered body. GET and HEAD requests whose If-None-Match or
+// If-Modified-Since show the client's copy is current are answered with
+// 304 Not Modified. Handlers of PUT, PATCH and DELETE call
+// CheckPreconditions with the resource's current validators before
+// changing it, which enforces If-Match and If-Unmodified-Since with 412
+// Precondition Failed for optimistic concurrency.
+//
+// Like compression.Middleware, Middleware works on the bodies written
+// through RequestParser.SendResponse, so it behaves the same on every
+// adapter. When both are used, register compression first so ETags are
+// computed on the uncompressed body.
+package conditional
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hmmftg/requestCore/libError"
+	"github.com/hmmftg/requestCore/status"
+	"github.com/hmmftg/requestCore/v2/routing"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+// validatorsKey is the parser local holding the validators set by Set.
+const validatorsKey = "_v2_conditional_validators"
+
+// Validators describe the current state of a resource.
+type Validators struct {
+	// Version is an opaque version of the resource, such as a revision
+	// number or an update stamp. It becomes the entity tag.
+	Version string
+
+	// Weak marks the entity tag as weak: representations with the same
+	// version are equivalent but not byte-identical.
+	Weak bool
+
+	// LastModified is when the resource last changed. Zero omits
+	// Last-Modified and date-based preconditions.
+	LastModified time.Time
+}
+
+// ETag returns the entity tag of v, or "" if it has no version.
+func (v Validators) ETag() string {
+	if v.Version == "" {
+		return ""
+	}
+	tag := `"` + strings.ReplaceAll(v.Version, `"`, "") + `"`
+	if v.Weak {
+		tag = "W/" + tag
+	}
+	return tag
+}
+
+// Set records the validators of the resource a handler responds with.
+// Middleware sends them as ETag and Last-Modified on a successful
+// response and, for GET and HEAD, uses them instead of hashing the body.
+func Set(ctx *v2wf.RequestContext, v Validators) {
+	if ctx == nil || ctx.Parser == nil {
+		return
+	}
+	ctx.Parser.SetLocal(validatorsKey, v)
+}
+
+// CheckPreconditions evaluates If-Match and, when absent,
+// If-Unmodified-Since against the current validators of the resource.
+// It returns a 412 libError (PRECONDITION_FAILED) when the client's copy
+// is out of date, and nil when the request carries no precondition.
+// current with an empty Version means the resource does not exist, which
+// fails "If-Match: *".
+func CheckPreconditions(ctx *v2wf.RequestContext, current Validators) error {
+	if ctx == nil || ctx.Parser == nil {
+		return nil
+	}
+	if ifMatch := ctx.Parser.GetHeaderValue("If-Match"); ifMatch != "" {
+		if !matchStrong(ifMatch, current.ETag()) {
+			return preconditionFailed("If-Match")
+		}
+		return nil
+	}
+	if since, ok := parseTime(ctx.Parser.GetHeaderValue("If-Unmodified-Since")); ok && !current.LastModified.IsZero() {
+		if current.LastModified.Truncate(time.Second).After(since) {
+			return preconditionFailed("If-Unmodified-Since")
+		}
+	}
+	return nil
+}
+
+func preconditionFailed(header string) error {
+	return libError.NewWithDescription(
+		status.PreconditionFailed,
+		"PRECONDITION_FAILED",
+		"resource was modified: %s does not match",
+		header,
+	)
+}
+
+// Config configures Middleware.
+type Config struct {
+	// Weak makes the entity tags computed from response bodies weak.
+	Weak bool
+
+	// RequireIfMatch rejects PUT, PATCH and DELETE requests that carry
+	// neither If-Match nor If-Unmodified-Since with 428
+	// (PRECONDITION_REQUIRED), so clients cannot overwrite changes they
+	// have not seen.
+	RequireIfMatch bool
+
+	// Skipper, if set, exempts any request for which it returns true.
+	Skipper func(*v2wf.RequestContext) bool
+}
+
+// Middleware returns a routing.Middleware that adds validators to
+// successful responses and answers conditional GET and HEAD requests.
+// Like compression.Middleware it replaces the request's parser, so it
+// must run before any middleware or handler that writes the response.
+func Middleware(config Config) routing.Middleware {
+	return func(next routing.Handler) routing.Handler {
+		return func(ctx *v2wf.RequestContext) error {
+			if ctx.Parser == nil || (config.Skipper != nil && config.Skipper(ctx)) {
+				return next(ctx)
+			}
+			if config.RequireIfMatch && unsafe(ctx.Parser.GetMethod()) &&
+				ctx.Parser.GetHeaderValue("If-Match") == "" && ctx.Parser.GetHeaderValue("If-Unmodified-Since") == "" {
+				return libError.NewWithDescription(
+					status.PreconditionRequired,
+					"PRECONDITION_REQUIRED",
+					"%s requires an If-Match header",
+					ctx.Parser.GetMethod(),
+				)
+			}
+			if _, ok := ctx.Parser.(*parser); !ok {
+				p := &parser{RequestParser: ctx.Parser, req: ctx, config: config}
+				ctx.Parser = p
+				ctx.Legacy.Parser = p
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// parser wraps a RequestParser to add validators in SendResponse.
+type parser struct {
+	v2wf.RequestParser
+	req    *v2wf.RequestContext
+	config Config
+}
+
+// SendResponse implements RequestParser.
+func (p *parser) SendResponse(code int, contentType string, body []byte) error {
+	if p.req.Committed() || code < http.StatusOK || code >= http.StatusMultipleChoices {
+		return p.RequestParser.SendResponse(code, contentType, body)
+	}
+	v, _ := p.GetLocal(validatorsKey).(Validators)
+	method := p.GetMethod()
+	cacheable := code == http.StatusOK && (method == http.MethodGet || method == http.MethodHead)
+	if v.Version == "" && cacheable {
+		sum := sha256.Sum256(body)
+		v.Version, v.Weak = hex.EncodeToString(sum[:16]), p.config.Weak
+	}
+
+	etag := v.ETag()
+	if etag != "" {
+		p.SetRespHeader("ETag", etag)
+	}
+	if !v.LastModified.IsZero() {
+		p.SetRespHeader("Last-Modified", v.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if cacheable && p.notModified(etag, v.LastModified) {
+		return p.RequestParser.SendResponse(http.StatusNotModified, "", nil)
+	}
+	return p.RequestParser.SendResponse(code, contentType, body)
+}
+
+// notModified evaluates If-None-Match and, when absent,
+// If-Modified-Since.
+func (p *parser) notModified(etag string, modified time.Time) bool {
+	if ifNoneMatch := p.GetHeaderValue("If-None-Match"); ifNoneMatch != "" {
+		return matchWeak(ifNoneMatch, etag)
+	}
+	since, ok := parseTime(p.GetHeaderValue("If-Modified-Since"))
+	return ok && !modified.IsZero() && !modified.Truncate(time.Second).After(since)
+}
+
+// unsafe reports whether method modifies the target resource.
+func unsafe(method string) bool {
+	return method == http.MethodPut || method == http.MethodPatch || method == http.MethodDelete
+}
+
+// matchWeak reports whether the If-None-Match list matches etag using
+// the weak comparison.
+func matchWeak(list, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchStrong reports whether the If-Match list matches etag using the
+// strong comparison: weak tags never match.
+func matchStrong(list, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || (tag == etag && !strings.HasPrefix(tag, "W/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTime parses an HTTP date.
+func parseTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	t, err := http.ParseTime(value)
+	return t, err == nil
+}
@@ -0,0 +1,183 @@
+package conditional
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	legacy "github.com/hmmftg/requestCore/webFramework"
+
+	"github.com/hmmftg/requestCore/v2/compression"
+	v2response "github.com/hmmftg/requestCore/v2/response"
+	"github.com/hmmftg/requestCore/v2/routing"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+func newRequest(method string, headers map[string]string) (*v2wf.RequestContext, *v2wf.FakeParserV2) {
+	parser := v2wf.NewFakeParserV2()
+	parser.Method = method
+	parser.Path = "/items/1"
+	for k, v := range headers {
+		parser.ReqHeader[k] = v
+	}
+	commit := &v2wf.CommitState{}
+	parser.SetCommitState(commit)
+	ctx := &v2wf.RequestContext{
+		Parser:  parser,
+		Context: context.Background(),
+		Legacy:  legacy.WebFramework{Parser: parser},
+	}
+	ctx.SetCommitState(commit)
+	return ctx, parser
+}
+
+func serve(t *testing.T, mw routing.Middleware, ctx *v2wf.RequestContext, v *Validators, body string) {
+	t.Helper()
+	err := mw(func(ctx *v2wf.RequestContext) error {
+		if v != nil {
+			Set(ctx, *v)
+		}
+		return ctx.Parser.SendResponse(http.StatusOK, "application/json", []byte(body))
+	})(ctx)
+	if err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+}
+
+func TestMiddleware_BodyETag(t *testing.T) {
+	mw := Middleware(Config{})
+	ctx, parser := newRequest(http.MethodGet, nil)
+	serve(t, mw, ctx, nil, `{"id":1}`)
+	etag := parser.RespHeader["ETag"]
+	if parser.ResponseStatus != http.StatusOK || len(etag) != 34 || !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("expected strong body ETag, got %d %q", parser.ResponseStatus, etag)
+	}
+
+	for _, inm := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		ctx, parser = newRequest(http.MethodGet, map[string]string{"If-None-Match": inm})
+		serve(t, mw, ctx, nil, `{"id":1}`)
+		if parser.ResponseStatus != http.StatusNotModified || len(parser.ResponseBody) != 0 || parser.RespHeader["ETag"] != etag {
+			t.Fatalf("If-None-Match %s: expected 304 with ETag, got %d", inm, parser.ResponseStatus)
+		}
+	}
+
+	ctx, parser = newRequest(http.MethodGet, map[string]string{"If-None-Match": etag})
+	serve(t, mw, ctx, nil, `{"id":2}`)
+	if parser.ResponseStatus != http.StatusOK {
+		t.Fatalf("expected 200 for a changed body, got %d", parser.ResponseStatus)
+	}
+
+	ctx, parser = newRequest(http.MethodGet, nil)
+	serve(t, Middleware(Config{Weak: true}), ctx, nil, `{"id":1}`)
+	if parser.RespHeader["ETag"] != "W/"+etag {
+		t.Fatalf("expected weak ETag, got %q", parser.RespHeader["ETag"])
+	}
+
+	// Unsafe methods get no computed ETag.
+	ctx, parser = newRequest(http.MethodPost, nil)
+	serve(t, mw, ctx, nil, `{"id":1}`)
+	if parser.RespHeader["ETag"] != "" {
+		t.Fatalf("unexpected ETag on POST: %q", parser.RespHeader["ETag"])
+	}
+}
+
+func TestMiddleware_Validators(t *testing.T) {
+	mw := Middleware(Config{})
+	modified := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	v := &Validators{Version: "7", LastModified: modified.Add(500 * time.Millisecond)}
+
+	ctx, parser := newRequest(http.MethodGet, nil)
+	serve(t, mw, ctx, v, `{}`)
+	if parser.RespHeader["ETag"] != `"7"` || parser.RespHeader["Last-Modified"] != "Sun, 01 Mar 2026 10:00:00 GMT" {
+		t.Fatalf("unexpected validators %v", parser.RespHeader)
+	}
+
+	cases := map[string]struct {
+		header map[string]string
+		want   int
+	}{
+		"modified since":     {map[string]string{"If-Modified-Since": "Sun, 01 Mar 2026 09:59:59 GMT"}, http.StatusOK},
+		"not modified since": {map[string]string{"If-Modified-Since": "Sun, 01 Mar 2026 10:00:00 GMT"}, http.StatusNotModified},
+		"etag wins":          {map[string]string{"If-None-Match": `"6"`, "If-Modified-Since": "Sun, 01 Mar 2026 10:00:00 GMT"}, http.StatusOK},
+		"invalid date":       {map[string]string{"If-Modified-Since": "yesterday"}, http.StatusOK},
+	}
+	for name, c := range cases {
+		ctx, parser := newRequest(http.MethodGet, c.header)
+		serve(t, mw, ctx, v, `{}`)
+		if parser.ResponseStatus != c.want {
+			t.Errorf("%s: expected %d, got %d", name, c.want, parser.ResponseStatus)
+		}
+	}
+
+	// Validators set by write handlers are sent on success.
+	ctx, parser = newRequest(http.MethodPut, map[string]string{"If-None-Match": `"7"`})
+	serve(t, mw, ctx, v, `{}`)
+	if parser.ResponseStatus != http.StatusOK || parser.RespHeader["ETag"] != `"7"` {
+		t.Fatalf("expected 200 with new ETag on PUT, got %d %v", parser.ResponseStatus, parser.RespHeader)
+	}
+}
+
+func TestMiddleware_RequireIfMatch(t *testing.T) {
+	mw := Middleware(Config{RequireIfMatch: true})
+	ctx, _ := newRequest(http.MethodDelete, nil)
+	err := mw(func(*v2wf.RequestContext) error { return nil })(ctx)
+	if v2response.DefaultStatusResolver(err) != http.StatusPreconditionRequired {
+		t.Fatalf("expected 428, got %v", err)
+	}
+	ctx, _ = newRequest(http.MethodGet, nil)
+	if err := mw(func(*v2wf.RequestContext) error { return nil })(ctx); err != nil {
+		t.Fatalf("GET should not require If-Match: %v", err)
+	}
+}
+
+func TestCheckPreconditions(t *testing.T) {
+	modified := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	current := Validators{Version: "7", LastModified: modified}
+	cases := map[string]struct {
+		header  map[string]string
+		current Validators
+		want    int
+	}{
+		"no precondition":    {nil, current, 0},
+		"matching":           {map[string]string{"If-Match": `"6", "7"`}, current, 0},
+		"stale":              {map[string]string{"If-Match": `"6"`}, current, http.StatusPreconditionFailed},
+		"weak never matches": {map[string]string{"If-Match": `W/"7"`}, current, http.StatusPreconditionFailed},
+		"weak current":       {map[string]string{"If-Match": `"7"`}, Validators{Version: "7", Weak: true}, http.StatusPreconditionFailed},
+		"any existing":       {map[string]string{"If-Match": "*"}, current, 0},
+		"any missing":        {map[string]string{"If-Match": "*"}, Validators{}, http.StatusPreconditionFailed},
+		"unmodified since":   {map[string]string{"If-Unmodified-Since": "Sun, 01 Mar 2026 10:00:00 GMT"}, current, 0},
+		"modified since":     {map[string]string{"If-Unmodified-Since": "Sun, 01 Mar 2026 09:00:00 GMT"}, current, http.StatusPreconditionFailed},
+		"If-Match first":     {map[string]string{"If-Match": `"7"`, "If-Unmodified-Since": "Sun, 01 Mar 2026 09:00:00 GMT"}, current, 0},
+	}
+	for name, c := range cases {
+		ctx, _ := newRequest(http.MethodPut, c.header)
+		err := CheckPreconditions(ctx, c.current)
+		if got := v2response.DefaultStatusResolver(err); (c.want == 0 && err != nil) || (c.want != 0 && got != c.want) {
+			t.Errorf("%s: expected %d, got %v", name, c.want, err)
+		}
+	}
+}
+
+func TestMiddleware_WithCompression(t *testing.T) {
+	compress, err := compression.Middleware(compression.Config{MinSize: 10})
+	if err != nil {
+		t.Fatalf("compression: %v", err)
+	}
+	body := `{"items":"` + strings.Repeat("a", 200) + `"}`
+	chain := func(next routing.Handler) routing.Handler { return compress(Middleware(Config{})(next)) }
+
+	ctx, parser := newRequest(http.MethodGet, map[string]string{"Accept-Encoding": "gzip"})
+	serve(t, chain, ctx, nil, body)
+	etag := parser.RespHeader["ETag"]
+	if parser.RespHeader["Content-Encoding"] != "gzip" || !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("expected weak ETag on the compressed response, got %v", parser.RespHeader)
+	}
+
+	ctx, parser = newRequest(http.MethodGet, map[string]string{"Accept-Encoding": "gzip", "If-None-Match": etag})
+	serve(t, chain, ctx, nil, body)
+	if parser.ResponseStatus != http.StatusNotModified {
+		t.Fatalf("expected the weak ETag to revalidate, got %d", parser.ResponseStatus)
+	}
+}
@@ -0,0 +1,278 @@
+// Package csrf provides a framework-neutral CSRF protection middleware for
+// v2 routing.
+//
+// Two strategies are supported. The double-submit cookie strategy issues a
+// random token in a cookie and requires unsafe requests to echo it in a
+// header or form field; it needs no server-side state. The session
+// strategy keeps the token in the v2 session (see
+// [github.com/hmmftg/requestCore/v2/session]) and therefore requires the
+// session middleware to run first.
+//
+// A rejected request fails with a 403 libError coded TokenMissing or
+// TokenMismatch and is routed through the v2 response registry like any
+// other handler error, so its message is localized through the error
+// descriptions.
+package csrf
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hmmftg/requestCore/libError"
+	"github.com/hmmftg/requestCore/status"
+
+	"github.com/hmmftg/requestCore/v2/routing"
+	"github.com/hmmftg/requestCore/v2/session"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+// Strategy selects where the expected token is kept.
+type Strategy int
+
+const (
+	// DoubleSubmit (default) keeps the token in a cookie readable by
+	// scripts and compares it with the submitted token.
+	DoubleSubmit Strategy = iota
+	// SessionBound keeps the token in the request's session.
+	SessionBound
+)
+
+const (
+	// DefaultCookieName is the default double-submit cookie name.
+	DefaultCookieName = "csrf_token"
+	// DefaultHeaderName is the default request header carrying the token.
+	DefaultHeaderName = "X-CSRF-Token"
+	// DefaultFormField is the default form field carrying the token.
+	DefaultFormField = "csrf_token"
+	// SessionKey is the session key holding the token in SessionBound mode.
+	SessionKey = "_csrf_token"
+	// TokenKey is the local storage key for the request's token on the
+	// request parser.
+	TokenKey = "_v2_csrf_token"
+)
+
+// Error codes of rejected requests. Seed them in the error descriptions
+// to localize the 403 response.
+const (
+	// TokenMissing is reported when an unsafe request carries no token
+	// or no token was issued to the client.
+	TokenMissing = "CSRF_TOKEN_MISSING"
+	// TokenMismatch is reported when the submitted token does not match.
+	TokenMismatch = "CSRF_TOKEN_MISMATCH"
+)
+
+var (
+	// ErrNoSession is returned in SessionBound mode when the session
+	// middleware did not run before the CSRF middleware. It is a
+	// configuration error and resolves to HTTP 500.
+	ErrNoSession = errors.New("csrf: session middleware is required for SessionBound mode")
+)
+
+// Config configures the CSRF middleware.
+type Config struct {
+	// Strategy selects double-submit cookie or session-bound tokens.
+	// Default: DoubleSubmit.
+	Strategy Strategy
+
+	// HeaderName is the request header checked for the token.
+	// Default: DefaultHeaderName.
+	HeaderName string
+
+	// FormField is the form field checked when the header is absent.
+	// Default: DefaultFormField.
+	FormField string
+
+	// CookieName is the double-submit cookie name.
+	// Default: DefaultCookieName.
+	CookieName string
+
+	// CookiePath is the double-submit cookie path.
+	// Default: "/".
+	CookiePath string
+
+	// CookieDomain is the optional double-submit cookie domain.
+	CookieDomain string
+
+	// SameSite is the SameSite attribute of the double-submit cookie.
+	// SameSiteNoneMode forces Secure, as browsers require.
+	// Default: http.SameSiteLaxMode.
+	SameSite http.SameSite
+
+	// Secure marks the double-submit cookie Secure.
+	Secure bool
+
+	// ExemptPaths lists request paths that skip validation, for example
+	// webhooks authenticated by signature. A trailing "*" matches any
+	// path with that prefix. Paths are compared with the parser's
+	// GetPath without the query string; note that the Gin adapter reports
+	// the route pattern (":id") rather than the concrete path.
+	ExemptPaths []string
+
+	// Skipper, if set, exempts any request for which it returns true.
+	Skipper func(*v2wf.RequestContext) bool
+}
+
+func (c Config) withDefaults() Config {
+	if c.HeaderName == "" {
+		c.HeaderName = DefaultHeaderName
+	}
+	if c.FormField == "" {
+		c.FormField = DefaultFormField
+	}
+	if c.CookieName == "" {
+		c.CookieName = DefaultCookieName
+	}
+	if c.CookiePath == "" {
+		c.CookiePath = "/"
+	}
+	if c.SameSite == 0 {
+		c.SameSite = http.SameSiteLaxMode
+	}
+	if c.SameSite == http.SameSiteNoneMode {
+		c.Secure = true
+	}
+	return c
+}
+
+// Middleware returns a routing.Middleware that issues a CSRF token on
+// every request and validates it on unsafe methods (anything but GET,
+// HEAD, OPTIONS and TRACE). The token is available to handlers through
+// Token, for example to render it into a form. Exempt requests are
+// passed through untouched: no token is issued or checked for them.
+func Middleware(config Config) routing.Middleware {
+	config = config.withDefaults()
+	return func(next routing.Handler) routing.Handler {
+		return func(ctx *v2wf.RequestContext) error {
+			if ctx.Parser == nil {
+				return next(ctx)
+			}
+			if config.exempt(ctx) {
+				return next(ctx)
+			}
+			expected, issued, err := config.issue(ctx)
+			if err != nil {
+				return err
+			}
+			ctx.Parser.SetLocal(TokenKey, expected)
+
+			if safeMethod(ctx.Parser.GetMethod()) {
+				return next(ctx)
+			}
+			if issued {
+				// The client cannot have submitted a token it was
+				// only just given.
+				return errTokenMissing()
+			}
+			submitted := ctx.Parser.GetHeaderValue(config.HeaderName)
+			if submitted == "" {
+				submitted = ctx.Parser.FormValue(config.FormField)
+			}
+			if err := validate(expected, submitted); err != nil {
+				return err
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// Token returns the CSRF token issued for the request, or "" if the CSRF
+// middleware did not run.
+func Token(ctx *v2wf.RequestContext) string {
+	if ctx == nil || ctx.Parser == nil {
+		return ""
+	}
+	return ctx.Parser.GetLocalString(TokenKey)
+}
+
+// issue returns the token the client must submit and reports whether it
+// was created for this request because the client had none yet.
+func (c Config) issue(ctx *v2wf.RequestContext) (string, bool, error) {
+	if c.Strategy == SessionBound {
+		sess := session.FromContext(ctx)
+		if sess == nil {
+			if s, ok := ctx.Session.(*session.Session); ok {
+				sess = s
+			}
+		}
+		if sess == nil {
+			return "", false, ErrNoSession
+		}
+		if token := sess.GetString(SessionKey); token != "" {
+			return token, false, nil
+		}
+		token := newToken()
+		sess.Set(SessionKey, token)
+		return token, true, nil
+	}
+
+	if token := ctx.Parser.GetCookie(c.CookieName); token != "" {
+		return token, false, nil
+	}
+	token := newToken()
+	ctx.Parser.SetCookie(&http.Cookie{
+		Name:     c.CookieName,
+		Value:    token,
+		Path:     c.CookiePath,
+		Domain:   c.CookieDomain,
+		Secure:   c.Secure,
+		SameSite: c.SameSite,
+		// Scripts must read the cookie to echo it in the header.
+		HttpOnly: false,
+	})
+	return token, true, nil
+}
+
+func (c Config) exempt(ctx *v2wf.RequestContext) bool {
+	if c.Skipper != nil && c.Skipper(ctx) {
+		return true
+	}
+	path := ctx.Parser.GetPath()
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	for _, p := range c.ExemptPaths {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == p {
+			return true
+		}
+	}
+	return false
+}
+
+func validate(expected, submitted string) error {
+	if expected == "" || submitted == "" {
+		return errTokenMissing()
+	}
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(submitted)) != 1 {
+		return libError.NewWithDescription(status.Forbidden, TokenMismatch, "csrf: token mismatch")
+	}
+	return nil
+}
+
+func errTokenMissing() error {
+	return libError.NewWithDescription(status.Forbidden, TokenMissing, "csrf: token missing")
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func newToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("csrf: crypto/rand failed: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
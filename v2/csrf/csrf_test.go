@@ -0,0 +1,164 @@
+package csrf
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/hmmftg/requestCore/libError"
+	"github.com/hmmftg/requestCore/status"
+
+	v2response "github.com/hmmftg/requestCore/v2/response"
+	"github.com/hmmftg/requestCore/v2/routing"
+	"github.com/hmmftg/requestCore/v2/session"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+func newRequest(method, path string) (*v2wf.RequestContext, *v2wf.FakeParserV2) {
+	parser := v2wf.NewFakeParserV2()
+	parser.Method = method
+	parser.Path = path
+	commit := &v2wf.CommitState{}
+	parser.SetCommitState(commit)
+	ctx := &v2wf.RequestContext{Parser: parser, Context: context.Background()}
+	ctx.SetCommitState(commit)
+	return ctx, parser
+}
+
+func run(mw routing.Middleware, ctx *v2wf.RequestContext) (bool, error) {
+	called := false
+	err := mw(func(*v2wf.RequestContext) error {
+		called = true
+		return nil
+	})(ctx)
+	return called, err
+}
+
+func TestMiddleware_DoubleSubmit(t *testing.T) {
+	mw := Middleware(Config{ExemptPaths: []string{"/hooks/*"}})
+
+	// A safe request issues the cookie and exposes the token.
+	ctx, parser := newRequest(http.MethodGet, "/form")
+	if called, err := run(mw, ctx); err != nil || !called {
+		t.Fatalf("GET: called=%v err=%v", called, err)
+	}
+	if len(parser.SetCookies) != 1 || parser.SetCookies[0].Name != DefaultCookieName {
+		t.Fatalf("expected token cookie, got %+v", parser.SetCookies)
+	}
+	cookie := parser.SetCookies[0]
+	if cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || Token(ctx) != cookie.Value {
+		t.Fatalf("unexpected cookie %+v token %q", cookie, Token(ctx))
+	}
+	token := cookie.Value
+
+	// The token echoed in the header or a form field is accepted.
+	ctx, parser = newRequest(http.MethodPost, "/form")
+	parser.Cookies[DefaultCookieName] = token
+	parser.ReqHeader[DefaultHeaderName] = token
+	if called, err := run(mw, ctx); err != nil || !called || len(parser.SetCookies) != 0 {
+		t.Fatalf("POST with header: called=%v err=%v", called, err)
+	}
+	ctx, parser = newRequest(http.MethodPost, "/form")
+	parser.Cookies[DefaultCookieName] = token
+	parser.Args[DefaultFormField] = token
+	if called, err := run(mw, ctx); err != nil || !called {
+		t.Fatalf("POST with form field: called=%v err=%v", called, err)
+	}
+
+	cases := []struct {
+		name   string
+		cookie string
+		header string
+		want   string
+	}{
+		{"no cookie", "", token, TokenMissing},
+		{"no token", token, "", TokenMissing},
+		{"mismatch", token, "forged", TokenMismatch},
+	}
+	for _, tc := range cases {
+		ctx, parser = newRequest(http.MethodDelete, "/form")
+		if tc.cookie != "" {
+			parser.Cookies[DefaultCookieName] = tc.cookie
+		}
+		parser.ReqHeader[DefaultHeaderName] = tc.header
+		called, err := run(mw, ctx)
+		var libErr libError.Error
+		if called || !errors.As(err, &libErr) || libErr.Action().Status != status.Forbidden || libErr.Action().Description != tc.want {
+			t.Fatalf("%s: called=%v err=%v", tc.name, called, err)
+		}
+	}
+
+	// Exempt paths skip validation.
+	ctx, parser = newRequest(http.MethodPost, "/hooks/payment?x=1")
+	if called, err := run(mw, ctx); err != nil || !called || len(parser.SetCookies) != 0 || parser.GetLocal(TokenKey) != nil {
+		t.Fatalf("exempt: called=%v err=%v cookies=%+v", called, err, parser.SetCookies)
+	}
+}
+
+func TestMiddleware_SessionBound(t *testing.T) {
+	store := session.NewMemoryStore(session.MemoryStoreConfig{})
+	sess := session.NewSession(store)
+	mw := Middleware(Config{Strategy: SessionBound})
+
+	ctx, parser := newRequest(http.MethodGet, "/form")
+	parser.SetLocal(session.SessionKey, sess)
+	if _, err := run(mw, ctx); err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	token := Token(ctx)
+	if token == "" || sess.GetString(SessionKey) != token || len(parser.SetCookies) != 0 {
+		t.Fatalf("expected session token, got %q", token)
+	}
+
+	ctx, parser = newRequest(http.MethodPost, "/form")
+	parser.SetLocal(session.SessionKey, sess)
+	parser.ReqHeader[DefaultHeaderName] = token
+	if called, err := run(mw, ctx); err != nil || !called {
+		t.Fatalf("POST: called=%v err=%v", called, err)
+	}
+
+	ctx, _ = newRequest(http.MethodPost, "/form")
+	if _, err := run(mw, ctx); !errors.Is(err, ErrNoSession) {
+		t.Fatalf("expected ErrNoSession, got %v", err)
+	}
+}
+
+func TestMiddleware_SessionBoundExempt(t *testing.T) {
+	mw := Middleware(Config{Strategy: SessionBound, ExemptPaths: []string{"/hooks/*"}})
+
+	// Exempt routes need no session middleware.
+	ctx, _ := newRequest(http.MethodPost, "/hooks/payment")
+	if called, err := run(mw, ctx); err != nil || !called {
+		t.Fatalf("exempt without session: called=%v err=%v", called, err)
+	}
+
+	// An existing session is left untouched.
+	store := session.NewMemoryStore(session.MemoryStoreConfig{})
+	sess := session.NewSession(store)
+	ctx, parser := newRequest(http.MethodGet, "/hooks/payment")
+	parser.SetLocal(session.SessionKey, sess)
+	if called, err := run(mw, ctx); err != nil || !called || sess.GetString(SessionKey) != "" {
+		t.Fatalf("exempt with session: called=%v err=%v token=%q", called, err, sess.GetString(SessionKey))
+	}
+}
+
+func TestError_RoutedThroughRegistry(t *testing.T) {
+	registry := v2response.NewRegistry(nil)
+	for status, h := range v2response.DefaultErrorHandlers() {
+		if err := registry.Register(status, h); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	}
+	ctx, parser := newRequest(http.MethodPost, "/form")
+	_, err := run(Middleware(Config{}), ctx)
+	if status := registry.Resolve(err); status != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", status)
+	}
+	if err := registry.Handle(ctx, err); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if parser.ResponseStatus != http.StatusForbidden {
+		t.Fatalf("expected 403 response, got %d", parser.ResponseStatus)
+	}
+}
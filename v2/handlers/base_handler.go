@@ -10,6 +10,7 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 
+	"github.com/hmmftg/requestCore/libAuthz"
 	"github.com/hmmftg/requestCore/libContext"
 	"github.com/hmmftg/requestCore/libError"
 	"github.com/hmmftg/requestCore/libLogger"
@@ -62,6 +63,7 @@ func buildEndpointHandler(
 			ctx.Legacy.Parser = w.Parser
 		}
 		libContext.AddWebLogs(w, endpoint.Title, legacy.HandlerLogTag)
+		v2response.Offer(ctx, endpoint.Produces...)
 
 		// Initialize tracing if enabled. The span is ended via defer
 		// so it always completes, even on panic.
@@ -140,6 +142,21 @@ func runLifecycle(
 	requestInserted *bool,
 	start time.Time,
 ) error {
+	// Enforce the endpoint's roles and permissions before anything of the
+	// request is bound.
+	if requirement := endpoint.Requirement(); !requirement.IsZero() {
+		decision, errAuthz := libAuthz.Check(ctx.Context, w.Parser, endpoint.Title, requirement)
+		legacy.AddLog(w, legacy.HandlerLogTag, slog.Any("authorization", decision))
+		if errAuthz != nil {
+			if wErr := respondErrorV2(respHandler, w, ctx, trxCarrier, endpoint.Title, errAuthz); wErr != nil {
+				legacy.AddLog(w, endpoint.Title+"-req-failed", slog.Any("write-error", wErr))
+				return wErr
+			}
+			legacy.AddLog(w, endpoint.Title+"-req-failed", slog.Any("error", errAuthz))
+			return errAuthz
+		}
+	}
+
 	// Parse the request through the root libRequest.ParseRequest using
 	// the typed closure captured in the endpoint.
 	reqPtr, header, errParse := trxCarrier.parseRequest(w)
@@ -344,10 +361,17 @@ func respondErrorV2(respHandler *v2response.Handler, w legacy.WebFramework, ctx
 	return nil
 }
 
-// respondOKV2 renders a successful response through the v2 responder and
-// records the outcome. It returns any transport write or renderer error.
+// respondOKV2 renders a successful response through the v2 responder, or
+// sends it as a download if it is a *response.FileResponse, and records
+// the outcome. It returns any transport write or renderer error.
 func respondOKV2(respHandler *v2response.Handler, w legacy.WebFramework, ctx *v2wf.RequestContext, trxCarrier *endpointTrxCarrier, title string, resp any) error {
-	if wErr := respHandler.OK(ctx, resp); wErr != nil {
+	send := func() error { return respHandler.OK(ctx, resp) }
+	if attachment, ok := resp.(*response.FileResponse); ok && attachment != nil {
+		// Endpoints returning a *response.FileResponse download the file,
+		// like v1 handlers with HandlerParameters.FileResponse.
+		send = func() error { return respHandler.OKWithAttachment(ctx, attachment) }
+	}
+	if wErr := send(); wErr != nil {
 		// Renderer encode or transport write failed.
 		legacy.AddLog(w, title+"-req-failed", slog.Any("write-error", wErr))
 		trxCarrier.setOutcome(wErr, http.StatusInternalServerError)
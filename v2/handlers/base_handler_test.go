@@ -12,6 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.opentelemetry.io/otel/trace"
 
+	"github.com/hmmftg/requestCore/libAuthz"
 	"github.com/hmmftg/requestCore/libRequest"
 	"github.com/hmmftg/requestCore/response"
 	v2libGin "github.com/hmmftg/requestCore/v2/libGin"
@@ -592,3 +593,54 @@ func TestEndpoint_OutcomeOnError(t *testing.T) {
 		t.Fatalf("expected 500, got %d", outcome.HTTPStatus)
 	}
 }
+
+// TestEndpoint_Roles verifies that endpoint roles are enforced before the
+// handler runs and that denials render a 403.
+func TestEndpoint_Roles(t *testing.T) {
+	engine := gin.New()
+	router := v2libGin.NewRouter(engine)
+	respHandler := testRespHandler()
+
+	var audit []libAuthz.Decision
+	a, err := libAuthz.New(libAuthz.Config{
+		RoleMap:  map[string]string{"teller": "transfers.create"},
+		Resolver: libAuthz.HeaderResolver("User-Roles"),
+		Audit:    func(d libAuthz.Decision) { audit = append(audit, d) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	libAuthz.SetDefault(a)
+	t.Cleanup(func() { libAuthz.SetDefault(nil) })
+
+	called := 0
+	e := NewEndpoint[struct{}, TestResp]("test-roles", libRequest.NoBinding,
+		func(req *struct{}, trx *HandlerRequest[struct{}, TestResp]) (TestResp, error) {
+			called++
+			return TestResp{Status: "ok"}, nil
+		},
+	).WithRoles("teller", "admin").WithPermissions("transfers.create")
+	if err := RegisterEndpoint(router, nil, respHandler, "GET", "/roles", e); err != nil {
+		t.Fatalf("RegisterEndpoint: %v", err)
+	}
+
+	for _, tt := range []struct {
+		roles  string
+		status int
+	}{
+		{"teller", http.StatusOK},
+		{"auditor", http.StatusForbidden},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/roles", nil)
+		req.Header.Set("User-Id", "u1")
+		req.Header.Set("User-Roles", tt.roles)
+		engine.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Fatalf("roles %s: expected %d, got %d: %s", tt.roles, tt.status, w.Code, w.Body.String())
+		}
+	}
+	if called != 1 || len(audit) != 2 || audit[1].Handler != "test-roles" || audit[1].Allowed {
+		t.Fatalf("unexpected handler calls %d or audit %+v", called, audit)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/hmmftg/requestCore"
+	"github.com/hmmftg/requestCore/libAuthz"
 	"github.com/hmmftg/requestCore/libRequest"
 	legacy "github.com/hmmftg/requestCore/webFramework"
 
@@ -27,6 +28,10 @@ import (
 // it into a routing.RouteGroup with the full BaseHandler lifecycle.
 type Endpoint struct {
 	Title           string
+	Description     string
+	Tags            []string
+	Deprecated      bool
+	Produces        []string
 	Path            string
 	Body            libRequest.Type
 	ValidateHeader  bool
@@ -35,6 +40,11 @@ type Endpoint struct {
 	EnableTracing   bool
 	TracingSpanName string
 
+	// Roles lists roles of which the caller needs at least one and
+	// Permissions the permissions the caller needs all of; see libAuthz.
+	Roles       []string
+	Permissions []string
+
 	// run executes the typed handler and is set by NewEndpoint. It
 	// receives a fully-initialized HandlerRequest and returns the typed
 	// response and error. The closure captures the Req/Resp types.
@@ -179,6 +189,63 @@ func (e *Endpoint) WithPath(path string) *Endpoint {
 	return e
 }
 
+// WithDescription sets the long description shown in API documentation.
+func (e *Endpoint) WithDescription(description string) *Endpoint {
+	e.Description = description
+	return e
+}
+
+// WithTags sets the API documentation tags used to group the endpoint.
+func (e *Endpoint) WithTags(tags ...string) *Endpoint {
+	e.Tags = append(e.Tags, tags...)
+	return e
+}
+
+// MarkDeprecated flags the endpoint as deprecated in API documentation.
+func (e *Endpoint) MarkDeprecated() *Endpoint {
+	e.Deprecated = true
+	return e
+}
+
+// WithProduces restricts the media types the endpoint's responses may be
+// negotiated to, for example "text/csv" only for an export endpoint. It
+// takes effect when the response renderer is a renderers.Negotiator and is
+// listed as the response content types in API documentation.
+func (e *Endpoint) WithProduces(mediaTypes ...string) *Endpoint {
+	e.Produces = append(e.Produces, mediaTypes...)
+	return e
+}
+
+// WithRoles requires the caller to have at least one of roles. It is
+// enforced before the request is parsed, through the authorizer of the
+// authz middleware or the libAuthz default.
+func (e *Endpoint) WithRoles(roles ...string) *Endpoint {
+	e.Roles = append(e.Roles, roles...)
+	return e
+}
+
+// WithPermissions requires the caller's roles to grant all of
+// permissions.
+func (e *Endpoint) WithPermissions(permissions ...string) *Endpoint {
+	e.Permissions = append(e.Permissions, permissions...)
+	return e
+}
+
+// Requirement returns the access the endpoint requires.
+func (e *Endpoint) Requirement() libAuthz.Requirement {
+	return libAuthz.Requirement{Roles: e.Roles, Permissions: e.Permissions}
+}
+
+// RequestType returns the Req type parameter passed to NewEndpoint.
+func (e *Endpoint) RequestType() reflect.Type {
+	return e.reqType
+}
+
+// ResponseType returns the Resp type parameter passed to NewEndpoint.
+func (e *Endpoint) ResponseType() reflect.Type {
+	return e.respType
+}
+
 // WithHeaderValidation enables request header validation.
 func (e *Endpoint) WithHeaderValidation() *Endpoint {
 	e.ValidateHeader = true
@@ -280,7 +347,7 @@ func RegisterEndpoint(
 		path = endpoint.Path
 	}
 	h := buildEndpointHandler(core, respHandler, endpoint, args)
-	return router.Handle(method, path, h)
+	return routing.HandleWithMetadata(router, method, path, h, endpoint)
 }
 
 // GetEndpoint registers a GET endpoint.
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/hmmftg/requestCore/libError"
+	"github.com/hmmftg/requestCore/libQuery"
+	"github.com/hmmftg/requestCore/libRequest"
+	"github.com/hmmftg/requestCore/status"
+
+	"github.com/hmmftg/requestCore/v2/renderers"
+	v2response "github.com/hmmftg/requestCore/v2/response"
+)
+
+// StreamRows sends rows as the endpoint's response, encoding each row as
+// it is read (see v2response.Stream), and closes rows. Return its error
+// from the handler:
+//
+//	return nil, handlers.StreamRows(trx, renderers.NDJSONRenderer{}, rows)
+//
+// Errors before the response is committed, such as a failed query or an
+// unacceptable Accept header, go through the endpoint's error handling
+// like any handler error; the value the handler returns is not sent.
+// renderer is negotiated against the endpoint's Produces types; nil
+// means JSON.
+func StreamRows[Req, Resp, Row any](trx *HandlerRequest[Req, Resp], renderer renderers.Renderer, rows v2response.Rows[Row]) error {
+	return v2response.Stream(nil, trx.V2, http.StatusOK, renderer, rows)
+}
+
+// NewQueryStreamEndpoint creates an Endpoint that runs command and
+// streams its rows with renderer instead of materialising them like the
+// v1 QueryHandler. The query arguments are the request fields whose form
+// tags are listed in command.Args, and the query is cancelled when the
+// client disconnects. The endpoint's response type is []Row.
+func NewQueryStreamEndpoint[Req, Row any](
+	title string,
+	body libRequest.Type,
+	command libQuery.QueryCommand,
+	renderer renderers.Renderer,
+) *Endpoint {
+	return NewEndpoint(title, body, func(req *Req, trx *HandlerRequest[Req, []Row]) ([]Row, error) {
+		args := make([]any, 0, len(command.Args))
+		for _, arg := range command.Args {
+			name, _ := arg.(string)
+			_, val, err := libQuery.GetFormTagValue(name, req)
+			if err != nil {
+				return nil, errors.Join(err, libError.NewWithDescription(
+					status.InternalServerError,
+					"COMMAND_ARGUMENT_ERROR",
+					"command argument error: %s", command.Name,
+				))
+			}
+			args = append(args, *val)
+		}
+		db := trx.Core.GetDB()
+		rows, err := libQuery.QueryRows[Row](trx.V2.Context, db, command.GetCommand(db.GetDbMode()), args...)
+		if err != nil {
+			return nil, err
+		}
+		return nil, StreamRows(trx, renderer, rows)
+	})
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+
+	"github.com/hmmftg/requestCore"
+	"github.com/hmmftg/requestCore/libQuery"
+	"github.com/hmmftg/requestCore/libRequest"
+	v2libGin "github.com/hmmftg/requestCore/v2/libGin"
+	"github.com/hmmftg/requestCore/v2/renderers"
+)
+
+type txFilter struct {
+	Account string `form:"account"`
+}
+
+type txRow struct {
+	ID     int    `db:"id" json:"id"`
+	Amount string `db:"amount" json:"amount"`
+}
+
+// TestQueryStreamEndpoint verifies that query rows are streamed with the
+// request's arguments and that a failing query is an ordinary error
+// response.
+func TestQueryStreamEndpoint(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer db.Close()
+	core := requestCore.RequestCoreModel{QueryInterface: libQuery.QueryRunnerModel{DB: db}}
+
+	engine := gin.New()
+	router := v2libGin.NewRouter(engine)
+	endpoint := NewQueryStreamEndpoint[txFilter, txRow]("export", libRequest.Query,
+		libQuery.QueryCommand{Name: "transactions", Command: "select id, amount from tx where account = ?", Args: []any{"account"}},
+		renderers.NDJSONRenderer{})
+	if err := RegisterEndpoint(router, core, testRespHandler(), http.MethodGet, "/export", endpoint); err != nil {
+		t.Fatalf("RegisterEndpoint: %v", err)
+	}
+
+	mock.ExpectPrepare("select id, amount from tx").ExpectQuery().WithArgs("a1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "amount"}).AddRow(1, "10").AddRow(2, "20"))
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export?account=a1", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("expected NDJSON 200, got %d %v", w.Code, w.Header())
+	}
+	if got := w.Body.String(); got != "{\"id\":1,\"amount\":\"10\"}\n{\"id\":2,\"amount\":\"20\"}\n" {
+		t.Fatalf("unexpected body %q", got)
+	}
+
+	mock.ExpectPrepare("select id, amount from tx").ExpectQuery().WillReturnError(errors.New("database is down"))
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export?account=a1", nil))
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Type") == "application/x-ndjson" {
+		t.Fatalf("expected an error response, got %d %v", w.Code, w.Header())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
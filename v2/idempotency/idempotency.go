@@ -0,0 +1,310 @@
+// Package idempotency provides an Idempotency-Key middleware for v2
+// routing, so clients can safely retry non-idempotent requests such as
+// POSTs on flaky mobile networks.
+//
+// The first request with a key locks it (through a locks.Locker, so
+// concurrent retries on other replicas wait their turn), runs the
+// handler once and stores the response's status, headers and body in a
+// Store. Repeats with the same key replay the stored response with an
+// Idempotent-Replayed header; repeats whose method, path or body differ
+// fail with 409. Keys are scoped to the caller named by the User-Id
+// header, so one user cannot replay another's response. Unlike libRequest.CheckDuplicateRequest, which rejects
+// every duplicate Request-Id with an error, the client gets the original
+// outcome back.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/hmmftg/requestCore/libError"
+	"github.com/hmmftg/requestCore/status"
+	"github.com/hmmftg/requestCore/v2/internal/native"
+	"github.com/hmmftg/requestCore/v2/locks"
+	"github.com/hmmftg/requestCore/v2/routing"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+// Header names.
+const (
+	// HeaderKey is the default request header carrying the key.
+	HeaderKey = "Idempotency-Key"
+	// HeaderRequestID is the header reused as key with UseRequestID.
+	HeaderRequestID = "Request-Id"
+	// HeaderReplayed marks replayed responses.
+	HeaderReplayed = "Idempotent-Replayed"
+	// HeaderUserID is the default header identifying the caller.
+	HeaderUserID = "User-Id"
+)
+
+// Defaults.
+const (
+	// DefaultTTL is how long responses are kept for replay.
+	DefaultTTL = 24 * time.Hour
+	// DefaultMaxResponseSize is the largest response body stored.
+	DefaultMaxResponseSize = 1 << 20
+	// DefaultMaxRequestSize is the largest keyed request body read for
+	// the fingerprint.
+	DefaultMaxRequestSize = 4 << 20
+)
+
+// Record is a stored response.
+type Record struct {
+	// Key is the idempotency key, scoped to the caller.
+	Key string
+	// Fingerprint identifies the request the key was first used with.
+	Fingerprint string
+	// Status, ContentType, Headers and Body describe the response.
+	Status      int
+	ContentType string
+	Headers     map[string]string
+	Body        []byte
+	// Expires is when the record may be discarded.
+	Expires time.Time
+}
+
+// Config configures Middleware.
+type Config struct {
+	// Store holds the recorded responses.
+	// Default: a new MemoryStore.
+	Store Store
+
+	// Locker serializes requests with the same key.
+	// Default: a new locks.MemoryLocker.
+	Locker locks.Locker
+
+	// Header is the request header carrying the key.
+	// Default: HeaderKey.
+	Header string
+
+	// UseRequestID falls back to the Request-Id header when Header is
+	// absent.
+	UseRequestID bool
+
+	// UserHeader is the request header identifying the caller. Keys are
+	// scoped to its value, so callers sending the same key do not share
+	// responses. Run jwtauth.Middleware first so the header comes from
+	// the verified token rather than the client.
+	// Default: HeaderUserID.
+	UserHeader string
+
+	// Required rejects requests without a key with 400
+	// (IDEMPOTENCY_KEY_REQUIRED) instead of running them unprotected.
+	Required bool
+
+	// Methods lists the methods the middleware applies to.
+	// Default: POST and PATCH.
+	Methods []string
+
+	// TTL is how long responses are kept for replay.
+	// Default: DefaultTTL.
+	TTL time.Duration
+
+	// MaxResponseSize is the largest response body recorded; larger
+	// responses are sent but not recorded, so repeats run again.
+	// Default: DefaultMaxResponseSize.
+	MaxResponseSize int
+
+	// MaxRequestSize is the largest request body of a keyed request, in
+	// bytes. The body is buffered to fingerprint it, so larger bodies
+	// fail with 413 (IDEMPOTENCY_BODY_TOO_LARGE) before the handler runs.
+	// Default: DefaultMaxRequestSize.
+	MaxRequestSize int64
+
+	// Skipper, if set, exempts any request for which it returns true.
+	Skipper func(*v2wf.RequestContext) bool
+
+	// Clock is the clock source for deterministic testing.
+	// If nil, time.Now is used.
+	Clock func() time.Time
+}
+
+// Middleware returns a routing.Middleware that runs each keyed request
+// once and replays its response for repeats. Responses with a 5xx status
+// and responses of handlers that wrote nothing are not recorded, so the
+// client can retry them.
+//
+// The middleware replaces the request's parser to capture the response,
+// so it must run before any middleware or handler that writes it.
+func Middleware(config Config) routing.Middleware {
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if config.Locker == nil {
+		config.Locker = locks.NewMemoryLocker()
+	}
+	if config.Header == "" {
+		config.Header = HeaderKey
+	}
+	if config.UserHeader == "" {
+		config.UserHeader = HeaderUserID
+	}
+	if len(config.Methods) == 0 {
+		config.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	if config.TTL <= 0 {
+		config.TTL = DefaultTTL
+	}
+	if config.MaxResponseSize <= 0 {
+		config.MaxResponseSize = DefaultMaxResponseSize
+	}
+	if config.MaxRequestSize <= 0 {
+		config.MaxRequestSize = DefaultMaxRequestSize
+	}
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+
+	return func(next routing.Handler) routing.Handler {
+		return func(ctx *v2wf.RequestContext) error {
+			if ctx.Parser == nil || (config.Skipper != nil && config.Skipper(ctx)) ||
+				!slices.Contains(config.Methods, ctx.Parser.GetMethod()) {
+				return next(ctx)
+			}
+			key := ctx.Parser.GetHeaderValue(config.Header)
+			if key == "" && config.UseRequestID {
+				key = ctx.Parser.GetHeaderValue(HeaderRequestID)
+			}
+			if key == "" {
+				if config.Required {
+					return libError.NewWithDescription(
+						status.BadRequest,
+						"IDEMPOTENCY_KEY_REQUIRED",
+						"%s requires an %s header",
+						ctx.Parser.GetMethod(),
+						config.Header,
+					)
+				}
+				return next(ctx)
+			}
+			fingerprint, err := fingerprint(ctx, config.MaxRequestSize)
+			if err != nil {
+				return err
+			}
+
+			// Escaping the caller keeps the first ':' as the separator.
+			scoped := url.QueryEscape(ctx.Parser.GetHeaderValue(config.UserHeader)) + ":" + key
+
+			c := ctx.ContextOrBackground()
+			lock, ok, err := config.Locker.TryAcquire(c, "idempotency:"+scoped)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				ctx.Parser.SetRespHeader("Retry-After", "1")
+				return libError.NewWithDescription(
+					status.Conflict,
+					"IDEMPOTENCY_IN_PROGRESS",
+					"a request with idempotency key %s is in progress",
+					key,
+				)
+			}
+			defer func() {
+				if err := lock.Release(context.WithoutCancel(c)); err != nil {
+					slog.Error("idempotency: release failed", slog.String("key", key), slog.Any("error", err))
+				}
+			}()
+
+			record, found, err := config.Store.Load(c, scoped, config.Clock())
+			if err != nil {
+				return err
+			}
+			if found {
+				if record.Fingerprint != fingerprint {
+					return libError.NewWithDescription(
+						status.Conflict,
+						"IDEMPOTENCY_KEY_REUSED",
+						"idempotency key %s was used with a different request",
+						key,
+					)
+				}
+				return replay(ctx, record)
+			}
+
+			capture := &parser{RequestParser: ctx.Parser, req: ctx, headers: map[string]string{}}
+			ctx.Parser = capture
+			ctx.Legacy.Parser = capture
+			err = next(ctx)
+			if capture.status == 0 || capture.status >= http.StatusInternalServerError {
+				return err
+			}
+			if len(capture.body) > config.MaxResponseSize {
+				slog.Warn("idempotency: response too large to record", slog.String("key", key), slog.Int("size", len(capture.body)))
+				return err
+			}
+			record = Record{
+				Key:         scoped,
+				Fingerprint: fingerprint,
+				Status:      capture.status,
+				ContentType: capture.contentType,
+				Headers:     capture.headers,
+				Body:        capture.body,
+				Expires:     config.Clock().Add(config.TTL),
+			}
+			if saveErr := config.Store.Save(context.WithoutCancel(c), record); saveErr != nil {
+				slog.Error("idempotency: save failed", slog.String("key", key), slog.Any("error", saveErr))
+			}
+			return err
+		}
+	}
+}
+
+// fingerprint hashes the method, path and raw body of the request. The
+// body is left readable for the handler. When the framework request is
+// unavailable only the method and path are hashed.
+func fingerprint(ctx *v2wf.RequestContext, limit int64) (string, error) {
+	body, _, err := native.ReadBody(ctx, limit)
+	if errors.Is(err, native.ErrBodyTooLarge) {
+		return "", libError.NewWithDescription(status.PayloadTooLarge, "IDEMPOTENCY_BODY_TOO_LARGE",
+			"keyed request bodies are limited to %d bytes", limit)
+	}
+	if err != nil {
+		return "", libError.NewWithDescription(status.BadRequest, "INVALID_BODY", "read request body: %v", err)
+	}
+	h := sha256.New()
+	h.Write([]byte(ctx.Parser.GetMethod() + " " + ctx.Parser.GetPath() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// replay sends a recorded response.
+func replay(ctx *v2wf.RequestContext, record Record) error {
+	for name, value := range record.Headers {
+		ctx.Parser.SetRespHeader(name, value)
+	}
+	ctx.Parser.SetRespHeader(HeaderReplayed, "true")
+	return ctx.Parser.SendResponse(record.Status, record.ContentType, record.Body)
+}
+
+// parser wraps a RequestParser to capture the response it writes.
+type parser struct {
+	v2wf.RequestParser
+	req         *v2wf.RequestContext
+	headers     map[string]string
+	status      int
+	contentType string
+	body        []byte
+}
+
+// SetRespHeader implements RequestParser.
+func (p *parser) SetRespHeader(name, value string) {
+	p.headers[http.CanonicalHeaderKey(name)] = value
+	p.RequestParser.SetRespHeader(name, value)
+}
+
+// SendResponse implements RequestParser.
+func (p *parser) SendResponse(code int, contentType string, body []byte) error {
+	committed := p.req.Committed()
+	err := p.RequestParser.SendResponse(code, contentType, body)
+	if err == nil && !committed && p.status == 0 {
+		p.status, p.contentType, p.body = code, contentType, slices.Clone(body)
+	}
+	return err
+}
@@ -0,0 +1,187 @@
+package idempotency
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	legacyLibNetHttp "github.com/hmmftg/requestCore/libNetHttp"
+	legacy "github.com/hmmftg/requestCore/webFramework"
+
+	"github.com/hmmftg/requestCore/v2/locks"
+	v2response "github.com/hmmftg/requestCore/v2/response"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+func newRequest(method, body string, headers map[string]string) (*v2wf.RequestContext, *v2wf.FakeParserV2) {
+	req := httptest.NewRequest(method, "/orders", strings.NewReader(body))
+	parser := v2wf.NewFakeParserV2()
+	parser.Method = method
+	parser.Path = "/orders"
+	for k, v := range headers {
+		parser.ReqHeader[k] = v
+	}
+	commit := &v2wf.CommitState{}
+	parser.SetCommitState(commit)
+	ctx := &v2wf.RequestContext{
+		Parser:        parser,
+		Context:       context.Background(),
+		LegacyContext: legacyLibNetHttp.WithRequestResponse(context.Background(), req, httptest.NewRecorder()),
+		Legacy:        legacy.WebFramework{Parser: parser},
+	}
+	ctx.SetCommitState(commit)
+	return ctx, parser
+}
+
+// orders is a handler that counts its calls and echoes the request body.
+type orders struct {
+	calls  int
+	status int
+}
+
+func (o *orders) handle(ctx *v2wf.RequestContext) error {
+	o.calls++
+	req, _ := legacyLibNetHttp.RequestFromContext(ctx.LegacyContext.(context.Context))
+	body, _ := io.ReadAll(req.Body)
+	ctx.Parser.SetRespHeader("Location", "/orders/1")
+	return ctx.Parser.SendResponse(o.status, "application/json", body)
+}
+
+func TestMiddleware_Replay(t *testing.T) {
+	h := &orders{status: http.StatusCreated}
+	mw := Middleware(Config{})(h.handle)
+	key := map[string]string{HeaderKey: "k1"}
+
+	ctx, parser := newRequest(http.MethodPost, `{"item":1}`, key)
+	if err := mw(ctx); err != nil {
+		t.Fatalf("first: %v", err)
+	}
+	if h.calls != 1 || parser.ResponseStatus != http.StatusCreated || string(parser.ResponseBody) != `{"item":1}` {
+		t.Fatalf("unexpected first response %d %q (calls %d)", parser.ResponseStatus, parser.ResponseBody, h.calls)
+	}
+
+	ctx, parser = newRequest(http.MethodPost, `{"item":1}`, key)
+	if err := mw(ctx); err != nil {
+		t.Fatalf("repeat: %v", err)
+	}
+	if h.calls != 1 || parser.ResponseStatus != http.StatusCreated || string(parser.ResponseBody) != `{"item":1}` ||
+		parser.RespHeader["Location"] != "/orders/1" || parser.RespHeader[HeaderReplayed] != "true" {
+		t.Fatalf("expected replay, got %d %q %v (calls %d)", parser.ResponseStatus, parser.ResponseBody, parser.RespHeader, h.calls)
+	}
+
+	ctx, _ = newRequest(http.MethodPost, `{"item":2}`, key)
+	if err := mw(ctx); v2response.DefaultStatusResolver(err) != http.StatusConflict || h.calls != 1 {
+		t.Fatalf("expected 409 for a different body, got %v", err)
+	}
+
+	// Other keys and methods are independent.
+	ctx, _ = newRequest(http.MethodPost, `{"item":1}`, map[string]string{HeaderKey: "k2"})
+	_ = mw(ctx)
+	ctx, _ = newRequest(http.MethodGet, "", key)
+	_ = mw(ctx)
+	if h.calls != 3 {
+		t.Fatalf("expected 3 handler calls, got %d", h.calls)
+	}
+}
+
+func TestMiddleware_InProgress(t *testing.T) {
+	locker := locks.NewMemoryLocker()
+	lock, _, _ := locker.TryAcquire(context.Background(), "idempotency:u1:k1")
+	h := &orders{status: http.StatusCreated}
+	mw := Middleware(Config{Locker: locker})(h.handle)
+
+	ctx, parser := newRequest(http.MethodPost, `{}`, map[string]string{HeaderKey: "k1", HeaderUserID: "u1"})
+	if err := mw(ctx); v2response.DefaultStatusResolver(err) != http.StatusConflict || parser.RespHeader["Retry-After"] != "1" {
+		t.Fatalf("expected 409 with Retry-After, got %v %v", err, parser.RespHeader)
+	}
+	_ = lock.Release(context.Background())
+	ctx, _ = newRequest(http.MethodPost, `{}`, map[string]string{HeaderKey: "k1", HeaderUserID: "u1"})
+	if err := mw(ctx); err != nil || h.calls != 1 {
+		t.Fatalf("expected the request to run once the lock is free, got %v", err)
+	}
+}
+
+func TestMiddleware_NotRecorded(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	store := NewMemoryStore()
+	h := &orders{status: http.StatusServiceUnavailable}
+	mw := Middleware(Config{Store: store, TTL: time.Hour, Clock: func() time.Time { return now }})(h.handle)
+	key := map[string]string{HeaderKey: "k1"}
+
+	// Server errors are not recorded, so the retry runs again.
+	ctx, _ := newRequest(http.MethodPost, `{}`, key)
+	_ = mw(ctx)
+	h.status = http.StatusCreated
+	ctx, _ = newRequest(http.MethodPost, `{}`, key)
+	_ = mw(ctx)
+	ctx, _ = newRequest(http.MethodPost, `{}`, key)
+	_ = mw(ctx)
+	if h.calls != 2 || store.Len() != 1 {
+		t.Fatalf("expected 2 calls and 1 record, got %d and %d", h.calls, store.Len())
+	}
+
+	// Records expire after TTL.
+	now = now.Add(time.Hour)
+	ctx, _ = newRequest(http.MethodPost, `{}`, key)
+	_ = mw(ctx)
+	if h.calls != 3 {
+		t.Fatalf("expected the expired key to run again, got %d calls", h.calls)
+	}
+}
+
+func TestMiddleware_Keys(t *testing.T) {
+	h := &orders{status: http.StatusCreated}
+	required := Middleware(Config{Required: true})(h.handle)
+	ctx, _ := newRequest(http.MethodPost, `{}`, nil)
+	if err := required(ctx); v2response.DefaultStatusResolver(err) != http.StatusBadRequest || h.calls != 0 {
+		t.Fatalf("expected 400 without key, got %v", err)
+	}
+
+	byRequestID := Middleware(Config{UseRequestID: true})(h.handle)
+	for i := 0; i < 2; i++ {
+		ctx, _ = newRequest(http.MethodPost, `{}`, map[string]string{HeaderRequestID: "r1"})
+		if err := byRequestID(ctx); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if h.calls != 1 {
+		t.Fatalf("expected Request-Id to be used as key, got %d calls", h.calls)
+	}
+}
+
+func TestMiddleware_MaxRequestSize(t *testing.T) {
+	h := &orders{status: http.StatusCreated}
+	mw := Middleware(Config{MaxRequestSize: 8})(h.handle)
+
+	ctx, _ := newRequest(http.MethodPost, `{"item":12}`, map[string]string{HeaderKey: "k1"})
+	if err := mw(ctx); v2response.DefaultStatusResolver(err) != http.StatusRequestEntityTooLarge || h.calls != 0 {
+		t.Fatalf("expected 413 for an oversized keyed body, got %v", err)
+	}
+
+	ctx, parser := newRequest(http.MethodPost, `{"a":1}`, map[string]string{HeaderKey: "k2"})
+	if err := mw(ctx); err != nil || string(parser.ResponseBody) != `{"a":1}` {
+		t.Fatalf("expected a body within the limit to reach the handler, got %v %q", err, parser.ResponseBody)
+	}
+}
+
+func TestMiddleware_ScopedToUser(t *testing.T) {
+	h := &orders{status: http.StatusCreated}
+	mw := Middleware(Config{UseRequestID: true})(h.handle)
+
+	for _, user := range []string{"u1", "u2", "u1"} {
+		ctx, parser := newRequest(http.MethodPost, "", map[string]string{HeaderRequestID: "1", HeaderUserID: user})
+		if err := mw(ctx); err != nil {
+			t.Fatalf("%s: %v", user, err)
+		}
+		if user == "u2" && parser.RespHeader[HeaderReplayed] != "" {
+			t.Fatal("expected another user's key not to replay")
+		}
+	}
+	if h.calls != 2 {
+		t.Fatalf("expected one handler call per user, got %d", h.calls)
+	}
+}
@@ -0,0 +1,192 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/hmmftg/requestCore/libQuery"
+)
+
+// DefaultSQLTable is the table used by SQLStore when no table name is
+// configured.
+const DefaultSQLTable = "idempotency_keys"
+
+// SQLStoreConfig configures a SQLStore.
+type SQLStoreConfig struct {
+	// Table is the records table.
+	// Default: DefaultSQLTable.
+	Table string
+
+	// CleanupInterval is how often RunCleanup deletes expired records.
+	// Default: 10 minutes.
+	CleanupInterval time.Duration
+
+	// Clock is the clock source of Cleanup, for deterministic testing.
+	// If nil, time.Now is used.
+	Clock func() time.Time
+}
+
+// SQLStore implements Store with a database table accessed through
+// libQuery.QueryRunnerInterface, so replicas replay each other's
+// responses. Use it with a distributed locks.Locker, such as one from
+// locks.NewLocker on the same database. The expected schema is:
+//
+//	CREATE TABLE idempotency_keys (
+//	    key_name      VARCHAR(200)  PRIMARY KEY,
+//	    fingerprint   VARCHAR(64)   NOT NULL,
+//	    status        INTEGER       NOT NULL,
+//	    content_type  VARCHAR(200)  NOT NULL,
+//	    headers       TEXT          NOT NULL,
+//	    body          BYTEA         NOT NULL,
+//	    expires_at    BIGINT        NOT NULL
+//	);
+//	CREATE INDEX idempotency_keys_expires_at ON idempotency_keys (expires_at);
+//
+// headers holds a JSON object; expires_at is in Unix milliseconds. Use
+// the database's binary type for body (BLOB on Oracle, MySQL and SQLite).
+type SQLStore struct {
+	core   libQuery.QueryRunnerInterface
+	config SQLStoreConfig
+
+	load    libQuery.QueryCommand
+	remove  libQuery.DmlCommand
+	insert  libQuery.DmlCommand
+	cleanup libQuery.DmlCommand
+}
+
+// NewSQLStore creates a SQLStore on core.
+func NewSQLStore(core libQuery.QueryRunnerInterface, config SQLStoreConfig) *SQLStore {
+	if config.Table == "" {
+		config.Table = DefaultSQLTable
+	}
+	if config.CleanupInterval <= 0 {
+		config.CleanupInterval = 10 * time.Minute
+	}
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+	t := config.Table
+	return &SQLStore{
+		core:   core,
+		config: config,
+		load: libQuery.QueryCommand{
+			Name:    "idempotency-load",
+			Command: "SELECT fingerprint, status, content_type, headers, body, expires_at FROM " + t + " WHERE key_name=$1 AND expires_at>$2",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "SELECT fingerprint, status, content_type, headers, body, expires_at FROM " + t + " WHERE key_name=:1 AND expires_at>:2",
+				libQuery.MySql:  "SELECT fingerprint, status, content_type, headers, body, expires_at FROM " + t + " WHERE key_name=? AND expires_at>?",
+				libQuery.Sqlite: "SELECT fingerprint, status, content_type, headers, body, expires_at FROM " + t + " WHERE key_name=? AND expires_at>?",
+			},
+		},
+		remove: libQuery.DmlCommand{
+			Name:    "idempotency-remove",
+			Command: "DELETE FROM " + t + " WHERE key_name=$1",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "DELETE FROM " + t + " WHERE key_name=:1",
+				libQuery.MySql:  "DELETE FROM " + t + " WHERE key_name=?",
+				libQuery.Sqlite: "DELETE FROM " + t + " WHERE key_name=?",
+			},
+			Type: libQuery.Delete,
+		},
+		insert: libQuery.DmlCommand{
+			Name:    "idempotency-insert",
+			Command: "INSERT INTO " + t + " (key_name, fingerprint, status, content_type, headers, body, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "INSERT INTO " + t + " (key_name, fingerprint, status, content_type, headers, body, expires_at) VALUES (:1, :2, :3, :4, :5, :6, :7)",
+				libQuery.MySql:  "INSERT INTO " + t + " (key_name, fingerprint, status, content_type, headers, body, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+				libQuery.Sqlite: "INSERT INTO " + t + " (key_name, fingerprint, status, content_type, headers, body, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			},
+			Type: libQuery.Insert,
+		},
+		cleanup: libQuery.DmlCommand{
+			Name:    "idempotency-cleanup",
+			Command: "DELETE FROM " + t + " WHERE expires_at<=$1",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "DELETE FROM " + t + " WHERE expires_at<=:1",
+				libQuery.MySql:  "DELETE FROM " + t + " WHERE expires_at<=?",
+				libQuery.Sqlite: "DELETE FROM " + t + " WHERE expires_at<=?",
+			},
+			Type: libQuery.Delete,
+		},
+	}
+}
+
+// recordRow is the database representation of a Record.
+type recordRow struct {
+	Fingerprint string `db:"fingerprint"`
+	Status      int    `db:"status"`
+	ContentType string `db:"content_type"`
+	Headers     string `db:"headers"`
+	Body        []byte `db:"body"`
+	ExpiresAt   int64  `db:"expires_at"`
+}
+
+// Load implements Store.
+func (s *SQLStore) Load(_ context.Context, key string, now time.Time) (Record, bool, error) {
+	rows, err := libQuery.QueryToStruct[recordRow](s.core, s.load.GetCommand(s.core.GetDbMode()), key, now.UnixMilli())
+	if err != nil {
+		return Record{}, false, fmt.Errorf("idempotency: load: %w", err)
+	}
+	if len(rows) == 0 {
+		return Record{}, false, nil
+	}
+	row := rows[0]
+	record := Record{
+		Key:         key,
+		Fingerprint: row.Fingerprint,
+		Status:      row.Status,
+		ContentType: row.ContentType,
+		Body:        row.Body,
+		Expires:     time.UnixMilli(row.ExpiresAt),
+	}
+	if err := json.Unmarshal([]byte(row.Headers), &record.Headers); err != nil {
+		return Record{}, false, fmt.Errorf("idempotency: decode headers of %s: %w", key, err)
+	}
+	return record, true, nil
+}
+
+// Save implements Store. An expired record of the key is deleted first.
+func (s *SQLStore) Save(ctx context.Context, record Record) error {
+	headers, err := json.Marshal(record.Headers)
+	if err != nil {
+		return err
+	}
+	mode := s.core.GetDbMode()
+	if _, err := s.core.Dml(ctx, "idempotency", s.remove.Name, s.remove.GetCommand(mode), record.Key); err != nil {
+		return err
+	}
+	_, err = s.core.Dml(ctx, "idempotency", s.insert.Name, s.insert.GetCommand(mode),
+		record.Key, record.Fingerprint, record.Status, record.ContentType, string(headers), record.Body, record.Expires.UnixMilli())
+	return err
+}
+
+// Cleanup deletes expired records and returns how many were removed.
+func (s *SQLStore) Cleanup(ctx context.Context) (int64, error) {
+	result, err := s.core.Dml(ctx, "idempotency", s.cleanup.Name, s.cleanup.GetCommand(s.core.GetDbMode()),
+		s.config.Clock().UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+	return n, nil
+}
+
+// RunCleanup calls Cleanup every CleanupInterval until ctx is cancelled
+// and returns ctx.Err(). Failures are logged and retried on the next tick.
+func (s *SQLStore) RunCleanup(ctx context.Context) error {
+	ticker := time.NewTicker(s.config.CleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := s.Cleanup(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("idempotency: cleanup failed", slog.String("table", s.config.Table), slog.Any("error", err))
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hmmftg/requestCore/libQuery"
+)
+
+func newSQLStore(t *testing.T) (*SQLStore, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	core := libQuery.Init(db, "prog", "mod", libQuery.Postgres)
+	core.Mode = libQuery.Postgres
+	return NewSQLStore(core, SQLStoreConfig{Clock: func() time.Time { return time.UnixMilli(1700000000000) }}), mock
+}
+
+// expectDml registers the audited transaction libQuery.Dml runs around
+// every statement.
+func expectDml(mock sqlmock.Sqlmock, pattern string, rows int64, args ...driver.Value) {
+	mock.ExpectBegin()
+	for i := 0; i < 4; i++ {
+		mock.ExpectExec("set_config").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(pattern).WithArgs(args...).WillReturnResult(sqlmock.NewResult(0, rows))
+	mock.ExpectCommit()
+}
+
+var recordColumns = []string{"fingerprint", "status", "content_type", "headers", "body", "expires_at"}
+
+func TestSQLStore(t *testing.T) {
+	store, mock := newSQLStore(t)
+	now := time.UnixMilli(1700000000000)
+	record := Record{
+		Key:         "k1",
+		Fingerprint: "abc",
+		Status:      201,
+		ContentType: "application/json",
+		Headers:     map[string]string{"Location": "/orders/1"},
+		Body:        []byte(`{"id":1}`),
+		Expires:     now.Add(time.Hour),
+	}
+
+	expectDml(mock, "DELETE FROM idempotency_keys WHERE key_name", 0, "k1")
+	expectDml(mock, "INSERT INTO idempotency_keys", 1,
+		"k1", "abc", 201, "application/json", `{"Location":"/orders/1"}`, []byte(`{"id":1}`), record.Expires.UnixMilli())
+	if err := store.Save(context.Background(), record); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	mock.ExpectPrepare("SELECT fingerprint").ExpectQuery().WithArgs("k1", now.UnixMilli()).WillReturnRows(
+		sqlmock.NewRows(recordColumns).AddRow("abc", 201, "application/json", `{"Location":"/orders/1"}`, []byte(`{"id":1}`), record.Expires.UnixMilli()))
+	got, found, err := store.Load(context.Background(), "k1", now)
+	if err != nil || !found {
+		t.Fatalf("Load: found=%v err=%v", found, err)
+	}
+	if got.Status != 201 || string(got.Body) != `{"id":1}` || got.Headers["Location"] != "/orders/1" || !got.Expires.Equal(record.Expires) {
+		t.Fatalf("unexpected record %+v", got)
+	}
+
+	mock.ExpectPrepare("SELECT fingerprint").ExpectQuery().WithArgs("k2", now.UnixMilli()).WillReturnRows(sqlmock.NewRows(recordColumns))
+	if _, found, err := store.Load(context.Background(), "k2", now); err != nil || found {
+		t.Fatalf("expected no record, found=%v err=%v", found, err)
+	}
+
+	expectDml(mock, "DELETE FROM idempotency_keys WHERE expires_at", 3, now.UnixMilli())
+	if n, err := store.Cleanup(context.Background()); err != nil || n != 3 {
+		t.Fatalf("Cleanup: %d %v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
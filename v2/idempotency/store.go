@@ -0,0 +1,74 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store holds recorded responses. Middleware only calls Save while it
+// holds the key's lock, so implementations need not guard against
+// concurrent saves of one key.
+type Store interface {
+	// Load returns the record of key, or false if there is none or it
+	// expired before now.
+	Load(ctx context.Context, key string, now time.Time) (Record, bool, error)
+
+	// Save stores record, replacing any expired record of its key.
+	Save(ctx context.Context, record Record) error
+}
+
+// memorySweepEvery is how many saves MemoryStore handles between sweeps
+// of expired records.
+const memorySweepEvery = 1024
+
+// MemoryStore implements Store in process memory. Records are per
+// replica; use SQLStore to share them.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+	saves   int
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record)}
+}
+
+// Load implements Store.
+func (s *MemoryStore) Load(_ context.Context, key string, now time.Time) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, found := s.records[key]
+	if !found || !record.Expires.After(now) {
+		return Record{}, false, nil
+	}
+	return record, true, nil
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(_ context.Context, record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.Key] = record
+
+	s.saves++
+	if s.saves >= memorySweepEvery {
+		s.saves = 0
+		now := time.Now()
+		for k, r := range s.records {
+			if !r.Expires.After(now) {
+				delete(s.records, k)
+			}
+		}
+	}
+	return nil
+}
+
+// Len returns the number of records held, including expired records not
+// yet swept.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
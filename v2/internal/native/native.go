@@ -0,0 +1,124 @@
+// Package native gives v2 middleware access to the framework-native
+// request behind a RequestContext, for the few operations
+// RequestParser does not offer, such as reading and replacing the raw
+// request body.
+package native
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofiber/fiber/v2"
+
+	legacyLibNetHttp "github.com/hmmftg/requestCore/libNetHttp"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+// Request is the framework-native request of a RequestContext.
+type Request interface {
+	// Body returns the raw request body. On net/http it can only be read
+	// once; call SetBody to make it readable again for the handler.
+	Body() io.Reader
+
+	// SetBody replaces the request body and its Content-Length.
+	SetBody(body []byte)
+
+	// SetHeader replaces a request header.
+	SetHeader(name, value string)
+
+	// DelHeader removes a request header.
+	DelHeader(name string)
+}
+
+// FromContext returns the native request of ctx: the *http.Request of
+// net/http, chi and Gin, or the fasthttp request of Fiber. It returns
+// false for other LegacyContext values, such as those of fake parsers.
+func FromContext(ctx *v2wf.RequestContext) (Request, bool) {
+	switch native := ctx.LegacyContext.(type) {
+	case *gin.Context:
+		return httpRequest{native.Request}, native.Request != nil
+	case *fiber.Ctx:
+		return fiberRequest{native}, true
+	case context.Context:
+		req, ok := legacyLibNetHttp.RequestFromContext(native)
+		return httpRequest{req}, ok
+	}
+	return nil, false
+}
+
+// ErrBodyTooLarge is returned by ReadBody for a body over its limit.
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// ReadBody reads the request body of ctx and restores it, so the handler
+// can still bind it. A body longer than limit bytes is not buffered
+// beyond the limit and fails with ErrBodyTooLarge. It returns false when
+// the native request is unavailable.
+func ReadBody(ctx *v2wf.RequestContext, limit int64) ([]byte, bool, error) {
+	req, ok := FromContext(ctx)
+	if !ok {
+		return nil, false, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body(), limit+1))
+	if err != nil {
+		return nil, true, err
+	}
+	if int64(len(body)) > limit {
+		return nil, true, ErrBodyTooLarge
+	}
+	req.SetBody(body)
+	return body, true, nil
+}
+
+// httpRequest is a net/http request (net/http, chi and Gin).
+type httpRequest struct{ req *http.Request }
+
+func (r httpRequest) Body() io.Reader {
+	if r.req.Body == nil {
+		return bytes.NewReader(nil)
+	}
+	return r.req.Body
+}
+
+func (r httpRequest) SetBody(body []byte) {
+	if r.req.Body != nil {
+		_ = r.req.Body.Close()
+	}
+	r.req.Body = io.NopCloser(bytes.NewReader(body))
+	r.req.ContentLength = int64(len(body))
+	if r.req.Header.Get("Content-Length") != "" {
+		r.req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+}
+
+func (r httpRequest) SetHeader(name, value string) {
+	r.req.Header.Set(name, value)
+}
+
+func (r httpRequest) DelHeader(name string) {
+	r.req.Header.Del(name)
+}
+
+// fiberRequest is a fasthttp request. Body returns the raw body:
+// fiber.Ctx.Body would decompress it without a size limit.
+type fiberRequest struct{ c *fiber.Ctx }
+
+func (r fiberRequest) Body() io.Reader {
+	return bytes.NewReader(r.c.Request().Body())
+}
+
+func (r fiberRequest) SetBody(body []byte) {
+	r.c.Request().SetBody(body)
+}
+
+func (r fiberRequest) SetHeader(name, value string) {
+	r.c.Request().Header.Set(name, value)
+}
+
+func (r fiberRequest) DelHeader(name string) {
+	r.c.Request().Header.Del(name)
+}
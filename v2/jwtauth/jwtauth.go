@@ -0,0 +1,177 @@
+// Package jwtauth provides a framework-neutral JWT bearer authentication
+// middleware for v2 routing.
+//
+// The middleware verifies the token in the Authorization header with
+// HS256, RS256 or ES256 keys from parameters (LoadKeys), a JWK Set file
+// (LoadJWKSFile) or an identity provider's JWK Set URL (RemoteJWKS), and
+// checks its "exp", "nbf", "iss" and "aud" claims with a clock skew
+// allowance. It then overwrites the identity headers of the request
+// (User-Id, Branch-Id, Bank-Id and Person-Id) with the token's claims, so
+// libRequest.RequestHeader, and with it HandlerRequest.Header, reflects
+// the verified token rather than whatever the client or gateway sent.
+// The full claims are available to handlers through FromContext.
+//
+// A rejected request fails with an *Error, which reports HTTP 401 and is
+// routed through the v2 response registry like any other handler error.
+package jwtauth
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/hmmftg/requestCore/libError"
+	"github.com/hmmftg/requestCore/status"
+	"github.com/hmmftg/requestCore/v2/internal/native"
+	"github.com/hmmftg/requestCore/v2/routing"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+// ClaimsKey is the local storage key for the verified *Claims on the
+// request parser. v1 handlers read it with Parser.GetLocal.
+const ClaimsKey = "_v2_jwt_claims"
+
+// DefaultHeaderClaims maps the identity headers of
+// libRequest.RequestHeader to the claims that fill them.
+var DefaultHeaderClaims = map[string]string{
+	"User-Id":   "sub",
+	"Branch-Id": "branch",
+	"Bank-Id":   "bank",
+	"Person-Id": "person",
+}
+
+// Error is returned when a request carries no valid token. It wraps a
+// libError with status 401, so the response registry resolves it to
+// HTTP 401 and the legacy fallback renders it.
+type Error struct {
+	// Reason describes why the token was rejected.
+	Reason string
+
+	err error
+}
+
+func newError(code, reason string) *Error {
+	return &Error{
+		Reason: reason,
+		err:    libError.NewWithDescription(status.Unauthorized, code, "%s", reason),
+	}
+}
+
+func invalid(reason string) *Error {
+	return newError("TOKEN_INVALID", reason)
+}
+
+func (e *Error) Error() string {
+	return "jwtauth: " + e.Reason
+}
+
+// Unwrap returns the libError carrying the response status and code.
+func (e *Error) Unwrap() error { return e.err }
+
+// Config configures the JWT middleware.
+type Config struct {
+	VerifierConfig
+
+	// HeaderClaims maps request headers to the claims that replace them.
+	// Mapped headers are removed when the claim is absent, so a client
+	// cannot supply an identity the token does not carry.
+	// Default: DefaultHeaderClaims.
+	HeaderClaims map[string]string
+
+	// Optional lets requests without an Authorization header through
+	// anonymously, with the mapped headers removed. Requests with an
+	// invalid token are still rejected.
+	Optional bool
+
+	// Skipper, if set, exempts any request for which it returns true
+	// from authentication. Its mapped identity headers are still
+	// removed, so they are never trusted from the client.
+	Skipper func(*v2wf.RequestContext) bool
+}
+
+// Middleware returns a routing.Middleware that requires a valid bearer
+// token on every request, rejecting others with an *Error and a
+// WWW-Authenticate challenge. On fake parsers, which have no native
+// request, the headers cannot be rewritten and only FromContext reflects
+// the token.
+func Middleware(config Config) (routing.Middleware, error) {
+	verifier, err := NewVerifier(config.VerifierConfig)
+	if err != nil {
+		return nil, err
+	}
+	if config.HeaderClaims == nil {
+		config.HeaderClaims = DefaultHeaderClaims
+	}
+
+	return func(next routing.Handler) routing.Handler {
+		return func(ctx *v2wf.RequestContext) error {
+			if ctx.Parser == nil {
+				return next(ctx)
+			}
+			if config.Skipper != nil && config.Skipper(ctx) {
+				config.setHeaders(ctx, nil)
+				return next(ctx)
+			}
+			token, found := bearerToken(ctx.Parser.GetHeaderValue("Authorization"))
+			if !found {
+				if config.Optional {
+					config.setHeaders(ctx, nil)
+					return next(ctx)
+				}
+				ctx.Parser.SetRespHeader("WWW-Authenticate", "Bearer")
+				return newError("TOKEN_MISSING", "bearer token required")
+			}
+
+			claims, err := verifier.Verify(ctx.ContextOrBackground(), token)
+			if err != nil {
+				var authErr *Error
+				if errors.As(err, &authErr) {
+					ctx.Parser.SetRespHeader("WWW-Authenticate", `Bearer error="invalid_token"`)
+					return err
+				}
+				slog.Error("jwtauth: verification keys unavailable", slog.Any("error", err))
+				return libError.NewWithDescription(status.InternalServerError, "AUTH_KEYS_UNAVAILABLE", "verification keys unavailable")
+			}
+			ctx.Parser.SetLocal(ClaimsKey, claims)
+			config.setHeaders(ctx, claims)
+			return next(ctx)
+		}
+	}, nil
+}
+
+// FromContext returns the verified claims of the request, or nil if the
+// request is anonymous or the middleware did not run.
+func FromContext(ctx *v2wf.RequestContext) *Claims {
+	if ctx == nil || ctx.Parser == nil {
+		return nil
+	}
+	claims, _ := ctx.Parser.GetLocal(ClaimsKey).(*Claims)
+	return claims
+}
+
+// setHeaders replaces the mapped request headers with claims, removing
+// those whose claim is missing.
+func (c Config) setHeaders(ctx *v2wf.RequestContext, claims *Claims) {
+	req, ok := native.FromContext(ctx)
+	if !ok {
+		return
+	}
+	for header, claim := range c.HeaderClaims {
+		if value := claims.String(claim); value != "" {
+			req.SetHeader(header, value)
+		} else {
+			req.DelHeader(header)
+		}
+	}
+}
+
+// bearerToken extracts the token of a "Bearer" Authorization header; the
+// scheme is case-insensitive.
+func bearerToken(authorization string) (string, bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(authorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -0,0 +1,143 @@
+package jwtauth_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hmmftg/requestCore/libRequest"
+	"github.com/hmmftg/requestCore/response"
+
+	"github.com/hmmftg/requestCore/v2/jwtauth"
+	v2libNetHttp "github.com/hmmftg/requestCore/v2/libNetHttp"
+	"github.com/hmmftg/requestCore/v2/renderers"
+	v2response "github.com/hmmftg/requestCore/v2/response"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+type identity struct {
+	Header  libRequest.RequestHeader
+	Subject string
+}
+
+// newServer protects GET /me with the middleware and replies with the
+// request header bound the way libRequest.ParseRequest binds it.
+func newServer(t *testing.T, config jwtauth.Config) http.Handler {
+	t.Helper()
+	config.Keys = jwtauth.StaticKeys{{Algorithm: jwtauth.HS256, Key: hmacSecret}}
+	config.Clock = func() time.Time { return now }
+	mw, err := jwtauth.Middleware(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := v2libNetHttp.NewRouter()
+	registry := v2response.NewRegistry(nil)
+	registry.SetFallback(v2response.LegacyFallback(response.WebHanlder{
+		MessageDesc: make(map[string]string),
+		ErrorDesc:   make(map[string]string),
+	}))
+	router.SetErrorHandler(v2response.NewHandler(registry, renderers.JSONRenderer{}, response.WebHanlder{}))
+	_ = router.With(mw).Get("/me", func(ctx *v2wf.RequestContext) error {
+		var id identity
+		if err := ctx.Parser.GetHeader(&id.Header); err != nil {
+			return err
+		}
+		if claims := jwtauth.FromContext(ctx); claims != nil {
+			id.Subject = claims.Subject
+		}
+		body, _ := json.Marshal(id)
+		return ctx.Parser.SendResponse(http.StatusOK, "application/json", body)
+	})
+	return router.Native().(http.Handler)
+}
+
+func get(handler http.Handler, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	handler := newServer(t, jwtauth.Config{})
+	token := sign(t, jwtauth.HS256, "", hmacSecret, with(validClaims(), "person", nil))
+
+	// Claims replace the identity headers, including ones the client
+	// tried to set and the claim does not carry.
+	w := get(handler, map[string]string{
+		"Authorization": "bearer " + token,
+		"User-Id":       "admin",
+		"Person-Id":     "p-1",
+		"Program-Id":    "web",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var id identity
+	if err := json.Unmarshal(w.Body.Bytes(), &id); err != nil {
+		t.Fatal(err)
+	}
+	h := id.Header
+	if h.User != "u-42" || h.Branch != "0101" || h.Bank != "17" || h.Person != "" || h.Program != "web" || id.Subject != "u-42" {
+		t.Fatalf("unexpected identity %+v", id)
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		code    string
+	}{
+		{"missing", map[string]string{"User-Id": "admin"}, "TOKEN-MISSING"},
+		{"other scheme", map[string]string{"Authorization": "Basic YWRtaW46YWRtaW4="}, "TOKEN-MISSING"},
+		{"expired", map[string]string{"Authorization": "Bearer " + sign(t, jwtauth.HS256, "", hmacSecret, with(validClaims(), "exp", now.Add(-time.Hour).Unix()))}, "TOKEN-EXPIRED"},
+		{"tampered", map[string]string{"Authorization": "Bearer " + token[:len(token)-2] + "AA"}, "TOKEN-INVALID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(handler, tt.headers)
+			if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), tt.code) {
+				t.Fatalf("expected 401 %s, got %d: %s", tt.code, w.Code, w.Body)
+			}
+			if !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Bearer") {
+				t.Fatalf("expected a Bearer challenge, got %v", w.Header())
+			}
+		})
+	}
+}
+
+func TestMiddleware_Optional(t *testing.T) {
+	handler := newServer(t, jwtauth.Config{Optional: true})
+
+	w := get(handler, map[string]string{"User-Id": "admin", "Branch-Id": "0001"})
+	var id identity
+	if err := json.Unmarshal(w.Body.Bytes(), &id); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if id.Header.User != "" || id.Header.Branch != "" || id.Subject != "" {
+		t.Fatalf("expected anonymous request without identity headers, got %+v", id)
+	}
+
+	w = get(handler, map[string]string{"Authorization": "Bearer not.a.token"})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected invalid token to be rejected, got %d", w.Code)
+	}
+}
+
+func TestMiddleware_Skipper(t *testing.T) {
+	handler := newServer(t, jwtauth.Config{Skipper: func(*v2wf.RequestContext) bool { return true }})
+
+	w := get(handler, map[string]string{"User-Id": "admin", "Bank-Id": "17", "Program-Id": "web"})
+	var id identity
+	if err := json.Unmarshal(w.Body.Bytes(), &id); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if id.Header.User != "" || id.Header.Bank != "" || id.Header.Program != "web" {
+		t.Fatalf("expected skipped request without identity headers, got %+v", id)
+	}
+}
@@ -0,0 +1,440 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hmmftg/requestCore/libParams"
+)
+
+// Supported signing algorithms.
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+const (
+	// HMACKeyParam is the secure parameter name prefix for HS256 secrets.
+	// Secrets are numbered "jwt-hmac-key#1", "jwt-hmac-key#2", ... and
+	// base64-encoded.
+	HMACKeyParam = "jwt-hmac-key"
+
+	// PublicKeyParam is the parameter name prefix for PEM-encoded RSA or
+	// P-256 public keys, numbered like HMACKeyParam.
+	PublicKeyParam = "jwt-public-key"
+
+	// minRSABits is the smallest RSA modulus accepted for RS256.
+	minRSABits = 2048
+
+	// maxJWKSSize bounds the JWKS documents read from files and URLs.
+	maxJWKSSize = 1 << 20
+)
+
+// Key is a verification key.
+type Key struct {
+	// ID is the key ID matched against the token's "kid" header. A key
+	// without an ID is tried for every token of its algorithm.
+	ID string
+
+	// Algorithm is the only algorithm the key verifies: HS256, RS256 or
+	// ES256. Binding keys to algorithms prevents a token from choosing
+	// how its signature is checked, for example verifying an HMAC with
+	// an RSA public key.
+	Algorithm string
+
+	// Key is the []byte HMAC secret, *rsa.PublicKey or P-256
+	// *ecdsa.PublicKey.
+	Key any
+}
+
+func (k Key) validate() error {
+	switch k.Algorithm {
+	case HS256:
+		if secret, ok := k.Key.([]byte); !ok || len(secret) < 32 {
+			return fmt.Errorf("jwtauth: key %q: HS256 needs a secret of at least 32 bytes", k.ID)
+		}
+	case RS256:
+		if pub, ok := k.Key.(*rsa.PublicKey); !ok || pub.N.BitLen() < minRSABits {
+			return fmt.Errorf("jwtauth: key %q: RS256 needs an RSA public key of at least %d bits", k.ID, minRSABits)
+		}
+	case ES256:
+		if pub, ok := k.Key.(*ecdsa.PublicKey); !ok || pub.Curve != elliptic.P256() {
+			return fmt.Errorf("jwtauth: key %q: ES256 needs a P-256 public key", k.ID)
+		}
+	default:
+		return fmt.Errorf("jwtauth: key %q: unsupported algorithm %q", k.ID, k.Algorithm)
+	}
+	return nil
+}
+
+// KeySource supplies verification keys. Implementations must be safe
+// for concurrent use.
+type KeySource interface {
+	// Keys returns the keys that may have signed a token with key ID kid
+	// (empty when the token names none). Sources that cache keys may
+	// refresh them when no cached key has that ID.
+	Keys(ctx context.Context, kid string) ([]Key, error)
+}
+
+// StaticKeys is a fixed KeySource, for keys from configuration or a
+// JWKS file.
+type StaticKeys []Key
+
+// Keys implements KeySource.
+func (s StaticKeys) Keys(_ context.Context, kid string) ([]Key, error) {
+	return matching(s, kid), nil
+}
+
+// matching returns the keys with ID kid and the keys without an ID.
+func matching(keys []Key, kid string) []Key {
+	var out []Key
+	for _, k := range keys {
+		if k.ID == "" || k.ID == kid {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+func hasID(keys []Key, kid string) bool {
+	for _, k := range keys {
+		if k.ID == kid {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadKeys reads the numbered HMACKeyParam secure parameters and
+// PublicKeyParam parameters of group. Secure values must already be
+// decrypted. Keys from parameters have no ID, so every configured key is
+// tried; adding a key with the next number rotates the signing key
+// without rejecting tokens signed with the previous one.
+func LoadKeys(params libParams.ParamInterface, group string) (StaticKeys, error) {
+	if params == nil {
+		return nil, errors.New("jwtauth: nil parameters")
+	}
+	var keys StaticKeys
+	for n := 1; ; n++ {
+		name := fmt.Sprintf("%s#%d", HMACKeyParam, n)
+		param := params.GetSecureParam(group, name)
+		if param == nil {
+			break
+		}
+		secret, err := base64.StdEncoding.DecodeString(param.Value)
+		if err != nil {
+			return nil, fmt.Errorf("jwtauth: decode %s/%s: %w", group, name, err)
+		}
+		keys = append(keys, Key{Algorithm: HS256, Key: secret})
+	}
+	for n := 1; ; n++ {
+		name := fmt.Sprintf("%s#%d", PublicKeyParam, n)
+		value := params.GetParam(group, name)
+		if value == nil {
+			break
+		}
+		key, err := ParsePublicKeyPEM([]byte(*value))
+		if err != nil {
+			return nil, fmt.Errorf("jwtauth: %s/%s: %w", group, name, err)
+		}
+		keys = append(keys, key)
+	}
+	for _, k := range keys {
+		if err := k.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwtauth: no %s#N or %s#N parameters in group %q", HMACKeyParam, PublicKeyParam, group)
+	}
+	return keys, nil
+}
+
+// ParsePublicKeyPEM parses a PEM "PUBLIC KEY" block holding an RSA key,
+// for RS256, or a P-256 key, for ES256.
+func ParsePublicKeyPEM(data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return Key{}, errors.New("jwtauth: no PEM PUBLIC KEY block")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return Key{}, fmt.Errorf("jwtauth: parse public key: %w", err)
+	}
+	var key Key
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		key = Key{Algorithm: RS256, Key: pub}
+	case *ecdsa.PublicKey:
+		key = Key{Algorithm: ES256, Key: pub}
+	default:
+		return Key{}, fmt.Errorf("jwtauth: unsupported public key type %T", pub)
+	}
+	return key, key.validate()
+}
+
+// jwk is a JSON Web Key (RFC 7517) of the types this package verifies.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS parses a JWK Set document. Keys for encryption ("use":
+// "enc") and of unsupported types are skipped; malformed keys fail the
+// whole set.
+func ParseJWKS(data []byte) ([]Key, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwtauth: parse JWKS: %w", err)
+	}
+	var keys []Key
+	for _, j := range set.Keys {
+		if j.Use == "enc" {
+			continue
+		}
+		key, ok, err := j.key()
+		if err != nil {
+			return nil, fmt.Errorf("jwtauth: JWKS key %q: %w", j.Kid, err)
+		}
+		if !ok {
+			continue
+		}
+		if err := key.validate(); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (j jwk) key() (Key, bool, error) {
+	key := Key{ID: j.Kid, Algorithm: j.Alg}
+	switch {
+	case j.Kty == "RSA" && (j.Alg == "" || j.Alg == RS256):
+		n, err := decodeBigInt(j.N)
+		if err != nil {
+			return key, false, err
+		}
+		e, err := decodeBigInt(j.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return key, false, errors.New("invalid RSA exponent")
+		}
+		key.Algorithm, key.Key = RS256, &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case j.Kty == "EC" && j.Crv == "P-256" && (j.Alg == "" || j.Alg == ES256):
+		x, err := decodeBigInt(j.X)
+		if err != nil {
+			return key, false, err
+		}
+		y, err := decodeBigInt(j.Y)
+		if err != nil {
+			return key, false, err
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		if _, err := pub.ECDH(); err != nil {
+			return key, false, errors.New("EC point is not on P-256")
+		}
+		key.Algorithm, key.Key = ES256, pub
+	case j.Kty == "oct" && (j.Alg == "" || j.Alg == HS256):
+		secret, err := base64.RawURLEncoding.DecodeString(j.K)
+		if err != nil {
+			return key, false, err
+		}
+		key.Algorithm, key.Key = HS256, secret
+	default:
+		return key, false, nil
+	}
+	return key, true, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// LoadJWKSFile reads a JWK Set from a file.
+func LoadJWKSFile(path string) (StaticKeys, error) {
+	f, err := os.Open(path) // #nosec G304 -- path comes from configuration
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxJWKSSize))
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(data)
+}
+
+// RemoteJWKSConfig configures a RemoteJWKS.
+type RemoteJWKSConfig struct {
+	// URL is the JWK Set location, such as an identity provider's
+	// jwks_uri.
+	URL string
+
+	// Client performs the requests.
+	// Default: an http.Client with a 10 second timeout.
+	Client *http.Client
+
+	// TTL is how long fetched keys are used before they are refetched.
+	// Default: 1 hour.
+	TTL time.Duration
+
+	// MinRefreshInterval limits refetches triggered by unknown key IDs,
+	// so tokens with made-up IDs cannot make every request fetch the
+	// set.
+	// Default: 1 minute.
+	MinRefreshInterval time.Duration
+
+	// Clock is the clock source for deterministic testing.
+	// If nil, time.Now is used.
+	Clock func() time.Time
+}
+
+// RemoteJWKS is a KeySource that fetches a JWK Set over HTTP and caches
+// it. The set is refetched after TTL, and sooner when a token names an
+// unknown key ID, which is how a provider's key rotation is picked up.
+// When a refetch fails, the cached keys stay in use.
+type RemoteJWKS struct {
+	config RemoteJWKSConfig
+
+	mu        sync.Mutex
+	keys      []Key
+	fetched   time.Time
+	attempted time.Time
+	refresh   *jwksRefresh
+}
+
+// jwksRefresh is a fetch in flight, shared by the callers waiting on it.
+type jwksRefresh struct {
+	done chan struct{}
+	err  error
+}
+
+// NewRemoteJWKS creates a RemoteJWKS. Keys are fetched on first use.
+func NewRemoteJWKS(config RemoteJWKSConfig) (*RemoteJWKS, error) {
+	if config.URL == "" {
+		return nil, errors.New("jwtauth: RemoteJWKSConfig.URL is required")
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if config.TTL <= 0 {
+		config.TTL = time.Hour
+	}
+	if config.MinRefreshInterval <= 0 {
+		config.MinRefreshInterval = time.Minute
+	}
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+	return &RemoteJWKS{config: config}, nil
+}
+
+// Keys implements KeySource. Callers served from the cache never wait on
+// a fetch; callers that need a refetch share a single one, which runs
+// without holding the cache lock and outlives a cancelled caller.
+func (r *RemoteJWKS) Keys(ctx context.Context, kid string) ([]Key, error) {
+	r.mu.Lock()
+	now := r.config.Clock()
+	stale := r.keys == nil || now.Sub(r.fetched) >= r.config.TTL
+	unknown := kid != "" && !hasID(r.keys, kid)
+	call := r.refresh
+	if call == nil && (stale || unknown) && (r.keys == nil || now.Sub(r.attempted) >= r.config.MinRefreshInterval) {
+		r.attempted = now
+		call = &jwksRefresh{done: make(chan struct{})}
+		r.refresh = call
+		go r.update(context.WithoutCancel(ctx), call, now)
+	}
+	if call == nil || (!stale && !unknown) {
+		keys := r.keys
+		r.mu.Unlock()
+		return matching(keys, kid), nil
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.keys == nil {
+		return nil, call.err
+	}
+	return matching(r.keys, kid), nil
+}
+
+// update runs the fetch of call and stores its keys.
+func (r *RemoteJWKS) update(ctx context.Context, call *jwksRefresh, now time.Time) {
+	keys, err := r.fetch(ctx)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case err == nil:
+		r.keys, r.fetched = keys, now
+	case r.keys != nil:
+		slog.Warn("jwtauth: JWKS refresh failed, using cached keys",
+			slog.String("url", r.config.URL), slog.Any("error", err))
+	}
+	call.err = err
+	r.refresh = nil
+	close(call.done)
+}
+
+func (r *RemoteJWKS) fetch(ctx context.Context) ([]Key, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := r.config.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("jwtauth: fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwtauth: fetch JWKS: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+	if err != nil {
+		return nil, fmt.Errorf("jwtauth: fetch JWKS: %w", err)
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		keys = []Key{}
+	}
+	return keys, nil
+}
@@ -0,0 +1,246 @@
+package jwtauth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hmmftg/requestCore/libParams"
+	"github.com/hmmftg/requestCore/v2/jwtauth"
+)
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func jwks(keys ...map[string]string) []byte {
+	data, _ := json.Marshal(map[string]any{"keys": keys})
+	return data
+}
+
+func TestParseJWKS(t *testing.T) {
+	ecJWK := map[string]string{"kty": "EC", "crv": "P-256", "kid": "ec", "x": b64(ecKey.X.Bytes()), "y": b64(ecKey.Y.Bytes())}
+	encJWK := rsaJWK("enc", &rsaKey.PublicKey)
+	encJWK["use"] = "enc"
+	okp := map[string]string{"kty": "OKP", "crv": "Ed25519", "kid": "ed", "x": "AA"}
+
+	keys, err := jwtauth.ParseJWKS(jwks(rsaJWK("rs", &rsaKey.PublicKey), ecJWK, encJWK, okp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].Algorithm != jwtauth.RS256 || keys[1].Algorithm != jwtauth.ES256 {
+		t.Fatalf("unexpected keys %+v", keys)
+	}
+
+	claims, err := verifierFor(t, jwtauth.StaticKeys(keys)).Verify(t.Context(), sign(t, jwtauth.ES256, "ec", ecKey, validClaims()))
+	if err != nil || claims.Subject != "u-42" {
+		t.Fatalf("expected the JWKS key to verify, got %v", err)
+	}
+
+	small, _ := rsa.GenerateKey(rand.Reader, 1024)
+	if _, err := jwtauth.ParseJWKS(jwks(rsaJWK("small", &small.PublicKey))); err == nil {
+		t.Fatal("expected a 1024-bit RSA key to be rejected")
+	}
+	offCurve := map[string]string{"kty": "EC", "crv": "P-256", "x": b64([]byte{1}), "y": b64([]byte{2})}
+	if _, err := jwtauth.ParseJWKS(jwks(offCurve)); err == nil {
+		t.Fatal("expected a point off the curve to be rejected")
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwks(ecJWK), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fileKeys, err := jwtauth.LoadJWKSFile(path)
+	if err != nil || len(fileKeys) != 1 || fileKeys[0].ID != "ec" {
+		t.Fatalf("unexpected file keys %+v: %v", fileKeys, err)
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	der, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	params := libParams.ApplicationParams[any]{
+		ParameterGroups: map[string]libParams.ParametersMap{
+			"auth": {Params: map[string]string{
+				jwtauth.PublicKeyParam + "#1": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+			}},
+		},
+		SecureParameterGroups: map[string]libParams.SecureParametersMap{
+			"auth": {SecureParams: map[string]libParams.SecurityParam{
+				jwtauth.HMACKeyParam + "#1": {Value: base64.StdEncoding.EncodeToString(hmacSecret)},
+			}},
+		},
+	}
+	keys, err := jwtauth.LoadKeys(params, "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := verifierFor(t, keys)
+	for _, token := range []string{
+		sign(t, jwtauth.HS256, "", hmacSecret, validClaims()),
+		sign(t, jwtauth.RS256, "any", rsaKey, validClaims()),
+	} {
+		if _, err := v.Verify(t.Context(), token); err != nil {
+			t.Fatalf("expected configured keys to verify, got %v", err)
+		}
+	}
+
+	if _, err := jwtauth.LoadKeys(params, "missing"); err == nil {
+		t.Fatal("expected error for group without keys")
+	}
+}
+
+func TestRemoteJWKS(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		served  = jwks(rsaJWK("k1", &rsaKey.PublicKey))
+		fetches atomic.Int32
+		fail    atomic.Bool
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		if fail.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = w.Write(served)
+	}))
+	defer server.Close()
+
+	clock := now
+	remote, err := jwtauth.NewRemoteJWKS(jwtauth.RemoteJWKSConfig{
+		URL:                server.URL,
+		TTL:                time.Hour,
+		MinRefreshInterval: time.Minute,
+		Clock:              func() time.Time { return clock },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := verifierFor(t, remote)
+	verify := func(kid string, key *rsa.PrivateKey) error {
+		_, err := v.Verify(t.Context(), sign(t, jwtauth.RS256, kid, key, validClaims()))
+		return err
+	}
+
+	for range 3 {
+		if err := verify("k1", rsaKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("expected keys to be cached, got %d fetches", n)
+	}
+
+	// The provider rotates to k2: the unknown ID triggers one refetch,
+	// and further unknown IDs within MinRefreshInterval do not.
+	rotated, _ := rsa.GenerateKey(rand.Reader, 2048)
+	mu.Lock()
+	served = jwks(rsaJWK("k2", &rotated.PublicKey))
+	mu.Unlock()
+	clock = clock.Add(2 * time.Minute)
+	if err := verify("k2", rotated); err != nil {
+		t.Fatalf("expected the rotated key to be fetched, got %v", err)
+	}
+	if err := verify("k3", rotated); err == nil || fetches.Load() != 2 {
+		t.Fatalf("expected unknown kid to be rejected without refetch, got %v after %d fetches", err, fetches.Load())
+	}
+
+	// A failed refresh keeps the cached keys.
+	fail.Store(true)
+	clock = clock.Add(2 * time.Hour)
+	if err := verify("k2", rotated); err != nil {
+		t.Fatalf("expected cached keys after a failed refresh, got %v", err)
+	}
+	if fetches.Load() != 3 {
+		t.Fatalf("expected a refresh attempt after TTL, got %d fetches", fetches.Load())
+	}
+}
+
+func TestRemoteJWKS_SlowRefresh(t *testing.T) {
+	var (
+		fetches atomic.Int32
+		started = make(chan struct{}, 1)
+		release = make(chan struct{})
+	)
+	rotated, _ := rsa.GenerateKey(rand.Reader, 2048)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if fetches.Add(1) == 1 {
+			_, _ = w.Write(jwks(rsaJWK("k1", &rsaKey.PublicKey)))
+			return
+		}
+		started <- struct{}{}
+		<-release
+		_, _ = w.Write(jwks(rsaJWK("k1", &rsaKey.PublicKey), rsaJWK("k2", &rotated.PublicKey)))
+	}))
+	defer server.Close()
+
+	clock := now
+	remote, err := jwtauth.NewRemoteJWKS(jwtauth.RemoteJWKSConfig{
+		URL:   server.URL,
+		Clock: func() time.Time { return clock },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := verifierFor(t, remote)
+	verify := func(kid string, key *rsa.PrivateKey) error {
+		_, err := v.Verify(t.Context(), sign(t, jwtauth.RS256, kid, key, validClaims()))
+		return err
+	}
+	if err := verify("k1", rsaKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two callers with the rotated key share one refetch, while cached
+	// keys are served without waiting for it.
+	clock = clock.Add(2 * time.Minute)
+	errs := make(chan error, 2)
+	go func() { errs <- verify("k2", rotated) }()
+	<-started
+	go func() { errs <- verify("k2", rotated) }()
+	cached := make(chan error, 1)
+	go func() { cached <- verify("k1", rsaKey) }()
+	select {
+	case err := <-cached:
+		close(release)
+		if err != nil {
+			t.Fatalf("expected cached keys during the refetch, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		close(release)
+		t.Fatal("expected cached keys without waiting for the refetch")
+	}
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Fatalf("expected the rotated key after the refetch, got %v", err)
+		}
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Fatalf("expected a single refetch, got %d fetches", n)
+	}
+}
+
+func verifierFor(t *testing.T, keys jwtauth.KeySource) *jwtauth.Verifier {
+	t.Helper()
+	v, err := jwtauth.NewVerifier(jwtauth.VerifierConfig{Keys: keys, Clock: func() time.Time { return now }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
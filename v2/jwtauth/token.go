@@ -0,0 +1,283 @@
+package jwtauth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxTokenSize bounds the tokens the verifier decodes.
+const maxTokenSize = 8 << 10
+
+// Claims are the verified claims of a token.
+type Claims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+	ID        string
+
+	// Raw holds every claim, registered or not, as decoded JSON with
+	// numbers as json.Number.
+	Raw map[string]any
+}
+
+// String returns claim name as a string: strings as is, numbers in their
+// JSON form and booleans as "true" or "false". Other and missing claims
+// return "".
+func (c *Claims) String(name string) string {
+	if c == nil {
+		return ""
+	}
+	switch v := c.Raw[name].(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// Strings returns claim name as a list of strings, accepting a single
+// string or an array of strings.
+func (c *Claims) Strings(name string) []string {
+	if c == nil {
+		return nil
+	}
+	switch v := c.Raw[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// VerifierConfig configures a Verifier.
+type VerifierConfig struct {
+	// Keys supplies the verification keys. Required.
+	Keys KeySource
+
+	// Algorithms lists the accepted algorithms.
+	// Default: HS256, RS256 and ES256.
+	Algorithms []string
+
+	// Issuer, if set, must equal the token's "iss" claim.
+	Issuer string
+
+	// Audience, if set, must share a value with the token's "aud" claim.
+	Audience []string
+
+	// ClockSkew is the leeway allowed when checking "exp" and "nbf"
+	// against the local clock.
+	// Default: 1 minute.
+	ClockSkew time.Duration
+
+	// Clock is the clock source for deterministic testing.
+	// If nil, time.Now is used.
+	Clock func() time.Time
+}
+
+// Verifier verifies signed tokens in compact JWS form. It is safe for
+// concurrent use.
+type Verifier struct {
+	config VerifierConfig
+}
+
+// NewVerifier creates a Verifier.
+func NewVerifier(config VerifierConfig) (*Verifier, error) {
+	if config.Keys == nil {
+		return nil, errors.New("jwtauth: VerifierConfig.Keys is required")
+	}
+	if len(config.Algorithms) == 0 {
+		config.Algorithms = []string{HS256, RS256, ES256}
+	}
+	for _, alg := range config.Algorithms {
+		if alg != HS256 && alg != RS256 && alg != ES256 {
+			return nil, fmt.Errorf("jwtauth: unsupported algorithm %q", alg)
+		}
+	}
+	if config.ClockSkew <= 0 {
+		config.ClockSkew = time.Minute
+	}
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+	return &Verifier{config: config}, nil
+}
+
+// Verify checks token's signature and its "exp", "nbf", "iss" and "aud"
+// claims and returns its claims. Tokens without "exp" are rejected.
+// Invalid tokens fail with an *Error; a failing KeySource fails with its
+// own error.
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	if len(token) > maxTokenSize {
+		return nil, invalid("token too large")
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalid("malformed token")
+	}
+	var header struct {
+		Alg  string `json:"alg"`
+		Kid  string `json:"kid"`
+		Crit []any  `json:"crit"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, invalid("malformed header")
+	}
+	if !slices.Contains(v.config.Algorithms, header.Alg) {
+		return nil, invalid(fmt.Sprintf("algorithm %q not accepted", header.Alg))
+	}
+	if header.Crit != nil {
+		return nil, invalid("unsupported critical header")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalid("malformed signature")
+	}
+
+	keys, err := v.config.Keys.Keys(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signed := []byte(token[:len(parts[0])+1+len(parts[1])])
+	verified := false
+	for _, key := range keys {
+		if key.Algorithm == header.Alg && verifySignature(key, signed, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, invalid("signature verification failed")
+	}
+
+	var raw map[string]any
+	if err := decodeSegment(parts[1], &raw); err != nil || raw == nil {
+		return nil, invalid("malformed claims")
+	}
+	claims, err := registered(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *Verifier) validate(c *Claims) error {
+	now := v.config.Clock()
+	skew := v.config.ClockSkew
+	if c.ExpiresAt.IsZero() {
+		return invalid("missing exp claim")
+	}
+	if !now.Before(c.ExpiresAt.Add(skew)) {
+		return newError("TOKEN_EXPIRED", "token expired")
+	}
+	if !c.NotBefore.IsZero() && now.Add(skew).Before(c.NotBefore) {
+		return invalid("token not valid yet")
+	}
+	if v.config.Issuer != "" && c.Issuer != v.config.Issuer {
+		return invalid("unexpected issuer")
+	}
+	if len(v.config.Audience) > 0 && !slices.ContainsFunc(c.Audience, func(aud string) bool {
+		return slices.Contains(v.config.Audience, aud)
+	}) {
+		return invalid("unexpected audience")
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// registered extracts the registered claims of raw.
+func registered(raw map[string]any) (*Claims, error) {
+	c := &Claims{Raw: raw}
+	var ok bool
+	for name, dst := range map[string]*string{"iss": &c.Issuer, "sub": &c.Subject, "jti": &c.ID} {
+		if v, found := raw[name]; found {
+			if *dst, ok = v.(string); !ok {
+				return nil, invalid("malformed " + name + " claim")
+			}
+		}
+	}
+	for name, dst := range map[string]*time.Time{"exp": &c.ExpiresAt, "nbf": &c.NotBefore, "iat": &c.IssuedAt} {
+		if v, found := raw[name]; found {
+			n, isNumber := v.(json.Number)
+			seconds, err := n.Float64()
+			if !isNumber || err != nil || math.IsInf(seconds, 0) || math.Abs(seconds) > 1e12 {
+				return nil, invalid("malformed " + name + " claim")
+			}
+			whole, frac := math.Modf(seconds)
+			*dst = time.Unix(int64(whole), int64(frac*1e9))
+		}
+	}
+	if _, found := raw["aud"]; found {
+		c.Audience = c.Strings("aud")
+		if len(c.Audience) == 0 {
+			return nil, invalid("malformed aud claim")
+		}
+	}
+	return c, nil
+}
+
+func verifySignature(key Key, signed, signature []byte) bool {
+	digest := sha256.Sum256(signed)
+	switch key.Algorithm {
+	case HS256:
+		secret, ok := key.Key.([]byte)
+		if !ok {
+			return false
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case RS256:
+		pub, ok := key.Key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature) == nil
+	case ES256:
+		// JWS encodes ECDSA signatures as the fixed-size concatenation
+		// of r and s rather than ASN.1.
+		pub, ok := key.Key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(pub, digest[:], r, s)
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package jwtauth_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hmmftg/requestCore/v2/jwtauth"
+)
+
+var (
+	now        = time.Unix(1_700_000_000, 0)
+	hmacSecret = []byte("0123456789abcdef0123456789abcdef")
+	rsaKey, _  = rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _   = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+)
+
+// sign returns a compact JWS of claims. key is an HMAC secret or a
+// private key matching alg.
+func sign(t *testing.T, alg, kid string, key any, claims map[string]any) string {
+	t.Helper()
+	header := map[string]any{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	var err error
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest[:])
+		if err == nil {
+			sig = make([]byte, 64)
+			r.FillBytes(sig[:32])
+			s.FillBytes(sig[32:])
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss":    "https://idp.example",
+		"aud":    []string{"payments", "other"},
+		"sub":    "u-42",
+		"exp":    now.Add(time.Hour).Unix(),
+		"nbf":    now.Add(-time.Minute).Unix(),
+		"branch": "0101",
+		"bank":   17,
+	}
+}
+
+func with(claims map[string]any, name string, value any) map[string]any {
+	if value == nil {
+		delete(claims, name)
+	} else {
+		claims[name] = value
+	}
+	return claims
+}
+
+func newVerifier(t *testing.T) *jwtauth.Verifier {
+	t.Helper()
+	v, err := jwtauth.NewVerifier(jwtauth.VerifierConfig{
+		Keys: jwtauth.StaticKeys{
+			{ID: "hs", Algorithm: jwtauth.HS256, Key: hmacSecret},
+			{ID: "rs", Algorithm: jwtauth.RS256, Key: &rsaKey.PublicKey},
+			{Algorithm: jwtauth.ES256, Key: &ecKey.PublicKey},
+		},
+		Issuer:    "https://idp.example",
+		Audience:  []string{"payments"},
+		ClockSkew: 30 * time.Second,
+		Clock:     func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestVerifier(t *testing.T) {
+	v := newVerifier(t)
+	for _, tt := range []struct {
+		name, alg, kid string
+		key            any
+	}{
+		{"HS256", jwtauth.HS256, "hs", hmacSecret},
+		{"RS256", jwtauth.RS256, "rs", rsaKey},
+		{"ES256 without kid", jwtauth.ES256, "", ecKey},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.Verify(t.Context(), sign(t, tt.alg, tt.kid, tt.key, validClaims()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Subject != "u-42" || claims.String("branch") != "0101" || claims.String("bank") != "17" ||
+				!claims.ExpiresAt.Equal(now.Add(time.Hour)) || len(claims.Audience) != 2 {
+				t.Fatalf("unexpected claims %+v", claims)
+			}
+		})
+	}
+}
+
+func TestVerifier_Rejected(t *testing.T) {
+	v := newVerifier(t)
+	otherRSA, _ := rsa.GenerateKey(rand.Reader, 2048)
+	pubDER := rsaKey.PublicKey.N.Bytes()
+	tests := []struct {
+		name  string
+		token string
+		code  string
+	}{
+		{"expired beyond skew", sign(t, jwtauth.HS256, "hs", hmacSecret, with(validClaims(), "exp", now.Add(-31*time.Second).Unix())), "TOKEN_EXPIRED"},
+		{"not yet valid beyond skew", sign(t, jwtauth.HS256, "hs", hmacSecret, with(validClaims(), "nbf", now.Add(31*time.Second).Unix())), "TOKEN_INVALID"},
+		{"missing exp", sign(t, jwtauth.HS256, "hs", hmacSecret, with(validClaims(), "exp", nil)), "TOKEN_INVALID"},
+		{"wrong issuer", sign(t, jwtauth.HS256, "hs", hmacSecret, with(validClaims(), "iss", "https://evil.example")), "TOKEN_INVALID"},
+		{"wrong audience", sign(t, jwtauth.HS256, "hs", hmacSecret, with(validClaims(), "aud", "ledger")), "TOKEN_INVALID"},
+		{"unknown signer", sign(t, jwtauth.RS256, "rs", otherRSA, validClaims()), "TOKEN_INVALID"},
+		{"alg none", sign(t, "none", "", []byte{}, validClaims()), "TOKEN_INVALID"},
+		// An HMAC keyed with the RSA public key must not verify against
+		// the RS256 key.
+		{"algorithm confusion", sign(t, jwtauth.HS256, "rs", pubDER, validClaims()), "TOKEN_INVALID"},
+		{"malformed", "a.b", "TOKEN_INVALID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.Verify(t.Context(), tt.token)
+			var authErr *jwtauth.Error
+			if claims != nil || !errors.As(err, &authErr) {
+				t.Fatalf("expected *Error, got %v, %v", claims, err)
+			}
+			if got := authErr.Unwrap().Error(); !strings.Contains(got, tt.code) {
+				t.Fatalf("expected %s, got %s", tt.code, got)
+			}
+		})
+	}
+
+	skewed := sign(t, jwtauth.HS256, "hs", hmacSecret, with(validClaims(), "exp", now.Add(-29*time.Second).Unix()))
+	if _, err := v.Verify(t.Context(), skewed); err != nil {
+		t.Fatalf("expected a token expired within the skew to pass, got %v", err)
+	}
+}
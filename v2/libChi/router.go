@@ -197,6 +197,8 @@ func (g *chiGroup) wrapHandler(h routing.Handler) http.HandlerFunc {
 			},
 		}
 		reqCtx.SetCommitState(commit)
+		reqCtx.Context = v2wf.NewContext(reqCtx.Context, reqCtx)
+		reqCtx.Context = routing.WithUpgrader(reqCtx.Context, v2libNetHttp.NewUpgrader(w, req))
 
 		// Apply middleware chain
 		chain := h
@@ -8,7 +8,11 @@
 package libFiber
 
 import (
+	"bufio"
+	"context"
+	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 
@@ -110,3 +114,67 @@ func (p *FiberParserV2) SetCommitState(cs *v2wf.CommitState) {
 func (p *FiberParserV2) SetBeforeCommitHookRunner(fn func() error) {
 	p.hookRunner = fn
 }
+
+// StreamEvents implements v2wf.RequestParser. fasthttp writes streamed
+// bodies after the handler returns, so fn runs then, on fasthttp's body
+// stream writer, and its error is logged. Client disconnects surface as
+// failed flushes, so set EventStreamOptions.Heartbeat to notice them on
+// idle streams.
+func (p *FiberParserV2) StreamEvents(opts v2wf.EventStreamOptions, fn func(v2wf.EventStream) error) error {
+	if p.commitState != nil && p.commitState.Committed() {
+		return nil
+	}
+	if p.hookRunner != nil {
+		if err := p.hookRunner(); err != nil {
+			return err
+		}
+	}
+	v2wf.SetEventStreamHeaders(p.Ctx.Set)
+	p.Ctx.Status(http.StatusOK)
+	// The fiber.Ctx is released once the handler returns; capture what
+	// the stream needs now.
+	ctx := context.WithoutCancel(p.Ctx.UserContext())
+	lastEventID := strings.Clone(p.Ctx.Get(v2wf.HeaderLastEventID))
+	path := strings.Clone(p.Ctx.Path())
+	p.Ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		stream := v2wf.NewEventStreamWriter(ctx, w, w.Flush, lastEventID)
+		if err := stream.Run(opts, fn); err != nil {
+			slog.Warn("libFiber: event stream failed", slog.String("path", path), slog.Any("error", err))
+		}
+	})
+	if p.commitState != nil {
+		p.commitState.MarkCommitted(http.StatusOK)
+	}
+	return nil
+}
+
+// StreamResponse implements v2wf.RequestParser. fasthttp writes streamed
+// bodies after the handler returns, so fn runs then, on fasthttp's body
+// stream writer, and its error is logged. fasthttp cannot abort a
+// streamed body, so a failed stream still ends with a terminating chunk;
+// formats such as NDJSON let clients detect the truncation.
+func (p *FiberParserV2) StreamResponse(status int, contentType string, fn func(v2wf.ResponseStream) error) error {
+	if p.commitState != nil && p.commitState.Committed() {
+		return nil
+	}
+	if p.hookRunner != nil {
+		if err := p.hookRunner(); err != nil {
+			return err
+		}
+	}
+	p.Ctx.Set("Content-Type", contentType)
+	p.Ctx.Status(status)
+	// The fiber.Ctx is released once the handler returns; capture what
+	// the stream needs now.
+	ctx := context.WithoutCancel(p.Ctx.UserContext())
+	path := strings.Clone(p.Ctx.Path())
+	p.Ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := v2wf.NewResponseStreamWriter(ctx, w, w.Flush).Run(fn); err != nil {
+			slog.Warn("libFiber: response stream failed", slog.String("path", path), slog.Any("error", err))
+		}
+	})
+	if p.commitState != nil {
+		p.commitState.MarkCommitted(status)
+	}
+	return nil
+}
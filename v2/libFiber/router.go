@@ -216,7 +216,7 @@ func (r *FiberRouter) registerCatchAll() {
 			},
 		}
 		reqCtx.SetCommitState(commit)
-		reqCtx.Context = c.UserContext()
+		reqCtx.Context = v2wf.NewContext(c.UserContext(), reqCtx)
 		if err := handler(reqCtx); err != nil {
 			r.dispatchError(reqCtx, err)
 		}
@@ -279,7 +279,8 @@ func (r *FiberRouter) wrapHandler(h routing.Handler) fiber.Handler {
 			},
 		}
 		reqCtx.SetCommitState(commit)
-		reqCtx.Context = c.UserContext()
+		reqCtx.Context = v2wf.NewContext(c.UserContext(), reqCtx)
+		reqCtx.Context = routing.WithUpgrader(reqCtx.Context, &upgrader{c: c})
 
 		// Apply middleware chain
 		chain := h
@@ -0,0 +1,92 @@
+package libFiber
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gorilla/websocket"
+)
+
+// upgrader implements routing.Upgrader on fasthttp's connection
+// hijacking. fasthttp hands over the connection only after the handler
+// returns, so the handshake and serve run then, on their own goroutine.
+type upgrader struct {
+	c   *fiber.Ctx
+	req *http.Request
+}
+
+// Request implements routing.Upgrader. The request is copied out of
+// fasthttp's buffers, which are reused once the handler returns.
+func (u *upgrader) Request() (*http.Request, error) {
+	if u.req != nil {
+		return u.req, nil
+	}
+	fr := u.c.Request()
+	requestURI := string(fr.RequestURI())
+	uri, err := url.ParseRequestURI(requestURI)
+	if err != nil {
+		return nil, err
+	}
+	header := make(http.Header)
+	for k, v := range fr.Header.All() {
+		header.Add(string(k), string(v))
+	}
+	u.req = &http.Request{
+		Method:     string(fr.Header.Method()),
+		URL:        uri,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Host:       string(fr.Host()),
+		RemoteAddr: u.c.Context().RemoteAddr().String(),
+		RequestURI: requestURI,
+	}
+	return u.req, nil
+}
+
+// Upgrade implements routing.Upgrader. It returns once the hijack is
+// scheduled; the handshake response is written by up on the hijacked
+// connection.
+func (u *upgrader) Upgrade(up *websocket.Upgrader, serve func(*websocket.Conn)) error {
+	req, err := u.Request()
+	if err != nil {
+		return err
+	}
+	ctx := u.c.Context()
+	ctx.HijackSetNoResponse(true)
+	ctx.Hijack(func(conn net.Conn) {
+		ws, err := up.Upgrade(&hijackWriter{conn: conn, header: make(http.Header)}, req, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		serve(ws)
+	})
+	return nil
+}
+
+// hijackWriter is the http.ResponseWriter handed to the gorilla
+// upgrader, which hijacks it at once and writes the handshake response
+// to the connection itself.
+type hijackWriter struct {
+	conn   net.Conn
+	header http.Header
+}
+
+func (w *hijackWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *hijackWriter) Write(b []byte) (int, error) {
+	return w.conn.Write(b)
+}
+
+func (w *hijackWriter) WriteHeader(int) {}
+
+func (w *hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.conn, bufio.NewReadWriter(bufio.NewReader(w.conn), bufio.NewWriter(w.conn)), nil
+}
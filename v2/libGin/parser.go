@@ -6,6 +6,7 @@ package libGin
 
 import (
 	"net/http"
+	"time"
 
 	legacyLibGin "github.com/hmmftg/requestCore/libGin"
 	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
@@ -80,3 +81,57 @@ func (p *GinParserV2) SetCommitState(cs *v2wf.CommitState) {
 func (p *GinParserV2) SetBeforeCommitHookRunner(fn func() error) {
 	p.hookRunner = fn
 }
+
+// StreamEvents implements v2wf.RequestParser. The server's write
+// deadline is cleared for the stream, and the request context signals
+// client disconnects.
+func (p *GinParserV2) StreamEvents(opts v2wf.EventStreamOptions, fn func(v2wf.EventStream) error) error {
+	if p.commitState != nil && p.commitState.Committed() {
+		return nil
+	}
+	if p.hookRunner != nil {
+		if err := p.hookRunner(); err != nil {
+			return err
+		}
+	}
+	v2wf.SetEventStreamHeaders(p.Ctx.Header)
+	p.Ctx.Status(http.StatusOK)
+	p.Ctx.Writer.WriteHeaderNow()
+	if p.commitState != nil {
+		p.commitState.MarkCommitted(http.StatusOK)
+	}
+	rc := http.NewResponseController(p.Ctx.Writer)
+	_ = rc.SetWriteDeadline(time.Time{})
+	stream := v2wf.NewEventStreamWriter(p.Ctx.Request.Context(), p.Ctx.Writer, rc.Flush, p.Ctx.GetHeader(v2wf.HeaderLastEventID))
+	return stream.Run(opts, fn)
+}
+
+// StreamResponse implements v2wf.RequestParser. The server's write
+// deadline is cleared for the stream. If fn fails, the connection is
+// hijacked and closed so the chunked body is never terminated.
+func (p *GinParserV2) StreamResponse(status int, contentType string, fn func(v2wf.ResponseStream) error) error {
+	if p.commitState != nil && p.commitState.Committed() {
+		return nil
+	}
+	if p.hookRunner != nil {
+		if err := p.hookRunner(); err != nil {
+			return err
+		}
+	}
+	p.Ctx.Header("Content-Type", contentType)
+	p.Ctx.Status(status)
+	p.Ctx.Writer.WriteHeaderNow()
+	if p.commitState != nil {
+		p.commitState.MarkCommitted(status)
+	}
+	rc := http.NewResponseController(p.Ctx.Writer)
+	_ = rc.SetWriteDeadline(time.Time{})
+	err := v2wf.NewResponseStreamWriter(p.Ctx.Request.Context(), p.Ctx.Writer, rc.Flush).Run(fn)
+	if err != nil {
+		// HTTP/2 connections cannot be hijacked and are left alone.
+		if conn, _, herr := rc.Hijack(); herr == nil {
+			_ = conn.Close()
+		}
+	}
+	return err
+}
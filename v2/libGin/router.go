@@ -174,7 +174,8 @@ func (r *GinRouter) wrapHandler(h routing.Handler) gin.HandlerFunc {
 		}
 		reqCtx.SetCommitState(commit)
 		// Use the request context for cancellation/tracing.
-		reqCtx.Context = c.Request.Context()
+		reqCtx.Context = v2wf.NewContext(c.Request.Context(), reqCtx)
+		reqCtx.Context = routing.WithUpgrader(reqCtx.Context, upgrader{c: c})
 
 		// Apply middleware chain
 		chain := h
@@ -0,0 +1,30 @@
+package libGin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// upgrader implements routing.Upgrader by hijacking the connection of a
+// Gin response writer.
+type upgrader struct {
+	c *gin.Context
+}
+
+// Request implements routing.Upgrader.
+func (u upgrader) Request() (*http.Request, error) {
+	return u.c.Request, nil
+}
+
+// Upgrade implements routing.Upgrader. serve runs before Upgrade returns.
+func (u upgrader) Upgrade(up *websocket.Upgrader, serve func(*websocket.Conn)) error {
+	ws, err := up.Upgrade(u.c.Writer, u.c.Request, nil)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+	serve(ws)
+	return nil
+}
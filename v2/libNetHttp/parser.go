@@ -6,6 +6,7 @@ package libNetHttp
 
 import (
 	"net/http"
+	"time"
 
 	legacyLibNetHttp "github.com/hmmftg/requestCore/libNetHttp"
 	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
@@ -103,3 +104,55 @@ func (p *NetHTTPParserV2) SetCommitState(cs *v2wf.CommitState) {
 func (p *NetHTTPParserV2) SetBeforeCommitHookRunner(fn func() error) {
 	p.hookRunner = fn
 }
+
+// StreamEvents implements v2wf.RequestParser. The server's write
+// deadline is cleared for the stream, and the request context signals
+// client disconnects.
+func (p *NetHTTPParserV2) StreamEvents(opts v2wf.EventStreamOptions, fn func(v2wf.EventStream) error) error {
+	if p.commitState != nil && p.commitState.Committed() {
+		return nil
+	}
+	if p.hookRunner != nil {
+		if err := p.hookRunner(); err != nil {
+			return err
+		}
+	}
+	v2wf.SetEventStreamHeaders(p.Response.Header().Set)
+	p.Response.WriteHeader(http.StatusOK)
+	if p.commitState != nil {
+		p.commitState.MarkCommitted(http.StatusOK)
+	}
+	rc := http.NewResponseController(p.Response)
+	_ = rc.SetWriteDeadline(time.Time{})
+	stream := v2wf.NewEventStreamWriter(p.Request.Context(), p.Response, rc.Flush, p.Request.Header.Get(v2wf.HeaderLastEventID))
+	return stream.Run(opts, fn)
+}
+
+// StreamResponse implements v2wf.RequestParser. The server's write
+// deadline is cleared for the stream. If fn fails, the connection is
+// hijacked and closed so the chunked body is never terminated.
+func (p *NetHTTPParserV2) StreamResponse(status int, contentType string, fn func(v2wf.ResponseStream) error) error {
+	if p.commitState != nil && p.commitState.Committed() {
+		return nil
+	}
+	if p.hookRunner != nil {
+		if err := p.hookRunner(); err != nil {
+			return err
+		}
+	}
+	p.Response.Header().Set("Content-Type", contentType)
+	p.Response.WriteHeader(status)
+	if p.commitState != nil {
+		p.commitState.MarkCommitted(status)
+	}
+	rc := http.NewResponseController(p.Response)
+	_ = rc.SetWriteDeadline(time.Time{})
+	err := v2wf.NewResponseStreamWriter(p.Request.Context(), p.Response, rc.Flush).Run(fn)
+	if err != nil {
+		// HTTP/2 connections cannot be hijacked and are left alone.
+		if conn, _, herr := rc.Hijack(); herr == nil {
+			_ = conn.Close()
+		}
+	}
+	return err
+}
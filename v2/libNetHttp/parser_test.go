@@ -1,11 +1,13 @@
 package libNetHttp
 
 import (
+	"bufio"
 	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/hmmftg/requestCore/v2/routing"
 	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
@@ -286,3 +288,44 @@ func TestNetHTTPRouter_MethodNotAllowed(t *testing.T) {
 		t.Fatalf("expected 'method not allowed', got %s", string(body))
 	}
 }
+
+func TestNetHTTPParserV2_StreamEventsDisconnect(t *testing.T) {
+	started := make(chan struct{})
+	stopped := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parser := InitContextV2(r, w)
+		commit := &v2wf.CommitState{}
+		parser.SetCommitState(commit)
+		stopped <- parser.StreamEvents(v2wf.EventStreamOptions{Heartbeat: 10 * time.Millisecond}, func(s v2wf.EventStream) error {
+			close(started)
+			<-s.Context().Done()
+			return s.Context().Err()
+		})
+		if !commit.Committed() || commit.Status() != http.StatusOK {
+			t.Errorf("expected the stream to commit 200")
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	defer resp.Body.Close()
+	<-started
+	line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+	if line != ": heartbeat\n" {
+		t.Fatalf("expected a heartbeat, got %q", line)
+	}
+	cancel()
+	select {
+	case err := <-stopped:
+		if err == nil {
+			t.Fatal("expected the stream to end with the disconnect")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not notice the disconnect")
+	}
+}
@@ -148,12 +148,17 @@ func (r *NetHTTPRouter) MethodNotAllowed(handler routing.Handler) {
 // intercept405 wraps the mux handler to intercept 405 responses from
 // Go 1.22+ ServeMux and dispatch them through the v2 handler. It buffers
 // the mux's response so the 405 body can be replaced with the v2 handler's
-// response.
+// response. Requests matching a registered pattern cannot be 405s and are
+// served directly, so their responses can stream and be hijacked.
 func (r *NetHTTPRouter) intercept405(next http.Handler) http.Handler {
 	if r.methodNA == nil {
 		return next
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if _, pattern := r.mux.Handler(req); pattern != "" {
+			next.ServeHTTP(w, req)
+			return
+		}
 		rec := httptest.NewRecorder()
 		next.ServeHTTP(rec, req)
 		if rec.Code == http.StatusMethodNotAllowed && r.methodNA != nil {
@@ -172,6 +177,7 @@ func (r *NetHTTPRouter) intercept405(next http.Handler) http.Handler {
 				},
 			}
 			reqCtx.SetCommitState(commit)
+			reqCtx.Context = v2wf.NewContext(reqCtx.Context, reqCtx)
 			if err := r.methodNA(reqCtx); err != nil {
 				r.dispatchError(reqCtx, err)
 			}
@@ -207,6 +213,8 @@ func (r *NetHTTPRouter) wrapHandler(h routing.Handler, mws []routing.Middleware)
 			},
 		}
 		reqCtx.SetCommitState(commit)
+		reqCtx.Context = v2wf.NewContext(reqCtx.Context, reqCtx)
+		reqCtx.Context = routing.WithUpgrader(reqCtx.Context, NewUpgrader(w, req))
 
 		// Apply middleware chain
 		chain := h
@@ -0,0 +1,39 @@
+package libNetHttp
+
+import (
+	"net/http"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/hmmftg/requestCore/v2/routing"
+)
+
+// upgrader implements routing.Upgrader by hijacking the connection of a
+// net/http response writer.
+type upgrader struct {
+	w http.ResponseWriter
+	r *http.Request
+}
+
+// NewUpgrader returns a routing.Upgrader for r and w. The net/http and
+// chi adapters attach it to every request; w must implement
+// http.Hijacker.
+func NewUpgrader(w http.ResponseWriter, r *http.Request) routing.Upgrader {
+	return upgrader{w: w, r: r}
+}
+
+// Request implements routing.Upgrader.
+func (u upgrader) Request() (*http.Request, error) {
+	return u.r, nil
+}
+
+// Upgrade implements routing.Upgrader. serve runs before Upgrade returns.
+func (u upgrader) Upgrade(up *websocket.Upgrader, serve func(*websocket.Conn)) error {
+	ws, err := up.Upgrade(u.w, u.r, nil)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+	serve(ws)
+	return nil
+}
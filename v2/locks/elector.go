@@ -0,0 +1,151 @@
+package locks
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ElectorConfig configures an Elector.
+type ElectorConfig struct {
+	// Interval is how often the Elector refreshes its lock while leader
+	// and retries acquisition while follower. For a LeaseLocker it must
+	// be well below the lease TTL.
+	// Default: 10s.
+	Interval time.Duration
+
+	// OnElected is called when this instance becomes leader. ctx is
+	// cancelled when leadership is lost or the Elector stops, so
+	// long-running singleton loops can use it directly.
+	OnElected func(ctx context.Context)
+
+	// OnRevoked is called when this instance stops being leader.
+	OnRevoked func()
+}
+
+// Elector runs lease-based leader election on a named lock. Exactly one
+// instance across replicas holds the lock; it is the leader until it
+// fails to refresh the lock or stops.
+type Elector struct {
+	locker Locker
+	name   string
+	config ElectorConfig
+
+	leader atomic.Bool
+
+	mu     sync.Mutex
+	lock   Lock
+	cancel context.CancelFunc
+}
+
+// NewElector creates an Elector for the named lock.
+func NewElector(locker Locker, name string, config ElectorConfig) *Elector {
+	if config.Interval <= 0 {
+		config.Interval = 10 * time.Second
+		if ll, ok := locker.(*LeaseLocker); ok && ll.TTL()/3 < config.Interval {
+			config.Interval = ll.TTL() / 3
+		}
+	}
+	return &Elector{locker: locker, name: name, config: config}
+}
+
+// IsLeader reports whether this instance currently holds leadership.
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run campaigns for leadership until ctx is cancelled, then releases the
+// lock if held. It returns ctx.Err().
+func (e *Elector) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.config.Interval)
+	defer ticker.Stop()
+	for {
+		e.step(ctx)
+		select {
+		case <-ctx.Done():
+			e.resign()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// step performs one campaign iteration: refresh when leader, try to
+// acquire when follower.
+func (e *Elector) step(ctx context.Context) {
+	e.mu.Lock()
+	lock := e.lock
+	e.mu.Unlock()
+
+	if lock != nil {
+		if err := lock.Refresh(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("locks: leadership lost",
+				slog.String("lock", e.name), slog.Any("error", err))
+			e.demote()
+		}
+		return
+	}
+
+	lock, ok, err := e.locker.TryAcquire(ctx, e.name)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("locks: leader election failed",
+				slog.String("lock", e.name), slog.Any("error", err))
+		}
+		return
+	}
+	if ok {
+		e.promote(ctx, lock)
+	}
+}
+
+func (e *Elector) promote(ctx context.Context, lock Lock) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	e.mu.Lock()
+	e.lock = lock
+	e.cancel = cancel
+	e.mu.Unlock()
+	e.leader.Store(true)
+	slog.Info("locks: elected leader", slog.String("lock", e.name))
+	if e.config.OnElected != nil {
+		go e.config.OnElected(leaderCtx)
+	}
+}
+
+// demote drops leadership and returns the lock that was held, if any.
+func (e *Elector) demote() Lock {
+	e.mu.Lock()
+	lock, cancel := e.lock, e.cancel
+	e.lock, e.cancel = nil, nil
+	e.mu.Unlock()
+	if lock == nil {
+		return nil
+	}
+	e.leader.Store(false)
+	cancel()
+	if e.config.OnRevoked != nil {
+		e.config.OnRevoked()
+	}
+	return lock
+}
+
+// resign drops leadership and releases the lock.
+func (e *Elector) resign() {
+	lock := e.demote()
+	if lock == nil {
+		return
+	}
+	// ctx is already cancelled; give the release its own deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := lock.Release(ctx); err != nil && !errors.Is(err, ErrNotHeld) {
+		slog.Warn("locks: release leadership",
+			slog.String("lock", e.name), slog.Any("error", err))
+	}
+}
@@ -0,0 +1,225 @@
+package locks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hmmftg/requestCore/libQuery"
+)
+
+// DefaultLeaseTable is the table used by LeaseLocker when no table name
+// is configured.
+const DefaultLeaseTable = "distributed_leases"
+
+// LeaseConfig configures a LeaseLocker.
+type LeaseConfig struct {
+	// Table is the leases table.
+	// Default: DefaultLeaseTable.
+	Table string
+
+	// TTL is how long a lease stays valid without a Refresh. Holders
+	// must refresh well within the TTL (the Elector refreshes every
+	// TTL/3).
+	// Default: 30s.
+	TTL time.Duration
+
+	// Owner identifies this process instance.
+	// Default: hostname, PID, and a random suffix.
+	Owner string
+
+	// Clock is the clock source for deterministic testing.
+	// If nil, time.Now is used.
+	Clock func() time.Time
+}
+
+// LeaseLocker implements Locker with a leases table. A lease is held
+// while its expiry is in the future; holders extend it with Refresh and
+// an expired lease can be taken over by any owner. Each acquisition
+// stores its own token, the owner ID and a random nonce, so leases are
+// exclusive within a process too: a second TryAcquire of a held name
+// fails even from the same locker, and releasing one acquisition never
+// deletes another's lease. The expected schema is:
+//
+//	CREATE TABLE distributed_leases (
+//	    name        VARCHAR(200) PRIMARY KEY,
+//	    owner       VARCHAR(200) NOT NULL,
+//	    expires_at  BIGINT       NOT NULL
+//	);
+//
+// Expiry is stored as Unix milliseconds of the holder's clock, so replica
+// clocks must be reasonably synchronized (well within the TTL).
+type LeaseLocker struct {
+	core   libQuery.QueryRunnerInterface
+	config LeaseConfig
+
+	claim   libQuery.DmlCommand
+	insert  libQuery.DmlCommand
+	renew   libQuery.DmlCommand
+	release libQuery.DmlCommand
+	owner   libQuery.QueryCommand
+}
+
+// NewLeaseLocker creates a LeaseLocker on core.
+func NewLeaseLocker(core libQuery.QueryRunnerInterface, config LeaseConfig) *LeaseLocker {
+	if config.Table == "" {
+		config.Table = DefaultLeaseTable
+	}
+	if config.TTL <= 0 {
+		config.TTL = 30 * time.Second
+	}
+	if config.Owner == "" {
+		config.Owner = defaultOwner()
+	}
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+	t := config.Table
+	return &LeaseLocker{
+		core:   core,
+		config: config,
+		claim: libQuery.DmlCommand{
+			Name:    "lease-claim",
+			Command: "UPDATE " + t + " SET owner=$1, expires_at=$2 WHERE name=$3 AND expires_at<$4",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "UPDATE " + t + " SET owner=:1, expires_at=:2 WHERE name=:3 AND expires_at<:4",
+				libQuery.MySql:  "UPDATE " + t + " SET owner=?, expires_at=? WHERE name=? AND expires_at<?",
+				libQuery.Sqlite: "UPDATE " + t + " SET owner=?1, expires_at=?2 WHERE name=?3 AND expires_at<?4",
+			},
+			Type: libQuery.Update,
+		},
+		insert: libQuery.DmlCommand{
+			Name:    "lease-insert",
+			Command: "INSERT INTO " + t + " (name, owner, expires_at) VALUES ($1, $2, $3)",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "INSERT INTO " + t + " (name, owner, expires_at) VALUES (:1, :2, :3)",
+				libQuery.MySql:  "INSERT INTO " + t + " (name, owner, expires_at) VALUES (?, ?, ?)",
+				libQuery.Sqlite: "INSERT INTO " + t + " (name, owner, expires_at) VALUES (?, ?, ?)",
+			},
+			Type: libQuery.Insert,
+		},
+		renew: libQuery.DmlCommand{
+			Name:    "lease-renew",
+			Command: "UPDATE " + t + " SET expires_at=$1 WHERE name=$2 AND owner=$3",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "UPDATE " + t + " SET expires_at=:1 WHERE name=:2 AND owner=:3",
+				libQuery.MySql:  "UPDATE " + t + " SET expires_at=? WHERE name=? AND owner=?",
+				libQuery.Sqlite: "UPDATE " + t + " SET expires_at=? WHERE name=? AND owner=?",
+			},
+			Type: libQuery.Update,
+		},
+		release: libQuery.DmlCommand{
+			Name:    "lease-release",
+			Command: "DELETE FROM " + t + " WHERE name=$1 AND owner=$2",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "DELETE FROM " + t + " WHERE name=:1 AND owner=:2",
+				libQuery.MySql:  "DELETE FROM " + t + " WHERE name=? AND owner=?",
+				libQuery.Sqlite: "DELETE FROM " + t + " WHERE name=? AND owner=?",
+			},
+			Type: libQuery.Delete,
+		},
+		owner: libQuery.QueryCommand{
+			Name:    "lease-owner",
+			Command: "SELECT owner, expires_at FROM " + t + " WHERE name=$1",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "SELECT owner, expires_at FROM " + t + " WHERE name=:1",
+				libQuery.MySql:  "SELECT owner, expires_at FROM " + t + " WHERE name=?",
+				libQuery.Sqlite: "SELECT owner, expires_at FROM " + t + " WHERE name=?",
+			},
+		},
+	}
+}
+
+// Owner returns this locker's owner ID.
+func (l *LeaseLocker) Owner() string { return l.config.Owner }
+
+// TTL returns the lease duration.
+func (l *LeaseLocker) TTL() time.Duration { return l.config.TTL }
+
+// leaseRow is the database representation of a lease.
+type leaseRow struct {
+	Owner     string `db:"owner"`
+	ExpiresAt int64  `db:"expires_at"`
+}
+
+// TryAcquire claims the lease if it is free or expired, inserting the row
+// on first use.
+func (l *LeaseLocker) TryAcquire(ctx context.Context, name string) (Lock, bool, error) {
+	mode := l.core.GetDbMode()
+	now := l.config.Clock()
+	expires := now.Add(l.config.TTL).UnixMilli()
+	token := l.config.Owner + "-" + randomHex(6)
+
+	result, err := l.core.Dml(ctx, "locks", l.claim.Name, l.claim.GetCommand(mode), token, expires, name, now.UnixMilli())
+	if err != nil {
+		return nil, false, err
+	}
+	if n, err := result.RowsAffected(); err == nil && n > 0 {
+		return l.newLease(name, token), true, nil
+	}
+
+	if _, err := l.core.Dml(ctx, "locks", l.insert.Name, l.insert.GetCommand(mode), name, token, expires); err != nil {
+		// A concurrent insert violates the primary key. Confirm the row
+		// exists before reporting contention rather than a database
+		// failure.
+		rows, qErr := libQuery.QueryToStruct[leaseRow](l.core, l.owner.GetCommand(mode), name)
+		if qErr == nil && len(rows) > 0 {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return l.newLease(name, token), true, nil
+}
+
+func (l *LeaseLocker) newLease(name, token string) *lease {
+	return &lease{locker: l, name: name, token: token, held: true}
+}
+
+// lease is a lock held through a LeaseLocker.
+type lease struct {
+	locker *LeaseLocker
+	name   string
+	token  string // owner column value of this acquisition
+
+	mu   sync.Mutex
+	held bool
+}
+
+func (l *lease) Name() string { return l.name }
+
+// Refresh extends the lease by the TTL. It returns ErrLockLost if another
+// owner took over the lease after it expired.
+func (l *lease) Refresh(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.held {
+		return ErrNotHeld
+	}
+	ll := l.locker
+	expires := ll.config.Clock().Add(ll.config.TTL).UnixMilli()
+	result, err := ll.core.Dml(ctx, "locks", ll.renew.Name, ll.renew.GetCommand(ll.core.GetDbMode()),
+		expires, l.name, l.token)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		l.held = false
+		return fmt.Errorf("%w: %s", ErrLockLost, l.name)
+	}
+	return nil
+}
+
+// Release deletes the lease row if it is still held by this acquisition.
+func (l *lease) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.held {
+		return ErrNotHeld
+	}
+	l.held = false
+	ll := l.locker
+	_, err := ll.core.Dml(ctx, "locks", ll.release.Name, ll.release.GetCommand(ll.core.GetDbMode()),
+		l.name, l.token)
+	return err
+}
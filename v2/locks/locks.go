@@ -0,0 +1,106 @@
+// Package locks provides named distributed locks and lease-based leader
+// election on top of the application database, so that scheduled jobs and
+// singleton pollers run on exactly one replica.
+//
+// Three database strategies are available:
+//
+//   - PostgresLocker uses session-level advisory locks on a pinned
+//     connection (pg_try_advisory_lock).
+//   - OracleLocker uses DBMS_LOCK on a pinned connection.
+//   - LeaseLocker uses a leases table with expiry timestamps and
+//     heartbeats. It works on every database mode and only needs
+//     libQuery.QueryRunnerInterface.
+//
+// NewLocker picks a strategy from the QueryRunnerInterface's DBMode.
+// MemoryLocker is an in-process implementation for tests and
+// single-instance deployments.
+package locks
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/hmmftg/requestCore/libQuery"
+)
+
+// ErrLockLost is returned by Lock.Refresh when the lock is no longer
+// held, for example because the lease expired and another owner took it
+// or the pinned database connection was closed.
+var ErrLockLost = errors.New("locks: lock lost")
+
+// ErrNotHeld is returned by Lock.Release when the lock was already
+// released.
+var ErrNotHeld = errors.New("locks: lock not held")
+
+// Lock is a held distributed lock.
+type Lock interface {
+	// Name returns the lock name.
+	Name() string
+
+	// Refresh confirms the lock is still held and extends its lease
+	// where applicable. It returns ErrLockLost if the lock is gone.
+	Refresh(ctx context.Context) error
+
+	// Release releases the lock. Releasing twice returns ErrNotHeld.
+	Release(ctx context.Context) error
+}
+
+// Locker acquires named locks.
+type Locker interface {
+	// TryAcquire attempts to acquire the named lock without waiting.
+	// It returns (lock, true, nil) on success, (nil, false, nil) if
+	// another owner holds it, and a non-nil error on database failure.
+	TryAcquire(ctx context.Context, name string) (Lock, bool, error)
+}
+
+// NewLocker returns a Locker for the database behind core. Postgres and
+// Oracle use native session locks when the underlying *sql.DB is
+// reachable (libQuery.QueryRunnerModel); every other case uses a
+// LeaseLocker with the given configuration.
+func NewLocker(core libQuery.QueryRunnerInterface, config LeaseConfig) Locker {
+	if db := sqlDB(core); db != nil {
+		switch core.GetDbMode() {
+		case libQuery.Postgres:
+			return NewPostgresLocker(db)
+		case libQuery.Oracle:
+			return NewOracleLocker(db)
+		}
+	}
+	return NewLeaseLocker(core, config)
+}
+
+// sqlDB returns the *sql.DB behind a QueryRunnerInterface, or nil if the
+// implementation does not expose one.
+func sqlDB(core libQuery.QueryRunnerInterface) *sql.DB {
+	switch m := core.(type) {
+	case libQuery.QueryRunnerModel:
+		return m.DB
+	case *libQuery.QueryRunnerModel:
+		if m != nil {
+			return m.DB
+		}
+	}
+	return nil
+}
+
+// defaultOwner returns an owner ID unique to this process instance.
+func defaultOwner() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), randomHex(6))
+}
+
+// randomHex returns n random bytes hex-encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("locks: crypto/rand failed: %v", err))
+	}
+	return fmt.Sprintf("%x", b)
+}
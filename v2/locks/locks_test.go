@@ -0,0 +1,284 @@
+package locks
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hmmftg/requestCore/libQuery"
+)
+
+func newPostgresCore(t *testing.T) (libQuery.QueryRunnerModel, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	core := libQuery.Init(db, "prog", "mod", libQuery.Postgres)
+	core.Mode = libQuery.Postgres
+	return core, mock
+}
+
+// expectDml registers the audited transaction libQuery.Dml runs around
+// every statement.
+func expectDml(mock sqlmock.Sqlmock, pattern string, rows int64) {
+	mock.ExpectBegin()
+	for i := 0; i < 4; i++ {
+		mock.ExpectExec("set_config").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(pattern).WillReturnResult(sqlmock.NewResult(0, rows))
+	mock.ExpectCommit()
+}
+
+func TestNewLocker(t *testing.T) {
+	core, _ := newPostgresCore(t)
+	if _, ok := NewLocker(core, LeaseConfig{}).(*PostgresLocker); !ok {
+		t.Fatal("expected PostgresLocker for Postgres mode")
+	}
+	core.Mode = libQuery.Oracle
+	if _, ok := NewLocker(&core, LeaseConfig{}).(*OracleLocker); !ok {
+		t.Fatal("expected OracleLocker for Oracle mode")
+	}
+	core.Mode = libQuery.Sqlite
+	if _, ok := NewLocker(core, LeaseConfig{}).(*LeaseLocker); !ok {
+		t.Fatal("expected LeaseLocker fallback")
+	}
+}
+
+func TestPostgresLocker(t *testing.T) {
+	core, mock := newPostgresCore(t)
+	locker := NewPostgresLocker(core.DB)
+	key := advisoryKey("nightly-report")
+
+	mock.ExpectQuery("SELECT pg_try_advisory_lock").WithArgs(key).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	lock, ok, err := locker.TryAcquire(context.Background(), "nightly-report")
+	if err != nil || !ok {
+		t.Fatalf("TryAcquire: ok=%v err=%v", ok, err)
+	}
+	if err := lock.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	mock.ExpectQuery("SELECT pg_advisory_unlock").WithArgs(key).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_advisory_unlock"}).AddRow(true))
+	if err := lock.Release(context.Background()); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if err := lock.Release(context.Background()); !errors.Is(err, ErrNotHeld) {
+		t.Fatalf("expected ErrNotHeld on double release, got %v", err)
+	}
+
+	mock.ExpectQuery("SELECT pg_try_advisory_lock").WithArgs(key).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+	if _, ok, err := locker.TryAcquire(context.Background(), "nightly-report"); ok || err != nil {
+		t.Fatalf("expected contention, got ok=%v err=%v", ok, err)
+	}
+
+	// A failed unlock discards the session instead of pooling it with
+	// the lock still held.
+	mock.ExpectQuery("SELECT pg_try_advisory_lock").WithArgs(key).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	lock, ok, err = locker.TryAcquire(context.Background(), "nightly-report")
+	if err != nil || !ok {
+		t.Fatalf("TryAcquire: ok=%v err=%v", ok, err)
+	}
+	mock.ExpectQuery("SELECT pg_advisory_unlock").WithArgs(key).WillReturnError(errors.New("timeout"))
+	mock.ExpectClose()
+	if err := lock.Release(context.Background()); err == nil {
+		t.Fatal("expected the unlock error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestLeaseLocker(t *testing.T) {
+	core, mock := newPostgresCore(t)
+	now := time.UnixMilli(1700000000000)
+	locker := NewLeaseLocker(core, LeaseConfig{
+		Owner: "replica-a",
+		TTL:   time.Minute,
+		Clock: func() time.Time { return now },
+	})
+
+	// First use: claim finds no row, insert creates it.
+	expectDml(mock, "UPDATE distributed_leases SET owner", 0)
+	expectDml(mock, "INSERT INTO distributed_leases", 1)
+	lock, ok, err := locker.TryAcquire(context.Background(), "poller")
+	if err != nil || !ok {
+		t.Fatalf("TryAcquire: ok=%v err=%v", ok, err)
+	}
+
+	expectDml(mock, "UPDATE distributed_leases SET expires_at", 1)
+	if err := lock.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	// Another owner took over the expired lease.
+	expectDml(mock, "UPDATE distributed_leases SET expires_at", 0)
+	if err := lock.Refresh(context.Background()); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("expected ErrLockLost, got %v", err)
+	}
+	if err := lock.Release(context.Background()); !errors.Is(err, ErrNotHeld) {
+		t.Fatalf("expected ErrNotHeld after loss, got %v", err)
+	}
+
+	// Contention: the row exists and belongs to someone else.
+	expectDml(mock, "UPDATE distributed_leases SET owner", 0)
+	mock.ExpectBegin()
+	for i := 0; i < 4; i++ {
+		mock.ExpectExec("set_config").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec("INSERT INTO distributed_leases").WillReturnError(errors.New("duplicate key"))
+	mock.ExpectRollback()
+	mock.ExpectPrepare("SELECT owner, expires_at FROM distributed_leases").ExpectQuery().
+		WithArgs("poller").
+		WillReturnRows(sqlmock.NewRows([]string{"owner", "expires_at"}).AddRow("replica-b", now.Add(time.Minute).UnixMilli()))
+	if _, ok, err := locker.TryAcquire(context.Background(), "poller"); ok || err != nil {
+		t.Fatalf("expected contention, got ok=%v err=%v", ok, err)
+	}
+
+	// Reacquire after takeover, then release.
+	expectDml(mock, "UPDATE distributed_leases SET owner", 1)
+	lock, ok, err = locker.TryAcquire(context.Background(), "poller")
+	if err != nil || !ok {
+		t.Fatalf("TryAcquire: ok=%v err=%v", ok, err)
+	}
+	expectDml(mock, "DELETE FROM distributed_leases", 1)
+	if err := lock.Release(context.Background()); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// tokenArg matches any lease token, recording the first one it sees.
+type tokenArg struct{ token *string }
+
+func (a tokenArg) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	if ok && *a.token == "" {
+		*a.token = s
+	}
+	return ok && s == *a.token
+}
+
+func expectDmlArgs(mock sqlmock.Sqlmock, pattern string, rows int64, args ...driver.Value) {
+	mock.ExpectBegin()
+	for i := 0; i < 4; i++ {
+		mock.ExpectExec("set_config").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(pattern).WithArgs(args...).WillReturnResult(sqlmock.NewResult(0, rows))
+	mock.ExpectCommit()
+}
+
+// TestLeaseLocker_Exclusive verifies a held lease cannot be acquired again
+// by the same locker, and that each acquisition renews and releases only
+// its own lease.
+func TestLeaseLocker_Exclusive(t *testing.T) {
+	core, mock := newPostgresCore(t)
+	now := time.UnixMilli(1700000000000)
+	locker := NewLeaseLocker(core, LeaseConfig{
+		Owner: "replica-a",
+		TTL:   time.Minute,
+		Clock: func() time.Time { return now },
+	})
+	expires := now.Add(time.Minute).UnixMilli()
+
+	var token string
+	expectDmlArgs(mock, "UPDATE distributed_leases SET owner", 0,
+		tokenArg{&token}, expires, "idem", now.UnixMilli())
+	expectDmlArgs(mock, "INSERT INTO distributed_leases", 1, "idem", tokenArg{&token}, expires)
+	lock, ok, err := locker.TryAcquire(context.Background(), "idem")
+	if err != nil || !ok || !strings.HasPrefix(token, "replica-a-") {
+		t.Fatalf("TryAcquire: ok=%v err=%v token=%q", ok, err, token)
+	}
+
+	// The same locker is turned away while the lease is held.
+	expectDml(mock, "UPDATE distributed_leases SET owner", 0)
+	mock.ExpectBegin()
+	for i := 0; i < 4; i++ {
+		mock.ExpectExec("set_config").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec("INSERT INTO distributed_leases").WillReturnError(errors.New("duplicate key"))
+	mock.ExpectRollback()
+	mock.ExpectPrepare("SELECT owner, expires_at FROM distributed_leases").ExpectQuery().
+		WithArgs("idem").
+		WillReturnRows(sqlmock.NewRows([]string{"owner", "expires_at"}).AddRow(token, expires))
+	if _, ok, err := locker.TryAcquire(context.Background(), "idem"); ok || err != nil {
+		t.Fatalf("expected contention within the process, got ok=%v err=%v", ok, err)
+	}
+
+	expectDmlArgs(mock, "UPDATE distributed_leases SET expires_at", 1, expires, "idem", token)
+	if err := lock.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	expectDmlArgs(mock, "DELETE FROM distributed_leases", 1, "idem", token)
+	if err := lock.Release(context.Background()); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("condition not met")
+}
+
+func TestElector_Failover(t *testing.T) {
+	locker := NewMemoryLocker()
+	var elected, revoked atomic.Int32
+	config := ElectorConfig{
+		Interval:  10 * time.Millisecond,
+		OnElected: func(context.Context) { elected.Add(1) },
+		OnRevoked: func() { revoked.Add(1) },
+	}
+	a := NewElector(locker, "scheduler", config)
+	b := NewElector(locker, "scheduler", config)
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+	doneA := make(chan struct{})
+	go func() { _ = a.Run(ctxA); close(doneA) }()
+	waitFor(t, a.IsLeader)
+
+	go func() { _ = b.Run(ctxB) }()
+	time.Sleep(50 * time.Millisecond)
+	if b.IsLeader() {
+		t.Fatal("expected a single leader")
+	}
+
+	// Simulate lease expiry: a loses leadership, b takes over.
+	locker.Revoke("scheduler")
+	waitFor(t, func() bool { return !a.IsLeader() && b.IsLeader() || a.IsLeader() && !b.IsLeader() })
+	waitFor(t, func() bool { return revoked.Load() >= 1 })
+
+	// Stopping an elector releases its lock for the other.
+	cancelA()
+	<-doneA
+	waitFor(t, b.IsLeader)
+	if elected.Load() < 2 {
+		t.Fatalf("expected at least 2 elections, got %d", elected.Load())
+	}
+}
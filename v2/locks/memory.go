@@ -0,0 +1,66 @@
+package locks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// MemoryLocker is an in-process Locker. Locks are only exclusive within
+// a single process, so it is suitable for tests and single-instance
+// deployments.
+type MemoryLocker struct {
+	mu   sync.Mutex
+	held map[string]*memoryLock
+}
+
+// NewMemoryLocker creates an empty MemoryLocker.
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{held: make(map[string]*memoryLock)}
+}
+
+// TryAcquire acquires the named lock if no other holder has it.
+func (m *MemoryLocker) TryAcquire(_ context.Context, name string) (Lock, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.held[name]; ok {
+		return nil, false, nil
+	}
+	l := &memoryLock{locker: m, name: name}
+	m.held[name] = l
+	return l, true, nil
+}
+
+// Revoke forcibly drops the named lock, as if its lease had expired.
+// The holder's next Refresh returns ErrLockLost.
+func (m *MemoryLocker) Revoke(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.held, name)
+}
+
+type memoryLock struct {
+	locker *MemoryLocker
+	name   string
+}
+
+func (l *memoryLock) Name() string { return l.name }
+
+func (l *memoryLock) Refresh(context.Context) error {
+	l.locker.mu.Lock()
+	defer l.locker.mu.Unlock()
+	if l.locker.held[l.name] != l {
+		return fmt.Errorf("%w: %s", ErrLockLost, l.name)
+	}
+	return nil
+}
+
+func (l *memoryLock) Release(context.Context) error {
+	l.locker.mu.Lock()
+	defer l.locker.mu.Unlock()
+	if l.locker.held[l.name] != l {
+		return ErrNotHeld
+	}
+	delete(l.locker.held, l.name)
+	return nil
+}
@@ -0,0 +1,190 @@
+package locks
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// sessionLock is a lock bound to a pinned database connection. Postgres
+// advisory locks and Oracle DBMS_LOCK locks belong to the database
+// session, so the connection is held for the lifetime of the lock and
+// returned to the pool on release. A connection whose lock state is
+// unknown is discarded instead, so no later borrower of the pooled
+// session inherits the lock.
+type sessionLock struct {
+	name    string
+	release func(ctx context.Context, conn *sql.Conn) error
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+func (l *sessionLock) Name() string { return l.name }
+
+// Refresh pings the pinned connection. A broken connection means the
+// database session, and with it the lock, is gone.
+func (l *sessionLock) Refresh(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return ErrNotHeld
+	}
+	if err := l.conn.PingContext(ctx); err != nil {
+		discard(l.conn)
+		l.conn = nil
+		return fmt.Errorf("%w: %s: %v", ErrLockLost, l.name, err)
+	}
+	return nil
+}
+
+func (l *sessionLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return ErrNotHeld
+	}
+	err := l.release(ctx, l.conn)
+	if err != nil {
+		// Closing would return the session, still holding the lock, to
+		// the pool; discarding it ends the session and the lock with it.
+		discard(l.conn)
+	} else {
+		_ = l.conn.Close()
+	}
+	l.conn = nil
+	return err
+}
+
+// discard closes conn and removes its session from the pool: returning
+// driver.ErrBadConn from Raw marks the connection bad, so Close ends the
+// session instead of pooling it.
+func discard(conn *sql.Conn) {
+	_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+	_ = conn.Close()
+}
+
+// PostgresLocker implements Locker with Postgres session-level advisory
+// locks. Lock names are hashed to the 64-bit advisory lock key space.
+type PostgresLocker struct {
+	db *sql.DB
+}
+
+// NewPostgresLocker creates a PostgresLocker on db.
+func NewPostgresLocker(db *sql.DB) *PostgresLocker {
+	return &PostgresLocker{db: db}
+}
+
+// advisoryKey maps a lock name to a Postgres advisory lock key.
+func advisoryKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// TryAcquire calls pg_try_advisory_lock on a dedicated connection.
+func (l *PostgresLocker) TryAcquire(ctx context.Context, name string) (Lock, bool, error) {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("locks: acquire connection for %q: %w", name, err)
+	}
+	key := advisoryKey(name)
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		discard(conn)
+		return nil, false, fmt.Errorf("locks: pg_try_advisory_lock(%q): %w", name, err)
+	}
+	if !acquired {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+	return &sessionLock{
+		name: name,
+		conn: conn,
+		release: func(ctx context.Context, conn *sql.Conn) error {
+			var released bool
+			if err := conn.QueryRowContext(ctx, "SELECT pg_advisory_unlock($1)", key).Scan(&released); err != nil {
+				return fmt.Errorf("locks: pg_advisory_unlock(%q): %w", name, err)
+			}
+			if !released {
+				return fmt.Errorf("%w: %s", ErrNotHeld, name)
+			}
+			return nil
+		},
+	}, true, nil
+}
+
+// DBMS_LOCK.REQUEST and DBMS_LOCK.RELEASE result codes.
+const (
+	oracleLockSuccess      = 0
+	oracleLockTimeout      = 1
+	oracleLockAlreadyOwned = 4
+)
+
+// OracleLocker implements Locker with Oracle DBMS_LOCK exclusive locks.
+// The database user needs EXECUTE on DBMS_LOCK.
+type OracleLocker struct {
+	db *sql.DB
+}
+
+// NewOracleLocker creates an OracleLocker on db.
+func NewOracleLocker(db *sql.DB) *OracleLocker {
+	return &OracleLocker{db: db}
+}
+
+const (
+	oracleRequestCommand = `--sql
+		DECLARE
+			h VARCHAR2(128);
+		BEGIN
+			DBMS_LOCK.ALLOCATE_UNIQUE(:1, h);
+			:2 := DBMS_LOCK.REQUEST(h, DBMS_LOCK.X_MODE, 0, FALSE);
+		END;`
+	oracleReleaseCommand = `--sql
+		DECLARE
+			h VARCHAR2(128);
+		BEGIN
+			DBMS_LOCK.ALLOCATE_UNIQUE(:1, h);
+			:2 := DBMS_LOCK.RELEASE(h);
+		END;`
+)
+
+// TryAcquire calls DBMS_LOCK.REQUEST with a zero timeout on a dedicated
+// connection.
+func (l *OracleLocker) TryAcquire(ctx context.Context, name string) (Lock, bool, error) {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("locks: acquire connection for %q: %w", name, err)
+	}
+	var result int64
+	if _, err := conn.ExecContext(ctx, oracleRequestCommand, name, sql.Out{Dest: &result}); err != nil {
+		discard(conn)
+		return nil, false, fmt.Errorf("locks: DBMS_LOCK.REQUEST(%q): %w", name, err)
+	}
+	switch result {
+	case oracleLockSuccess, oracleLockAlreadyOwned:
+	case oracleLockTimeout:
+		_ = conn.Close()
+		return nil, false, nil
+	default:
+		discard(conn)
+		return nil, false, fmt.Errorf("locks: DBMS_LOCK.REQUEST(%q) returned %d", name, result)
+	}
+	return &sessionLock{
+		name: name,
+		conn: conn,
+		release: func(ctx context.Context, conn *sql.Conn) error {
+			var result int64
+			if _, err := conn.ExecContext(ctx, oracleReleaseCommand, name, sql.Out{Dest: &result}); err != nil {
+				return fmt.Errorf("locks: DBMS_LOCK.RELEASE(%q): %w", name, err)
+			}
+			if result != oracleLockSuccess {
+				return fmt.Errorf("locks: DBMS_LOCK.RELEASE(%q) returned %d", name, result)
+			}
+			return nil
+		},
+	}, true, nil
+}
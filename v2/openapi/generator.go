@@ -0,0 +1,390 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/hmmftg/requestCore/libRequest"
+	legacyResponse "github.com/hmmftg/requestCore/response"
+
+	"github.com/hmmftg/requestCore/v2/handlers"
+	"github.com/hmmftg/requestCore/v2/routing"
+)
+
+// ErrorSchemaName is the component name of the error envelope.
+const ErrorSchemaName = "WsResponse"
+
+var (
+	requestHeaderType = reflect.TypeOf(libRequest.RequestHeader{})
+	paginationType    = reflect.TypeOf(libRequest.PaginationData{})
+	wsResponseType    = reflect.TypeOf(legacyResponse.WsResponse{})
+)
+
+// Generator accumulates endpoints and builds the OpenAPI document. It is
+// safe for concurrent use; the document is rebuilt lazily after an
+// endpoint is added.
+type Generator struct {
+	mu        sync.Mutex
+	info      Info
+	servers   []Server
+	endpoints []Route
+	source    func() []Route
+	doc       *Document
+	raw       []byte
+}
+
+// Route is an endpoint registered for a method on a full canonical
+// pattern.
+type Route struct {
+	Method   string
+	Pattern  string
+	Endpoint *handlers.Endpoint
+
+	// Version is the API version of routes registered through a
+	// routing.Versioned group, documented as x-api-version.
+	Version string
+
+	// Deprecated marks the operation deprecated in addition to
+	// Endpoint.Deprecated, for example for a deprecated API version.
+	Deprecated bool
+}
+
+// NewGenerator creates a Generator for an API described by info. An empty
+// title or version is replaced with a placeholder, since both are required
+// by the specification.
+func NewGenerator(info Info) *Generator {
+	if info.Title == "" {
+		info.Title = "API"
+	}
+	if info.Version == "" {
+		info.Version = "0.0.0"
+	}
+	return &Generator{info: info}
+}
+
+// AddServer adds a base URL to the document.
+func (g *Generator) AddServer(server Server) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.servers = append(g.servers, server)
+	g.doc, g.raw = nil, nil
+}
+
+// Add records an endpoint registered for method on the full canonical
+// pattern (for example "/api/users/{id}").
+func (g *Generator) Add(method, pattern string, endpoint *handlers.Endpoint) {
+	if endpoint == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.endpoints = append(g.endpoints, Route{Method: strings.ToUpper(method), Pattern: pattern, Endpoint: endpoint})
+	g.doc, g.raw = nil, nil
+}
+
+// SetSource documents the routes returned by source in addition to those
+// passed to Add. Source is called on every Document and JSON call, since
+// the routes it lists may change; the v2 App uses it to document the
+// routes of its router.
+func (g *Generator) SetSource(source func() []Route) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.source = source
+	g.doc, g.raw = nil, nil
+}
+
+// Document returns the OpenAPI document for the endpoints added so far.
+// When a method and pattern are served by several API versions, the
+// latest version is documented; see DocumentVersion. The returned
+// document is shared and must not be modified.
+func (g *Generator) Document() *Document {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.documentLocked()
+}
+
+// DocumentVersion returns the document of API version: its versioned
+// routes, including those it serves by falling back to a lower version,
+// and every unversioned route.
+func (g *Generator) DocumentVersion(version string) *Document {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.build(version)
+}
+
+// JSON returns the document encoded as JSON.
+func (g *Generator) JSON() ([]byte, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.raw == nil || g.source != nil {
+		raw, err := json.Marshal(g.documentLocked())
+		if err != nil {
+			return nil, fmt.Errorf("openapi: encode document: %w", err)
+		}
+		g.raw = raw
+	}
+	return g.raw, nil
+}
+
+// JSONVersion returns the document of API version encoded as JSON.
+func (g *Generator) JSONVersion(version string) ([]byte, error) {
+	raw, err := json.Marshal(g.DocumentVersion(version))
+	if err != nil {
+		return nil, fmt.Errorf("openapi: encode document: %w", err)
+	}
+	return raw, nil
+}
+
+func (g *Generator) documentLocked() *Document {
+	if g.doc == nil || g.source != nil {
+		g.doc = g.build("")
+	}
+	return g.doc
+}
+
+// routes returns the routes documented for version, or for the latest
+// version of each method and pattern when version is "".
+func (g *Generator) routes(version string) []Route {
+	routes := slices.Clone(g.endpoints)
+	if g.source != nil {
+		routes = append(routes, g.source()...)
+	}
+	selected := make([]Route, 0, len(routes))
+	latest := make(map[string]int)
+	for _, r := range routes {
+		if r.Endpoint == nil {
+			continue
+		}
+		if version != "" {
+			if r.Version == "" || routing.CompareVersions(r.Version, version) == 0 {
+				selected = append(selected, r)
+			}
+			continue
+		}
+		key := r.Method + " " + r.Pattern
+		if i, ok := latest[key]; ok {
+			if routing.CompareVersions(r.Version, selected[i].Version) > 0 {
+				selected[i] = r
+			}
+			continue
+		}
+		latest[key] = len(selected)
+		selected = append(selected, r)
+	}
+	return selected
+}
+
+func (g *Generator) build(version string) *Document {
+	s := newSchemas()
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    g.info,
+		Servers: slices.Clone(g.servers),
+		Paths:   make(map[string]*PathItem),
+	}
+	errorRef := &Schema{Ref: "#/components/schemas/" + ErrorSchemaName}
+	s.byType[wsResponseType] = ErrorSchemaName
+	s.byName[ErrorSchemaName] = s.structSchema(wsResponseType)
+
+	ids := make(map[string]int)
+	tags := make(map[string]bool)
+	for _, r := range g.routes(version) {
+		op := g.operation(s, r, errorRef)
+		id := op.OperationID
+		if n := ids[id]; n > 0 {
+			op.OperationID = fmt.Sprintf("%s%d", id, n+1)
+		}
+		ids[id]++
+		for _, tag := range op.Tags {
+			if !tags[tag] {
+				tags[tag] = true
+				doc.Tags = append(doc.Tags, Tag{Name: tag})
+			}
+		}
+
+		item, ok := doc.Paths[r.Pattern]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[r.Pattern] = item
+		}
+		(*item)[strings.ToLower(r.Method)] = op
+	}
+	doc.Components.Schemas = s.byName
+	return doc
+}
+
+func (g *Generator) operation(s *schemas, r Route, errorRef *Schema) *Operation {
+	e := r.Endpoint
+	op := &Operation{
+		OperationID: operationID(r.Method, r.Pattern, e.Title),
+		Summary:     e.Title,
+		Description: e.Description,
+		Tags:        slices.Clone(e.Tags),
+		Deprecated:  e.Deprecated || r.Deprecated,
+		APIVersion:  r.Version,
+		Responses: map[string]*Response{
+			"default": {
+				Description: "Error",
+				Content:     map[string]MediaType{"application/json": {Schema: errorRef}},
+			},
+		},
+	}
+
+	// Path parameters come from the pattern; bound URI fields refine them.
+	uriFields := map[string]*Parameter{}
+	if e.RequestType() != nil && bindsURI(e.Body) {
+		for _, p := range fieldParameters(s, e.RequestType(), "path", "uri", "params") {
+			uriFields[p.Name] = p
+		}
+	}
+	for _, name := range pathParams(r.Pattern) {
+		p, ok := uriFields[name]
+		if !ok {
+			p = &Parameter{Name: name, In: "path", Schema: &Schema{Type: "string"}}
+		}
+		p.Required = true
+		op.Parameters = append(op.Parameters, p)
+	}
+
+	if e.RequestType() != nil {
+		switch e.Body {
+		case libRequest.JSON, libRequest.JSONWithURI:
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{"application/json": {Schema: s.schemaFor(e.RequestType())}},
+			}
+		case libRequest.Query, libRequest.QueryWithURI, libRequest.QueryWithPagination:
+			op.Parameters = append(op.Parameters, fieldParameters(s, e.RequestType(), "query", "form", "query")...)
+		}
+	}
+	if e.Body == libRequest.QueryWithPagination || e.Body == libRequest.URIAndPagination {
+		op.Parameters = append(op.Parameters, fieldParameters(s, paginationType, "query", "form", "query")...)
+	}
+
+	for _, p := range fieldParameters(s, requestHeaderType, "header", "header") {
+		p.Required = p.Required && e.ValidateHeader
+		op.Parameters = append(op.Parameters, p)
+	}
+
+	ok := &Response{Description: "Success"}
+	if t := e.ResponseType(); t != nil && !emptyType(t) {
+		schema := s.schemaFor(t)
+		ok.Content = map[string]MediaType{}
+		for _, mediaType := range produces(e) {
+			ok.Content[mediaType] = MediaType{Schema: schema}
+		}
+	}
+	op.Responses[fmt.Sprint(http.StatusOK)] = ok
+	return op
+}
+
+func bindsURI(body libRequest.Type) bool {
+	switch body {
+	case libRequest.URI, libRequest.URIAndPagination, libRequest.JSONWithURI, libRequest.QueryWithURI:
+		return true
+	}
+	return false
+}
+
+// fieldParameters describes the fields of struct t carrying one of the
+// given tags as parameters located in "in".
+func fieldParameters(s *schemas, t reflect.Type, in string, tagNames ...string) []*Parameter {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var params []*Parameter
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			params = append(params, fieldParameters(s, f.Type, in, tagNames...)...)
+			continue
+		}
+		var name string
+		for _, tag := range tagNames {
+			if v, _, _ := strings.Cut(f.Tag.Get(tag), ","); v != "" && v != "-" {
+				name = v
+				break
+			}
+		}
+		if name == "" {
+			continue
+		}
+		schema := s.schemaFor(f.Type)
+		params = append(params, &Parameter{
+			Name:     name,
+			In:       in,
+			Required: applyValidation(schema, f.Tag.Get("validate")),
+			Schema:   schema,
+		})
+	}
+	return params
+}
+
+// pathParams returns the {name} parameters of a canonical pattern.
+func pathParams(pattern string) []string {
+	var names []string
+	for {
+		start := strings.IndexByte(pattern, '{')
+		if start < 0 {
+			return names
+		}
+		end := strings.IndexByte(pattern[start:], '}')
+		if end < 0 {
+			return names
+		}
+		names = append(names, pattern[start+1:start+end])
+		pattern = pattern[start+end+1:]
+	}
+}
+
+// emptyType reports whether t has no meaningful response body, such as
+// struct{}.
+func emptyType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.NumField() == 0
+}
+
+// operationID derives a camelCase operation ID from the endpoint title,
+// or from the method and pattern when the title is empty.
+func operationID(method, pattern, title string) string {
+	source := title
+	if source == "" {
+		source = strings.ToLower(method) + " " + pattern
+	}
+	var b strings.Builder
+	upper := false
+	for _, r := range source {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = b.Len() > 0
+			continue
+		}
+		switch {
+		case b.Len() == 0:
+			r = unicode.ToLower(r)
+		case upper:
+			r = unicode.ToUpper(r)
+		}
+		upper = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// produces returns the media types of an endpoint's success response.
+func produces(e *handlers.Endpoint) []string {
+	if len(e.Produces) == 0 {
+		return []string{"application/json"}
+	}
+	return e.Produces
+}
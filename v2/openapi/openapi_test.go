@@ -0,0 +1,144 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hmmftg/requestCore/libRequest"
+
+	"github.com/hmmftg/requestCore/v2/handlers"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type createUser struct {
+	Name     string    `json:"name" validate:"required,min=3,max=50"`
+	Email    string    `json:"email,omitempty" validate:"omitempty,email"`
+	Age      int       `json:"age" validate:"gte=18,lt=130"`
+	Role     string    `json:"role" validate:"oneof=admin user"`
+	Tags     []string  `json:"tags" validate:"max=5,dive,alphanum"`
+	Address  *address  `json:"address"`
+	Born     time.Time `json:"born"`
+	Internal string    `json:"-"`
+}
+
+type user struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type findUsers struct {
+	Name  string `form:"name" query:"name" validate:"required"`
+	Limit int    `form:"limit" query:"limit" validate:"omitempty,max=100"`
+}
+
+func noop[Req, Resp any](*Req, *handlers.HandlerRequest[Req, Resp]) (Resp, error) {
+	var zero Resp
+	return zero, nil
+}
+
+func TestGenerator_Document(t *testing.T) {
+	g := NewGenerator(Info{Title: "Users", Version: "1.0.0"})
+	g.Add("POST", "/api/users", handlers.NewEndpoint("Create user", libRequest.JSON, noop[createUser, user]).
+		WithTags("users").WithHeaderValidation())
+	g.Add("GET", "/api/users", handlers.NewEndpoint("", libRequest.QueryWithPagination, noop[findUsers, []user]).
+		WithProduces("application/json", "text/csv"))
+	g.Add("DELETE", "/api/users/{id}", handlers.NewEndpoint("", libRequest.NoBinding, noop[struct{}, struct{}]).MarkDeprecated())
+
+	raw, err := g.JSON()
+	if err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+	var doc Document
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if doc.OpenAPI != Version || doc.Info.Title != "Users" || len(doc.Tags) != 1 {
+		t.Fatalf("unexpected document header: %+v", doc)
+	}
+
+	create := (*doc.Paths["/api/users"])["post"]
+	if create == nil || create.OperationID != "createUser" || create.Summary != "Create user" {
+		t.Fatalf("unexpected create operation: %+v", create)
+	}
+	body := create.RequestBody.Content["application/json"].Schema
+	schema := doc.Components.Schemas[body.Ref[len("#/components/schemas/"):]]
+	if schema == nil {
+		t.Fatalf("missing request schema %q", body.Ref)
+	}
+	if len(schema.Required) != 1 || schema.Required[0] != "name" {
+		t.Fatalf("unexpected required fields: %v", schema.Required)
+	}
+	name := schema.Properties["name"]
+	if *name.MinLength != 3 || *name.MaxLength != 50 {
+		t.Fatalf("unexpected name constraints: %+v", name)
+	}
+	if schema.Properties["email"].Format != "email" {
+		t.Fatal("expected email format")
+	}
+	age := schema.Properties["age"]
+	if *age.Minimum != 18 || *age.ExclusiveMaximum != 130 {
+		t.Fatalf("unexpected age constraints: %+v", age)
+	}
+	if enum := schema.Properties["role"].Enum; len(enum) != 2 || enum[0] != "admin" {
+		t.Fatalf("unexpected role enum: %v", enum)
+	}
+	tags := schema.Properties["tags"]
+	if *tags.MaxItems != 5 || tags.Items.Pattern == "" {
+		t.Fatalf("unexpected tags constraints: %+v", tags)
+	}
+	if schema.Properties["born"].Format != "date-time" || schema.Properties["Internal"] != nil {
+		t.Fatal("unexpected born/internal properties")
+	}
+	if addr := schema.Properties["address"]; addr.Ref == "" || doc.Components.Schemas[ErrorSchemaName] == nil {
+		t.Fatal("expected address and error envelope components")
+	}
+
+	var requestID *Parameter
+	for _, p := range create.Parameters {
+		if p.Name == "Request-Id" {
+			requestID = p
+		}
+	}
+	if requestID == nil || requestID.In != "header" || !requestID.Required || *requestID.Schema.MinLength != 10 {
+		t.Fatalf("unexpected Request-Id header: %+v", requestID)
+	}
+	if create.Responses["default"].Content["application/json"].Schema.Ref != "#/components/schemas/"+ErrorSchemaName {
+		t.Fatal("expected WsResponse error envelope")
+	}
+
+	list := (*doc.Paths["/api/users"])["get"]
+	params := map[string]*Parameter{}
+	for _, p := range list.Parameters {
+		params[p.Name] = p
+	}
+	if p := params["name"]; p == nil || p.In != "query" || !p.Required {
+		t.Fatalf("unexpected name query parameter: %+v", p)
+	}
+	if params["_start"] == nil || params["_order"] == nil || len(params["_order"].Schema.Enum) != 2 {
+		t.Fatal("expected pagination parameters")
+	}
+	if params["Request-Id"].Required {
+		t.Fatal("Request-Id is only required with header validation")
+	}
+	if items := list.Responses["200"].Content["application/json"].Schema; items.Type != "array" {
+		t.Fatalf("unexpected list response: %+v", items)
+	}
+	if csv, ok := list.Responses["200"].Content["text/csv"]; !ok || csv.Schema.Type != "array" {
+		t.Fatalf("expected text/csv list response, got %+v", list.Responses["200"].Content)
+	}
+
+	del := (*doc.Paths["/api/users/{id}"])["delete"]
+	if !del.Deprecated || del.OperationID != "deleteApiUsersId" {
+		t.Fatalf("unexpected delete operation: %+v", del)
+	}
+	if p := del.Parameters[0]; p.Name != "id" || p.In != "path" || !p.Required {
+		t.Fatalf("unexpected path parameter: %+v", p)
+	}
+	if del.Responses["200"].Content != nil {
+		t.Fatal("expected no content for empty response")
+	}
+}
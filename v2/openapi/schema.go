@@ -0,0 +1,258 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	unsafeName     = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// schemas collects named component schemas while reflecting over types.
+type schemas struct {
+	byType map[reflect.Type]string
+	byName map[string]*Schema
+}
+
+func newSchemas() *schemas {
+	return &schemas{
+		byType: make(map[reflect.Type]string),
+		byName: make(map[string]*Schema),
+	}
+}
+
+// schemaFor returns the schema of t. Named struct types are added to the
+// components once and referenced with $ref.
+func (s *schemas) schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + s.define(t)}
+	default:
+		// interface{} and anything without a JSON representation.
+		return &Schema{}
+	}
+}
+
+// define registers the named struct type t and returns its component name.
+func (s *schemas) define(t reflect.Type) string {
+	if name, ok := s.byType[t]; ok {
+		return name
+	}
+	name := unsafeName.ReplaceAllString(t.String(), "_")
+	for i := 2; s.byName[name] != nil; i++ {
+		name = unsafeName.ReplaceAllString(t.String(), "_") + strconv.Itoa(i)
+	}
+	// Reserve the name before reflecting so recursive types terminate.
+	s.byType[t] = name
+	s.byName[name] = &Schema{}
+	*s.byName[name] = *s.structSchema(t)
+	return name
+}
+
+// structSchema builds an object schema from the exported fields of t,
+// flattening embedded structs as encoding/json does.
+func (s *schemas) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	s.addFields(schema, t)
+	return schema
+}
+
+func (s *schemas) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, skip := jsonName(f)
+		if skip {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.addFields(schema, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		prop := s.schemaFor(f.Type)
+		if applyValidation(prop, f.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = prop
+	}
+}
+
+// jsonName returns the name from the json tag and whether the field is
+// excluded from JSON.
+func jsonName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	return name, false
+}
+
+// applyValidation maps go-playground validator rules onto schema and
+// reports whether the field is required. Rules after "dive" apply to the
+// elements of a slice or map.
+func applyValidation(schema *Schema, tag string) bool {
+	if tag == "" || tag == "-" {
+		return false
+	}
+	required := false
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "dive":
+			target := schema.Items
+			if target == nil {
+				target = schema.AdditionalProperties
+			}
+			if target != nil && target.Ref == "" {
+				applyValidation(target, strings.Join(rules[i+1:], ","))
+			}
+			return required
+		case "min", "gte":
+			setBound(schema, param, false, false)
+		case "max", "lte":
+			setBound(schema, param, true, false)
+		case "gt":
+			setBound(schema, param, false, true)
+		case "lt":
+			setBound(schema, param, true, true)
+		case "len":
+			setBound(schema, param, false, false)
+			setBound(schema, param, true, false)
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				schema.Enum = append(schema.Enum, enumValue(schema.Type, v))
+			}
+		case "email":
+			schema.Format = "email"
+		case "url", "uri", "http_url":
+			schema.Format = "uri"
+		case "uuid", "uuid4", "uuid_rfc4122", "uuid4_rfc4122":
+			schema.Format = "uuid"
+		case "ip", "ipv4":
+			schema.Format = "ipv4"
+		case "ipv6":
+			schema.Format = "ipv6"
+		case "datetime":
+			schema.Format = "date-time"
+		case "numeric", "number":
+			schema.Pattern = `^[0-9]+$`
+		case "alpha":
+			schema.Pattern = `^[A-Za-z]+$`
+		case "alphanum":
+			schema.Pattern = `^[A-Za-z0-9]+$`
+		}
+	}
+	return required
+}
+
+// setBound applies a size or value bound according to the schema type:
+// lengths for strings, item counts for arrays and values for numbers.
+func setBound(schema *Schema, param string, upper, exclusive bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch schema.Type {
+	case "string":
+		v := int(n)
+		if exclusive {
+			if upper {
+				v--
+			} else {
+				v++
+			}
+		}
+		if upper {
+			schema.MaxLength = &v
+		} else {
+			schema.MinLength = &v
+		}
+	case "array":
+		v := int(n)
+		if upper {
+			schema.MaxItems = &v
+		} else {
+			schema.MinItems = &v
+		}
+	case "integer", "number":
+		switch {
+		case upper && exclusive:
+			schema.ExclusiveMaximum = &n
+		case upper:
+			schema.Maximum = &n
+		case exclusive:
+			schema.ExclusiveMinimum = &n
+		default:
+			schema.Minimum = &n
+		}
+	}
+}
+
+func enumValue(schemaType, v string) any {
+	switch schemaType {
+	case "integer":
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
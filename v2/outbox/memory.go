@@ -0,0 +1,112 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process Store. Events do not survive a restart
+// and are not written transactionally with any database, so it is
+// suitable for tests and for fire-and-forget in-process publishing.
+type MemoryStore struct {
+	mu     sync.Mutex
+	events map[string]*memoryEvent
+}
+
+type memoryEvent struct {
+	event  Event
+	status Status
+	err    string
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{events: make(map[string]*memoryEvent)}
+}
+
+// Add records events as pending.
+func (s *MemoryStore) Add(events ...Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ev := range events {
+		if ev.NextAttemptAt.IsZero() {
+			ev.NextAttemptAt = ev.CreatedAt
+		}
+		s.events[ev.ID] = &memoryEvent{event: ev, status: StatusPending}
+	}
+}
+
+// Status returns the delivery status and last error of an event.
+func (s *MemoryStore) Status(id string) (Status, string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.events[id]
+	if !ok {
+		return "", "", false
+	}
+	return e.status, e.err, true
+}
+
+// Pending returns pending events of aggregates that are due at now,
+// ordered by creation time and ID.
+func (s *MemoryStore) Pending(_ context.Context, now time.Time, limit int) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blocked := make(map[string]bool)
+	for _, e := range s.events {
+		if e.status == StatusPending && e.event.NextAttemptAt.After(now) {
+			blocked[e.event.AggregateKey] = true
+		}
+	}
+	var events []Event
+	for _, e := range s.events {
+		if e.status == StatusPending && !blocked[e.event.AggregateKey] {
+			events = append(events, e.event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].CreatedAt.Equal(events[j].CreatedAt) {
+			return events[i].CreatedAt.Before(events[j].CreatedAt)
+		}
+		return events[i].ID < events[j].ID
+	})
+	if limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// MarkPublished marks the event as published.
+func (s *MemoryStore) MarkPublished(_ context.Context, id string, _ time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.events[id]
+	if !ok {
+		return fmt.Errorf("outbox: event %q not found", id)
+	}
+	e.status = StatusPublished
+	e.err = ""
+	return nil
+}
+
+// MarkFailed records a failed attempt.
+func (s *MemoryStore) MarkFailed(_ context.Context, id string, attempts int, next time.Time, cause error, dead bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.events[id]
+	if !ok {
+		return fmt.Errorf("outbox: event %q not found", id)
+	}
+	e.event.Attempts = attempts
+	e.event.NextAttemptAt = next
+	if cause != nil {
+		e.err = cause.Error()
+	}
+	if dead {
+		e.status = StatusFailed
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// Package outbox implements the transactional outbox pattern: handlers
+// write events into an outbox table in the same database transaction as
+// their business DML, and a Relay publishes them to registered sinks
+// afterwards with retries.
+//
+// Writing events:
+//
+//   - SQLStore.ExecDML is the transactional counterpart of
+//     handlers.ExecDML: it runs a libQuery.DmlModel's DML commands and
+//     the outbox inserts in one transaction, so either both commit or
+//     neither does.
+//   - SQLStore.Exec does the same for a plain list of
+//     libQuery.DmlCommand values.
+//   - SQLStore.Insert returns the outbox insert as a libQuery.DmlCommand.
+//     libQuery runs every command in its own transaction, so a command
+//     list that includes it is not atomic; put it last so the event is
+//     only recorded once the business DML succeeded.
+//
+// Publishing events:
+//
+// A Relay polls the Store for pending events and submits one job per
+// aggregate key to a workers.Worker. Events sharing an aggregate key are
+// published strictly in insertion order: a failed event blocks the
+// events after it until it is published or exhausts its attempts.
+// Delivery is at-least-once, so sinks should treat Event.ID as an
+// idempotency key. Run a single Relay per database, for example from a
+// locks.Elector's OnElected callback.
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoSink is recorded for events whose topic has no registered sink.
+var ErrNoSink = errors.New("outbox: no sink registered for topic")
+
+// ErrNoTransaction is returned by SQLStore.Exec and SQLStore.ExecDML when
+// the store's QueryRunnerInterface does not expose a *sql.DB to open a
+// transaction on.
+var ErrNoTransaction = errors.New("outbox: query runner does not support transactions")
+
+// Status is the delivery state of an outbox event.
+type Status string
+
+const (
+	// StatusPending events are waiting to be published or retried.
+	StatusPending Status = "pending"
+	// StatusPublished events were delivered to every sink.
+	StatusPublished Status = "published"
+	// StatusFailed events exhausted their attempts and are no longer
+	// retried. They no longer block later events of their aggregate.
+	StatusFailed Status = "failed"
+)
+
+// Event is a message recorded in the outbox.
+type Event struct {
+	// ID uniquely identifies the event. IDs generated by NewEvent sort
+	// in creation order.
+	ID string `json:"id"`
+
+	// Topic selects the sinks the event is published to.
+	Topic string `json:"topic"`
+
+	// AggregateKey groups events that must be published in order, for
+	// example an account or order number.
+	AggregateKey string `json:"aggregateKey"`
+
+	// Payload is the JSON-encoded event body.
+	Payload json.RawMessage `json:"payload"`
+
+	// Headers are optional metadata forwarded to sinks.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// CreatedAt is when the event was recorded.
+	CreatedAt time.Time `json:"createdAt"`
+
+	// Attempts is the number of failed publish attempts so far.
+	// Maintained by the Store.
+	Attempts int `json:"attempts"`
+
+	// NextAttemptAt is the earliest time the event may be retried.
+	// Maintained by the Store.
+	NextAttemptAt time.Time `json:"nextAttemptAt"`
+}
+
+// NewEvent creates an event with a JSON-encoded payload.
+func NewEvent(topic, aggregateKey string, payload any) (Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, fmt.Errorf("outbox: marshal %s payload: %w", topic, err)
+	}
+	now := time.Now()
+	return Event{
+		ID:            newEventID(now),
+		Topic:         topic,
+		AggregateKey:  aggregateKey,
+		Payload:       data,
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}, nil
+}
+
+// eventSeq orders events created by this process within one millisecond.
+var eventSeq atomic.Uint32
+
+// newEventID returns an ID made of the creation time, a process-wide
+// sequence and random bytes, so IDs sort in creation order.
+func newEventID(now time.Time) string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("outbox: crypto/rand failed: %v", err))
+	}
+	return fmt.Sprintf("%013x%08x%x", now.UnixMilli(), eventSeq.Add(1), b)
+}
+
+// Store persists outbox events for a Relay.
+type Store interface {
+	// Pending returns up to limit pending events ordered by creation,
+	// skipping every aggregate with an event whose NextAttemptAt is after
+	// now. A backed-off aggregate thus never fills the batch and blocks
+	// the others, and its later events wait behind its head.
+	Pending(ctx context.Context, now time.Time, limit int) ([]Event, error)
+
+	// MarkPublished records that the event was delivered.
+	MarkPublished(ctx context.Context, id string, at time.Time) error
+
+	// MarkFailed records a failed attempt. When dead is true the event
+	// moves to StatusFailed; otherwise it is retried at next.
+	MarkFailed(ctx context.Context, id string, attempts int, next time.Time, cause error, dead bool) error
+}
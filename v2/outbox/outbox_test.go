@@ -0,0 +1,314 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hmmftg/requestCore/libCallApi"
+	"github.com/hmmftg/requestCore/libQuery"
+	"github.com/hmmftg/requestCore/v2/workers"
+	"github.com/hmmftg/requestCore/webFramework"
+)
+
+func newWorker(t *testing.T) *workers.InProcessWorker {
+	t.Helper()
+	w := workers.NewInProcessWorker(workers.Config{WorkerCount: 4, QueueSize: 16})
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = w.Shutdown(ctx)
+	})
+	return w
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("condition not met")
+}
+
+func mustEvent(t *testing.T, topic, key string, payload any, at time.Time) Event {
+	t.Helper()
+	event, err := NewEvent(topic, key, payload)
+	if err != nil {
+		t.Fatalf("NewEvent: %v", err)
+	}
+	event.CreatedAt = at
+	event.NextAttemptAt = at
+	return event
+}
+
+func hasStatus(store *MemoryStore, id string, want Status) func() bool {
+	return func() bool {
+		got, _, _ := store.Status(id)
+		return got == want
+	}
+}
+
+func TestRelay_OrderingAndRetry(t *testing.T) {
+	var clock atomic.Int64
+	clock.Store(time.UnixMilli(1700000000000).UnixMilli())
+	now := func() time.Time { return time.UnixMilli(clock.Load()) }
+
+	store := NewMemoryStore()
+	a1 := mustEvent(t, "account.updated", "acc-1", map[string]int{"seq": 1}, now().Add(-3*time.Millisecond))
+	a2 := mustEvent(t, "account.updated", "acc-1", map[string]int{"seq": 2}, now().Add(-2*time.Millisecond))
+	b1 := mustEvent(t, "account.updated", "acc-2", map[string]int{"seq": 1}, now().Add(-time.Millisecond))
+	store.Add(a1, a2, b1)
+
+	var mu sync.Mutex
+	var delivered []string
+	var failOnce atomic.Bool
+	failOnce.Store(true)
+	relay := NewRelay(store, newWorker(t), RelayConfig{InitialBackoff: time.Second, Clock: now})
+	relay.Register("account.updated", SinkFunc(func(_ webFramework.WebFramework, event Event) error {
+		if event.ID == a1.ID && failOnce.CompareAndSwap(true, false) {
+			return errors.New("broker unavailable")
+		}
+		mu.Lock()
+		delivered = append(delivered, event.ID)
+		mu.Unlock()
+		return nil
+	}))
+
+	if _, err := relay.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+	waitFor(t, hasStatus(store, b1.ID, StatusPublished))
+	waitFor(t, func() bool {
+		_, lastErr, _ := store.Status(a1.ID)
+		return lastErr != ""
+	})
+	if status, _, _ := store.Status(a2.ID); status != StatusPending {
+		t.Fatalf("a2 must wait behind a1, got %s", status)
+	}
+
+	// a1 is not due yet: nothing is dispatched.
+	waitFor(t, func() bool { return relay.claim("acc-1") })
+	relay.release("acc-1")
+	if n, err := relay.Poll(context.Background()); err != nil || n != 0 {
+		t.Fatalf("expected no dispatch before backoff, got n=%d err=%v", n, err)
+	}
+
+	clock.Add(time.Second.Milliseconds())
+	if _, err := relay.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+	waitFor(t, hasStatus(store, a2.ID, StatusPublished))
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{b1.ID, a1.ID, a2.ID}
+	if strings.Join(delivered, ",") != strings.Join(want, ",") {
+		t.Fatalf("delivery order = %v, want %v", delivered, want)
+	}
+}
+
+// TestRelay_BlockedAggregate verifies that an aggregate waiting for a
+// retry with at least BatchSize pending events does not hold up the
+// delivery of other aggregates.
+func TestRelay_BlockedAggregate(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	store := NewMemoryStore()
+	var poisoned []Event
+	for i := range 3 {
+		poisoned = append(poisoned, mustEvent(t, "account.updated", "acc-1", i, now.Add(time.Duration(i-10)*time.Millisecond)))
+	}
+	poisoned[0].Attempts = 1
+	poisoned[0].NextAttemptAt = now.Add(time.Minute)
+	healthy := mustEvent(t, "account.updated", "acc-2", "x", now.Add(-time.Millisecond))
+	store.Add(append(poisoned, healthy)...)
+
+	var mu sync.Mutex
+	var delivered []string
+	relay := NewRelay(store, newWorker(t), RelayConfig{BatchSize: 2, Clock: func() time.Time { return now }})
+	relay.Register("account.updated", SinkFunc(func(_ webFramework.WebFramework, event Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, event.ID)
+		return nil
+	}))
+
+	if n, err := relay.Poll(context.Background()); err != nil || n != 1 {
+		t.Fatalf("expected the healthy aggregate to be dispatched, got n=%d err=%v", n, err)
+	}
+	waitFor(t, hasStatus(store, healthy.ID, StatusPublished))
+	mu.Lock()
+	defer mu.Unlock()
+	if len(delivered) != 1 || delivered[0] != healthy.ID {
+		t.Fatalf("events behind the backed-off head must wait, delivered %v", delivered)
+	}
+}
+
+func TestRelay_DeadLetter(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	store := NewMemoryStore()
+	orphan := mustEvent(t, "unknown", "acc-1", "x", now.Add(-2*time.Millisecond))
+	next := mustEvent(t, "known", "acc-1", "y", now.Add(-time.Millisecond))
+	store.Add(orphan, next)
+
+	bus := NewBus()
+	var got atomic.Value
+	bus.Subscribe("known", func(_ webFramework.WebFramework, event Event) error {
+		got.Store(event.ID)
+		return nil
+	})
+	relay := NewRelay(store, newWorker(t), RelayConfig{MaxAttempts: 1, Clock: func() time.Time { return now }})
+	relay.Register("known", bus)
+
+	if _, err := relay.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+	waitFor(t, hasStatus(store, next.ID, StatusPublished))
+	status, lastErr, _ := store.Status(orphan.ID)
+	if status != StatusFailed || !strings.Contains(lastErr, ErrNoSink.Error()) {
+		t.Fatalf("expected dead-lettered orphan, got %s %q", status, lastErr)
+	}
+	if got.Load() != next.ID {
+		t.Fatalf("bus subscriber got %v", got.Load())
+	}
+}
+
+func newPostgresCore(t *testing.T) (libQuery.QueryRunnerModel, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	core := libQuery.Init(db, "prog", "mod", libQuery.Postgres)
+	core.Mode = libQuery.Postgres
+	return core, mock
+}
+
+func TestSQLStore_Exec(t *testing.T) {
+	core, mock := newPostgresCore(t)
+	store := NewSQLStore(core, "")
+	event := mustEvent(t, "account.updated", "acc-1", map[string]string{"state": "closed"}, time.UnixMilli(1700000000000))
+	event.Headers = map[string]string{"X-Tenant": "t1"}
+	update := libQuery.DmlCommand{
+		Name:    "close-account",
+		Command: "UPDATE accounts SET state=$1 WHERE id=$2",
+		Args:    []any{"closed", "acc-1"},
+		Type:    libQuery.Update,
+	}
+
+	mock.ExpectBegin()
+	for i := 0; i < 4; i++ {
+		mock.ExpectExec("set_config").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec("UPDATE accounts").WithArgs("closed", "acc-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO outbox_events").
+		WithArgs(event.ID, "account.updated", "acc-1", `{"state":"closed"}`, `{"X-Tenant":"t1"}`,
+			"pending", 0, "", int64(1700000000000), int64(1700000000000)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := store.Exec(context.Background(), "accounts", "close", []libQuery.DmlCommand{update}, event); err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+
+	// A failing outbox insert rolls back the business DML.
+	mock.ExpectBegin()
+	for i := 0; i < 4; i++ {
+		mock.ExpectExec("set_config").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec("UPDATE accounts").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO outbox_events").WillReturnError(errors.New("duplicate key"))
+	mock.ExpectRollback()
+	if err := store.Exec(context.Background(), "accounts", "close", []libQuery.DmlCommand{update}, event); err == nil {
+		t.Fatal("expected error from failing insert")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+	if err := NewSQLStore(&fakeRunner{}, "").Exec(context.Background(), "m", "f", nil, event); !errors.Is(err, ErrNoTransaction) {
+		t.Fatalf("expected ErrNoTransaction, got %v", err)
+	}
+}
+
+// fakeRunner is a QueryRunnerInterface without an underlying *sql.DB.
+type fakeRunner struct{ libQuery.QueryRunnerInterface }
+
+func TestSQLStore_PendingAndMark(t *testing.T) {
+	core, mock := newPostgresCore(t)
+	store := NewSQLStore(core, "events")
+
+	mock.ExpectPrepare("SELECT id, topic, aggregate_key, payload, headers, attempts, created_at, next_attempt_at FROM events "+
+		"WHERE status='pending' AND aggregate_key NOT IN \\(SELECT aggregate_key FROM events WHERE status='pending' AND next_attempt_at>\\$1\\)").
+		ExpectQuery().WithArgs(int64(1500), 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "topic", "aggregate_key", "payload", "headers", "attempts", "created_at", "next_attempt_at"}).
+			AddRow("e1", "t", "k", `{"a":1}`, `{"h":"v"}`, 2, int64(1000), int64(2000)))
+	events, err := store.Pending(context.Background(), time.UnixMilli(1500), 10)
+	if err != nil {
+		t.Fatalf("Pending: %v", err)
+	}
+	if len(events) != 1 || events[0].ID != "e1" || events[0].Attempts != 2 ||
+		events[0].Headers["h"] != "v" || events[0].NextAttemptAt.UnixMilli() != 2000 {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+
+	mock.ExpectBegin()
+	for i := 0; i < 4; i++ {
+		mock.ExpectExec("set_config").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec("UPDATE events SET status").WithArgs("failed", 3, "boom", int64(5000), "e1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := store.MarkFailed(context.Background(), "e1", 3, time.UnixMilli(5000), errors.New("boom"), true); err != nil {
+		t.Fatalf("MarkFailed: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	var gotKey, gotTopic, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey = r.Header.Get(IdempotencyKeyHeader)
+		gotTopic = r.Header.Get(TopicHeader)
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	sink := HTTPSink{
+		APIs:    libCallApi.RemoteAPIModel{RemoteAPIList: map[string]libCallApi.RemoteAPI{"hooks": {Domain: server.URL, Name: "hooks"}}},
+		APIName: "hooks",
+		Path:    "events",
+	}
+	event := Event{ID: "e1", Topic: "account.updated", Payload: json.RawMessage(`{"a":1}`)}
+	w := webFramework.WebFramework{Ctx: context.Background()}
+	if err := sink.Publish(w, event); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if gotKey != "e1" || gotTopic != "account.updated" || gotBody != `{"a":1}` {
+		t.Fatalf("unexpected request: key=%q topic=%q body=%q", gotKey, gotTopic, gotBody)
+	}
+
+	status.Store(http.StatusServiceUnavailable)
+	if err := sink.Publish(w, event); err == nil {
+		t.Fatal("expected error on 503")
+	}
+}
@@ -0,0 +1,245 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/hmmftg/requestCore/v2/workers"
+	"github.com/hmmftg/requestCore/webFramework"
+)
+
+// AllTopics registers a sink for every topic.
+const AllTopics = "*"
+
+// RelayJobName is the workers job name used for relay batches.
+const RelayJobName = "outbox-relay"
+
+// RelayConfig configures a Relay.
+type RelayConfig struct {
+	// Interval is how often Run polls the store.
+	// Default: 1s.
+	Interval time.Duration
+
+	// BatchSize is the maximum number of pending events read per poll.
+	// Default: 100.
+	BatchSize int
+
+	// MaxAttempts is the number of failed attempts after which an event
+	// is marked StatusFailed and stops blocking its aggregate.
+	// Default: 10.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry of an event.
+	// Default: 1s.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the retry delay.
+	// Default: 5m.
+	MaxBackoff time.Duration
+
+	// Clock is the clock source for deterministic testing.
+	// If nil, time.Now is used.
+	Clock func() time.Time
+}
+
+// Relay publishes pending outbox events to registered sinks. Each poll
+// submits one job per aggregate key to the worker; a key is never
+// dispatched again while its previous job is still running, so events of
+// an aggregate are published in order.
+type Relay struct {
+	store  Store
+	worker workers.Worker
+	config RelayConfig
+
+	mu    sync.Mutex
+	sinks map[string][]Sink
+	busy  map[string]bool
+}
+
+// NewRelay creates a Relay that reads from store and publishes on worker.
+func NewRelay(store Store, worker workers.Worker, config RelayConfig) *Relay {
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 10
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 5 * time.Minute
+	}
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+	return &Relay{
+		store:  store,
+		worker: worker,
+		config: config,
+		sinks:  make(map[string][]Sink),
+		busy:   make(map[string]bool),
+	}
+}
+
+// Register adds sinks for topic. Sinks registered for AllTopics receive
+// every event in addition to the topic's own sinks.
+func (r *Relay) Register(topic string, sinks ...Sink) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sinks[topic] = append(r.sinks[topic], sinks...)
+}
+
+// Run polls the store every Interval until ctx is cancelled and returns
+// ctx.Err(). Poll errors are logged and retried on the next tick.
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.Poll(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("outbox: poll failed", slog.Any("error", err))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll reads one batch of pending events and submits a job for every
+// aggregate key that is due and not already being published. It returns
+// the number of events dispatched.
+func (r *Relay) Poll(ctx context.Context) (int, error) {
+	now := r.config.Clock()
+	events, err := r.store.Pending(ctx, now, r.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var keys []string
+	groups := make(map[string][]Event)
+	for _, event := range events {
+		if _, ok := groups[event.AggregateKey]; !ok {
+			keys = append(keys, event.AggregateKey)
+		}
+		groups[event.AggregateKey] = append(groups[event.AggregateKey], event)
+	}
+
+	dispatched := 0
+	var errs []error
+	for _, key := range keys {
+		group := groups[key]
+		// The head of each group is the oldest undelivered event; while
+		// it waits for a retry the whole aggregate waits with it.
+		if group[0].NextAttemptAt.After(now) || !r.claim(key) {
+			continue
+		}
+		err := r.worker.Submit(ctx, workers.Job{
+			Name: RelayJobName,
+			Handler: func(jctx *workers.JobContext) error {
+				defer r.release(key)
+				return r.publishGroup(jctx, group)
+			},
+			Options: workers.JobOptions{
+				Attributes: map[string]string{"outbox.aggregate_key": key},
+			},
+		})
+		if err != nil {
+			r.release(key)
+			errs = append(errs, fmt.Errorf("outbox: submit aggregate %q: %w", key, err))
+			continue
+		}
+		dispatched += len(group)
+	}
+	return dispatched, errors.Join(errs...)
+}
+
+func (r *Relay) claim(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.busy[key] {
+		return false
+	}
+	r.busy[key] = true
+	return true
+}
+
+func (r *Relay) release(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.busy, key)
+}
+
+// publishGroup publishes an aggregate's events in order, stopping at the
+// first event that fails and will be retried.
+func (r *Relay) publishGroup(jctx *workers.JobContext, events []Event) error {
+	for _, event := range events {
+		if event.NextAttemptAt.After(r.config.Clock()) {
+			return nil
+		}
+		pubErr := r.publish(jctx.WebFramework, event)
+		if pubErr == nil {
+			if err := r.store.MarkPublished(jctx.Context, event.ID, r.config.Clock()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		attempts := event.Attempts + 1
+		dead := attempts >= r.config.MaxAttempts
+		next := r.config.Clock().Add(r.backoff(attempts))
+		webFramework.AddLog(jctx.WebFramework, webFramework.HandlerLogTag,
+			slog.Group("outbox publish failed",
+				slog.String("id", event.ID),
+				slog.String("topic", event.Topic),
+				slog.Int("attempts", attempts),
+				slog.Bool("dead", dead),
+				slog.Any("error", pubErr)))
+		if err := r.store.MarkFailed(jctx.Context, event.ID, attempts, next, pubErr, dead); err != nil {
+			return err
+		}
+		if !dead {
+			return nil
+		}
+	}
+	return nil
+}
+
+// publish delivers the event to its topic's sinks and the AllTopics
+// sinks.
+func (r *Relay) publish(w webFramework.WebFramework, event Event) error {
+	r.mu.Lock()
+	sinks := append(append([]Sink(nil), r.sinks[event.Topic]...), r.sinks[AllTopics]...)
+	r.mu.Unlock()
+	if len(sinks) == 0 {
+		return fmt.Errorf("%w %q", ErrNoSink, event.Topic)
+	}
+	var errs []error
+	for _, sink := range sinks {
+		if err := sink.Publish(w, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// backoff returns the retry delay after the given number of failed
+// attempts, doubling from InitialBackoff up to MaxBackoff.
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.config.InitialBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= r.config.MaxBackoff {
+			return r.config.MaxBackoff
+		}
+	}
+	return delay
+}
@@ -0,0 +1,134 @@
+package outbox
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/hmmftg/requestCore/libCallApi"
+	"github.com/hmmftg/requestCore/webFramework"
+)
+
+// Sink delivers events to a destination. w is the relay job's
+// webFramework.WebFramework: w.Ctx carries cancellation and tracing and
+// webFramework.AddLog entries land in the job's transaction log.
+// Publish may be called more than once for the same event.
+type Sink interface {
+	Publish(w webFramework.WebFramework, event Event) error
+}
+
+// SinkFunc adapts a function to the Sink interface.
+type SinkFunc func(w webFramework.WebFramework, event Event) error
+
+// Publish calls f.
+func (f SinkFunc) Publish(w webFramework.WebFramework, event Event) error {
+	return f(w, event)
+}
+
+// IdempotencyKeyHeader carries Event.ID on webhook requests so receivers
+// can drop redeliveries.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// TopicHeader carries Event.Topic on webhook requests.
+const TopicHeader = "X-Outbox-Topic"
+
+// HTTPSink posts event payloads to a webhook configured in a
+// libCallApi.RemoteAPIModel, so authentication and endpoints come from
+// the application's remote API configuration. Event headers are
+// forwarded together with IdempotencyKeyHeader and TopicHeader. Any
+// non-2xx response is a failure.
+type HTTPSink struct {
+	// APIs holds the remote API configuration.
+	APIs libCallApi.RemoteAPIModel
+
+	// APIName selects the remote API in APIs.
+	APIName string
+
+	// Path is appended to the API's domain.
+	Path string
+
+	// Method is the HTTP method.
+	// Default: POST.
+	Method string
+}
+
+// Publish sends the event to the webhook.
+func (s HTTPSink) Publish(w webFramework.WebFramework, event Event) error {
+	method := s.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	headers := make(map[string]string, len(event.Headers)+2)
+	for k, v := range event.Headers {
+		headers[k] = v
+	}
+	headers[IdempotencyKeyHeader] = event.ID
+	headers[TopicHeader] = event.Topic
+	_, desc, code, err := s.APIs.ConsumeRestAPI(w, event.Payload, s.APIName, s.Path, "application/json", method, headers)
+	if err != nil {
+		return fmt.Errorf("outbox: webhook %s: %s: %w", s.APIName, desc, err)
+	}
+	if code < 200 || code > 299 {
+		return fmt.Errorf("outbox: webhook %s: %s", s.APIName, desc)
+	}
+	return nil
+}
+
+// LogSink writes events to a slog.Logger. It is useful as a catch-all
+// audit sink and during development.
+type LogSink struct {
+	// Logger receives the events.
+	// Default: slog.Default().
+	Logger *slog.Logger
+}
+
+// Publish logs the event.
+func (s LogSink) Publish(_ webFramework.WebFramework, event Event) error {
+	logger := s.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Info("outbox event",
+		slog.String("id", event.ID),
+		slog.String("topic", event.Topic),
+		slog.String("aggregate_key", event.AggregateKey),
+		slog.String("payload", string(event.Payload)))
+	return nil
+}
+
+// Bus is an in-process publish/subscribe Sink. Publish calls every
+// subscriber of the event's topic synchronously and fails if any of them
+// fails, so a failing subscriber causes redelivery to all of them.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[string][]SinkFunc
+}
+
+// NewBus creates a Bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[string][]SinkFunc)}
+}
+
+// Subscribe registers handler for events of topic.
+func (b *Bus) Subscribe(topic string, handler SinkFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[topic] = append(b.subscribers[topic], handler)
+}
+
+// Publish delivers the event to the topic's subscribers. Events without
+// subscribers are dropped.
+func (b *Bus) Publish(w webFramework.WebFramework, event Event) error {
+	b.mu.RLock()
+	handlers := b.subscribers[event.Topic]
+	b.mu.RUnlock()
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(w, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,308 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hmmftg/requestCore"
+	"github.com/hmmftg/requestCore/handlers"
+	"github.com/hmmftg/requestCore/libError"
+	"github.com/hmmftg/requestCore/libQuery"
+	"github.com/hmmftg/requestCore/status"
+	"github.com/hmmftg/requestCore/webFramework"
+)
+
+// DefaultTable is the table used by SQLStore when no table name is
+// configured.
+const DefaultTable = "outbox_events"
+
+// SQLStore keeps outbox events in a database table next to the business
+// data, so they can be written in the same transaction. The expected
+// schema is:
+//
+//	CREATE TABLE outbox_events (
+//	    id               VARCHAR(64)  PRIMARY KEY,
+//	    topic            VARCHAR(200) NOT NULL,
+//	    aggregate_key    VARCHAR(200) NOT NULL,
+//	    payload          TEXT         NOT NULL,
+//	    headers          TEXT,
+//	    status           VARCHAR(32)  NOT NULL,
+//	    attempts         INTEGER      NOT NULL,
+//	    last_error       TEXT,
+//	    created_at       BIGINT       NOT NULL,
+//	    next_attempt_at  BIGINT       NOT NULL,
+//	    published_at     BIGINT
+//	);
+//	CREATE INDEX outbox_events_pending ON outbox_events (status, created_at, id);
+//	CREATE INDEX outbox_events_backoff ON outbox_events (status, next_attempt_at, aggregate_key);
+//
+// Timestamps are stored as Unix milliseconds and headers as JSON text, so
+// the schema is portable across the supported database modes.
+type SQLStore struct {
+	core libQuery.QueryRunnerInterface
+
+	insert    libQuery.DmlCommand
+	published libQuery.DmlCommand
+	failed    libQuery.DmlCommand
+	pending   libQuery.QueryCommand
+}
+
+// NewSQLStore creates a SQLStore on the given table. An empty table name
+// selects DefaultTable.
+func NewSQLStore(core libQuery.QueryRunnerInterface, table string) *SQLStore {
+	if table == "" {
+		table = DefaultTable
+	}
+	const columns = "id, topic, aggregate_key, payload, headers, status, attempts, last_error, created_at, next_attempt_at"
+	const selected = "id, topic, aggregate_key, payload, headers, attempts, created_at, next_attempt_at"
+	// due excludes aggregates whose head is waiting for a retry; only the
+	// head of an aggregate is ever backed off.
+	due := "aggregate_key NOT IN (SELECT aggregate_key FROM " + table + " WHERE status='pending' AND next_attempt_at>"
+	return &SQLStore{
+		core: core,
+		insert: libQuery.DmlCommand{
+			Name:    "outbox-insert",
+			Command: "INSERT INTO " + table + " (" + columns + ") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "INSERT INTO " + table + " (" + columns + ") VALUES (:1, :2, :3, :4, :5, :6, :7, :8, :9, :10)",
+				libQuery.MySql:  "INSERT INTO " + table + " (" + columns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+				libQuery.Sqlite: "INSERT INTO " + table + " (" + columns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			},
+			Type: libQuery.Insert,
+		},
+		published: libQuery.DmlCommand{
+			Name:    "outbox-published",
+			Command: "UPDATE " + table + " SET status=$1, last_error='', published_at=$2 WHERE id=$3",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "UPDATE " + table + " SET status=:1, last_error='', published_at=:2 WHERE id=:3",
+				libQuery.MySql:  "UPDATE " + table + " SET status=?, last_error='', published_at=? WHERE id=?",
+				libQuery.Sqlite: "UPDATE " + table + " SET status=?, last_error='', published_at=? WHERE id=?",
+			},
+			Type: libQuery.Update,
+		},
+		failed: libQuery.DmlCommand{
+			Name:    "outbox-failed",
+			Command: "UPDATE " + table + " SET status=$1, attempts=$2, last_error=$3, next_attempt_at=$4 WHERE id=$5",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "UPDATE " + table + " SET status=:1, attempts=:2, last_error=:3, next_attempt_at=:4 WHERE id=:5",
+				libQuery.MySql:  "UPDATE " + table + " SET status=?, attempts=?, last_error=?, next_attempt_at=? WHERE id=?",
+				libQuery.Sqlite: "UPDATE " + table + " SET status=?, attempts=?, last_error=?, next_attempt_at=? WHERE id=?",
+			},
+			Type: libQuery.Update,
+		},
+		pending: libQuery.QueryCommand{
+			Name: "outbox-pending",
+			Command: "SELECT " + selected + " FROM " + table + " WHERE status='pending' AND " + due + "$1) " +
+				"ORDER BY created_at, id LIMIT $2",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "SELECT " + selected + " FROM " + table + " WHERE status='pending' AND " + due + ":1) " +
+					"ORDER BY created_at, id FETCH FIRST :2 ROWS ONLY",
+				libQuery.MySql: "SELECT " + selected + " FROM " + table + " WHERE status='pending' AND " + due + "?) " +
+					"ORDER BY created_at, id LIMIT ?",
+				libQuery.Sqlite: "SELECT " + selected + " FROM " + table + " WHERE status='pending' AND " + due + "?) " +
+					"ORDER BY created_at, id LIMIT ?",
+			},
+		},
+	}
+}
+
+// Insert returns the DML command that records event in the outbox, for
+// use in a libQuery.DmlModel's command lists or with Exec.
+func (s *SQLStore) Insert(event Event) (libQuery.DmlCommand, error) {
+	headers := ""
+	if len(event.Headers) > 0 {
+		data, err := json.Marshal(event.Headers)
+		if err != nil {
+			return libQuery.DmlCommand{}, fmt.Errorf("outbox: encode event %s headers: %w", event.ID, err)
+		}
+		headers = string(data)
+	}
+	if event.NextAttemptAt.IsZero() {
+		event.NextAttemptAt = event.CreatedAt
+	}
+	command := s.insert
+	command.Name = "outbox-" + event.ID
+	command.Args = []any{
+		event.ID, event.Topic, event.AggregateKey, string(event.Payload), headers,
+		string(StatusPending), event.Attempts, "",
+		event.CreatedAt.UnixMilli(), event.NextAttemptAt.UnixMilli(),
+	}
+	return command, nil
+}
+
+// Exec runs the Insert, Update and Delete commands and records events in
+// a single transaction on the store's database. Either everything
+// commits or nothing does.
+func (s *SQLStore) Exec(ctx context.Context, moduleName, methodName string, commands []libQuery.DmlCommand, events ...Event) error {
+	_, err := s.execTx(ctx, s.core, nil, moduleName, methodName, commands, events)
+	return err
+}
+
+// ExecDML is the transactional counterpart of handlers.ExecDML. It runs
+// the pre-control commands, then the DML commands for key together with
+// the outbox inserts for events in one transaction, then the finalize
+// commands. The result map has the same shape as handlers.ExecDML's.
+func (s *SQLStore) ExecDML(request libQuery.DmlModel, key, title string, w webFramework.WebFramework, core requestCore.RequestCoreInterface, events ...Event) (map[string]any, error) {
+	if err := handlers.PreControlDML(request, key, title, w, core); err != nil {
+		return nil, err
+	}
+	resp, err := s.execTx(w.Ctx, core.GetDB(), w.Parser, title, "dml."+key, request.DmlCommands()[key], events)
+	if err != nil {
+		return nil, errors.Join(err, libError.NewWithDescription(status.InternalServerError, "ERROR_IN_EXECUTE_DML", "unable to execute dml commands %s", title))
+	}
+	handlers.FinalizeDML(request, key, title, w, core)
+	return resp, nil
+}
+
+// queryRunnerModel returns the libQuery.QueryRunnerModel behind a
+// QueryRunnerInterface, if it is one.
+func queryRunnerModel(core libQuery.QueryRunnerInterface) (libQuery.QueryRunnerModel, bool) {
+	switch m := core.(type) {
+	case libQuery.QueryRunnerModel:
+		return m, m.DB != nil
+	case *libQuery.QueryRunnerModel:
+		if m != nil {
+			return *m, m.DB != nil
+		}
+	}
+	return libQuery.QueryRunnerModel{}, false
+}
+
+// execTx runs commands and the outbox inserts in one audited transaction,
+// like libQuery.QueryRunnerModel.Dml does for a single statement. When
+// parser is set, arguments referencing parser locals are resolved and
+// output parameters are stored back, as in DmlCommand.ExecuteWithContext.
+func (s *SQLStore) execTx(ctx context.Context, core libQuery.QueryRunnerInterface, parser webFramework.RequestParser,
+	moduleName, methodName string, commands []libQuery.DmlCommand, events []Event,
+) (map[string]any, error) {
+	model, ok := queryRunnerModel(core)
+	if !ok {
+		return nil, ErrNoTransaction
+	}
+	commands = slices.Clip(commands)
+	for _, event := range events {
+		command, err := s.Insert(event)
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, command)
+	}
+
+	tx, err := model.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, libError.Join(err, "error in outbox->BeginTrx()")
+	}
+	rollback := func(err error) error {
+		if errRB := tx.Rollback(); errRB != nil {
+			return errors.Join(errRB, err)
+		}
+		return err
+	}
+	if err := model.SetModifVariables(ctx, moduleName, methodName, tx); err != nil {
+		return nil, rollback(err)
+	}
+
+	resp := map[string]any{}
+	mode := model.GetDbMode()
+	for _, command := range commands {
+		switch command.Type {
+		case libQuery.Insert, libQuery.Update, libQuery.Delete:
+		default:
+			return nil, rollback(fmt.Errorf("outbox: %s: %s is not a DML command", command.Type, command.Name))
+		}
+		args := command.Args
+		if parser != nil {
+			args = libQuery.GetLocalArgs(parser, args)
+		}
+		result, err := tx.ExecContext(ctx, command.GetCommand(mode), libQuery.PrepareArgs(args)...)
+		if err != nil {
+			if command.CustomError != nil {
+				return nil, rollback(command.CustomError)
+			}
+			return nil, rollback(libError.Join(err, "%s: %s", command.Type, command.Name))
+		}
+		var outValues map[string]string
+		if parser != nil {
+			outValues = libQuery.GetOutArgs(parser, command.Args...)
+		}
+		resp[command.Name] = libQuery.GetDmlResult(result, outValues)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, libError.Join(err, "error in outbox->Commit()")
+	}
+	return resp, nil
+}
+
+// eventRow is the database representation of a pending Event.
+type eventRow struct {
+	ID            string `db:"id"`
+	Topic         string `db:"topic"`
+	AggregateKey  string `db:"aggregate_key"`
+	Payload       string `db:"payload"`
+	Headers       string `db:"headers"`
+	Attempts      int    `db:"attempts"`
+	CreatedAt     int64  `db:"created_at"`
+	NextAttemptAt int64  `db:"next_attempt_at"`
+}
+
+func (r eventRow) toEvent() (Event, error) {
+	event := Event{
+		ID:            r.ID,
+		Topic:         r.Topic,
+		AggregateKey:  r.AggregateKey,
+		Payload:       json.RawMessage(r.Payload),
+		Attempts:      r.Attempts,
+		CreatedAt:     time.UnixMilli(r.CreatedAt),
+		NextAttemptAt: time.UnixMilli(r.NextAttemptAt),
+	}
+	if r.Headers != "" {
+		if err := json.Unmarshal([]byte(r.Headers), &event.Headers); err != nil {
+			return Event{}, fmt.Errorf("outbox: decode event %s headers: %w", r.ID, err)
+		}
+	}
+	return event, nil
+}
+
+// Pending reads up to limit pending events of aggregates that are due at
+// now, in creation order.
+func (s *SQLStore) Pending(_ context.Context, now time.Time, limit int) ([]Event, error) {
+	rows, err := libQuery.QueryToStruct[eventRow](s.core, s.pending.GetCommand(s.core.GetDbMode()), now.UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]Event, 0, len(rows))
+	for _, row := range rows {
+		event, err := row.toEvent()
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// MarkPublished marks the event as published.
+func (s *SQLStore) MarkPublished(ctx context.Context, id string, at time.Time) error {
+	_, err := s.core.Dml(ctx, "outbox", s.published.Name, s.published.GetCommand(s.core.GetDbMode()),
+		string(StatusPublished), at.UnixMilli(), id)
+	return err
+}
+
+// MarkFailed records a failed attempt.
+func (s *SQLStore) MarkFailed(ctx context.Context, id string, attempts int, next time.Time, cause error, dead bool) error {
+	state := StatusPending
+	if dead {
+		state = StatusFailed
+	}
+	message := ""
+	if cause != nil {
+		message = cause.Error()
+	}
+	_, err := s.core.Dml(ctx, "outbox", s.failed.Name, s.failed.GetCommand(s.core.GetDbMode()),
+		string(state), attempts, message, next.UnixMilli(), id)
+	return err
+}
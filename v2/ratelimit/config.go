@@ -0,0 +1,43 @@
+package ratelimit
+
+import (
+	"fmt"
+
+	"github.com/hmmftg/requestCore/v2/routing"
+)
+
+// Limits maps route group names to their limits. It is meant to be
+// embedded in the application's YAML parameters, for example:
+//
+//	rateLimits:
+//	  public:
+//	    requests: 20
+//	    window: 1s
+//	    burst: 40
+//	  partners:
+//	    algorithm: sliding_window
+//	    requests: 1000
+//	    window: 1m
+//	    key: header:Program-Id
+type Limits map[string]Limit
+
+// Validate checks every limit.
+func (l Limits) Validate() error {
+	for group, limit := range l {
+		if err := limit.Validate(); err != nil {
+			return fmt.Errorf("%w (group %s)", err, group)
+		}
+	}
+	return nil
+}
+
+// Middleware returns the rate limiting middleware of group, counting in
+// store under the group name. It fails if group has no limit, so a typo
+// in the YAML does not silently disable limiting.
+func (l Limits) Middleware(group string, store Store) (routing.Middleware, error) {
+	limit, ok := l[group]
+	if !ok {
+		return nil, fmt.Errorf("ratelimit: no limit configured for group %q", group)
+	}
+	return Middleware(Config{Limit: limit, Name: group, Store: store})
+}
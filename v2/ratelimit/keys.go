@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	legacyLibNetHttp "github.com/hmmftg/requestCore/libNetHttp"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+// DefaultAPIKeyHeader is the header read by KeyByAPIKey.
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// KeyFunc returns the key a request is counted under. An empty key
+// exempts the request.
+type KeyFunc func(*v2wf.RequestContext) string
+
+// ParseKey resolves a Limit.Key specification:
+//
+//	""  or "ip"        client IP (KeyByIP)
+//	"header:<name>"    a request header such as User-Id or Program-Id
+//	"api_key"          the X-API-Key header (KeyByAPIKey)
+//	"route"            method and path (KeyByRoute)
+func ParseKey(spec string) (KeyFunc, error) {
+	switch spec {
+	case "", "ip":
+		return KeyByIP(), nil
+	case "api_key":
+		return KeyByAPIKey(DefaultAPIKeyHeader), nil
+	case "route":
+		return KeyByRoute(), nil
+	}
+	if name, ok := strings.CutPrefix(spec, "header:"); ok && name != "" {
+		return KeyByHeader(name), nil
+	}
+	return nil, fmt.Errorf("ratelimit: unknown key %q", spec)
+}
+
+// KeyByIP counts requests per client IP as reported by the framework:
+// gin.Context.ClientIP (which honours Gin's trusted proxies), fiber's
+// Ctx.IP, or the connection's remote address for net/http and chi.
+func KeyByIP() KeyFunc {
+	return func(ctx *v2wf.RequestContext) string {
+		if ip := ClientIP(ctx); ip != "" {
+			return "ip:" + ip
+		}
+		return ""
+	}
+}
+
+// KeyByHeader counts requests per value of the named header, for example
+// User-Id or Program-Id. Requests without the header are counted per
+// client IP, so omitting it does not bypass the limit.
+func KeyByHeader(name string) KeyFunc {
+	byIP := KeyByIP()
+	return func(ctx *v2wf.RequestContext) string {
+		if value := ctx.Parser.GetHeaderValue(name); value != "" {
+			return strings.ToLower(name) + ":" + value
+		}
+		return byIP(ctx)
+	}
+}
+
+// KeyByAPIKey counts requests per API key read from header. Like
+// KeyByHeader it falls back to the client IP.
+func KeyByAPIKey(header string) KeyFunc {
+	return KeyByHeader(header)
+}
+
+// KeyByRoute counts requests per method and path, so a limit applied to
+// a group is split between its endpoints. Note that the Gin adapter
+// reports the route pattern, while the others report the concrete path.
+func KeyByRoute() KeyFunc {
+	return func(ctx *v2wf.RequestContext) string {
+		path := ctx.Parser.GetPath()
+		if i := strings.IndexByte(path, '?'); i >= 0 {
+			path = path[:i]
+		}
+		return "route:" + ctx.Parser.GetMethod() + " " + path
+	}
+}
+
+// ClientIP returns the client IP of the request, or "" if the framework
+// context does not expose it.
+func ClientIP(ctx *v2wf.RequestContext) string {
+	switch native := ctx.LegacyContext.(type) {
+	case interface{ ClientIP() string }: // *gin.Context
+		return native.ClientIP()
+	case interface{ IP() string }: // *fiber.Ctx
+		return native.IP()
+	case context.Context:
+		req, ok := legacyLibNetHttp.RequestFromContext(native)
+		if !ok {
+			return ""
+		}
+		if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+			return host
+		}
+		return req.RemoteAddr
+	}
+	return ""
+}
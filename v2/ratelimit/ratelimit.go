@@ -0,0 +1,334 @@
+// Package ratelimit provides a framework-neutral inbound rate limiting
+// middleware for v2 routing.
+//
+// Requests are counted per key (client IP, a header such as User-Id or
+// Program-Id, an API key, or the route) with either a token bucket or a
+// sliding window. Counters live in a Store: MemoryStore for a single
+// replica, SQLStore to share limits between replicas. Every limited
+// response carries RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset
+// and RateLimit-Policy headers; a rejected request also gets Retry-After
+// and fails with an *Error, which reports HTTP 429 and is routed through
+// the v2 response registry like any other handler error.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/hmmftg/requestCore/libError"
+	"github.com/hmmftg/requestCore/status"
+	"github.com/hmmftg/requestCore/v2/routing"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+// Algorithm selects how requests are counted.
+type Algorithm string
+
+const (
+	// TokenBucket (default) refills Requests tokens per Window up to
+	// Burst and spends one per request, allowing short bursts.
+	TokenBucket Algorithm = "token_bucket"
+	// SlidingWindow allows Requests per Window, weighting the previous
+	// window's count by how much of it still overlaps the last Window.
+	SlidingWindow Algorithm = "sliding_window"
+)
+
+// Response headers set by the middleware.
+const (
+	HeaderLimit      = "RateLimit-Limit"
+	HeaderRemaining  = "RateLimit-Remaining"
+	HeaderReset      = "RateLimit-Reset"
+	HeaderPolicy     = "RateLimit-Policy"
+	HeaderRetryAfter = "Retry-After"
+)
+
+// Limit is a rate limit. It is usually loaded from YAML as part of
+// Limits.
+type Limit struct {
+	// Algorithm selects token bucket or sliding window counting.
+	// Default: TokenBucket.
+	Algorithm Algorithm `yaml:"algorithm" json:"algorithm,omitempty"`
+
+	// Requests is the number of requests allowed per Window.
+	Requests int `yaml:"requests" json:"requests"`
+
+	// Window is the period Requests applies to.
+	Window time.Duration `yaml:"window" json:"window"`
+
+	// Burst is the token bucket capacity.
+	// Default: Requests.
+	Burst int `yaml:"burst" json:"burst,omitempty"`
+
+	// Key selects what requests are counted by; see ParseKey.
+	// Default: "ip".
+	Key string `yaml:"key" json:"key,omitempty"`
+}
+
+// Validate reports whether the limit is usable.
+func (l Limit) Validate() error {
+	switch l.Algorithm {
+	case "", TokenBucket, SlidingWindow:
+	default:
+		return fmt.Errorf("ratelimit: unknown algorithm %q", l.Algorithm)
+	}
+	if l.Requests <= 0 || l.Window <= 0 {
+		return errors.New("ratelimit: requests and window must be positive")
+	}
+	if l.Burst < 0 {
+		return errors.New("ratelimit: burst must not be negative")
+	}
+	_, err := ParseKey(l.Key)
+	return err
+}
+
+func (l Limit) withDefaults() Limit {
+	if l.Algorithm == "" {
+		l.Algorithm = TokenBucket
+	}
+	if l.Burst == 0 {
+		l.Burst = l.Requests
+	}
+	return l
+}
+
+// policy formats the RateLimit-Policy header value.
+func (l Limit) policy() string {
+	p := fmt.Sprintf("%d;w=%d", l.Requests, int64(math.Ceil(l.Window.Seconds())))
+	if l.Algorithm == TokenBucket && l.Burst != l.Requests {
+		p += fmt.Sprintf(";burst=%d", l.Burst)
+	}
+	return p
+}
+
+// Decision is the outcome of counting one request.
+type Decision struct {
+	// Allowed reports whether the request is within the limit.
+	Allowed bool
+	// Limit is the number of requests allowed at once: Burst for a
+	// token bucket, Requests for a sliding window.
+	Limit int
+	// Remaining is how many more requests would be allowed now.
+	Remaining int
+	// Reset is how long until the full limit is available again.
+	Reset time.Duration
+	// RetryAfter is how long until a rejected request would be allowed.
+	RetryAfter time.Duration
+}
+
+// State is the stored counter of one key. Its meaning depends on the
+// algorithm; stores only persist it.
+type State struct {
+	// Count is the tokens left (TokenBucket) or the requests counted in
+	// the current window (SlidingWindow).
+	Count float64
+	// Previous is the requests counted in the previous window
+	// (SlidingWindow only).
+	Previous float64
+	// Stamp is the last refill (TokenBucket) or the start of the current
+	// window (SlidingWindow), in Unix milliseconds.
+	Stamp int64
+	// Expires is when the state is back to its initial value and can be
+	// discarded, in Unix milliseconds.
+	Expires int64
+}
+
+// take counts one request at now against state and returns the new state.
+// found is false when the key has no stored state.
+func (l Limit) take(state State, found bool, now time.Time) (State, Decision) {
+	if l.Algorithm == SlidingWindow {
+		return l.slidingWindow(state, found, now)
+	}
+	return l.tokenBucket(state, found, now)
+}
+
+func (l Limit) tokenBucket(state State, found bool, now time.Time) (State, Decision) {
+	ms := now.UnixMilli()
+	capacity := float64(l.Burst)
+	perMs := float64(l.Requests) / float64(l.Window.Milliseconds())
+
+	tokens := capacity
+	if found {
+		tokens = math.Min(capacity, state.Count+float64(ms-state.Stamp)*perMs)
+	}
+	decision := Decision{Limit: l.Burst}
+	if tokens >= 1 {
+		tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = msDuration((1 - tokens) / perMs)
+	}
+	decision.Remaining = int(tokens)
+	decision.Reset = msDuration((capacity - tokens) / perMs)
+	return State{Count: tokens, Stamp: ms, Expires: ms + decision.Reset.Milliseconds()}, decision
+}
+
+func (l Limit) slidingWindow(state State, found bool, now time.Time) (State, Decision) {
+	ms := now.UnixMilli()
+	window := l.Window.Milliseconds()
+	start := ms - ms%window
+	limit := float64(l.Requests)
+
+	if !found || state.Stamp != start {
+		previous := 0.0
+		if found && state.Stamp == start-window {
+			previous = state.Count
+		}
+		state = State{Previous: previous, Stamp: start}
+	}
+	state.Expires = start + 2*window
+
+	elapsed := float64(ms - start)
+	estimate := state.Previous*(1-elapsed/float64(window)) + state.Count
+	decision := Decision{Limit: l.Requests, Reset: time.Duration(start+window-ms) * time.Millisecond}
+	if estimate+1 <= limit {
+		state.Count++
+		decision.Allowed = true
+		decision.Remaining = int(limit - estimate - 1)
+		return state, decision
+	}
+
+	// The estimate only falls as the previous window slides out, or at
+	// the next window when the current count becomes the previous one.
+	switch {
+	case state.Count+1 <= limit && state.Previous > 0:
+		needed := float64(window) * (1 - (limit-state.Count-1)/state.Previous)
+		decision.RetryAfter = msDuration(needed - elapsed)
+	default:
+		needed := float64(window) * (1 - (limit-1)/state.Count)
+		decision.RetryAfter = decision.Reset + msDuration(needed)
+	}
+	return state, decision
+}
+
+func msDuration(ms float64) time.Duration {
+	return time.Duration(math.Ceil(ms)) * time.Millisecond
+}
+
+// Error is returned when a request exceeds its limit. It wraps a
+// libError with status 429 and code RATE_LIMITED, so the response
+// registry resolves it to HTTP 429 and the legacy fallback renders it.
+type Error struct {
+	// Key is the limited key, without the middleware name.
+	Key string
+	// Decision is the rejected decision.
+	Decision Decision
+
+	err error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ratelimit: limit exceeded, retry after %s", e.Decision.RetryAfter)
+}
+
+// Unwrap returns the libError carrying the response status and code.
+func (e *Error) Unwrap() error { return e.err }
+
+// RetryAfter returns how long the client should wait before retrying.
+func (e *Error) RetryAfter() time.Duration { return e.Decision.RetryAfter }
+
+// Config configures the rate limiting middleware.
+type Config struct {
+	// Limit is the limit applied to each key.
+	Limit Limit
+
+	// Name namespaces the keys in Store, so route groups sharing a store
+	// keep separate counters.
+	// Default: "default".
+	Name string
+
+	// Store holds the counters.
+	// Default: a new MemoryStore.
+	Store Store
+
+	// KeyFunc overrides Limit.Key.
+	KeyFunc KeyFunc
+
+	// FailOpen lets requests through when the store fails instead of
+	// failing them. Store errors are logged either way.
+	FailOpen bool
+
+	// Skipper, if set, exempts any request for which it returns true.
+	Skipper func(*v2wf.RequestContext) bool
+
+	// Clock is the clock source for deterministic testing.
+	// If nil, time.Now is used.
+	Clock func() time.Time
+}
+
+// Middleware returns a routing.Middleware that counts every request
+// against config.Limit and rejects those over it with an *Error.
+// Requests whose key is empty are not limited.
+func Middleware(config Config) (routing.Middleware, error) {
+	if err := config.Limit.Validate(); err != nil {
+		return nil, err
+	}
+	limit := config.Limit.withDefaults()
+	keyFunc := config.KeyFunc
+	if keyFunc == nil {
+		keyFunc, _ = ParseKey(limit.Key)
+	}
+	if config.Name == "" {
+		config.Name = "default"
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+	policy := limit.policy()
+
+	return func(next routing.Handler) routing.Handler {
+		return func(ctx *v2wf.RequestContext) error {
+			if ctx.Parser == nil || (config.Skipper != nil && config.Skipper(ctx)) {
+				return next(ctx)
+			}
+			key := keyFunc(ctx)
+			if key == "" {
+				return next(ctx)
+			}
+
+			var decision Decision
+			now := config.Clock()
+			err := config.Store.Update(ctx.ContextOrBackground(), config.Name+":"+key, now, func(state State, found bool) State {
+				state, decision = limit.take(state, found, now)
+				return state
+			})
+			if err != nil {
+				slog.Error("ratelimit: store failed", slog.String("name", config.Name), slog.Any("error", err))
+				if config.FailOpen {
+					return next(ctx)
+				}
+				return err
+			}
+
+			ctx.Parser.SetRespHeader(HeaderLimit, strconv.Itoa(decision.Limit))
+			ctx.Parser.SetRespHeader(HeaderRemaining, strconv.Itoa(decision.Remaining))
+			ctx.Parser.SetRespHeader(HeaderReset, strconv.FormatInt(seconds(decision.Reset), 10))
+			ctx.Parser.SetRespHeader(HeaderPolicy, policy)
+			if !decision.Allowed {
+				ctx.Parser.SetRespHeader(HeaderRetryAfter, strconv.FormatInt(max(seconds(decision.RetryAfter), 1), 10))
+				return &Error{
+					Key:      key,
+					Decision: decision,
+					err: libError.NewWithDescription(
+						status.DuplicateRequest,
+						"RATE_LIMITED",
+						"rate limit of %s exceeded",
+						config.Name,
+					),
+				}
+			}
+			return next(ctx)
+		}
+	}, nil
+}
+
+// seconds rounds d up to whole seconds, as the headers require.
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,248 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	legacyLibNetHttp "github.com/hmmftg/requestCore/libNetHttp"
+	legacy "github.com/hmmftg/requestCore/webFramework"
+
+	v2response "github.com/hmmftg/requestCore/v2/response"
+	"github.com/hmmftg/requestCore/v2/routing"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+func newRequest(headers map[string]string) (*v2wf.RequestContext, *v2wf.FakeParserV2) {
+	parser := v2wf.NewFakeParserV2()
+	parser.Method = http.MethodGet
+	parser.Path = "/items"
+	for k, v := range headers {
+		parser.ReqHeader[k] = v
+	}
+	commit := &v2wf.CommitState{}
+	parser.SetCommitState(commit)
+	ctx := &v2wf.RequestContext{
+		Parser:  parser,
+		Context: context.Background(),
+		Legacy:  legacy.WebFramework{Parser: parser},
+	}
+	ctx.SetCommitState(commit)
+	return ctx, parser
+}
+
+func run(mw routing.Middleware, ctx *v2wf.RequestContext) (bool, error) {
+	called := false
+	err := mw(func(*v2wf.RequestContext) error {
+		called = true
+		return nil
+	})(ctx)
+	return called, err
+}
+
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time          { return c.now }
+func (c *clock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestMiddleware_TokenBucket(t *testing.T) {
+	c := &clock{now: time.UnixMilli(1700000000000)}
+	mw, err := Middleware(Config{
+		Limit: Limit{Requests: 2, Window: time.Second, Burst: 3, Key: "header:User-Id"},
+		Clock: c.Now,
+	})
+	if err != nil {
+		t.Fatalf("Middleware: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		ctx, parser := newRequest(map[string]string{"User-Id": "alice"})
+		if called, err := run(mw, ctx); err != nil || !called {
+			t.Fatalf("request %d: called=%v err=%v", i, called, err)
+		}
+		if got := parser.RespHeader[HeaderRemaining]; got != strconv.Itoa(2-i) {
+			t.Fatalf("request %d: remaining %q", i, got)
+		}
+	}
+
+	ctx, parser := newRequest(map[string]string{"User-Id": "alice"})
+	called, err := run(mw, ctx)
+	var limited *Error
+	if called || !errors.As(err, &limited) || limited.Key != "user-id:alice" {
+		t.Fatalf("expected rejection, called=%v err=%v", called, err)
+	}
+	if limited.RetryAfter() != 500*time.Millisecond || parser.RespHeader[HeaderRetryAfter] != "1" {
+		t.Fatalf("unexpected retry after %s / %q", limited.RetryAfter(), parser.RespHeader[HeaderRetryAfter])
+	}
+	if parser.RespHeader[HeaderLimit] != "3" || parser.RespHeader[HeaderReset] != "2" ||
+		parser.RespHeader[HeaderPolicy] != "2;w=1;burst=3" {
+		t.Fatalf("unexpected headers %v", parser.RespHeader)
+	}
+
+	// Other keys have their own bucket, and tokens refill over time.
+	if called, _ := run(mw, first(newRequest(map[string]string{"User-Id": "bob"}))); !called {
+		t.Fatal("bob should not share alice's bucket")
+	}
+	c.Advance(500 * time.Millisecond)
+	if called, err := run(mw, first(newRequest(map[string]string{"User-Id": "alice"}))); !called {
+		t.Fatalf("expected refill after 500ms, got %v", err)
+	}
+}
+
+func TestMiddleware_SlidingWindow(t *testing.T) {
+	c := &clock{now: time.UnixMilli(1700000000000)} // window start
+	store := NewMemoryStore()
+	mw, err := Middleware(Config{
+		Limit: Limit{Algorithm: SlidingWindow, Requests: 4, Window: time.Second, Key: "route"},
+		Store: store,
+		Clock: c.Now,
+	})
+	if err != nil {
+		t.Fatalf("Middleware: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if called, err := run(mw, first(newRequest(nil))); !called {
+			t.Fatalf("request %d rejected: %v", i, err)
+		}
+	}
+	_, err = run(mw, first(newRequest(nil)))
+	var limited *Error
+	if !errors.As(err, &limited) || limited.RetryAfter() != 1250*time.Millisecond {
+		t.Fatalf("expected rejection until 1.25s, got %v", err)
+	}
+
+	// Half way through the next window, half of the previous count
+	// still applies: 4*0.5 = 2 of 4 used.
+	c.Advance(1500 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if called, err := run(mw, first(newRequest(nil))); !called {
+			t.Fatalf("request %d in next window rejected: %v", i, err)
+		}
+	}
+	if called, _ := run(mw, first(newRequest(nil))); called {
+		t.Fatal("expected rejection once the weighted estimate reaches the limit")
+	}
+	if store.Len() != 1 {
+		t.Fatalf("expected one key, got %d", store.Len())
+	}
+}
+
+func TestMiddleware_Options(t *testing.T) {
+	if _, err := Middleware(Config{Limit: Limit{Requests: 1}}); err == nil {
+		t.Fatal("expected error for missing window")
+	}
+	if _, err := Middleware(Config{Limit: Limit{Requests: 1, Window: time.Second, Key: "cookie"}}); err == nil {
+		t.Fatal("expected error for unknown key")
+	}
+
+	failing := storeFunc(func() error { return errors.New("db down") })
+	mw, _ := Middleware(Config{Limit: Limit{Requests: 1, Window: time.Second, Key: "route"}, Store: failing})
+	if called, err := run(mw, first(newRequest(nil))); called || err == nil {
+		t.Fatalf("expected store error, called=%v err=%v", called, err)
+	}
+	mw, _ = Middleware(Config{Limit: Limit{Requests: 1, Window: time.Second, Key: "route"}, Store: failing, FailOpen: true})
+	if called, err := run(mw, first(newRequest(nil))); !called || err != nil {
+		t.Fatalf("expected fail open, called=%v err=%v", called, err)
+	}
+
+	// Without a client IP the request has no key and is not limited.
+	mw, _ = Middleware(Config{Limit: Limit{Requests: 1, Window: time.Hour}})
+	for i := 0; i < 3; i++ {
+		if called, _ := run(mw, first(newRequest(nil))); !called {
+			t.Fatal("request without key should pass")
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/items?page=2", nil)
+	req.RemoteAddr = "10.0.0.7:51234"
+	ctx, parser := newRequest(map[string]string{"X-API-Key": "k1"})
+	ctx.LegacyContext = legacyLibNetHttp.WithRequestResponse(context.Background(), req, httptest.NewRecorder())
+	parser.Path = "/items?page=2"
+
+	cases := map[string]string{
+		"":                  "ip:10.0.0.7",
+		"api_key":           "x-api-key:k1",
+		"header:Program-Id": "ip:10.0.0.7",
+		"route":             "route:GET /items",
+	}
+	for spec, want := range cases {
+		key, err := ParseKey(spec)
+		if err != nil {
+			t.Fatalf("ParseKey(%q): %v", spec, err)
+		}
+		if got := key(ctx); got != want {
+			t.Errorf("ParseKey(%q) key = %q, want %q", spec, got, want)
+		}
+	}
+}
+
+func TestLimits_YAML(t *testing.T) {
+	var params struct {
+		RateLimits Limits `yaml:"rateLimits"`
+	}
+	doc := `
+rateLimits:
+  partners:
+    algorithm: sliding_window
+    requests: 1000
+    window: 1m
+    key: header:Program-Id
+`
+	if err := yaml.Unmarshal([]byte(doc), &params); err != nil {
+		t.Fatalf("yaml: %v", err)
+	}
+	if err := params.RateLimits.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	want := Limit{Algorithm: SlidingWindow, Requests: 1000, Window: time.Minute, Key: "header:Program-Id"}
+	if params.RateLimits["partners"] != want {
+		t.Fatalf("unexpected limit %+v", params.RateLimits["partners"])
+	}
+	if _, err := params.RateLimits.Middleware("partners", nil); err != nil {
+		t.Fatalf("Middleware: %v", err)
+	}
+	if _, err := params.RateLimits.Middleware("public", nil); err == nil {
+		t.Fatal("expected error for unconfigured group")
+	}
+}
+
+func TestError_RoutedThroughRegistry(t *testing.T) {
+	mw, _ := Middleware(Config{Limit: Limit{Requests: 1, Window: 10 * time.Second, Key: "route"}})
+	_, _ = run(mw, first(newRequest(nil)))
+	ctx, parser := newRequest(nil)
+	_, err := run(mw, ctx)
+
+	registry := v2response.NewRegistry(nil)
+	for status, h := range v2response.DefaultErrorHandlers() {
+		if err := registry.Register(status, h); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	}
+	if status := registry.Resolve(err); status != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", status)
+	}
+	if err := registry.Handle(ctx, err); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if parser.ResponseStatus != http.StatusTooManyRequests || parser.RespHeader[HeaderRetryAfter] != "10" {
+		t.Fatalf("unexpected response %d, Retry-After %q", parser.ResponseStatus, parser.RespHeader[HeaderRetryAfter])
+	}
+}
+
+type storeFunc func() error
+
+func (f storeFunc) Update(context.Context, string, time.Time, func(State, bool) State) error {
+	return f()
+}
+
+func first(ctx *v2wf.RequestContext, _ *v2wf.FakeParserV2) *v2wf.RequestContext {
+	return ctx
+}
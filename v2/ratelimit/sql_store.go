@@ -0,0 +1,206 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/hmmftg/requestCore/libQuery"
+)
+
+// ErrConflict is returned by SQLStore when every attempt to update a key
+// lost to a concurrent update.
+var ErrConflict = errors.New("ratelimit: concurrent update")
+
+// DefaultSQLTable is the table used by SQLStore when no table name is
+// configured.
+const DefaultSQLTable = "rate_limits"
+
+// SQLStoreConfig configures a SQLStore.
+type SQLStoreConfig struct {
+	// Table is the counters table.
+	// Default: DefaultSQLTable.
+	Table string
+
+	// MaxAttempts bounds how often Update retries after losing a race
+	// with another replica.
+	// Default: 5.
+	MaxAttempts int
+
+	// CleanupInterval is how often RunCleanup deletes expired counters.
+	// Default: 10 minutes.
+	CleanupInterval time.Duration
+
+	// Clock is the clock source of Cleanup, for deterministic testing.
+	// If nil, time.Now is used.
+	Clock func() time.Time
+}
+
+// SQLStore implements Store with a database table accessed through
+// libQuery.QueryRunnerInterface, so replicas share their limits. The
+// expected schema is:
+//
+//	CREATE TABLE rate_limits (
+//	    key_name        VARCHAR(200)     PRIMARY KEY,
+//	    current_count   DOUBLE PRECISION NOT NULL,
+//	    previous_count  DOUBLE PRECISION NOT NULL,
+//	    stamp           BIGINT           NOT NULL,
+//	    expires_at      BIGINT           NOT NULL,
+//	    revision        BIGINT           NOT NULL
+//	);
+//	CREATE INDEX rate_limits_expires_at ON rate_limits (expires_at);
+//
+// Timestamps are stored as Unix milliseconds. Updates are conditional on
+// the revision that was read, so concurrent requests on different
+// replicas never lose a count; the loser reads the new state and retries.
+type SQLStore struct {
+	core   libQuery.QueryRunnerInterface
+	config SQLStoreConfig
+
+	load    libQuery.QueryCommand
+	insert  libQuery.DmlCommand
+	update  libQuery.DmlCommand
+	cleanup libQuery.DmlCommand
+}
+
+// NewSQLStore creates a SQLStore on core.
+func NewSQLStore(core libQuery.QueryRunnerInterface, config SQLStoreConfig) *SQLStore {
+	if config.Table == "" {
+		config.Table = DefaultSQLTable
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+	if config.CleanupInterval <= 0 {
+		config.CleanupInterval = 10 * time.Minute
+	}
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+	t := config.Table
+	return &SQLStore{
+		core:   core,
+		config: config,
+		load: libQuery.QueryCommand{
+			Name:    "ratelimit-load",
+			Command: "SELECT current_count, previous_count, stamp, expires_at, revision FROM " + t + " WHERE key_name=$1",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "SELECT current_count, previous_count, stamp, expires_at, revision FROM " + t + " WHERE key_name=:1",
+				libQuery.MySql:  "SELECT current_count, previous_count, stamp, expires_at, revision FROM " + t + " WHERE key_name=?",
+				libQuery.Sqlite: "SELECT current_count, previous_count, stamp, expires_at, revision FROM " + t + " WHERE key_name=?",
+			},
+		},
+		insert: libQuery.DmlCommand{
+			Name:    "ratelimit-insert",
+			Command: "INSERT INTO " + t + " (key_name, current_count, previous_count, stamp, expires_at, revision) VALUES ($1, $2, $3, $4, $5, 1)",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "INSERT INTO " + t + " (key_name, current_count, previous_count, stamp, expires_at, revision) VALUES (:1, :2, :3, :4, :5, 1)",
+				libQuery.MySql:  "INSERT INTO " + t + " (key_name, current_count, previous_count, stamp, expires_at, revision) VALUES (?, ?, ?, ?, ?, 1)",
+				libQuery.Sqlite: "INSERT INTO " + t + " (key_name, current_count, previous_count, stamp, expires_at, revision) VALUES (?, ?, ?, ?, ?, 1)",
+			},
+			Type: libQuery.Insert,
+		},
+		update: libQuery.DmlCommand{
+			Name:    "ratelimit-update",
+			Command: "UPDATE " + t + " SET current_count=$1, previous_count=$2, stamp=$3, expires_at=$4, revision=revision+1 WHERE key_name=$5 AND revision=$6",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "UPDATE " + t + " SET current_count=:1, previous_count=:2, stamp=:3, expires_at=:4, revision=revision+1 WHERE key_name=:5 AND revision=:6",
+				libQuery.MySql:  "UPDATE " + t + " SET current_count=?, previous_count=?, stamp=?, expires_at=?, revision=revision+1 WHERE key_name=? AND revision=?",
+				libQuery.Sqlite: "UPDATE " + t + " SET current_count=?, previous_count=?, stamp=?, expires_at=?, revision=revision+1 WHERE key_name=? AND revision=?",
+			},
+			Type: libQuery.Update,
+		},
+		cleanup: libQuery.DmlCommand{
+			Name:    "ratelimit-cleanup",
+			Command: "DELETE FROM " + t + " WHERE expires_at<=$1",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "DELETE FROM " + t + " WHERE expires_at<=:1",
+				libQuery.MySql:  "DELETE FROM " + t + " WHERE expires_at<=?",
+				libQuery.Sqlite: "DELETE FROM " + t + " WHERE expires_at<=?",
+			},
+			Type: libQuery.Delete,
+		},
+	}
+}
+
+// limitRow is the database representation of a State.
+type limitRow struct {
+	Current   float64 `db:"current_count"`
+	Previous  float64 `db:"previous_count"`
+	Stamp     int64   `db:"stamp"`
+	ExpiresAt int64   `db:"expires_at"`
+	Revision  int64   `db:"revision"`
+}
+
+// Update implements Store. The first count of a key inserts its row; a
+// concurrent insert by another replica makes the loser retry as an
+// update.
+func (s *SQLStore) Update(ctx context.Context, key string, now time.Time, fn func(State, bool) State) error {
+	mode := s.core.GetDbMode()
+	lastErr := ErrConflict
+	for attempt := 0; attempt < s.config.MaxAttempts; attempt++ {
+		rows, err := libQuery.QueryToStruct[limitRow](s.core, s.load.GetCommand(mode), key)
+		if err != nil {
+			return fmt.Errorf("ratelimit: load: %w", err)
+		}
+
+		if len(rows) == 0 {
+			state := fn(State{}, false)
+			_, err := s.core.Dml(ctx, "ratelimit", s.insert.Name, s.insert.GetCommand(mode),
+				key, state.Count, state.Previous, state.Stamp, state.Expires)
+			if err == nil {
+				return nil
+			}
+			lastErr = err
+			continue
+		}
+
+		row := rows[0]
+		state := fn(State{
+			Count:    row.Current,
+			Previous: row.Previous,
+			Stamp:    row.Stamp,
+			Expires:  row.ExpiresAt,
+		}, row.ExpiresAt > now.UnixMilli())
+		result, err := s.core.Dml(ctx, "ratelimit", s.update.Name, s.update.GetCommand(mode),
+			state.Count, state.Previous, state.Stamp, state.Expires, key, row.Revision)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil || n > 0 {
+			return nil
+		}
+		lastErr = ErrConflict
+	}
+	return fmt.Errorf("ratelimit: update %s: %w", key, lastErr)
+}
+
+// Cleanup deletes expired counters and returns how many were removed.
+func (s *SQLStore) Cleanup(ctx context.Context) (int64, error) {
+	result, err := s.core.Dml(ctx, "ratelimit", s.cleanup.Name, s.cleanup.GetCommand(s.core.GetDbMode()),
+		s.config.Clock().UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+	return n, nil
+}
+
+// RunCleanup calls Cleanup every CleanupInterval until ctx is cancelled
+// and returns ctx.Err(). Failures are logged and retried on the next tick.
+func (s *SQLStore) RunCleanup(ctx context.Context) error {
+	ticker := time.NewTicker(s.config.CleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := s.Cleanup(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("ratelimit: cleanup failed", slog.Any("error", err))
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hmmftg/requestCore/libQuery"
+)
+
+func newSQLStore(t *testing.T) (*SQLStore, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	core := libQuery.Init(db, "prog", "mod", libQuery.Postgres)
+	core.Mode = libQuery.Postgres
+	return NewSQLStore(core, SQLStoreConfig{MaxAttempts: 2}), mock
+}
+
+// expectDml registers the audited transaction libQuery.Dml runs around
+// every statement.
+func expectDml(mock sqlmock.Sqlmock, pattern string, rows int64, err error, args ...driver.Value) {
+	mock.ExpectBegin()
+	for i := 0; i < 4; i++ {
+		mock.ExpectExec("set_config").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	exec := mock.ExpectExec(pattern).WithArgs(args...)
+	if err != nil {
+		exec.WillReturnError(err)
+		mock.ExpectRollback()
+		return
+	}
+	exec.WillReturnResult(sqlmock.NewResult(0, rows))
+	mock.ExpectCommit()
+}
+
+var limitColumns = []string{"current_count", "previous_count", "stamp", "expires_at", "revision"}
+
+func TestSQLStore_Update(t *testing.T) {
+	store, mock := newSQLStore(t)
+	now := time.UnixMilli(1700000000000)
+	limit := Limit{Requests: 2, Window: time.Second}.withDefaults()
+	var decision Decision
+	take := func(state State, found bool) State {
+		state, decision = limit.take(state, found, now)
+		return state
+	}
+
+	// The first request inserts the key; losing the insert to another
+	// replica retries as an update of its row.
+	mock.ExpectPrepare("SELECT current_count").ExpectQuery().WithArgs("api:ip:1").WillReturnRows(sqlmock.NewRows(limitColumns))
+	expectDml(mock, "INSERT INTO rate_limits", 1, errors.New("duplicate key"),
+		"api:ip:1", 1.0, 0.0, now.UnixMilli(), now.UnixMilli()+500)
+	mock.ExpectPrepare("SELECT current_count").ExpectQuery().WithArgs("api:ip:1").
+		WillReturnRows(sqlmock.NewRows(limitColumns).AddRow(1.0, 0.0, now.UnixMilli(), now.UnixMilli()+500, 1))
+	expectDml(mock, "UPDATE rate_limits SET", 1, nil,
+		0.0, 0.0, now.UnixMilli(), now.UnixMilli()+1000, "api:ip:1", int64(1))
+	if err := store.Update(context.Background(), "api:ip:1", now, take); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if !decision.Allowed || decision.Remaining != 0 {
+		t.Fatalf("unexpected decision %+v", decision)
+	}
+
+	// A revision changed by every attempt reports a conflict.
+	for i := 0; i < 2; i++ {
+		mock.ExpectPrepare("SELECT current_count").ExpectQuery().WithArgs("api:ip:1").
+			WillReturnRows(sqlmock.NewRows(limitColumns).AddRow(0.0, 0.0, now.UnixMilli(), now.UnixMilli()+1000, 2+i))
+		expectDml(mock, "UPDATE rate_limits SET", 0, nil,
+			0.0, 0.0, now.UnixMilli(), now.UnixMilli()+1000, "api:ip:1", int64(2+i))
+	}
+	if err := store.Update(context.Background(), "api:ip:1", now, take); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	if decision.Allowed {
+		t.Fatal("empty bucket should reject")
+	}
+
+	expectDml(mock, "DELETE FROM rate_limits WHERE expires_at", 3, nil, sqlmock.AnyArg())
+	if n, err := store.Cleanup(context.Background()); err != nil || n != 3 {
+		t.Fatalf("Cleanup: n=%d err=%v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store holds the rate limit state of each key.
+type Store interface {
+	// Update atomically replaces the state of key with the result of fn.
+	// fn receives the stored state and whether there is one; a state
+	// whose Expires is not after now counts as absent. Stores may call
+	// fn more than once when a concurrent update wins.
+	Update(ctx context.Context, key string, now time.Time, fn func(state State, found bool) State) error
+}
+
+// memorySweepEvery is how many updates MemoryStore handles between
+// sweeps of expired keys.
+const memorySweepEvery = 1024
+
+// MemoryStore implements Store in process memory. Limits are per
+// replica; use SQLStore to share them.
+type MemoryStore struct {
+	mu      sync.Mutex
+	states  map[string]State
+	updates int
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string]State)}
+}
+
+// Update implements Store.
+func (s *MemoryStore) Update(_ context.Context, key string, now time.Time, fn func(State, bool) State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ms := now.UnixMilli()
+	state, found := s.states[key]
+	if found && state.Expires <= ms {
+		found = false
+	}
+	s.states[key] = fn(state, found)
+
+	s.updates++
+	if s.updates >= memorySweepEvery {
+		s.updates = 0
+		for k, st := range s.states {
+			if st.Expires <= ms {
+				delete(s.states, k)
+			}
+		}
+	}
+	return nil
+}
+
+// Len returns the number of keys held, including expired keys not yet
+// swept.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.states)
+}
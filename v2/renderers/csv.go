@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/csv"
 	"fmt"
+	"io"
 	"reflect"
 	"strings"
 )
@@ -93,6 +94,69 @@ func (r CSVRenderer) Encode(data any) ([]byte, error) {
 	}
 }
 
+// NewStreamEncoder returns an encoder that writes one CSV row per
+// element to w, accepting the element types Encode accepts in a slice:
+// []string rows, structs (or pointers to structs), or single values.
+// For structs the header row is written before the first element, so an
+// empty stream has no header.
+func (r CSVRenderer) NewStreamEncoder(w io.Writer) StreamEncoder {
+	return &csvStreamEncoder{headers: r.Headers, w: csv.NewWriter(w)}
+}
+
+type csvStreamEncoder struct {
+	headers []string
+	w       *csv.Writer
+	fields  []csvField
+	n       int
+}
+
+func (e *csvStreamEncoder) Encode(v any) error {
+	row, err := e.row(v)
+	if err != nil {
+		return err
+	}
+	if err := e.w.Write(row); err != nil {
+		return fmt.Errorf("csv: write row %d: %w", e.n, err)
+	}
+	e.n++
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvStreamEncoder) row(v any) ([]string, error) {
+	if row, ok := v.([]string); ok {
+		return row, nil
+	}
+	val := reflect.Indirect(reflect.ValueOf(v))
+	if val.Kind() != reflect.Struct {
+		return []string{fmt.Sprint(v)}, nil
+	}
+	if e.fields == nil {
+		fields, err := selectStructFields(val.Type(), e.headers)
+		if err != nil {
+			return nil, err
+		}
+		headerRow := make([]string, len(fields))
+		for i, f := range fields {
+			headerRow[i] = f.Name
+		}
+		if err := e.w.Write(headerRow); err != nil {
+			return nil, fmt.Errorf("csv: write header: %w", err)
+		}
+		e.fields = fields
+	}
+	row := make([]string, len(e.fields))
+	for j, f := range e.fields {
+		row[j] = formatCSVField(val.FieldByIndex(f.Index))
+	}
+	return row, nil
+}
+
+func (e *csvStreamEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
 type csvField struct {
 	Name  string
 	Index []int
@@ -1,6 +1,9 @@
 package renderers
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"io"
+)
 
 // JSONRenderer encodes data as JSON.
 type JSONRenderer struct {
@@ -25,3 +28,55 @@ func (r JSONRenderer) Encode(data any) ([]byte, error) {
 	}
 	return json.Marshal(data)
 }
+
+// NewStreamEncoder returns an encoder that writes its elements to w as a
+// JSON array.
+func (r JSONRenderer) NewStreamEncoder(w io.Writer) StreamEncoder {
+	return &jsonArrayEncoder{w: w, indent: r.Indent}
+}
+
+type jsonArrayEncoder struct {
+	w      io.Writer
+	indent string
+	n      int
+}
+
+func (e *jsonArrayEncoder) Encode(v any) error {
+	var (
+		buf []byte
+		err error
+	)
+	if e.indent != "" {
+		buf, err = json.MarshalIndent(v, e.indent, e.indent)
+	} else {
+		buf, err = json.Marshal(v)
+	}
+	if err != nil {
+		return err
+	}
+	sep := ","
+	if e.n == 0 {
+		sep = "["
+	}
+	if e.indent != "" {
+		sep += "\n" + e.indent
+	}
+	e.n++
+	if _, err := io.WriteString(e.w, sep); err != nil {
+		return err
+	}
+	_, err = e.w.Write(buf)
+	return err
+}
+
+func (e *jsonArrayEncoder) Close() error {
+	end := "]"
+	switch {
+	case e.n == 0:
+		end = "[]"
+	case e.indent != "":
+		end = "\n]"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
@@ -0,0 +1,56 @@
+package renderers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+)
+
+// NDJSONRenderer encodes data as newline-delimited JSON: one JSON value
+// per line. Slices and arrays are written one element per line; any
+// other value is written as a single line. Unlike a JSON array, a
+// truncated stream still parses line by line and its missing rows are
+// detectable, which makes NDJSON the preferred format for exports.
+type NDJSONRenderer struct{}
+
+// ContentType returns the NDJSON content type.
+func (r NDJSONRenderer) ContentType() string {
+	return "application/x-ndjson"
+}
+
+// Encode serializes data as NDJSON.
+func (r NDJSONRenderer) Encode(data any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := r.NewStreamEncoder(buf)
+	v := reflect.ValueOf(data)
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < v.Len(); i++ {
+			if err := enc.Encode(v.Index(i).Interface()); err != nil {
+				return nil, err
+			}
+		}
+	} else if err := enc.Encode(data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NewStreamEncoder returns an encoder that writes one line per element
+// to w.
+func (r NDJSONRenderer) NewStreamEncoder(w io.Writer) StreamEncoder {
+	return ndjsonEncoder{enc: json.NewEncoder(w)}
+}
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+// Encode writes v followed by a newline.
+func (e ndjsonEncoder) Encode(v any) error {
+	return e.enc.Encode(v)
+}
+
+func (e ndjsonEncoder) Close() error {
+	return nil
+}
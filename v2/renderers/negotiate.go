@@ -0,0 +1,163 @@
+package renderers
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MediaRange is one entry of an Accept header.
+type MediaRange struct {
+	// Type and Subtype are lower-cased; either may be "*".
+	Type    string
+	Subtype string
+	// Q is the quality value between 0 and 1.
+	Q float64
+}
+
+// Matches reports whether the range accepts mediaType ("type/subtype").
+func (m MediaRange) Matches(mediaType string) bool {
+	t, sub, _ := strings.Cut(mediaType, "/")
+	return (m.Type == "*" || m.Type == t) && (m.Subtype == "*" || m.Subtype == sub)
+}
+
+// specificity orders ranges for equal q: exact types before type/* before */*.
+func (m MediaRange) specificity() int {
+	switch {
+	case m.Type == "*":
+		return 0
+	case m.Subtype == "*":
+		return 1
+	}
+	return 2
+}
+
+// ParseAccept parses an Accept header into media ranges ordered by
+// preference: descending q, then more specific ranges first. Malformed
+// entries are skipped and q-values are clamped to [0, 1].
+func ParseAccept(accept string) []MediaRange {
+	var ranges []MediaRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		t, sub, ok := strings.Cut(strings.ToLower(strings.TrimSpace(params[0])), "/")
+		if !ok || t == "" || sub == "" || (t == "*" && sub != "*") {
+			continue
+		}
+		r := MediaRange{Type: t, Subtype: sub, Q: 1}
+		for _, p := range params[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if strings.EqualFold(strings.TrimSpace(key), "q") {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					r.Q = min(max(q, 0), 1)
+				}
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].Q != ranges[j].Q {
+			return ranges[i].Q > ranges[j].Q
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+	return ranges
+}
+
+// MediaType returns the media type of a content type, without
+// parameters: "text/plain; charset=utf-8" becomes "text/plain".
+func MediaType(contentType string) string {
+	t, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(t))
+}
+
+// Negotiator selects one of its renderers per request from the Accept
+// header. It implements Renderer with its first renderer, which is also
+// chosen when the client accepts anything, so it can be used wherever a
+// single renderer is expected (for example app.Config.Renderer); the v2
+// response handler calls Negotiate for every response.
+type Negotiator struct {
+	renderers []Renderer
+}
+
+// NewNegotiator creates a Negotiator offering rs in order of server
+// preference. It panics if rs is empty.
+func NewNegotiator(rs ...Renderer) *Negotiator {
+	if len(rs) == 0 {
+		panic("renderers: NewNegotiator needs at least one renderer")
+	}
+	return &Negotiator{renderers: slices.Clone(rs)}
+}
+
+// ContentType returns the content type of the first renderer.
+func (n *Negotiator) ContentType() string {
+	return n.renderers[0].ContentType()
+}
+
+// Encode encodes data with the first renderer.
+func (n *Negotiator) Encode(data any) ([]byte, error) {
+	return n.renderers[0].Encode(data)
+}
+
+// MediaTypes returns the media types the negotiator offers, in order.
+func (n *Negotiator) MediaTypes() []string {
+	types := make([]string, len(n.renderers))
+	for i, r := range n.renderers {
+		types[i] = MediaType(r.ContentType())
+	}
+	return types
+}
+
+// Negotiate returns the renderer best matching accept. When offered is
+// not empty, only renderers whose media type is listed are considered,
+// in the order of offered. An empty accept matches the first candidate.
+// It returns false when no candidate is acceptable.
+//
+// Each candidate gets the q of the most specific range matching it, and
+// the highest q wins, ties going to the earlier candidate. So
+// "text/csv, */*;q=0.1" picks CSV when offered and falls back to the
+// first candidate otherwise, while "application/json;q=0.1, */*" prefers
+// any other candidate over JSON. A candidate whose q is 0 is excluded.
+func (n *Negotiator) Negotiate(accept string, offered ...string) (Renderer, bool) {
+	candidates := n.renderers
+	if len(offered) > 0 {
+		candidates = nil
+		for _, o := range offered {
+			for _, r := range n.renderers {
+				if MediaType(r.ContentType()) == MediaType(o) {
+					candidates = append(candidates, r)
+					break
+				}
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, false
+	}
+	if strings.TrimSpace(accept) == "" {
+		return candidates[0], true
+	}
+
+	ranges := ParseAccept(accept)
+	var best Renderer
+	bestQ := 0.0
+	for _, c := range candidates {
+		if q := quality(ranges, MediaType(c.ContentType())); q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+	return best, best != nil
+}
+
+// quality returns the q of the most specific range matching mediaType,
+// or 0 if no range matches it.
+func quality(ranges []MediaRange, mediaType string) float64 {
+	best := -1
+	q := 0.0
+	for _, r := range ranges {
+		if r.Matches(mediaType) && r.specificity() > best {
+			best, q = r.specificity(), r.Q
+		}
+	}
+	return q
+}
@@ -4,9 +4,14 @@
 // Framework adapters are responsible for writing the bytes to their specific
 // transport (net/http.ResponseWriter, fasthttp.Response, etc.).
 //
-// Built-in renderers: JSON, XML, Text, CSV.
+// Built-in renderers: JSON, NDJSON, XML, Text, CSV. A Negotiator combines
+// several of them and picks one per request from the Accept header.
+// JSON, NDJSON and CSV also implement StreamRenderer, encoding rows as
+// they are produced instead of from a materialised slice.
 package renderers
 
+import "io"
+
 // Renderer encodes data into a byte payload with a declared content type.
 // Implementations must be safe for concurrent use.
 type Renderer interface {
@@ -18,3 +23,31 @@ type Renderer interface {
 	// for routing the error through the error handler registry.
 	Encode(data any) ([]byte, error)
 }
+
+// Negotiating is implemented by renderers that choose the actual renderer
+// per request, such as Negotiator. accept is the request's Accept header
+// and offered optionally restricts the media types to choose from.
+type Negotiating interface {
+	Renderer
+	Negotiate(accept string, offered ...string) (Renderer, bool)
+}
+
+// StreamRenderer is implemented by renderers that can encode a sequence
+// of values one at a time, for responses too large to hold in memory.
+// The streamed output matches what Encode produces for a slice of the
+// same values.
+type StreamRenderer interface {
+	Renderer
+	NewStreamEncoder(w io.Writer) StreamEncoder
+}
+
+// StreamEncoder encodes the elements of a streamed response.
+type StreamEncoder interface {
+	// Encode writes one element.
+	Encode(v any) error
+
+	// Close writes the trailer, such as a JSON array's closing bracket.
+	// Callers skip it when producing the elements failed, so the output
+	// stays visibly incomplete.
+	Close() error
+}
@@ -235,3 +235,141 @@ func TestCSVRenderer_HeaderNotFound(t *testing.T) {
 		t.Fatal("expected error for nonexistent header")
 	}
 }
+
+func TestParseAccept(t *testing.T) {
+	ranges := ParseAccept("text/*;q=0.5, application/xml;q=0.9, */*;q=0.1, application/json, bogus, text/csv;q=2")
+	want := []MediaRange{
+		{Type: "application", Subtype: "json", Q: 1},
+		{Type: "text", Subtype: "csv", Q: 1},
+		{Type: "application", Subtype: "xml", Q: 0.9},
+		{Type: "text", Subtype: "*", Q: 0.5},
+		{Type: "*", Subtype: "*", Q: 0.1},
+	}
+	if len(ranges) != len(want) {
+		t.Fatalf("expected %d ranges, got %+v", len(want), ranges)
+	}
+	for i := range want {
+		if ranges[i] != want[i] {
+			t.Fatalf("range %d: expected %+v, got %+v", i, want[i], ranges[i])
+		}
+	}
+}
+
+func TestNegotiator_Negotiate(t *testing.T) {
+	n := NewNegotiator(JSONRenderer{}, XMLRenderer{}, CSVRenderer{}, TextRenderer{})
+	cases := []struct {
+		accept  string
+		offered []string
+		want    string
+	}{
+		{"", nil, "application/json"},
+		{"*/*", nil, "application/json"},
+		{"application/xml", nil, "application/xml"},
+		{"text/*", nil, "text/csv"},
+		{"text/plain, text/csv;q=0.5", nil, "text/plain"},
+		{"application/json;q=0.2, application/xml;q=0.8", nil, "application/xml"},
+		{"*/*, application/json;q=0", nil, "application/xml"},
+		{"application/json, */*;q=0.1", []string{"text/csv"}, "text/csv"},
+		{"", []string{"text/csv", "application/json"}, "text/csv"},
+		{"application/json;q=0.1, */*", nil, "application/xml"},
+		{"text/*;q=0.3, text/plain, application/*;q=0.5", nil, "text/plain"},
+		{"text/*;q=0.3, application/*;q=0.3", nil, "application/json"},
+	}
+	for _, c := range cases {
+		r, ok := n.Negotiate(c.accept, c.offered...)
+		if !ok {
+			t.Fatalf("Negotiate(%q, %v): no match", c.accept, c.offered)
+		}
+		if got := MediaType(r.ContentType()); got != c.want {
+			t.Errorf("Negotiate(%q, %v) = %s, want %s", c.accept, c.offered, got, c.want)
+		}
+	}
+
+	// A less specific range with a higher q does not override the q of
+	// the most specific range matching a type.
+	jsonXML := NewNegotiator(JSONRenderer{}, XMLRenderer{})
+	if r, ok := jsonXML.Negotiate("application/json;q=0.1, */*"); !ok || MediaType(r.ContentType()) != "application/xml" {
+		t.Fatalf("expected XML over low-q JSON, got %v", r)
+	}
+
+	if _, ok := n.Negotiate("image/png"); ok {
+		t.Fatal("expected no match for image/png")
+	}
+	if _, ok := n.Negotiate("application/json", "text/csv"); ok {
+		t.Fatal("expected no match when JSON is not offered")
+	}
+	if _, ok := n.Negotiate("", "application/pdf"); ok {
+		t.Fatal("expected no match for an offered type without renderer")
+	}
+	if n.ContentType() != "application/json" {
+		t.Fatalf("expected first renderer's content type, got %s", n.ContentType())
+	}
+}
+
+func TestStreamRenderers_MatchEncode(t *testing.T) {
+	type row struct {
+		ID   int    `json:"id" csv:"id"`
+		Name string `json:"name" csv:"name"`
+	}
+	rows := []row{{ID: 1, Name: "a"}, {ID: 2, Name: "b,c"}}
+	for _, r := range []StreamRenderer{
+		JSONRenderer{},
+		JSONRenderer{Indent: "  "},
+		NDJSONRenderer{},
+		CSVRenderer{},
+		CSVRenderer{Headers: []string{"name"}},
+	} {
+		for _, data := range [][]row{rows, {}} {
+			want, err := r.Encode(data)
+			if err != nil {
+				t.Fatalf("%T Encode failed: %v", r, err)
+			}
+			var buf bytes.Buffer
+			enc := r.NewStreamEncoder(&buf)
+			for _, v := range data {
+				if err := enc.Encode(v); err != nil {
+					t.Fatalf("%T stream Encode failed: %v", r, err)
+				}
+			}
+			if err := enc.Close(); err != nil {
+				t.Fatalf("%T Close failed: %v", r, err)
+			}
+			if _, isCSV := r.(CSVRenderer); isCSV && len(data) == 0 {
+				// Without a first row there is no type to take a header from.
+				want = nil
+			}
+			if buf.String() != string(want) {
+				t.Fatalf("%T: stream %q, Encode %q", r, buf.String(), want)
+			}
+		}
+	}
+}
+
+func TestNDJSONRenderer_Encode(t *testing.T) {
+	r := NDJSONRenderer{}
+	if ct := r.ContentType(); ct != "application/x-ndjson" {
+		t.Fatalf("expected application/x-ndjson, got %s", ct)
+	}
+	buf, err := r.Encode([]map[string]int{{"a": 1}, {"b": 2}})
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if string(buf) != "{\"a\":1}\n{\"b\":2}\n" {
+		t.Fatalf("unexpected output %q", buf)
+	}
+	buf, err = r.Encode("single")
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if string(buf) != "\"single\"\n" {
+		t.Fatalf("unexpected output %q", buf)
+	}
+}
+
+func TestCSVRenderer_StreamHeaderNotFound(t *testing.T) {
+	type user struct{ Name string }
+	enc := CSVRenderer{Headers: []string{"missing"}}.NewStreamEncoder(&bytes.Buffer{})
+	if err := enc.Encode(user{Name: "a"}); err == nil {
+		t.Fatal("expected error for missing header")
+	}
+}
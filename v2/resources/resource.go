@@ -20,6 +20,7 @@ package resources
 
 import (
 	"fmt"
+	"path"
 	"strconv"
 
 	"github.com/hmmftg/requestCore/libError"
@@ -28,6 +29,7 @@ import (
 	"github.com/hmmftg/requestCore/status"
 	"github.com/hmmftg/requestCore/webFramework"
 
+	"github.com/hmmftg/requestCore/v2/conditional"
 	"github.com/hmmftg/requestCore/v2/handlers"
 	v2response "github.com/hmmftg/requestCore/v2/response"
 	"github.com/hmmftg/requestCore/v2/routing"
@@ -83,6 +85,14 @@ type Config[ID any] struct {
 	// If nil, the string value is used directly for string IDs.
 	IDParser func(string) (ID, error)
 
+	// Version, if set, returns the current validators of the resource
+	// with the given ID. Show and Edit then send them as ETag and
+	// Last-Modified (see conditional.Set), and Update and Destroy enforce
+	// If-Match and If-Unmodified-Since with 412 before the handler runs
+	// (see conditional.CheckPreconditions). An error aborts the request
+	// and is routed through the response registry.
+	Version func(ctx *v2wf.RequestContext, id ID) (conditional.Validators, error)
+
 	// EnablePatchAlias, when true, registers PATCH as an alias for
 	// Update on the /{id} path.
 	EnablePatchAlias bool
@@ -102,11 +112,12 @@ func Register[ID any](router routing.RouteGroup, config Config[ID]) error {
 	}
 	basePath := config.Path
 	idPath := basePath + "/{" + idParam + "}"
+	tag := path.Base(basePath)
 
 	// Register static routes first (before /{id}) for correct precedence.
 	// New: GET /{resource}/new
 	if op := config.Resource.New(); op != nil {
-		op = op.WithPath(basePath + "/new")
+		op = withTag(op, tag).WithPath(basePath + "/new")
 		if err := handlers.RegisterEndpoint(router, config.Core, config.RespHandler, "GET", basePath+"/new", op); err != nil {
 			return err
 		}
@@ -118,8 +129,8 @@ func Register[ID any](router routing.RouteGroup, config Config[ID]) error {
 
 	// Edit: GET /{resource}/{id}/edit
 	if op := config.Resource.Edit(); op != nil {
-		op = op.WithPath(basePath + "/{" + idParam + "}/edit")
-		op = withIDParser[ID](op, config, idParam)
+		op = withTag(op, tag).WithPath(basePath + "/{" + idParam + "}/edit")
+		op = withIDParser[ID](op, config, idParam, setValidators)
 		if err := handlers.RegisterEndpoint(router, config.Core, config.RespHandler, "GET", basePath+"/{"+idParam+"}/edit", op); err != nil {
 			return err
 		}
@@ -131,7 +142,7 @@ func Register[ID any](router routing.RouteGroup, config Config[ID]) error {
 
 	// List: GET /{resource}
 	if op := config.Resource.List(); op != nil {
-		op = op.WithPath(basePath)
+		op = withTag(op, tag).WithPath(basePath)
 		if err := handlers.RegisterEndpoint(router, config.Core, config.RespHandler, "GET", basePath, op); err != nil {
 			return err
 		}
@@ -143,7 +154,7 @@ func Register[ID any](router routing.RouteGroup, config Config[ID]) error {
 
 	// Create: POST /{resource}
 	if op := config.Resource.Create(); op != nil {
-		op = op.WithPath(basePath)
+		op = withTag(op, tag).WithPath(basePath)
 		if err := handlers.RegisterEndpoint(router, config.Core, config.RespHandler, "POST", basePath, op); err != nil {
 			return err
 		}
@@ -155,8 +166,8 @@ func Register[ID any](router routing.RouteGroup, config Config[ID]) error {
 
 	// Show: GET /{resource}/{id}
 	if op := config.Resource.Show(); op != nil {
-		op = op.WithPath(idPath)
-		op = withIDParser[ID](op, config, idParam)
+		op = withTag(op, tag).WithPath(idPath)
+		op = withIDParser[ID](op, config, idParam, setValidators)
 		if err := handlers.RegisterEndpoint(router, config.Core, config.RespHandler, "GET", idPath, op); err != nil {
 			return err
 		}
@@ -168,8 +179,8 @@ func Register[ID any](router routing.RouteGroup, config Config[ID]) error {
 
 	// Update: PUT /{resource}/{id}
 	if op := config.Resource.Update(); op != nil {
-		op = op.WithPath(idPath)
-		op = withIDParser[ID](op, config, idParam)
+		op = withTag(op, tag).WithPath(idPath)
+		op = withIDParser[ID](op, config, idParam, conditional.CheckPreconditions)
 		if err := handlers.RegisterEndpoint(router, config.Core, config.RespHandler, "PUT", idPath, op); err != nil {
 			return err
 		}
@@ -187,8 +198,8 @@ func Register[ID any](router routing.RouteGroup, config Config[ID]) error {
 
 	// Destroy: DELETE /{resource}/{id}
 	if op := config.Resource.Destroy(); op != nil {
-		op = op.WithPath(idPath)
-		op = withIDParser[ID](op, config, idParam)
+		op = withTag(op, tag).WithPath(idPath)
+		op = withIDParser[ID](op, config, idParam, conditional.CheckPreconditions)
 		if err := handlers.RegisterEndpoint(router, config.Core, config.RespHandler, "DELETE", idPath, op); err != nil {
 			return err
 		}
@@ -201,10 +212,20 @@ func Register[ID any](router routing.RouteGroup, config Config[ID]) error {
 	return nil
 }
 
+// withTag groups an operation under the resource name in API
+// documentation unless the endpoint already declares tags.
+func withTag(e *handlers.Endpoint, tag string) *handlers.Endpoint {
+	if len(e.Tags) == 0 && tag != "/" && tag != "." {
+		e.WithTags(tag)
+	}
+	return e
+}
+
 // withIDParser wraps an endpoint to parse the ID parameter before the
 // handler runs. The parsed ID is stored in the request context's Legacy
-// parser locals under the IDParam key.
-func withIDParser[ID any](e *handlers.Endpoint, config Config[ID], idParam string) *handlers.Endpoint {
+// parser locals under the IDParam key. When Config.Version is set, the
+// resource's validators are passed to validate.
+func withIDParser[ID any](e *handlers.Endpoint, config Config[ID], idParam string, validate func(*v2wf.RequestContext, conditional.Validators) error) *handlers.Endpoint {
 	// We inject ID parsing via WithIDParser, which runs before the
 	// initializer and receives the v2 RequestContext directly.
 	e.WithIDParser(func(ctx *v2wf.RequestContext) error {
@@ -221,11 +242,24 @@ func withIDParser[ID any](e *handlers.Endpoint, config Config[ID], idParam strin
 		if ctx.Legacy.Parser != nil {
 			ctx.Legacy.Parser.SetLocal(idParam+"_parsed", id)
 		}
-		return nil
+		if config.Version == nil {
+			return nil
+		}
+		current, err := config.Version(ctx, id)
+		if err != nil {
+			return err
+		}
+		return validate(ctx, current)
 	})
 	return e
 }
 
+// setValidators sends the validators of a read operation's resource.
+func setValidators(ctx *v2wf.RequestContext, v conditional.Validators) error {
+	conditional.Set(ctx, v)
+	return nil
+}
+
 // parseID converts a string URL parameter to the ID type.
 // If IDParser is configured, it is used. Otherwise, the string is used
 // directly for string IDs. For non-string IDs without an IDParser, a
@@ -12,11 +12,13 @@ import (
 
 	"github.com/hmmftg/requestCore/libRequest"
 	"github.com/hmmftg/requestCore/response"
+	"github.com/hmmftg/requestCore/v2/conditional"
 	"github.com/hmmftg/requestCore/v2/handlers"
 	v2libGin "github.com/hmmftg/requestCore/v2/libGin"
 	"github.com/hmmftg/requestCore/v2/renderers"
 	v2response "github.com/hmmftg/requestCore/v2/response"
 	"github.com/hmmftg/requestCore/v2/routing"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
 )
 
 func init() {
@@ -410,3 +412,56 @@ func (r *panicResourceImpl) Create() *handlers.Endpoint  { return nil }
 func (r *panicResourceImpl) Edit() *handlers.Endpoint    { return nil }
 func (r *panicResourceImpl) Update() *handlers.Endpoint  { return nil }
 func (r *panicResourceImpl) Destroy() *handlers.Endpoint { return nil }
+
+func TestRegister_Version(t *testing.T) {
+	engine := gin.New()
+	router := v2libGin.NewRouter(engine)
+	respHandler := testRespHandler()
+	router.SetErrorHandler(respHandler)
+	r := newTestResource()
+
+	err := Register[string](router.Group("").With(conditional.Middleware(conditional.Config{RequireIfMatch: true})), Config[string]{
+		Path:        "/items",
+		Resource:    r,
+		RespHandler: respHandler,
+		Version: func(ctx *v2wf.RequestContext, id string) (conditional.Validators, error) {
+			return conditional.Validators{Version: "v-" + r.items[id].Name}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	serve := func(method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("GET", "/items/1", "", nil)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"v-alice"` {
+		t.Fatalf("expected ETag from Version, got %d %q", w.Code, w.Header().Get("ETag"))
+	}
+	if w = serve("GET", "/items/1", "", map[string]string{"If-None-Match": `"v-alice"`}); w.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", w.Code)
+	}
+
+	update := `{"id":"1","name":"alice2"}`
+	if w = serve("PUT", "/items/1", update, nil); w.Code != http.StatusPreconditionRequired {
+		t.Fatalf("expected 428 without If-Match, got %d: %s", w.Code, w.Body.String())
+	}
+	if w = serve("PUT", "/items/1", update, map[string]string{"If-Match": `"v-bob"`}); w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 for stale If-Match, got %d: %s", w.Code, w.Body.String())
+	}
+	if w = serve("PUT", "/items/1", update, map[string]string{"If-Match": `"v-alice"`}); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for current If-Match, got %d: %s", w.Code, w.Body.String())
+	}
+	if w = serve("DELETE", "/items/1", "", map[string]string{"If-Match": `"v-alice"`}); w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 after the update, got %d", w.Code)
+	}
+}
@@ -3,7 +3,10 @@ package response
 import (
 	"encoding/json"
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	legacyResponse "github.com/hmmftg/requestCore/response"
 
@@ -32,9 +35,10 @@ type ErrorResponse struct {
 func DefaultErrorHandlers() map[int]v2wf.ErrorHandler {
 	r := renderers.JSONRenderer{}
 	return map[int]v2wf.ErrorHandler{
-		http.StatusUnauthorized: makeDefaultHandler(http.StatusUnauthorized, "UNAUTHORIZED", "Authentication required", r),
-		http.StatusForbidden:    makeDefaultHandler(http.StatusForbidden, "FORBIDDEN", "Permission denied", r),
-		http.StatusNotFound:     makeDefaultHandler(http.StatusNotFound, "NOT_FOUND", "Resource not found", r),
+		http.StatusUnauthorized:  makeDefaultHandler(http.StatusUnauthorized, "UNAUTHORIZED", "Authentication required", r),
+		http.StatusForbidden:     makeDefaultHandler(http.StatusForbidden, "FORBIDDEN", "Permission denied", r),
+		http.StatusNotFound:      makeDefaultHandler(http.StatusNotFound, "NOT_FOUND", "Resource not found", r),
+		http.StatusNotAcceptable: makeDefaultHandler(http.StatusNotAcceptable, "NOT_ACCEPTABLE", "No acceptable representation", r),
 		http.StatusTooManyRequests: func(ctx v2wf.ErrorContext) error {
 			body := ErrorResponse{
 				Errors: []ErrorResponseEntry{
@@ -47,7 +51,7 @@ func DefaultErrorHandlers() map[int]v2wf.ErrorHandler {
 				return err
 			}
 			if ctx.Request != nil && !ctx.Request.Committed() && ctx.Request.Legacy.Parser != nil {
-				ctx.Request.Legacy.Parser.SetRespHeader("Retry-After", "60")
+				ctx.Request.Legacy.Parser.SetRespHeader("Retry-After", retryAfter(ctx.Error))
 			}
 			addLogFailure(ctx.Request, "default-error", ctx.Error)
 			if err := commitError(ctx.Request, ctx.Status, r.ContentType(), payload); err != nil {
@@ -59,6 +63,17 @@ func DefaultErrorHandlers() map[int]v2wf.ErrorHandler {
 	}
 }
 
+// retryAfter returns the Retry-After seconds for a 429 error: the wait
+// reported by errors implementing RetryAfter() time.Duration (such as
+// ratelimit.Error), or 60.
+func retryAfter(err error) string {
+	var ra interface{ RetryAfter() time.Duration }
+	if errors.As(err, &ra) && ra.RetryAfter() > 0 {
+		return strconv.FormatInt(int64(math.Ceil(ra.RetryAfter().Seconds())), 10)
+	}
+	return "60"
+}
+
 func makeDefaultHandler(status int, code, description string, r renderers.Renderer) v2wf.ErrorHandler {
 	return func(ctx v2wf.ErrorContext) error {
 		body := ErrorResponse{
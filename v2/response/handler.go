@@ -106,10 +106,17 @@ func (h *Handler) OK(req *v2wf.RequestContext, data any) error {
 }
 
 // OKWithRenderer sends a successful response with the given renderer at HTTP 200.
+// A renderers.Negotiating renderer picks the format from the Accept header
+// and the media types set by Offer; if none is acceptable the request
+// fails with 406 through the error registry.
 func (h *Handler) OKWithRenderer(req *v2wf.RequestContext, renderer renderers.Renderer, data any) error {
 	if renderer == nil {
 		renderer = h.defaultRend
 	}
+	renderer, err := h.negotiate(req, renderer)
+	if err != nil {
+		return h.Error(req, err)
+	}
 	payload, err := renderer.Encode(data)
 	if err != nil {
 		addLogFailure(req, "renderer-encode", err)
@@ -128,6 +135,10 @@ func (h *Handler) OKWithStatusAndRenderer(req *v2wf.RequestContext, status int,
 	if renderer == nil {
 		renderer = h.defaultRend
 	}
+	renderer, err := h.negotiate(req, renderer)
+	if err != nil {
+		return h.Error(req, err)
+	}
 	payload, err := renderer.Encode(data)
 	if err != nil {
 		addLogFailure(req, "renderer-encode", err)
@@ -136,6 +147,32 @@ func (h *Handler) OKWithStatusAndRenderer(req *v2wf.RequestContext, status int,
 	return h.commit(req, status, renderer.ContentType(), payload)
 }
 
+// OKWithAttachment sends the file described by attachment as a download
+// through the parser's FileAttachment, after running the before-commit
+// hooks. The framework serves the file, so its status may also be 206 or
+// 304 for range and conditional requests; the commit state records 200.
+func (h *Handler) OKWithAttachment(req *v2wf.RequestContext, attachment *legacyResponse.FileResponse) error {
+	if req == nil {
+		return errors.New("response: nil request context")
+	}
+	if req.Parser == nil {
+		return errors.New("response: nil parser")
+	}
+	if attachment == nil {
+		return h.Error(req, errors.New("response: nil attachment"))
+	}
+	if req.Committed() {
+		return nil
+	}
+	if hookErr := req.RunBeforeCommitHooks(); hookErr != nil {
+		addLogFailure(req, "response-commit-hook", hookErr)
+		return hookErr
+	}
+	req.Parser.FileAttachment(attachment.Path, attachment.FileName)
+	req.MarkCommitted(http.StatusOK)
+	return nil
+}
+
 // Error handles an error through the error handler registry.
 func (h *Handler) Error(req *v2wf.RequestContext, err error) error {
 	if err == nil {
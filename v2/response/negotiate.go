@@ -0,0 +1,54 @@
+package response
+
+import (
+	"strings"
+
+	legacyError "github.com/hmmftg/requestCore/libError"
+	"github.com/hmmftg/requestCore/status"
+
+	"github.com/hmmftg/requestCore/v2/renderers"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+// OfferedKey is the parser local holding the media types offered for a
+// request's response. See Offer.
+const OfferedKey = "_v2_offered_media_types"
+
+// Offer restricts the media types a negotiating renderer may choose for
+// req's response, for example CSV only for an export endpoint. It has no
+// effect when the response is encoded by a non-negotiating renderer.
+func Offer(req *v2wf.RequestContext, mediaTypes ...string) {
+	if req == nil || req.Parser == nil || len(mediaTypes) == 0 {
+		return
+	}
+	req.Parser.SetLocal(OfferedKey, mediaTypes)
+}
+
+// negotiate resolves a renderers.Negotiating renderer against the
+// request's Accept header and offered media types. Other renderers are
+// returned as is. When nothing is acceptable it returns a 406 libError
+// with code NOT_ACCEPTABLE.
+func (h *Handler) negotiate(req *v2wf.RequestContext, renderer renderers.Renderer) (renderers.Renderer, error) {
+	n, ok := renderer.(renderers.Negotiating)
+	if !ok || req == nil || req.Parser == nil {
+		return renderer, nil
+	}
+	offered, _ := req.Parser.GetLocal(OfferedKey).([]string)
+	accept := req.Parser.GetHeaderValue("Accept")
+	req.AddVary("Accept")
+	if chosen, ok := n.Negotiate(accept, offered...); ok {
+		return chosen, nil
+	}
+	if len(offered) == 0 {
+		if m, ok := renderer.(interface{ MediaTypes() []string }); ok {
+			offered = m.MediaTypes()
+		}
+	}
+	return nil, legacyError.NewWithDescription(
+		status.NotAcceptable,
+		"NOT_ACCEPTABLE",
+		"none of %s satisfies Accept: %s",
+		strings.Join(offered, ", "),
+		accept,
+	)
+}
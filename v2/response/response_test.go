@@ -181,8 +181,8 @@ func TestRegistry_HandlerErrorTriggersFallback(t *testing.T) {
 
 func TestDefaultErrorHandlers(t *testing.T) {
 	handlers := DefaultErrorHandlers()
-	if len(handlers) != 5 {
-		t.Fatalf("expected 5 default handlers, got %d", len(handlers))
+	if len(handlers) != 6 {
+		t.Fatalf("expected 6 default handlers, got %d", len(handlers))
 	}
 
 	for status, handler := range handlers {
@@ -302,3 +302,116 @@ func TestHandler_DefaultRenderer(t *testing.T) {
 		t.Fatal("expected default JSON renderer")
 	}
 }
+
+type negotiatedItem struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func TestHandler_Negotiation(t *testing.T) {
+	reg := NewRegistry(nil)
+	for code, h := range DefaultErrorHandlers() {
+		if err := reg.Register(code, h); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	}
+	n := renderers.NewNegotiator(renderers.JSONRenderer{}, renderers.XMLRenderer{}, renderers.CSVRenderer{})
+	h := NewHandler(reg, n, legacyResponse.WebHanlder{})
+
+	req := makeTestRequest()
+	parser := req.Parser.(*v2wf.FakeParserV2)
+	parser.ReqHeader["Accept"] = "application/xml;q=0.9, application/json;q=0.5"
+	if err := h.OK(req, negotiatedItem{Name: "a"}); err != nil {
+		t.Fatalf("OK: %v", err)
+	}
+	if parser.ResponseContentType != "application/xml" || parser.RespHeader["Vary"] != "Accept" {
+		t.Fatalf("expected XML with Vary: Accept, got %s / %q", parser.ResponseContentType, parser.RespHeader["Vary"])
+	}
+
+	// Offer restricts the formats of an endpoint.
+	req = makeTestRequest()
+	parser = req.Parser.(*v2wf.FakeParserV2)
+	parser.ReqHeader["Accept"] = "application/json"
+	Offer(req, "text/csv")
+	if err := h.OK(req, []negotiatedItem{{Name: "a"}}); err != nil {
+		t.Fatalf("OK: %v", err)
+	}
+	if parser.ResponseStatus != http.StatusNotAcceptable || parser.ResponseContentType != "application/json" {
+		t.Fatalf("expected JSON 406, got %d %s", parser.ResponseStatus, parser.ResponseContentType)
+	}
+
+	// Plain renderers ignore Accept.
+	req = makeTestRequest()
+	parser = req.Parser.(*v2wf.FakeParserV2)
+	parser.ReqHeader["Accept"] = "application/xml"
+	if err := h.OKWithRenderer(req, renderers.TextRenderer{}, "hi"); err != nil {
+		t.Fatalf("OKWithRenderer: %v", err)
+	}
+	if parser.ResponseStatus != http.StatusOK || parser.RespHeader["Vary"] != "" {
+		t.Fatalf("expected plain 200, got %d, Vary %q", parser.ResponseStatus, parser.RespHeader["Vary"])
+	}
+}
+
+// sliceRows is a Rows over a slice that fails with err after the rows.
+type sliceRows struct {
+	rows   []negotiatedItem
+	err    error
+	i      int
+	closed bool
+}
+
+func (r *sliceRows) Next() bool {
+	if r.i >= len(r.rows) {
+		return false
+	}
+	r.i++
+	return true
+}
+func (r *sliceRows) Row() negotiatedItem { return r.rows[r.i-1] }
+func (r *sliceRows) Err() error          { return r.err }
+func (r *sliceRows) Close() error        { r.closed = true; return nil }
+
+func TestStream(t *testing.T) {
+	reg := NewRegistry(nil)
+	for code, h := range DefaultErrorHandlers() {
+		if err := reg.Register(code, h); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	}
+	n := renderers.NewNegotiator(renderers.JSONRenderer{}, renderers.XMLRenderer{}, renderers.CSVRenderer{})
+	h := NewHandler(reg, n, legacyResponse.WebHanlder{})
+
+	// XML cannot stream, so it is not offered.
+	req := makeTestRequest()
+	parser := req.Parser.(*v2wf.FakeParserV2)
+	parser.ReqHeader["Accept"] = "application/xml, text/csv;q=0.5"
+	rows := &sliceRows{rows: []negotiatedItem{{Name: "a"}, {Name: "b"}}}
+	if err := Stream(h, req, http.StatusOK, nil, rows); err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if parser.ResponseContentType != "text/csv" || string(parser.ResponseBody) != "Name\na\nb\n" || !rows.closed {
+		t.Fatalf("expected closed CSV rows, got %s %q closed=%v", parser.ResponseContentType, parser.ResponseBody, rows.closed)
+	}
+
+	// A query failing before the first row is an ordinary error response.
+	req = makeTestRequest()
+	parser = req.Parser.(*v2wf.FakeParserV2)
+	rows = &sliceRows{err: legacyError.NewWithDescription(status.InternalServerError, "UNABLE_TO_QUERY_STATEMENT", "boom")}
+	if err := Stream(h, req, http.StatusOK, renderers.NDJSONRenderer{}, rows); err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if parser.ResponseStatus != http.StatusInternalServerError || !rows.closed {
+		t.Fatalf("expected closed 500, got %d closed=%v", parser.ResponseStatus, rows.closed)
+	}
+
+	// Later failures leave the stream without its trailer.
+	req = makeTestRequest()
+	parser = req.Parser.(*v2wf.FakeParserV2)
+	req.SetCommitState(&v2wf.CommitState{})
+	rows = &sliceRows{rows: []negotiatedItem{{Name: "a"}}, err: errors.New("connection reset")}
+	if err := Stream(h, req, http.StatusOK, renderers.JSONRenderer{}, rows); err == nil {
+		t.Fatal("expected the row error")
+	}
+	if parser.ResponseStatus != http.StatusOK || string(parser.ResponseBody) != `[{"name":"a"}` || !req.Committed() {
+		t.Fatalf("expected truncated committed 200, got %d %q", parser.ResponseStatus, parser.ResponseBody)
+	}
+}
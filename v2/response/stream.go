@@ -0,0 +1,159 @@
+package response
+
+import (
+	"errors"
+	"slices"
+	"sync/atomic"
+
+	legacyError "github.com/hmmftg/requestCore/libError"
+	"github.com/hmmftg/requestCore/status"
+
+	"github.com/hmmftg/requestCore/v2/renderers"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+// Rows is a forward-only sequence of values, such as the
+// *libQuery.RowIterator returned by libQuery.QueryRows.
+type Rows[T any] interface {
+	Next() bool
+	Row() T
+	Err() error
+	Close() error
+}
+
+// streamFlushRows is how many rows Stream encodes between flushes, so
+// clients receive a slow query's rows as they arrive without a flush
+// per row.
+const streamFlushRows = 64
+
+// Stream sends rows with the given status code, encoding each row as it is
+// read instead of materialising the result, and closes rows. renderer
+// must implement renderers.StreamRenderer; a renderers.Negotiating
+// renderer is restricted to the media types that stream, and a nil
+// renderer means h's default. When h is nil, errors that occur before
+// the commit are returned instead of sent, for handlers whose caller
+// responds to errors, and a nil renderer means JSON.
+//
+// Stream reads the first row before committing, so a query that fails
+// immediately is reported through the error registry like any other
+// error. Later failures cannot change the status: they end the stream
+// without its trailer, abort the connection where the parser can (see
+// webFramework.RequestParser.StreamResponse) and are returned after an
+// AddLog failure entry.
+//
+// Writes block while the client is not reading, which throttles reading
+// rows to the client's pace. When the client disconnects the request
+// context is cancelled, so rows from a query started with that context
+// (libQuery.QueryRows) stop as well.
+func Stream[T any](h *Handler, req *v2wf.RequestContext, code int, renderer renderers.Renderer, rows Rows[T]) error {
+	// Once StreamResponse has taken fn, fn closes rows.
+	var handedOff atomic.Bool
+	defer func() {
+		if !handedOff.Load() {
+			_ = rows.Close()
+		}
+	}()
+	if req == nil || req.Parser == nil {
+		return errors.New("response: nil request context or parser")
+	}
+	if req.Committed() {
+		return nil
+	}
+	fail := func(err error) error {
+		if h == nil {
+			return err
+		}
+		return h.Error(req, err)
+	}
+	if renderer == nil && h != nil {
+		renderer = h.defaultRend
+	}
+	if renderer == nil {
+		renderer = renderers.JSONRenderer{}
+	}
+	offerStreaming(req, renderer)
+	renderer, err := h.negotiate(req, renderer)
+	if err != nil {
+		return fail(err)
+	}
+	sr, ok := renderer.(renderers.StreamRenderer)
+	if !ok {
+		return fail(legacyError.NewWithDescription(
+			status.InternalServerError,
+			"RENDERER_NOT_STREAMING",
+			"renderer for %s cannot stream",
+			renderer.ContentType(),
+		))
+	}
+	first := rows.Next()
+	if !first {
+		if err := rows.Err(); err != nil {
+			return fail(err)
+		}
+	}
+	if hookErr := req.RunBeforeCommitHooks(); hookErr != nil {
+		addLogFailure(req, "response-commit-hook", hookErr)
+		return hookErr
+	}
+	// On Fiber fn runs after the handler returns, so it must not touch
+	// req; the adapter logs its error instead.
+	err = req.Parser.StreamResponse(code, sr.ContentType(), func(stream v2wf.ResponseStream) error {
+		handedOff.Store(true)
+		defer func() { _ = rows.Close() }()
+		enc := sr.NewStreamEncoder(stream)
+		for n := 0; first; n++ {
+			if err := enc.Encode(rows.Row()); err != nil {
+				return err
+			}
+			if n%streamFlushRows == streamFlushRows-1 {
+				if err := stream.Flush(); err != nil {
+					return err
+				}
+			}
+			first = rows.Next()
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return enc.Close()
+	})
+	if err == nil {
+		handedOff.Store(true)
+	}
+	if handedOff.Load() {
+		req.MarkCommitted(code)
+	}
+	if err != nil {
+		addLogFailure(req, "response-stream", err)
+		return err
+	}
+	return nil
+}
+
+// offerStreaming restricts a negotiating renderer to its media types
+// that stream, within those already offered for the request.
+func offerStreaming(req *v2wf.RequestContext, renderer renderers.Renderer) {
+	n, ok := renderer.(renderers.Negotiating)
+	if !ok {
+		return
+	}
+	offered, _ := req.Parser.GetLocal(OfferedKey).([]string)
+	if len(offered) == 0 {
+		m, ok := renderer.(interface{ MediaTypes() []string })
+		if !ok {
+			return
+		}
+		offered = m.MediaTypes()
+	}
+	streaming := slices.DeleteFunc(slices.Clone(offered), func(mediaType string) bool {
+		r, ok := n.Negotiate(mediaType, mediaType)
+		if !ok {
+			return true
+		}
+		_, ok = r.(renderers.StreamRenderer)
+		return !ok
+	})
+	if len(streaming) > 0 {
+		Offer(req, streaming...)
+	}
+}
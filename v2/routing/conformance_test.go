@@ -3,22 +3,50 @@
 package routing_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	chi "github.com/go-chi/chi/v5"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/hmmftg/requestCore/libRequest"
+	"github.com/hmmftg/requestCore/response"
+
+	"github.com/hmmftg/requestCore/v2/compression"
+	"github.com/hmmftg/requestCore/v2/jwtauth"
 	v2libChi "github.com/hmmftg/requestCore/v2/libChi"
 	v2libFiber "github.com/hmmftg/requestCore/v2/libFiber"
 	v2libGin "github.com/hmmftg/requestCore/v2/libGin"
+	v2libNetHttp "github.com/hmmftg/requestCore/v2/libNetHttp"
+	"github.com/hmmftg/requestCore/v2/renderers"
+	v2response "github.com/hmmftg/requestCore/v2/response"
 	"github.com/hmmftg/requestCore/v2/routing"
+	"github.com/hmmftg/requestCore/v2/uploads"
 	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+	"github.com/hmmftg/requestCore/v2/workers"
 )
 
 // AdapterFactory creates a router and provides a way to make HTTP requests
@@ -75,6 +103,19 @@ func adapterFactories() []AdapterFactory {
 				}
 			},
 		},
+		{
+			Name: "nethttp",
+			NewRouter: func() (routing.Router, func(req *http.Request) (*http.Response, error)) {
+				router := v2libNetHttp.NewRouter()
+				return router, func(req *http.Request) (*http.Response, error) {
+					w := httptest.NewRecorder()
+					// Resolve Native per request so handlers configured
+					// after construction (e.g. 405) are applied.
+					router.Native().(http.Handler).ServeHTTP(w, req)
+					return w.Result(), nil
+				}
+			},
+		},
 	}
 }
 
@@ -420,3 +461,701 @@ func TestConformance_405MethodNotAllowed(t *testing.T) {
 		})
 	}
 }
+
+func TestConformance_WorkerOriginPropagation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prev)
+
+	type jobResult struct {
+		origin    workers.Origin
+		requestID string
+		user      string
+		program   string
+		spanCtx   trace.SpanContext
+	}
+
+	for _, af := range adapterFactories() {
+		t.Run(af.Name, func(t *testing.T) {
+			router, serve := af.NewRouter()
+			worker := workers.NewInProcessWorker(workers.Config{WorkerCount: 1, QueueSize: 1})
+			jobName := "notify-" + af.Name
+
+			var requestSpan trace.SpanContext
+			tracing := func(next routing.Handler) routing.Handler {
+				return func(ctx *v2wf.RequestContext) error {
+					spanCtx, span := tp.Tracer("test").Start(ctx.Context, "request")
+					defer span.End()
+					requestSpan = span.SpanContext()
+					ctx.Context = spanCtx
+					return next(ctx)
+				}
+			}
+
+			results := make(chan jobResult, 1)
+			if err := router.With(tracing).Post("/orders", func(ctx *v2wf.RequestContext) error {
+				err := worker.Submit(ctx.Context, workers.Job{
+					Name: jobName,
+					Handler: func(job *workers.JobContext) error {
+						p := job.WebFramework.Parser
+						results <- jobResult{
+							origin:    job.Origin,
+							requestID: p.GetHeaderValue("Request-Id"),
+							user:      p.GetHeaderValue("User-Id"),
+							program:   p.GetHeaderValue("Program-Id"),
+							spanCtx:   p.GetTraceContext(),
+						}
+						return nil
+					},
+				})
+				if err != nil {
+					return err
+				}
+				return ctx.Parser.SendResponse(http.StatusAccepted, "text/plain", nil)
+			}); err != nil {
+				t.Fatalf("Post: %v", err)
+			}
+
+			req := httptest.NewRequest("POST", "/orders", nil)
+			req.Header.Set("Request-Id", "req-"+af.Name+"-0001")
+			req.Header.Set("User-Id", "alice")
+			req.Header.Set("Program-Id", "payments")
+			resp, err := serve(req)
+			if err != nil {
+				t.Fatalf("serve: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusAccepted {
+				t.Fatalf("expected 202, got %d", resp.StatusCode)
+			}
+
+			var got jobResult
+			select {
+			case got = <-results:
+			case <-time.After(2 * time.Second):
+				t.Fatal("job did not run")
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			if err := worker.Shutdown(ctx); err != nil {
+				t.Fatalf("Shutdown: %v", err)
+			}
+
+			if got.requestID != "req-"+af.Name+"-0001" || got.user != "alice" || got.program != "payments" {
+				t.Fatalf("headers not propagated: %+v", got)
+			}
+			if got.origin.SpanContext.SpanID() != requestSpan.SpanID() {
+				t.Fatalf("expected origin span %s, got %s", requestSpan.SpanID(), got.origin.SpanContext.SpanID())
+			}
+			if !got.spanCtx.IsValid() || got.spanCtx.TraceID() == requestSpan.TraceID() {
+				t.Fatalf("expected job to run under a new root trace, got %v", got.spanCtx)
+			}
+
+			var jobSpan sdktrace.ReadOnlySpan
+			for _, s := range recorder.Ended() {
+				if s.Name() == "worker."+jobName {
+					jobSpan = s
+				}
+			}
+			if jobSpan == nil {
+				t.Fatal("job root span not recorded")
+			}
+			if jobSpan.SpanContext().SpanID() != got.spanCtx.SpanID() {
+				t.Fatal("parser trace context does not match job root span")
+			}
+			links := jobSpan.Links()
+			if len(links) != 1 || links[0].SpanContext.SpanID() != requestSpan.SpanID() {
+				t.Fatalf("expected link to request span, got %+v", links)
+			}
+		})
+	}
+}
+
+func auditMiddleware(next routing.Handler) routing.Handler {
+	return func(ctx *v2wf.RequestContext) error { return next(ctx) }
+}
+
+// TestConformance_RouteRegistry verifies that a Recorder reports the same
+// route set for every adapter and that recording does not change serving.
+func TestConformance_RouteRegistry(t *testing.T) {
+	var want []routing.RouteInfo
+	for _, af := range adapterFactories() {
+		t.Run(af.Name, func(t *testing.T) {
+			native, serve := af.NewRouter()
+			router := routing.NewRecorder(native, "global")
+			ok := func(ctx *v2wf.RequestContext) error {
+				return ctx.Parser.SendResponse(200, "text/plain", []byte("ok"))
+			}
+
+			api := router.Group("/api").With(auditMiddleware)
+			_ = router.Get("/health", ok)
+			_ = api.Get("/users", ok)
+			_ = api.Post("/users", ok)
+			_ = api.Group("/users").Delete("/{id}", ok)
+			if err := router.Get("/bad/{id", ok); err == nil {
+				t.Fatal("expected invalid pattern error")
+			}
+
+			got := router.Routes()
+			if want == nil {
+				want = got
+				if len(want) != 4 || want[0].Pattern != "/api/users" || want[0].Method != "GET" {
+					t.Fatalf("unexpected routes: %+v", want)
+				}
+				if mws := want[2].Middlewares; len(mws) != 2 || mws[0] != "global" || mws[1] != "routing_test.auditMiddleware" {
+					t.Fatalf("unexpected middleware chain: %v", mws)
+				}
+			} else if !reflect.DeepEqual(got, want) {
+				t.Fatalf("route set differs:\n got %+v\nwant %+v", got, want)
+			}
+
+			resp, err := serve(httptest.NewRequest("DELETE", "/api/users/7", nil))
+			if err != nil {
+				t.Fatalf("serve: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != 200 {
+				t.Fatalf("expected 200, got %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+// TestConformance_Versioning verifies version dispatch, fallback to the
+// nearest lower version and deprecation headers for path and header
+// versioning.
+func TestConformance_Versioning(t *testing.T) {
+	deprecated := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	reply := func(body string) routing.Handler {
+		return func(ctx *v2wf.RequestContext) error {
+			return ctx.Parser.SendResponse(200, "text/plain", []byte(body+" "+routing.RequestVersion(ctx)))
+		}
+	}
+
+	for _, strategy := range []routing.VersionStrategy{routing.VersionByPath, routing.VersionByHeader} {
+		for _, af := range adapterFactories() {
+			t.Run(fmt.Sprintf("%s/strategy-%d", af.Name, strategy), func(t *testing.T) {
+				native, serve := af.NewRouter()
+				router := routing.NewRecorder(native)
+				api, err := routing.NewVersioned(router.Group("/api"), routing.VersionConfig{
+					Strategy: strategy,
+					Versions: []string{"v1", "v2"},
+				})
+				if err != nil {
+					t.Fatalf("NewVersioned: %v", err)
+				}
+				if err := api.Deprecate("v1", routing.VersionPolicy{Deprecated: deprecated, Sunset: sunset}); err != nil {
+					t.Fatalf("Deprecate: %v", err)
+				}
+				_ = api.Version("v1").Get("/users", reply("list-v1"))
+				_ = api.Version("v1").Get("/users/{id}", reply("show-v1"))
+				_ = api.Version("v2").Get("/users/{id}", reply("show-v2"))
+				if err := api.Version("v2").Get("/users/{id}", reply("again")); err == nil {
+					t.Fatal("expected duplicate registration error")
+				}
+				if err := api.Version("v9").Get("/users", reply("v9")); err == nil {
+					t.Fatal("expected unknown version error")
+				}
+
+				get := func(version, path string) (string, http.Header) {
+					t.Helper()
+					req := httptest.NewRequest("GET", "/api"+path, nil)
+					if strategy == routing.VersionByPath {
+						req = httptest.NewRequest("GET", "/api/"+version+path, nil)
+					} else {
+						req.Header.Set(routing.DefaultVersionHeader, version)
+					}
+					resp, err := serve(req)
+					if err != nil {
+						t.Fatalf("serve: %v", err)
+					}
+					defer resp.Body.Close()
+					body, _ := io.ReadAll(resp.Body)
+					if resp.StatusCode != 200 {
+						t.Fatalf("GET %s %s: expected 200, got %d", version, path, resp.StatusCode)
+					}
+					return string(body), resp.Header
+				}
+
+				if body, header := get("v1", "/users/7"); body != "show-v1 v1" ||
+					header.Get("Deprecation") != "@1767225600" || header.Get("Sunset") != "Wed, 01 Jul 2026 00:00:00 GMT" {
+					t.Fatalf("unexpected v1 response %q %v", body, header)
+				}
+				if body, header := get("v2", "/users/7"); body != "show-v2 v2" || header.Get("Deprecation") != "" {
+					t.Fatalf("unexpected v2 response %q %v", body, header)
+				}
+				if body, _ := get("v2", "/users"); body != "list-v1 v2" {
+					t.Fatalf("expected v2 to fall back to v1, got %q", body)
+				}
+
+				routes := router.Routes()
+				if len(routes) != 4 {
+					t.Fatalf("expected 4 versioned routes, got %+v", routes)
+				}
+				for _, r := range routes {
+					fallback := r.Version == "v2" && !strings.HasSuffix(r.Pattern, "{id}")
+					if (r.Fallback == "v1") != fallback || r.Deprecated != (r.Version == "v1") {
+						t.Fatalf("unexpected route: %+v", r)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestConformance_Compression(t *testing.T) {
+	compress, err := compression.Middleware(compression.Config{MinSize: 64})
+	if err != nil {
+		t.Fatalf("Middleware: %v", err)
+	}
+	echo := func(ctx *v2wf.RequestContext) error {
+		var in struct {
+			Name string `json:"name"`
+		}
+		if err := ctx.Parser.GetBody(&in); err != nil {
+			return err
+		}
+		return ctx.Parser.SendResponse(200, "application/json", []byte(`{"name":"`+strings.Repeat(in.Name, 100)+`"}`))
+	}
+
+	for _, af := range adapterFactories() {
+		t.Run(af.Name, func(t *testing.T) {
+			router, serve := af.NewRouter()
+			_ = router.Group("/api").With(compression.Decompress(compression.DecompressConfig{}), compress).Post("/echo", echo)
+
+			var body bytes.Buffer
+			zw := gzip.NewWriter(&body)
+			_, _ = zw.Write([]byte(`{"name":"ab"}`))
+			_ = zw.Close()
+			req := httptest.NewRequest("POST", "/api/echo", &body)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Content-Encoding", "gzip")
+			req.Header.Set("Accept-Encoding", "gzip")
+
+			resp, err := serve(req)
+			if err != nil {
+				t.Fatalf("serve: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != 200 || resp.Header.Get("Content-Encoding") != "gzip" ||
+				!strings.Contains(resp.Header.Get("Vary"), "Accept-Encoding") {
+				t.Fatalf("unexpected response %d %v", resp.StatusCode, resp.Header)
+			}
+			zr, err := gzip.NewReader(resp.Body)
+			if err != nil {
+				t.Fatalf("gzip: %v", err)
+			}
+			got, _ := io.ReadAll(zr)
+			if want := `{"name":"` + strings.Repeat("ab", 100) + `"}`; string(got) != want {
+				t.Fatalf("unexpected body %q", got)
+			}
+		})
+	}
+}
+
+// TestConformance_EventStream verifies that every adapter streams
+// server-sent events, resumes from Last-Event-ID and ignores errors
+// returned after the stream has committed the response.
+func TestConformance_EventStream(t *testing.T) {
+	events := func(ctx *v2wf.RequestContext) error {
+		return ctx.Parser.StreamEvents(v2wf.EventStreamOptions{Retry: 3 * time.Second}, func(s v2wf.EventStream) error {
+			var last int
+			_, _ = fmt.Sscan(s.LastEventID(), &last)
+			for id := last + 1; id <= last+2; id++ {
+				if err := s.Send(v2wf.Event{ID: fmt.Sprint(id), Event: "status", Data: fmt.Sprintf(`{"id":%d}`, id)}); err != nil {
+					return err
+				}
+			}
+			return errors.New("should be ignored")
+		})
+	}
+
+	for _, af := range adapterFactories() {
+		t.Run(af.Name, func(t *testing.T) {
+			router, serve := af.NewRouter()
+			_ = router.Get("/events", events)
+
+			req := httptest.NewRequest("GET", "/events", nil)
+			req.Header.Set("Last-Event-ID", "5")
+			resp, err := serve(req)
+			if err != nil {
+				t.Fatalf("serve: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "text/event-stream" ||
+				resp.Header.Get("Cache-Control") != "no-cache" {
+				t.Fatalf("unexpected response %d %v", resp.StatusCode, resp.Header)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			want := "retry: 3000\n\n" +
+				"id: 6\nevent: status\ndata: {\"id\":6}\n\n" +
+				"id: 7\nevent: status\ndata: {\"id\":7}\n\n"
+			if string(body) != want {
+				t.Fatalf("unexpected body %q", body)
+			}
+		})
+	}
+}
+
+// listeningAdapters starts each adapter on a real listener, for tests
+// that need connection hijacking. start returns the server's base URL.
+// setErrorHandler installs a JSON error handler with the legacy fallback
+// on router and returns it.
+func setErrorHandler(router routing.Router) *v2response.Handler {
+	registry := v2response.NewRegistry(nil)
+	registry.SetFallback(v2response.LegacyFallback(response.WebHanlder{
+		MessageDesc: make(map[string]string),
+		ErrorDesc:   make(map[string]string),
+	}))
+	h := v2response.NewHandler(registry, renderers.JSONRenderer{}, response.WebHanlder{})
+	router.(interface{ SetErrorHandler(*v2response.Handler) }).SetErrorHandler(h)
+	return h
+}
+
+func listeningAdapters() []struct {
+	Name      string
+	NewRouter func(t *testing.T) (router routing.Router, start func() string)
+} {
+	serve := func(t *testing.T, h http.Handler) string {
+		server := httptest.NewServer(h)
+		t.Cleanup(server.Close)
+		return server.URL
+	}
+	return []struct {
+		Name      string
+		NewRouter func(t *testing.T) (router routing.Router, start func() string)
+	}{
+		{"gin", func(t *testing.T) (routing.Router, func() string) {
+			engine := gin.New()
+			return v2libGin.NewRouter(engine), func() string { return serve(t, engine) }
+		}},
+		{"fiber", func(t *testing.T) (routing.Router, func() string) {
+			app := fiber.New(fiber.Config{DisableStartupMessage: true})
+			return v2libFiber.NewRouter(app), func() string {
+				ln, err := net.Listen("tcp", "127.0.0.1:0")
+				if err != nil {
+					t.Fatalf("listen: %v", err)
+				}
+				go func() { _ = app.Listener(ln) }()
+				t.Cleanup(func() { _ = app.Shutdown() })
+				return "http://" + ln.Addr().String()
+			}
+		}},
+		{"chi", func(t *testing.T) (routing.Router, func() string) {
+			router := v2libChi.NewRouter()
+			return router, func() string { return serve(t, router.Native().(*chi.Mux)) }
+		}},
+		{"nethttp", func(t *testing.T) (routing.Router, func() string) {
+			router := v2libNetHttp.NewRouter()
+			router.MethodNotAllowed(func(ctx *v2wf.RequestContext) error {
+				return ctx.Parser.SendResponse(405, "text/plain", nil)
+			})
+			return router, func() string { return serve(t, router.Native().(http.Handler)) }
+		}},
+	}
+}
+
+// TestConformance_WebSocket verifies that every adapter upgrades
+// WebSocket routes behind middleware, exchanges messages, broadcasts
+// through a Hub and closes with the handler's close code.
+func TestConformance_WebSocket(t *testing.T) {
+	for _, af := range listeningAdapters() {
+		t.Run(af.Name, func(t *testing.T) {
+			router, start := af.NewRouter(t)
+			setErrorHandler(router)
+			hub := routing.NewHub()
+			joined := make(chan struct{}, 1)
+			var middlewareRan bool
+			mw := func(next routing.Handler) routing.Handler {
+				return func(ctx *v2wf.RequestContext) error {
+					middlewareRan = true
+					return next(ctx)
+				}
+			}
+			_ = router.Group("/api").With(mw).Get("/rooms/{room}", routing.WebSocket(routing.WebSocketConfig{},
+				func(ctx *v2wf.RequestContext) (routing.WebSocketHandler, error) {
+					room := ctx.Parser.GetURLParam("room")
+					return func(conn *routing.WebSocketConn) error {
+						hub.Join(room, conn)
+						joined <- struct{}{}
+						for {
+							_, data, err := conn.Read()
+							if err != nil {
+								return err
+							}
+							if string(data) == "bye" {
+								return &routing.CloseError{Code: 4000, Reason: "bye"}
+							}
+							if err := conn.Write(routing.TextMessage, []byte(room+":"+string(data))); err != nil {
+								return err
+							}
+						}
+					}, nil
+				}))
+			base := start()
+
+			resp, err := http.Get(base + "/api/rooms/a")
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusUpgradeRequired {
+				t.Fatalf("expected 426 without upgrade, got %d", resp.StatusCode)
+			}
+
+			ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(base, "http")+"/api/rooms/a", nil)
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer ws.Close()
+			_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+			<-joined
+			if !middlewareRan || hub.Count("a") != 1 {
+				t.Fatalf("expected middleware and join, got %v %d", middlewareRan, hub.Count("a"))
+			}
+
+			_ = ws.WriteMessage(websocket.TextMessage, []byte("hi"))
+			if _, got, err := ws.ReadMessage(); err != nil || string(got) != "a:hi" {
+				t.Fatalf("expected echo, got %q %v", got, err)
+			}
+			if n := hub.Broadcast("a", routing.TextMessage, []byte("news")); n != 1 {
+				t.Fatalf("expected 1 broadcast, got %d", n)
+			}
+			if _, got, err := ws.ReadMessage(); err != nil || string(got) != "news" {
+				t.Fatalf("expected broadcast, got %q %v", got, err)
+			}
+
+			_ = ws.WriteMessage(websocket.TextMessage, []byte("bye"))
+			_, _, err = ws.ReadMessage()
+			if !websocket.IsCloseError(err, 4000) {
+				t.Fatalf("expected close 4000, got %v", err)
+			}
+			deadline := time.Now().Add(5 * time.Second)
+			for hub.Count("a") != 0 && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+			if hub.Count("a") != 0 {
+				t.Fatal("expected the closed connection to leave the hub")
+			}
+		})
+	}
+}
+
+// countRows is a v2response.Rows yielding n numbered rows, then err.
+type countRows struct {
+	n, i int
+	err  error
+}
+
+type countRow struct {
+	ID int `json:"id"`
+}
+
+func (r *countRows) Next() bool {
+	if r.i >= r.n {
+		return false
+	}
+	r.i++
+	return true
+}
+func (r *countRows) Row() countRow { return countRow{ID: r.i} }
+func (r *countRows) Err() error    { return r.err }
+func (r *countRows) Close() error  { return nil }
+
+// TestConformance_StreamResponse verifies that every adapter streams
+// rows incrementally and that a stream failing part way does not reach
+// the client as a complete response. fasthttp cannot abort a streamed
+// body, so on Fiber only the missing rows show the truncation.
+func TestConformance_StreamResponse(t *testing.T) {
+	const rows = 5000
+	for _, af := range listeningAdapters() {
+		t.Run(af.Name, func(t *testing.T) {
+			router, start := af.NewRouter(t)
+			_ = router.Get("/export", func(ctx *v2wf.RequestContext) error {
+				var err error
+				if ctx.Parser.GetHeaderValue("X-Fail") != "" {
+					err = errors.New("connection to database lost")
+				}
+				return v2response.Stream(nil, ctx, http.StatusOK, renderers.NDJSONRenderer{}, &countRows{n: rows, err: err})
+			})
+			base := start()
+
+			resp, err := http.Get(base + "/export")
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil || resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+				t.Fatalf("unexpected response %d %v: %v", resp.StatusCode, resp.Header, err)
+			}
+			lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+			if len(lines) != rows || lines[rows-1] != fmt.Sprintf(`{"id":%d}`, rows) {
+				t.Fatalf("expected %d rows, got %d ending %q", rows, len(lines), lines[len(lines)-1])
+			}
+			if resp.ContentLength != -1 {
+				t.Fatalf("expected a chunked response, got Content-Length %d", resp.ContentLength)
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, base+"/export", nil)
+			req.Header.Set("X-Fail", "1")
+			resp, err = http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			body, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if af.Name == "fiber" {
+				if len(body) == 0 || body[len(body)-1] != '\n' {
+					t.Fatalf("expected whole NDJSON lines, got %q", body[max(0, len(body)-20):])
+				}
+				return
+			}
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Fatalf("expected the truncated stream to fail, got %v after %d bytes", err, len(body))
+			}
+		})
+	}
+}
+
+// TestConformance_Upload verifies that every adapter binds multipart
+// uploads into a FileStore and serves them back as attachments.
+func TestConformance_Upload(t *testing.T) {
+	type document struct {
+		Title string        `form:"title"`
+		File  *uploads.File `form:"file" validate:"required"`
+	}
+	content := "%PDF-1.7\n" + strings.Repeat("x", 10000)
+
+	for _, af := range adapterFactories() {
+		t.Run(af.Name, func(t *testing.T) {
+			store, err := uploads.NewLocalStore(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			router, serve := af.NewRouter()
+			h := setErrorHandler(router)
+			config := uploads.Config{Store: store, AllowedTypes: []string{"application/pdf"}}
+			_ = router.Post("/documents", func(ctx *v2wf.RequestContext) error {
+				doc, err := uploads.Bind[document](ctx, config)
+				if err != nil {
+					return err
+				}
+				return h.OK(ctx, map[string]string{"title": doc.Title, "key": doc.File.Key, "name": doc.File.Filename, "sha256": doc.File.SHA256})
+			})
+			_ = router.Get("/documents/{key}", func(ctx *v2wf.RequestContext) error {
+				file, err := store.FileResponse(ctx.Parser.GetURLParam("key"), "contract.pdf")
+				if err != nil {
+					return err
+				}
+				return h.OKWithAttachment(ctx, file)
+			})
+
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			_ = mw.WriteField("title", "contract")
+			fw, _ := mw.CreateFormFile("file", "scan.pdf")
+			_, _ = io.WriteString(fw, content)
+			_ = mw.Close()
+			req := httptest.NewRequest("POST", "/documents", &body)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			resp, err := serve(req)
+			if err != nil {
+				t.Fatalf("serve: %v", err)
+			}
+			var created map[string]string
+			err = json.NewDecoder(resp.Body).Decode(&created)
+			resp.Body.Close()
+			sum := sha256.Sum256([]byte(content))
+			if err != nil || resp.StatusCode != 200 || created["title"] != "contract" || created["name"] != "scan.pdf" ||
+				created["sha256"] != hex.EncodeToString(sum[:]) {
+				t.Fatalf("unexpected upload response %d %v: %v", resp.StatusCode, created, err)
+			}
+
+			resp, err = serve(httptest.NewRequest("GET", "/documents/"+created["key"], nil))
+			if err != nil {
+				t.Fatalf("serve: %v", err)
+			}
+			downloaded, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != 200 || string(downloaded) != content ||
+				!strings.Contains(resp.Header.Get("Content-Disposition"), "contract.pdf") {
+				t.Fatalf("unexpected download %d %v (%d bytes)", resp.StatusCode, resp.Header, len(downloaded))
+			}
+
+			resp, err = serve(httptest.NewRequest("GET", "/documents/..%2Fsecret", nil))
+			if err != nil {
+				t.Fatalf("serve: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != 404 {
+				t.Fatalf("expected 404 for an invalid key, got %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+// TestConformance_JWTAuth verifies that every adapter replaces the
+// identity headers with the verified token's claims before the handler
+// binds libRequest.RequestHeader.
+func TestConformance_JWTAuth(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	sign := func(claims string) string {
+		signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256"}`)) + "." +
+			base64.RawURLEncoding.EncodeToString([]byte(claims))
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signed))
+		return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+	token := sign(fmt.Sprintf(`{"sub":"u-42","branch":"0101","bank":"17","exp":%d}`, exp))
+
+	for _, af := range adapterFactories() {
+		t.Run(af.Name, func(t *testing.T) {
+			router, serve := af.NewRouter()
+			setErrorHandler(router)
+			auth, err := jwtauth.Middleware(jwtauth.Config{
+				VerifierConfig: jwtauth.VerifierConfig{Keys: jwtauth.StaticKeys{{Algorithm: jwtauth.HS256, Key: secret}}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = router.With(auth).Get("/me", func(ctx *v2wf.RequestContext) error {
+				var header libRequest.RequestHeader
+				if err := ctx.Parser.GetHeader(&header); err != nil {
+					return err
+				}
+				body := header.User + "|" + header.Branch + "|" + header.Bank + "|" + header.Person + "|" + jwtauth.FromContext(ctx).Subject
+				return ctx.Parser.SendResponse(http.StatusOK, "text/plain", []byte(body))
+			})
+
+			req := httptest.NewRequest("GET", "/me", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.Header.Set("User-Id", "admin")
+			req.Header.Set("Person-Id", "p-1")
+			resp, err := serve(req)
+			if err != nil {
+				t.Fatalf("serve: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != 200 || string(body) != "u-42|0101|17||u-42" {
+				t.Fatalf("unexpected response %d %q", resp.StatusCode, body)
+			}
+
+			req = httptest.NewRequest("GET", "/me", nil)
+			req.Header.Set("User-Id", "admin")
+			resp, err = serve(req)
+			if err != nil {
+				t.Fatalf("serve: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != 401 || resp.Header.Get("WWW-Authenticate") != "Bearer" {
+				t.Fatalf("expected 401 with a Bearer challenge, got %d %v", resp.StatusCode, resp.Header)
+			}
+		})
+	}
+}
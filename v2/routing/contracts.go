@@ -64,6 +64,28 @@ type Router interface {
 	Native() any
 }
 
+// MetadataRouteGroup is implemented by route groups that record a
+// description of each handler, such as the typed handlers.Endpoint, for
+// introspection and API documentation. Adapter groups do not implement
+// it; the v2 App wraps its router in a group that does.
+type MetadataRouteGroup interface {
+	RouteGroup
+
+	// HandleWithMetadata registers handler like Handle and records
+	// metadata for the route.
+	HandleWithMetadata(method, pattern string, handler Handler, metadata any) error
+}
+
+// HandleWithMetadata registers handler on group, passing metadata along
+// when the group implements MetadataRouteGroup and falling back to
+// Handle otherwise.
+func HandleWithMetadata(group RouteGroup, method, pattern string, handler Handler, metadata any) error {
+	if mg, ok := group.(MetadataRouteGroup); ok {
+		return mg.HandleWithMetadata(method, pattern, handler, metadata)
+	}
+	return group.Handle(method, pattern, handler)
+}
+
 // Chain composes multiple middleware into a single middleware.
 // The first middleware in the slice is the outermost wrapper.
 func Chain(middleware ...Middleware) Middleware {
@@ -0,0 +1,254 @@
+package routing
+
+import (
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RouteInfo describes a registered route.
+type RouteInfo struct {
+	// Method is the upper-case HTTP method.
+	Method string `json:"method"`
+
+	// Pattern is the full canonical pattern, including group prefixes.
+	Pattern string `json:"pattern"`
+
+	// Version is the API version of routes registered through a
+	// Versioned group.
+	Version string `json:"version,omitempty"`
+
+	// Fallback names the lower API version whose handler serves the
+	// route because Version has none of its own.
+	Fallback string `json:"fallback,omitempty"`
+
+	// Deprecated reports whether the route is deprecated.
+	Deprecated bool `json:"deprecated,omitempty"`
+
+	// Middlewares names the middleware applied by With on the groups
+	// leading to the route, outermost first.
+	Middlewares []string `json:"middlewares,omitempty"`
+
+	// Metadata is the value passed to HandleWithMetadata, such as the
+	// *handlers.Endpoint of typed routes. It is nil for plain handlers.
+	Metadata any `json:"-"`
+}
+
+// Recorder wraps a Router and records every route registered through it,
+// at any group depth, with its full pattern and middleware chain. Adapter
+// groups do not expose their prefix, so the Recorder tracks it itself.
+// Groups returned by Group and With keep recording.
+type Recorder struct {
+	Router
+	root *recorderGroup
+
+	mu     sync.Mutex
+	routes []RouteInfo
+}
+
+// NewRecorder wraps router. middlewareNames lists middleware the router
+// applies to every route on its own (for example global application
+// middleware), so that it appears in each route's chain.
+func NewRecorder(router Router, middlewareNames ...string) *Recorder {
+	r := &Recorder{Router: router}
+	r.root = &recorderGroup{
+		recorder:    r,
+		group:       router,
+		middlewares: middlewareNames,
+	}
+	return r
+}
+
+// Routes returns the recorded routes sorted by pattern, method and API
+// version. Routes whose metadata implements VersionedMetadata are listed
+// once per version they currently serve.
+func (r *Recorder) Routes() []RouteInfo {
+	r.mu.Lock()
+	recorded := make([]RouteInfo, len(r.routes))
+	copy(recorded, r.routes)
+	r.mu.Unlock()
+
+	routes := make([]RouteInfo, 0, len(recorded))
+	for _, info := range recorded {
+		versioned, ok := info.Metadata.(VersionedMetadata)
+		if !ok {
+			routes = append(routes, info)
+			continue
+		}
+		for _, rv := range versioned.RouteVersions() {
+			expanded := info
+			expanded.Version = rv.Version
+			expanded.Fallback = rv.Fallback
+			expanded.Deprecated = rv.Deprecated
+			expanded.Metadata = rv.Metadata
+			if len(rv.Middlewares) > 0 {
+				expanded.Middlewares = append(slices.Clip(info.Middlewares), rv.Middlewares...)
+			}
+			routes = append(routes, expanded)
+		}
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Pattern != routes[j].Pattern {
+			return routes[i].Pattern < routes[j].Pattern
+		}
+		if routes[i].Method != routes[j].Method {
+			return routes[i].Method < routes[j].Method
+		}
+		return CompareVersions(routes[i].Version, routes[j].Version) < 0
+	})
+	return routes
+}
+
+func (r *Recorder) record(info RouteInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes = append(r.routes, info)
+}
+
+func (r *Recorder) Group(prefix string) RouteGroup {
+	return r.root.Group(prefix)
+}
+
+func (r *Recorder) With(middleware ...Middleware) RouteGroup {
+	return r.root.With(middleware...)
+}
+
+func (r *Recorder) Handle(method, pattern string, handler Handler) error {
+	return r.root.Handle(method, pattern, handler)
+}
+
+func (r *Recorder) HandleWithMetadata(method, pattern string, handler Handler, metadata any) error {
+	return r.root.HandleWithMetadata(method, pattern, handler, metadata)
+}
+
+func (r *Recorder) Get(pattern string, handler Handler) error {
+	return r.root.Get(pattern, handler)
+}
+
+func (r *Recorder) Post(pattern string, handler Handler) error {
+	return r.root.Post(pattern, handler)
+}
+
+func (r *Recorder) Put(pattern string, handler Handler) error {
+	return r.root.Put(pattern, handler)
+}
+
+func (r *Recorder) Patch(pattern string, handler Handler) error {
+	return r.root.Patch(pattern, handler)
+}
+
+func (r *Recorder) Delete(pattern string, handler Handler) error {
+	return r.root.Delete(pattern, handler)
+}
+
+func (r *Recorder) Head(pattern string, handler Handler) error {
+	return r.root.Head(pattern, handler)
+}
+
+type recorderGroup struct {
+	recorder    *Recorder
+	group       RouteGroup
+	prefix      string
+	middlewares []string
+}
+
+func (g *recorderGroup) Group(prefix string) RouteGroup {
+	return &recorderGroup{
+		recorder:    g.recorder,
+		group:       g.group.Group(prefix),
+		prefix:      JoinPath(g.prefix, prefix),
+		middlewares: g.middlewares,
+	}
+}
+
+func (g *recorderGroup) With(middleware ...Middleware) RouteGroup {
+	names := make([]string, 0, len(g.middlewares)+len(middleware))
+	names = append(names, g.middlewares...)
+	for _, mw := range middleware {
+		names = append(names, MiddlewareName(mw))
+	}
+	return &recorderGroup{
+		recorder:    g.recorder,
+		group:       g.group.With(middleware...),
+		prefix:      g.prefix,
+		middlewares: names,
+	}
+}
+
+func (g *recorderGroup) Handle(method, pattern string, handler Handler) error {
+	return g.HandleWithMetadata(method, pattern, handler, nil)
+}
+
+func (g *recorderGroup) HandleWithMetadata(method, pattern string, handler Handler, metadata any) error {
+	if err := HandleWithMetadata(g.group, method, pattern, handler, metadata); err != nil {
+		return err
+	}
+	full := JoinPath(g.prefix, pattern)
+	if full == "" {
+		full = "/"
+	}
+	g.recorder.record(RouteInfo{
+		Method:      strings.ToUpper(method),
+		Pattern:     full,
+		Middlewares: g.middlewares,
+		Metadata:    metadata,
+	})
+	return nil
+}
+
+func (g *recorderGroup) Get(pattern string, handler Handler) error {
+	return g.Handle("GET", pattern, handler)
+}
+
+func (g *recorderGroup) Post(pattern string, handler Handler) error {
+	return g.Handle("POST", pattern, handler)
+}
+
+func (g *recorderGroup) Put(pattern string, handler Handler) error {
+	return g.Handle("PUT", pattern, handler)
+}
+
+func (g *recorderGroup) Patch(pattern string, handler Handler) error {
+	return g.Handle("PATCH", pattern, handler)
+}
+
+func (g *recorderGroup) Delete(pattern string, handler Handler) error {
+	return g.Handle("DELETE", pattern, handler)
+}
+
+func (g *recorderGroup) Head(pattern string, handler Handler) error {
+	return g.Handle("HEAD", pattern, handler)
+}
+
+// closureSuffix matches the ".funcN" and ".N" suffixes the compiler gives
+// closures, including closures inlined into their caller.
+var closureSuffix = regexp.MustCompile(`(\.func\d+|\.\d+)+$`)
+
+// MiddlewareName returns a readable name for mw derived from the function
+// that built it, for example "app.SessionMiddleware" for the closure
+// returned by app.SessionMiddleware.
+func MiddlewareName(mw Middleware) string {
+	if mw == nil {
+		return "<nil>"
+	}
+	fn := runtime.FuncForPC(reflect.ValueOf(mw).Pointer())
+	if fn == nil {
+		return "<unknown>"
+	}
+	name := closureSuffix.ReplaceAllString(fn.Name(), "")
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// Ensure the recorder implements the metadata-aware interfaces.
+var (
+	_ Router             = (*Recorder)(nil)
+	_ MetadataRouteGroup = (*Recorder)(nil)
+	_ MetadataRouteGroup = (*recorderGroup)(nil)
+)
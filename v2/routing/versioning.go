@@ -0,0 +1,555 @@
+package routing
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hmmftg/requestCore/libError"
+	"github.com/hmmftg/requestCore/status"
+
+	"github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+// VersionStrategy selects where a request names the API version it wants.
+type VersionStrategy int
+
+const (
+	// VersionByPath serves each version under its own path prefix, for
+	// example /v2/users.
+	VersionByPath VersionStrategy = iota
+
+	// VersionByHeader reads the version from a request header,
+	// Accept-Version unless VersionConfig.Header says otherwise.
+	VersionByHeader
+
+	// VersionByMediaType reads the version from the Accept header, either
+	// as a vendor media type (application/vnd.acme.v2+json) or as a
+	// version parameter (application/json; version=2).
+	VersionByMediaType
+)
+
+// DefaultVersionHeader is the request header read by VersionByHeader.
+const DefaultVersionHeader = "Accept-Version"
+
+// VersionLocal is the request local holding the version requested by the
+// client; read it with RequestVersion.
+const VersionLocal = "_v2_api_version"
+
+// VersionConfig configures a Versioned group.
+type VersionConfig struct {
+	// Strategy selects how the requested version is read.
+	Strategy VersionStrategy
+
+	// Versions lists the supported versions, such as "v1" and "v2". A
+	// version is an optional "v" followed by dot-separated numbers; the
+	// list is ordered by those numbers, not by position.
+	Versions []string
+
+	// Default is the version served when a request names none. It only
+	// applies to VersionByHeader and VersionByMediaType and defaults to
+	// the lowest version, so clients that predate versioning keep their
+	// behavior.
+	Default string
+
+	// Header is the request header read by VersionByHeader. Defaults to
+	// DefaultVersionHeader.
+	Header string
+
+	// Vendor is the vendor name matched by VersionByMediaType in
+	// application/vnd.<Vendor>.<version>+json media types.
+	Vendor string
+}
+
+// VersionPolicy describes the retirement of a version. Responses for
+// deprecated versions carry the Deprecation (RFC 9745) and Sunset
+// (RFC 8594) headers.
+type VersionPolicy struct {
+	// Deprecated is when the version was deprecated.
+	Deprecated time.Time
+
+	// Sunset is when the version stops being served. Optional.
+	Sunset time.Time
+
+	// Link optionally points at the migration guide and is sent as a
+	// Link header with rel="deprecation".
+	Link string
+}
+
+// RouteVersion describes one API version served by a versioned route.
+type RouteVersion struct {
+	// Version is the API version.
+	Version string
+
+	// Fallback names the lower version whose handler serves Version when
+	// Version has no handler of its own. It is empty otherwise.
+	Fallback string
+
+	// Deprecated reports whether Version is deprecated.
+	Deprecated bool
+
+	// Middlewares names the middleware applied by With on the version
+	// group of the serving handler.
+	Middlewares []string
+
+	// Metadata is the metadata registered with the serving handler.
+	Metadata any
+}
+
+// VersionedMetadata is implemented by the metadata of routes registered
+// through a Versioned group. Recorder expands such routes into one
+// RouteInfo per served version.
+type VersionedMetadata interface {
+	RouteVersions() []RouteVersion
+}
+
+// Versioned registers handlers per API version on top of a RouteGroup.
+// A request is served by the handler of the version it names or, when
+// that version has no handler for the route, by the nearest lower
+// version's handler. Routes registered only in later versions are not
+// served to earlier ones.
+//
+//	api, _ := routing.NewVersioned(app.Register("/api"), routing.VersionConfig{
+//		Strategy: routing.VersionByHeader,
+//		Versions: []string{"v1", "v2"},
+//	})
+//	api.Version("v1").Get("/users", listUsersV1) // v1 and v2
+//	api.Version("v2").Get("/users/{id}", showUser) // v2 only
+type Versioned struct {
+	group    RouteGroup
+	config   VersionConfig
+	versions []apiVersion
+	def      int
+
+	mu     sync.RWMutex
+	routes map[string]*versionedRoute
+}
+
+type apiVersion struct {
+	name   string
+	number []int
+	policy VersionPolicy
+}
+
+// NewVersioned creates a Versioned group registering its routes on group.
+func NewVersioned(group RouteGroup, config VersionConfig) (*Versioned, error) {
+	if len(config.Versions) == 0 {
+		return nil, fmt.Errorf("routing: no API versions configured")
+	}
+	if config.Header == "" {
+		config.Header = DefaultVersionHeader
+	}
+	versions := make([]apiVersion, 0, len(config.Versions))
+	for _, name := range config.Versions {
+		number, ok := parseVersion(name)
+		if !ok {
+			return nil, fmt.Errorf("routing: invalid API version %q", name)
+		}
+		versions = append(versions, apiVersion{name: name, number: number})
+	}
+	slices.SortFunc(versions, func(a, b apiVersion) int {
+		return slices.Compare(a.number, b.number)
+	})
+	for i := 1; i < len(versions); i++ {
+		if slices.Equal(versions[i-1].number, versions[i].number) {
+			return nil, fmt.Errorf("routing: duplicate API version %q", versions[i].name)
+		}
+	}
+
+	v := &Versioned{
+		group:    group,
+		config:   config,
+		versions: versions,
+		routes:   make(map[string]*versionedRoute),
+	}
+	if config.Default != "" {
+		i, ok := v.index(config.Default)
+		if !ok {
+			return nil, fmt.Errorf("routing: default API version %q is not configured", config.Default)
+		}
+		v.def = i
+	}
+	return v, nil
+}
+
+// Version returns the group registering handlers for version. Handlers
+// registered on it also serve later versions that do not override them.
+// Registration fails for versions missing from VersionConfig.Versions.
+func (v *Versioned) Version(version string) RouteGroup {
+	i, ok := v.index(version)
+	if !ok {
+		i = -1
+	}
+	return &versionGroup{versioned: v, version: i, name: version}
+}
+
+// Deprecate marks version as deprecated. Responses served for it carry
+// the Deprecation header and, when set, the Sunset and Link headers.
+func (v *Versioned) Deprecate(version string, policy VersionPolicy) error {
+	i, ok := v.index(version)
+	if !ok {
+		return fmt.Errorf("routing: API version %q is not configured", version)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.versions[i].policy = policy
+	return nil
+}
+
+// RequestVersion returns the API version a versioned route served the
+// request for, or "" outside versioned routes.
+func RequestVersion(ctx *webFramework.RequestContext) string {
+	version, _ := ctx.Parser.GetLocal(VersionLocal).(string)
+	return version
+}
+
+// CompareVersions orders version names by their numbers: it returns -1,
+// 0 or +1. Invalid names sort before valid ones, and "" sorts first.
+func CompareVersions(a, b string) int {
+	na, oka := parseVersion(a)
+	nb, okb := parseVersion(b)
+	switch {
+	case oka && okb:
+		return slices.Compare(na, nb)
+	case oka:
+		return 1
+	case okb:
+		return -1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+// parseVersion parses an optional "v" followed by dot-separated numbers.
+func parseVersion(name string) ([]int, bool) {
+	s := strings.TrimPrefix(strings.TrimPrefix(name, "v"), "V")
+	if s == "" {
+		return nil, false
+	}
+	parts := strings.Split(s, ".")
+	number := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		number[i] = n
+	}
+	// Trailing zeros do not change the version: "2" and "2.0" are equal.
+	for len(number) > 1 && number[len(number)-1] == 0 {
+		number = number[:len(number)-1]
+	}
+	return number, true
+}
+
+// index returns the position of the configured version equal to name.
+func (v *Versioned) index(name string) (int, bool) {
+	number, ok := parseVersion(name)
+	if !ok {
+		return 0, false
+	}
+	for i, ver := range v.versions {
+		if slices.Equal(ver.number, number) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// requested returns the position of the version a header or media type
+// request asks for. Versions between configured ones resolve to the
+// nearest lower configured version.
+func (v *Versioned) requested(ctx *webFramework.RequestContext) (int, error) {
+	var name string
+	switch v.config.Strategy {
+	case VersionByHeader:
+		name = strings.TrimSpace(ctx.Parser.GetHeaderValue(v.config.Header))
+	case VersionByMediaType:
+		name = v.mediaTypeVersion(ctx.Parser.GetHeaderValue("Accept"))
+	}
+	if name == "" {
+		return v.def, nil
+	}
+	if number, ok := parseVersion(name); ok {
+		for i := len(v.versions) - 1; i >= 0; i-- {
+			if slices.Compare(v.versions[i].number, number) <= 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, libError.NewWithDescription(
+		status.BadRequest,
+		"UNSUPPORTED_API_VERSION",
+		"unsupported API version: %s",
+		name,
+	)
+}
+
+// mediaTypeVersion returns the version named by the first media range of
+// accept that names one.
+func (v *Versioned) mediaTypeVersion(accept string) string {
+	vendor := ""
+	if v.config.Vendor != "" {
+		vendor = "vnd." + strings.ToLower(v.config.Vendor) + "."
+	}
+	for _, item := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+		if version := params["version"]; version != "" {
+			return version
+		}
+		_, subtype, _ := strings.Cut(mediaType, "/")
+		if rest, ok := strings.CutPrefix(subtype, vendor); ok && vendor != "" {
+			version, _, _ := strings.Cut(rest, "+")
+			return version
+		}
+	}
+	return ""
+}
+
+// register adds handler for the version at position i. The underlying
+// group sees each method and pattern once: a single route dispatching on
+// the requested version, or one route per version prefix for
+// VersionByPath.
+func (v *Versioned) register(method, pattern string, i int, handler Handler, middlewares []string, metadata any) error {
+	if err := ValidatePattern(pattern); err != nil {
+		return err
+	}
+	method = strings.ToUpper(method)
+	key := method + " " + pattern
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if route, ok := v.routes[key]; ok {
+		if route.handlers[i] != nil {
+			return fmt.Errorf("routing: %s %s already registered for API version %q", method, pattern, v.versions[i].name)
+		}
+		route.set(i, handler, middlewares, metadata)
+		return nil
+	}
+
+	route := &versionedRoute{
+		versioned:   v,
+		handlers:    make([]Handler, len(v.versions)),
+		middlewares: make([][]string, len(v.versions)),
+		metadata:    make([]any, len(v.versions)),
+	}
+	route.set(i, handler, middlewares, metadata)
+	if v.config.Strategy == VersionByPath {
+		for j, ver := range v.versions {
+			slot := &versionSlot{route: route, version: j}
+			if err := HandleWithMetadata(v.group, method, JoinPath("/"+ver.name, pattern), slot.serve, slot); err != nil {
+				return err
+			}
+		}
+	} else if err := HandleWithMetadata(v.group, method, pattern, route.serve, route); err != nil {
+		return err
+	}
+	v.routes[key] = route
+	return nil
+}
+
+// versionedRoute holds the handlers of one method and pattern, indexed
+// by version position.
+type versionedRoute struct {
+	versioned   *Versioned
+	handlers    []Handler
+	middlewares [][]string
+	metadata    []any
+}
+
+func (r *versionedRoute) set(i int, handler Handler, middlewares []string, metadata any) {
+	r.handlers[i] = handler
+	r.middlewares[i] = middlewares
+	r.metadata[i] = metadata
+}
+
+// resolve returns the position of the handler serving version i.
+func (r *versionedRoute) resolve(i int) (int, bool) {
+	for j := i; j >= 0; j-- {
+		if r.handlers[j] != nil {
+			return j, true
+		}
+	}
+	return 0, false
+}
+
+func (r *versionedRoute) serve(ctx *webFramework.RequestContext) error {
+	i, err := r.versioned.requested(ctx)
+	if err != nil {
+		return err
+	}
+	if r.versioned.config.Strategy == VersionByMediaType {
+		ctx.AddVary("Accept")
+	} else {
+		ctx.AddVary(r.versioned.config.Header)
+	}
+	return r.serveVersion(ctx, i)
+}
+
+func (r *versionedRoute) serveVersion(ctx *webFramework.RequestContext, i int) error {
+	v := r.versioned
+	v.mu.RLock()
+	j, ok := r.resolve(i)
+	handler := r.handlers[j]
+	ver := v.versions[i]
+	v.mu.RUnlock()
+	if !ok {
+		return libError.NewWithDescription(
+			status.NotFound,
+			"NOT_FOUND",
+			"%s is not available in API version %s",
+			ctx.Parser.GetPath(), ver.name,
+		)
+	}
+
+	if !ver.policy.Deprecated.IsZero() {
+		ctx.Parser.SetRespHeader("Deprecation", "@"+strconv.FormatInt(ver.policy.Deprecated.Unix(), 10))
+		if !ver.policy.Sunset.IsZero() {
+			ctx.Parser.SetRespHeader("Sunset", ver.policy.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if ver.policy.Link != "" {
+			ctx.Parser.SetRespHeader("Link", "<"+ver.policy.Link+`>; rel="deprecation"`)
+		}
+	}
+	ctx.Parser.SetLocal(VersionLocal, ver.name)
+	return handler(ctx)
+}
+
+// RouteVersions lists every version the route serves.
+func (r *versionedRoute) RouteVersions() []RouteVersion {
+	r.versioned.mu.RLock()
+	defer r.versioned.mu.RUnlock()
+	var versions []RouteVersion
+	for i := range r.versioned.versions {
+		if rv, ok := r.routeVersion(i); ok {
+			versions = append(versions, rv)
+		}
+	}
+	return versions
+}
+
+func (r *versionedRoute) routeVersion(i int) (RouteVersion, bool) {
+	j, ok := r.resolve(i)
+	if !ok {
+		return RouteVersion{}, false
+	}
+	ver := r.versioned.versions[i]
+	rv := RouteVersion{
+		Version:     ver.name,
+		Deprecated:  !ver.policy.Deprecated.IsZero(),
+		Middlewares: r.middlewares[j],
+		Metadata:    r.metadata[j],
+	}
+	if j != i {
+		rv.Fallback = r.versioned.versions[j].name
+	}
+	return rv, true
+}
+
+// versionSlot is the route registered under one version prefix by
+// VersionByPath.
+type versionSlot struct {
+	route   *versionedRoute
+	version int
+}
+
+func (s *versionSlot) serve(ctx *webFramework.RequestContext) error {
+	return s.route.serveVersion(ctx, s.version)
+}
+
+// RouteVersions lists the slot's version when the route serves it.
+func (s *versionSlot) RouteVersions() []RouteVersion {
+	s.route.versioned.mu.RLock()
+	defer s.route.versioned.mu.RUnlock()
+	if rv, ok := s.route.routeVersion(s.version); ok {
+		return []RouteVersion{rv}
+	}
+	return nil
+}
+
+// versionGroup registers handlers for one version.
+type versionGroup struct {
+	versioned   *Versioned
+	version     int
+	name        string
+	prefix      string
+	middlewares []Middleware
+}
+
+func (g *versionGroup) Group(prefix string) RouteGroup {
+	return &versionGroup{
+		versioned:   g.versioned,
+		version:     g.version,
+		name:        g.name,
+		prefix:      JoinPath(g.prefix, prefix),
+		middlewares: g.middlewares,
+	}
+}
+
+func (g *versionGroup) With(middleware ...Middleware) RouteGroup {
+	return &versionGroup{
+		versioned:   g.versioned,
+		version:     g.version,
+		name:        g.name,
+		prefix:      g.prefix,
+		middlewares: append(slices.Clip(g.middlewares), middleware...),
+	}
+}
+
+func (g *versionGroup) Handle(method, pattern string, handler Handler) error {
+	return g.HandleWithMetadata(method, pattern, handler, nil)
+}
+
+func (g *versionGroup) HandleWithMetadata(method, pattern string, handler Handler, metadata any) error {
+	if g.version < 0 {
+		return fmt.Errorf("routing: API version %q is not configured", g.name)
+	}
+	var names []string
+	if len(g.middlewares) > 0 {
+		handler = Chain(g.middlewares...)(handler)
+		names = make([]string, len(g.middlewares))
+		for i, mw := range g.middlewares {
+			names[i] = MiddlewareName(mw)
+		}
+	}
+	return g.versioned.register(method, JoinPath(g.prefix, pattern), g.version, handler, names, metadata)
+}
+
+func (g *versionGroup) Get(pattern string, handler Handler) error {
+	return g.Handle("GET", pattern, handler)
+}
+
+func (g *versionGroup) Post(pattern string, handler Handler) error {
+	return g.Handle("POST", pattern, handler)
+}
+
+func (g *versionGroup) Put(pattern string, handler Handler) error {
+	return g.Handle("PUT", pattern, handler)
+}
+
+func (g *versionGroup) Patch(pattern string, handler Handler) error {
+	return g.Handle("PATCH", pattern, handler)
+}
+
+func (g *versionGroup) Delete(pattern string, handler Handler) error {
+	return g.Handle("DELETE", pattern, handler)
+}
+
+func (g *versionGroup) Head(pattern string, handler Handler) error {
+	return g.Handle("HEAD", pattern, handler)
+}
+
+// Ensure the versioned types implement the routing interfaces.
+var (
+	_ MetadataRouteGroup = (*versionGroup)(nil)
+	_ VersionedMetadata  = (*versionedRoute)(nil)
+	_ VersionedMetadata  = (*versionSlot)(nil)
+)
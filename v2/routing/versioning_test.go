@@ -0,0 +1,81 @@
+package routing
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/hmmftg/requestCore/libError"
+
+	"github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1", "v2", -1},
+		{"v2", "2.0", 0},
+		{"v1.10", "v1.9", 1},
+		{"", "v1", -1},
+		{"v1", "beta", 1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNewVersioned_InvalidConfig(t *testing.T) {
+	for _, config := range []VersionConfig{
+		{},
+		{Versions: []string{"v1", "latest"}},
+		{Versions: []string{"v1", "1.0"}},
+		{Versions: []string{"v1"}, Default: "v2"},
+	} {
+		if _, err := NewVersioned(nil, config); err == nil {
+			t.Errorf("expected error for %+v", config)
+		}
+	}
+}
+
+func TestVersioned_Requested(t *testing.T) {
+	v, err := NewVersioned(nil, VersionConfig{
+		Strategy: VersionByMediaType,
+		Versions: []string{"v3", "v1"},
+		Vendor:   "acme",
+	})
+	if err != nil {
+		t.Fatalf("NewVersioned: %v", err)
+	}
+	tests := []struct {
+		accept  string
+		want    string
+		wantErr int
+	}{
+		{"", "v1", 0},
+		{"application/json", "v1", 0},
+		{"text/html, application/vnd.acme.v3+json", "v3", 0},
+		{"application/json; version=2", "v1", 0},
+		{"application/vnd.acme.v7+json", "v3", 0},
+		{"application/json; version=0.5", "", http.StatusBadRequest},
+		{"application/json; version=next", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		parser := webFramework.NewFakeParserV2()
+		parser.ReqHeader["Accept"] = tt.accept
+		i, err := v.requested(&webFramework.RequestContext{Parser: parser})
+		if tt.wantErr != 0 {
+			var libErr libError.ErrorData
+			if !errors.As(err, &libErr) || libErr.Action().Status.Int() != tt.wantErr {
+				t.Errorf("Accept %q: expected status %d, got %v", tt.accept, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil || v.versions[i].name != tt.want {
+			t.Errorf("Accept %q: got %q (%v), want %q", tt.accept, v.versions[i].name, err, tt.want)
+		}
+	}
+}
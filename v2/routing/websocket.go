@@ -0,0 +1,620 @@
+package routing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hmmftg/requestCore/libError"
+	"github.com/hmmftg/requestCore/status"
+	legacy "github.com/hmmftg/requestCore/webFramework"
+
+	"github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+// MessageType is the type of a WebSocket data message.
+type MessageType int
+
+// Message types.
+const (
+	TextMessage   MessageType = websocket.TextMessage
+	BinaryMessage MessageType = websocket.BinaryMessage
+)
+
+// Close codes (RFC 6455, section 7.4.1).
+const (
+	CloseNormal          = websocket.CloseNormalClosure
+	CloseGoingAway       = websocket.CloseGoingAway
+	CloseProtocolError   = websocket.CloseProtocolError
+	CloseUnsupportedData = websocket.CloseUnsupportedData
+	CloseNoStatus        = websocket.CloseNoStatusReceived
+	CloseAbnormal        = websocket.CloseAbnormalClosure
+	ClosePolicyViolation = websocket.ClosePolicyViolation
+	CloseMessageTooBig   = websocket.CloseMessageTooBig
+	CloseInternalError   = websocket.CloseInternalServerErr
+	CloseTryAgainLater   = websocket.CloseTryAgainLater
+)
+
+// WebSocketLogTitle is the AddLog title of WebSocket lifecycle events.
+const WebSocketLogTitle = "websocket"
+
+// Default WebSocket settings.
+const (
+	DefaultWebSocketReadLimit    = 1 << 20
+	DefaultWebSocketPingInterval = 30 * time.Second
+	DefaultWebSocketWriteTimeout = 10 * time.Second
+	DefaultWebSocketSendQueue    = 16
+)
+
+// ErrWebSocketClosed is returned by writes to a connection that is
+// closing.
+var ErrWebSocketClosed = errors.New("routing: websocket closed")
+
+// CloseError describes how a WebSocket connection was closed. Read and
+// Write return it once the peer has closed the connection, and a
+// handler may return one to close with a specific code.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket: closed with code %d", e.Code)
+	}
+	return fmt.Sprintf("websocket: closed with code %d: %s", e.Code, e.Reason)
+}
+
+// IsCloseError reports whether err is a CloseError with one of codes, or
+// with any code if none are given.
+func IsCloseError(err error, codes ...int) bool {
+	var ce *CloseError
+	if !errors.As(err, &ce) {
+		return false
+	}
+	if len(codes) == 0 {
+		return true
+	}
+	for _, code := range codes {
+		if ce.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Upgrader performs the WebSocket handshake on an adapter's native
+// connection. Adapters attach one to every request with WithUpgrader.
+type Upgrader interface {
+	// Request returns the handshake request in net/http form, for
+	// validation and origin checks.
+	Request() (*http.Request, error)
+
+	// Upgrade completes the handshake with upgrader and calls serve with
+	// the connection, which is closed once serve returns. On gin and
+	// net/http serve runs before Upgrade returns; on Fiber it runs after
+	// the handler returns, on the hijacked connection.
+	Upgrade(upgrader *websocket.Upgrader, serve func(*websocket.Conn)) error
+}
+
+type upgraderKey struct{}
+
+// WithUpgrader returns a copy of ctx carrying u. Adapters call it while
+// building each request's context.
+func WithUpgrader(ctx context.Context, u Upgrader) context.Context {
+	return context.WithValue(ctx, upgraderKey{}, u)
+}
+
+// UpgraderFromContext returns the Upgrader attached with WithUpgrader.
+func UpgraderFromContext(ctx context.Context) (Upgrader, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	u, ok := ctx.Value(upgraderKey{}).(Upgrader)
+	return u, ok && u != nil
+}
+
+// WebSocketConfig configures WebSocket.
+type WebSocketConfig struct {
+	// Subprotocols lists the supported subprotocols in order of
+	// preference.
+	Subprotocols []string
+
+	// CheckOrigin reports whether the Origin of the handshake request is
+	// allowed. Requests refused by it fail with 403.
+	// Default: requests without Origin, or whose Origin host is the
+	// request host.
+	CheckOrigin func(r *http.Request) bool
+
+	// ReadLimit is the largest message accepted; larger messages close
+	// the connection with CloseMessageTooBig.
+	// Default: DefaultWebSocketReadLimit.
+	ReadLimit int64
+
+	// PingInterval is how often the server pings the client. A client
+	// that has not answered within two intervals is disconnected.
+	// Default: DefaultWebSocketPingInterval.
+	PingInterval time.Duration
+
+	// WriteTimeout bounds every frame write.
+	// Default: DefaultWebSocketWriteTimeout.
+	WriteTimeout time.Duration
+
+	// SendQueue is the number of outgoing messages buffered per
+	// connection. Hub broadcasts to a connection whose queue is full
+	// close it with CloseTryAgainLater.
+	// Default: DefaultWebSocketSendQueue.
+	SendQueue int
+
+	// EnableCompression negotiates per-message compression.
+	EnableCompression bool
+}
+
+// WebSocketHandler serves one upgraded connection. When it returns the
+// connection is closed: normally for nil, with the code of a returned
+// *CloseError, and with CloseInternalError for other errors.
+type WebSocketHandler func(conn *WebSocketConn) error
+
+// WebSocket returns a Handler that upgrades GET requests to WebSocket
+// connections. accept runs first, behind the route's middleware, with
+// the full request: it may reject the request by returning an error,
+// which is dispatched like any handler error, or return the handler
+// that serves the connection. The handler must only use conn and what
+// accept captured, because on Fiber it runs after the request has been
+// released.
+//
+// The upgraded response is marked committed, so later errors are not
+// written. The upgrade and close are recorded with AddLog under
+// WebSocketLogTitle (the close only where the handler runs within the
+// request) and traced as a "websocket <path>" span.
+func WebSocket(config WebSocketConfig, accept func(ctx *webFramework.RequestContext) (WebSocketHandler, error)) Handler {
+	if config.CheckOrigin == nil {
+		config.CheckOrigin = sameOrigin
+	}
+	if config.ReadLimit <= 0 {
+		config.ReadLimit = DefaultWebSocketReadLimit
+	}
+	if config.PingInterval <= 0 {
+		config.PingInterval = DefaultWebSocketPingInterval
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = DefaultWebSocketWriteTimeout
+	}
+	if config.SendQueue <= 0 {
+		config.SendQueue = DefaultWebSocketSendQueue
+	}
+
+	return func(ctx *webFramework.RequestContext) error {
+		upgrader, ok := UpgraderFromContext(ctx.Context)
+		if !ok {
+			return libError.NewWithDescription(
+				status.InternalServerError,
+				"WEBSOCKET_UNSUPPORTED",
+				"the router does not support WebSocket upgrades",
+			)
+		}
+		req, err := upgrader.Request()
+		if err != nil {
+			return err
+		}
+		if err := checkHandshake(ctx, req); err != nil {
+			return err
+		}
+		if !config.CheckOrigin(req) {
+			return libError.NewWithDescription(
+				status.Forbidden,
+				"WEBSOCKET_ORIGIN",
+				"origin %s is not allowed",
+				req.Header.Get("Origin"),
+			)
+		}
+		handler, err := accept(ctx)
+		if err != nil {
+			return err
+		}
+
+		id := newConnID()
+		path := req.URL.Path
+		legacy.AddLog(ctx.Legacy, WebSocketLogTitle, slog.Group("upgrade",
+			slog.String("id", id),
+			slog.String("path", path),
+		))
+		spanCtx, span := otel.Tracer(tracerName).Start(context.WithoutCancel(ctx.Context), "websocket "+path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("websocket.id", id),
+				attribute.String("url.path", path),
+			),
+		)
+
+		var handshake error
+		up := &websocket.Upgrader{
+			HandshakeTimeout:  config.WriteTimeout,
+			Subprotocols:      config.Subprotocols,
+			EnableCompression: config.EnableCompression,
+			CheckOrigin:       func(*http.Request) bool { return true },
+			Error: func(_ http.ResponseWriter, _ *http.Request, code int, reason error) {
+				handshake = libError.NewWithDescription(status.StatusCode(code), "WEBSOCKET_HANDSHAKE", "%v", reason)
+			},
+		}
+		var returned atomic.Bool
+		var handlerErr error
+		err = upgrader.Upgrade(up, func(ws *websocket.Conn) {
+			inRequest := !returned.Load()
+			if inRequest {
+				ctx.MarkCommitted(http.StatusSwitchingProtocols)
+			}
+			conn := newWebSocketConn(spanCtx, ws, config, id)
+			span.SetAttributes(attribute.String("websocket.subprotocol", ws.Subprotocol()))
+			started := time.Now()
+			err := conn.serve(handler)
+			if inRequest {
+				handlerErr = err
+			}
+
+			closeErr := conn.closeError()
+			span.SetAttributes(
+				attribute.Int("websocket.close_code", closeErr.Code),
+				attribute.Int64("websocket.messages_received", conn.received.Load()),
+				attribute.Int64("websocket.messages_sent", conn.sent.Load()),
+			)
+			if err != nil && !IsCloseError(err) {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+			if inRequest {
+				legacy.AddLog(ctx.Legacy, WebSocketLogTitle, slog.Group("close",
+					slog.String("id", id),
+					slog.Int("code", closeErr.Code),
+					slog.Int64("received", conn.received.Load()),
+					slog.Int64("sent", conn.sent.Load()),
+					slog.Duration("duration", time.Since(started)),
+				))
+			}
+		})
+		returned.Store(true)
+		if err != nil {
+			span.RecordError(err)
+			span.End()
+			if handshake != nil {
+				return handshake
+			}
+			return err
+		}
+		ctx.MarkCommitted(http.StatusSwitchingProtocols)
+		if handlerErr != nil && !IsCloseError(handlerErr) {
+			return handlerErr
+		}
+		return nil
+	}
+}
+
+// tracerName is the instrumentation scope of WebSocket spans.
+const tracerName = "github.com/hmmftg/requestCore/v2/routing"
+
+// checkHandshake validates the handshake headers before the upgrade, so
+// that failures are dispatched as ordinary errors on every adapter.
+func checkHandshake(ctx *webFramework.RequestContext, req *http.Request) error {
+	if req.Method != http.MethodGet {
+		return libError.NewWithDescription(status.BadRequest, "WEBSOCKET_METHOD", "WebSocket handshakes must use GET, not %s", req.Method)
+	}
+	if !headerHasToken(req.Header, "Connection", "upgrade") || !headerHasToken(req.Header, "Upgrade", "websocket") {
+		ctx.Parser.SetRespHeader("Upgrade", "websocket")
+		return libError.NewWithDescription(status.UpgradeRequired, "WEBSOCKET_UPGRADE_REQUIRED", "%s requires a WebSocket upgrade", req.URL.Path)
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		ctx.Parser.SetRespHeader("Sec-WebSocket-Version", "13")
+		return libError.NewWithDescription(status.UpgradeRequired, "WEBSOCKET_VERSION", "unsupported WebSocket version %q", req.Header.Get("Sec-WebSocket-Version"))
+	}
+	if req.Header.Get("Sec-WebSocket-Key") == "" {
+		return libError.NewWithDescription(status.BadRequest, "WEBSOCKET_KEY", "missing Sec-WebSocket-Key header")
+	}
+	return nil
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameOrigin allows requests without Origin (non-browser clients) and
+// requests whose Origin host is the request host.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func newConnID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// message is a queued or received data message.
+type message struct {
+	typ  MessageType
+	data []byte
+}
+
+// WebSocketConn is an upgraded WebSocket connection. A reader goroutine
+// answers pings, enforces the pong deadline and queues incoming
+// messages for Read; a writer goroutine sends queued messages and pings.
+// Read may be called from one goroutine at a time; the other methods are
+// safe for concurrent use.
+type WebSocketConn struct {
+	ws     *websocket.Conn
+	config WebSocketConfig
+	id     string
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	send    chan message
+	inbound chan message
+	closing chan *CloseError
+
+	closeOnce  sync.Once
+	closed     atomic.Bool
+	writerDone chan struct{}
+	readerDone chan struct{}
+
+	received atomic.Int64
+	sent     atomic.Int64
+}
+
+func newWebSocketConn(ctx context.Context, ws *websocket.Conn, config WebSocketConfig, id string) *WebSocketConn {
+	ctx, cancel := context.WithCancelCause(ctx)
+	c := &WebSocketConn{
+		ws:         ws,
+		config:     config,
+		id:         id,
+		ctx:        ctx,
+		cancel:     cancel,
+		send:       make(chan message, config.SendQueue),
+		inbound:    make(chan message),
+		closing:    make(chan *CloseError, 1),
+		writerDone: make(chan struct{}),
+		readerDone: make(chan struct{}),
+	}
+	ws.SetReadLimit(config.ReadLimit)
+	go c.readLoop()
+	go c.writeLoop()
+	return c
+}
+
+// ID returns the connection's unique ID.
+func (c *WebSocketConn) ID() string {
+	return c.id
+}
+
+// Subprotocol returns the negotiated subprotocol, if any.
+func (c *WebSocketConn) Subprotocol() string {
+	return c.ws.Subprotocol()
+}
+
+// RemoteAddr returns the client's network address.
+func (c *WebSocketConn) RemoteAddr() string {
+	return c.ws.RemoteAddr().String()
+}
+
+// Context is cancelled when the connection closes. Its cause is a
+// *CloseError when the connection was closed by either side, or the
+// network error that broke it. It carries the connection's span.
+func (c *WebSocketConn) Context() context.Context {
+	return c.ctx
+}
+
+// Read returns the next data message, or the reason the connection
+// closed.
+func (c *WebSocketConn) Read() (MessageType, []byte, error) {
+	select {
+	case m := <-c.inbound:
+		return m.typ, m.data, nil
+	default:
+	}
+	select {
+	case m := <-c.inbound:
+		return m.typ, m.data, nil
+	case <-c.ctx.Done():
+		return 0, nil, context.Cause(c.ctx)
+	}
+}
+
+// ReadJSON reads the next data message into v.
+func (c *WebSocketConn) ReadJSON(v any) error {
+	_, data, err := c.Read()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Write queues a data message, waiting while the send queue is full.
+// Delivery failures close the connection.
+func (c *WebSocketConn) Write(typ MessageType, data []byte) error {
+	if c.closed.Load() {
+		return ErrWebSocketClosed
+	}
+	select {
+	case c.send <- message{typ: typ, data: data}:
+		return nil
+	case <-c.ctx.Done():
+		return context.Cause(c.ctx)
+	}
+}
+
+// WriteJSON queues v as a text message.
+func (c *WebSocketConn) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.Write(TextMessage, data)
+}
+
+// TrySend queues a data message without waiting. If the send queue is
+// full the client is too slow to keep up: the connection is closed with
+// CloseTryAgainLater and TrySend returns false.
+func (c *WebSocketConn) TrySend(typ MessageType, data []byte) bool {
+	if c.closed.Load() || c.ctx.Err() != nil {
+		return false
+	}
+	select {
+	case c.send <- message{typ: typ, data: data}:
+		return true
+	default:
+		c.requestClose(&CloseError{Code: CloseTryAgainLater, Reason: "send queue full"})
+		return false
+	}
+}
+
+// Close sends the queued messages and a close frame with code and
+// reason, then closes the connection. Later calls have no effect.
+func (c *WebSocketConn) Close(code int, reason string) error {
+	c.requestClose(&CloseError{Code: code, Reason: reason})
+	<-c.writerDone
+	return nil
+}
+
+func (c *WebSocketConn) requestClose(ce *CloseError) {
+	c.closeOnce.Do(func() {
+		c.closed.Store(true)
+		c.closing <- ce
+	})
+}
+
+// closeError returns how the connection was closed.
+func (c *WebSocketConn) closeError() *CloseError {
+	var ce *CloseError
+	if errors.As(context.Cause(c.ctx), &ce) {
+		return ce
+	}
+	return &CloseError{Code: CloseAbnormal}
+}
+
+// serve runs handler and closes the connection according to its result.
+func (c *WebSocketConn) serve(handler WebSocketHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("routing: websocket handler panic: %v", r)
+		}
+		var ce *CloseError
+		switch {
+		case err == nil:
+			ce = &CloseError{Code: CloseNormal}
+		case errors.As(err, &ce):
+		default:
+			ce = &CloseError{Code: CloseInternalError, Reason: "internal error"}
+		}
+		_ = c.Close(ce.Code, ce.Reason)
+		<-c.readerDone
+	}()
+	return handler(c)
+}
+
+func (c *WebSocketConn) readLoop() {
+	defer close(c.readerDone)
+	pongWait := 2 * c.config.PingInterval
+	_ = c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		typ, data, err := c.ws.ReadMessage()
+		if err != nil {
+			var ce *websocket.CloseError
+			if errors.As(err, &ce) {
+				c.cancel(&CloseError{Code: ce.Code, Reason: ce.Text})
+			} else if errors.Is(err, websocket.ErrReadLimit) {
+				c.requestClose(&CloseError{Code: CloseMessageTooBig, Reason: "message too big"})
+			} else {
+				c.cancel(err)
+			}
+			return
+		}
+		_ = c.ws.SetReadDeadline(time.Now().Add(pongWait))
+		c.received.Add(1)
+		select {
+		case c.inbound <- message{typ: MessageType(typ), data: data}:
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *WebSocketConn) writeLoop() {
+	defer close(c.writerDone)
+	defer c.ws.Close()
+	ticker := time.NewTicker(c.config.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case m := <-c.send:
+			if err := c.write(m); err != nil {
+				c.cancel(err)
+				return
+			}
+		case <-ticker.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.config.WriteTimeout)); err != nil {
+				c.cancel(err)
+				return
+			}
+		case ce := <-c.closing:
+			for drained := false; !drained; {
+				select {
+				case m := <-c.send:
+					if c.write(m) != nil {
+						drained = true
+					}
+				default:
+					drained = true
+				}
+			}
+			_ = c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(ce.Code, ce.Reason),
+				time.Now().Add(c.config.WriteTimeout))
+			c.cancel(ce)
+			return
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *WebSocketConn) write(m message) error {
+	_ = c.ws.SetWriteDeadline(time.Now().Add(c.config.WriteTimeout))
+	if err := c.ws.WriteMessage(int(m.typ), m.data); err != nil {
+		return err
+	}
+	c.sent.Add(1)
+	return nil
+}
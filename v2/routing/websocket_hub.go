@@ -0,0 +1,90 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// Hub tracks WebSocket connections by group, such as a branch or a user,
+// for broadcasting. Connections leave their groups when they close.
+type Hub struct {
+	mu     sync.RWMutex
+	groups map[string]map[*WebSocketConn]func() bool
+}
+
+// NewHub creates an empty Hub.
+func NewHub() *Hub {
+	return &Hub{groups: make(map[string]map[*WebSocketConn]func() bool)}
+}
+
+// Join adds conn to group. Joining a group twice has no effect.
+func (h *Hub) Join(group string, conn *WebSocketConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	members, ok := h.groups[group]
+	if !ok {
+		members = make(map[*WebSocketConn]func() bool)
+		h.groups[group] = members
+	}
+	if _, joined := members[conn]; joined {
+		return
+	}
+	members[conn] = context.AfterFunc(conn.Context(), func() { h.remove(group, conn) })
+}
+
+// Leave removes conn from group.
+func (h *Hub) Leave(group string, conn *WebSocketConn) {
+	h.mu.Lock()
+	stop, ok := h.groups[group][conn]
+	h.mu.Unlock()
+	if ok {
+		stop()
+		h.remove(group, conn)
+	}
+}
+
+func (h *Hub) remove(group string, conn *WebSocketConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.groups[group], conn)
+	if len(h.groups[group]) == 0 {
+		delete(h.groups, group)
+	}
+}
+
+// Count returns the number of connections in group.
+func (h *Hub) Count(group string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.groups[group])
+}
+
+// Broadcast queues a message to every connection in group without
+// waiting, and returns how many accepted it. Connections too slow to
+// keep up are closed (see WebSocketConn.TrySend).
+func (h *Hub) Broadcast(group string, typ MessageType, data []byte) int {
+	h.mu.RLock()
+	members := make([]*WebSocketConn, 0, len(h.groups[group]))
+	for conn := range h.groups[group] {
+		members = append(members, conn)
+	}
+	h.mu.RUnlock()
+
+	sent := 0
+	for _, conn := range members {
+		if conn.TrySend(typ, data) {
+			sent++
+		}
+	}
+	return sent
+}
+
+// BroadcastJSON broadcasts v to group as a text message.
+func (h *Hub) BroadcastJSON(group string, v any) (int, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	return h.Broadcast(group, TextMessage, data), nil
+}
@@ -0,0 +1,167 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	legacy "github.com/hmmftg/requestCore/webFramework"
+
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+// testUpgrader is a net/http Upgrader; the adapters cannot be imported
+// here.
+type testUpgrader struct {
+	w http.ResponseWriter
+	r *http.Request
+}
+
+func (u testUpgrader) Request() (*http.Request, error) { return u.r, nil }
+
+func (u testUpgrader) Upgrade(up *websocket.Upgrader, serve func(*websocket.Conn)) error {
+	ws, err := up.Upgrade(u.w, u.r, nil)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+	serve(ws)
+	return nil
+}
+
+// serveWebSocket serves h and returns its ws:// URL and a channel with
+// the error h returned for each request.
+func serveWebSocket(t *testing.T, h Handler) (string, <-chan error) {
+	t.Helper()
+	errs := make(chan error, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parser := v2wf.NewFakeParserV2()
+		commit := &v2wf.CommitState{}
+		parser.SetCommitState(commit)
+		ctx := &v2wf.RequestContext{
+			Context: WithUpgrader(r.Context(), testUpgrader{w: w, r: r}),
+			Parser:  parser,
+			Legacy:  legacy.WebFramework{Parser: parser},
+		}
+		ctx.SetCommitState(commit)
+		err := h(ctx)
+		if err != nil && !commit.Committed() {
+			w.WriteHeader(http.StatusForbidden)
+		}
+		errs <- err
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http"), errs
+}
+
+func TestWebSocket_Rejected(t *testing.T) {
+	accepted := false
+	url, errs := serveWebSocket(t, WebSocket(WebSocketConfig{}, func(*v2wf.RequestContext) (WebSocketHandler, error) {
+		accepted = true
+		return nil, errors.New("unauthorized")
+	}))
+
+	header := http.Header{"Origin": {"https://evil.example"}}
+	if _, _, err := websocket.DefaultDialer.Dial(url, header); err == nil {
+		t.Fatal("expected the foreign origin to be refused")
+	}
+	if err := <-errs; err == nil || !strings.Contains(err.Error(), "WEBSOCKET_ORIGIN") || accepted {
+		t.Fatalf("expected WEBSOCKET_ORIGIN before accept, got %v", err)
+	}
+
+	if _, _, err := websocket.DefaultDialer.Dial(url, nil); err == nil {
+		t.Fatal("expected accept to refuse the upgrade")
+	}
+	if err := <-errs; err == nil || err.Error() != "unauthorized" {
+		t.Fatalf("expected the accept error, got %v", err)
+	}
+}
+
+func TestWebSocket_CloseCodes(t *testing.T) {
+	var conns []*WebSocketConn
+	url, errs := serveWebSocket(t, WebSocket(WebSocketConfig{ReadLimit: 8}, func(*v2wf.RequestContext) (WebSocketHandler, error) {
+		return func(conn *WebSocketConn) error {
+			conns = append(conns, conn)
+			_, data, err := conn.Read()
+			if err != nil {
+				return err
+			}
+			return errors.New("failed on " + string(data))
+		}, nil
+	}))
+
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	_ = ws.WriteMessage(websocket.TextMessage, []byte("x"))
+	if _, _, err := ws.ReadMessage(); !websocket.IsCloseError(err, CloseInternalError) {
+		t.Fatalf("expected close 1011 for a handler error, got %v", err)
+	}
+	if err := <-errs; err == nil || err.Error() != "failed on x" {
+		t.Fatalf("expected the handler error, got %v", err)
+	}
+	ws.Close()
+
+	ws, _, err = websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer ws.Close()
+	_ = ws.WriteMessage(websocket.TextMessage, []byte("too long for the limit"))
+	if _, _, err := ws.ReadMessage(); !websocket.IsCloseError(err, CloseMessageTooBig) {
+		t.Fatalf("expected close 1009 for an oversized message, got %v", err)
+	}
+	<-errs
+	if ce := conns[1].closeError(); ce.Code != CloseMessageTooBig {
+		t.Fatalf("unexpected close %v", ce)
+	}
+}
+
+func TestHub_SlowConsumer(t *testing.T) {
+	hub := NewHub()
+	release := make(chan struct{})
+	causes := make(chan error, 1)
+	url, _ := serveWebSocket(t, WebSocket(WebSocketConfig{SendQueue: 1, WriteTimeout: 50 * time.Millisecond}, func(*v2wf.RequestContext) (WebSocketHandler, error) {
+		return func(conn *WebSocketConn) error {
+			hub.Join("slow", conn)
+			close(release)
+			<-conn.Context().Done()
+			causes <- context.Cause(conn.Context())
+			return nil
+		}, nil
+	}))
+
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer ws.Close()
+	<-release
+	// The client never reads, so the queue fills once the socket
+	// buffers are full.
+	payload := make([]byte, 64<<10)
+	deadline := time.Now().Add(5 * time.Second)
+	for hub.Broadcast("slow", BinaryMessage, payload) == 1 && time.Now().Before(deadline) {
+	}
+	select {
+	case err := <-causes:
+		if !IsCloseError(err, CloseTryAgainLater) {
+			t.Fatalf("expected close 1013 for the slow consumer, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow consumer was not disconnected")
+	}
+	for hub.Count("slow") != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if hub.Count("slow") != 0 {
+		t.Fatal("expected the slow consumer to leave the hub")
+	}
+}
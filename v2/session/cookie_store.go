@@ -43,6 +43,12 @@ type CookieStoreConfig struct {
 	// When nil, the payload is signed but readable by the client.
 	EncryptionKey []byte
 
+	// DecryptionKeys are previous encryption keys (32 bytes each) for key
+	// rotation. New tokens are encrypted with EncryptionKey; existing
+	// tokens are decrypted with EncryptionKey first, then each decryption
+	// key. Requires EncryptionKey.
+	DecryptionKeys [][]byte
+
 	// CookieName is the HTTP cookie name. Default: "requestcore_session".
 	CookieName string
 
@@ -87,6 +93,14 @@ func NewCookieStore(config CookieStoreConfig) (*CookieStore, error) {
 	if config.EncryptionKey != nil && len(config.EncryptionKey) != 32 {
 		return nil, errors.New("session: encryption key must be exactly 32 bytes")
 	}
+	if len(config.DecryptionKeys) > 0 && config.EncryptionKey == nil {
+		return nil, errors.New("session: decryption keys require an encryption key")
+	}
+	for _, key := range config.DecryptionKeys {
+		if len(key) != 32 {
+			return nil, errors.New("session: decryption keys must be exactly 32 bytes")
+		}
+	}
 	if config.CookieName == "" {
 		config.CookieName = "requestcore_session"
 	}
@@ -272,9 +286,21 @@ func (s *CookieStore) encrypt(plaintext []byte) ([]byte, error) {
 }
 
 // decrypt decrypts an AES-GCM ciphertext (nonce prepended) using the
-// configured EncryptionKey.
+// configured EncryptionKey, falling back to each of the DecryptionKeys.
 func (s *CookieStore) decrypt(ciphertext []byte) ([]byte, error) {
-	block, err := aes.NewCipher(s.config.EncryptionKey)
+	plaintext, err := decryptWithKey(s.config.EncryptionKey, ciphertext)
+	for _, key := range s.config.DecryptionKeys {
+		if err == nil {
+			break
+		}
+		plaintext, err = decryptWithKey(key, ciphertext)
+	}
+	return plaintext, err
+}
+
+// decryptWithKey decrypts an AES-GCM ciphertext (nonce prepended) with key.
+func decryptWithKey(key, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,73 @@
+package session
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/hmmftg/requestCore/libParams"
+)
+
+const (
+	// SigningKeyParam is the secure parameter name prefix for cookie
+	// signing keys. Keys are numbered "session-signing-key#1",
+	// "session-signing-key#2", ... and the highest number is the newest.
+	SigningKeyParam = "session-signing-key"
+
+	// EncryptionKeyParam is the secure parameter name prefix for cookie
+	// encryption keys, numbered like SigningKeyParam.
+	EncryptionKeyParam = "session-encryption-key"
+)
+
+// LoadCookieKeys fills the signing and encryption keys of config from the
+// numbered secure parameters of group (see SigningKeyParam and
+// EncryptionKeyParam). Values are base64-encoded and must already be
+// decrypted. The newest key becomes SecretKey / EncryptionKey and older
+// keys become VerificationKeys / DecryptionKeys, so adding a key with the
+// next number rotates the secret without invalidating existing cookies.
+// Retire a key by removing it once MaxAge has passed.
+func LoadCookieKeys(params libParams.ParamInterface, group string, config *CookieStoreConfig) error {
+	signing, err := loadKeys(params, group, SigningKeyParam)
+	if err != nil {
+		return err
+	}
+	if len(signing) == 0 {
+		return fmt.Errorf("session: no %s#N parameters in group %q", SigningKeyParam, group)
+	}
+	encryption, err := loadKeys(params, group, EncryptionKeyParam)
+	if err != nil {
+		return err
+	}
+
+	config.SecretKey = signing[0]
+	config.VerificationKeys = signing[1:]
+	config.EncryptionKey = nil
+	config.DecryptionKeys = nil
+	if len(encryption) > 0 {
+		config.EncryptionKey = encryption[0]
+		config.DecryptionKeys = encryption[1:]
+	}
+	return nil
+}
+
+// loadKeys reads prefix#1, prefix#2, ... until the first missing number
+// and returns the decoded keys newest first.
+func loadKeys(params libParams.ParamInterface, group, prefix string) ([][]byte, error) {
+	if params == nil {
+		return nil, errors.New("session: nil parameters")
+	}
+	var keys [][]byte
+	for n := 1; ; n++ {
+		name := fmt.Sprintf("%s#%d", prefix, n)
+		param := params.GetSecureParam(group, name)
+		if param == nil {
+			break
+		}
+		key, err := base64.StdEncoding.DecodeString(param.Value)
+		if err != nil {
+			return nil, fmt.Errorf("session: decode %s/%s: %w", group, name, err)
+		}
+		keys = append([][]byte{key}, keys...)
+	}
+	return keys, nil
+}
@@ -2,19 +2,64 @@ package session
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"time"
 )
 
+// ErrExpired is returned by Manager.LoadFromCookie when the stored session
+// exceeded the idle or absolute timeout. The session and flash returned
+// with it are fresh replacements.
+var ErrExpired = errors.New("session: expired")
+
+// lastActiveKey is the reserved session key holding the Unix millisecond
+// time of the last request that used the session.
+const lastActiveKey = "_last_active"
+
+// ManagerConfig configures a Manager.
+type ManagerConfig struct {
+	// Store is the session store.
+	Store Store
+
+	// IdleTimeout expires a session that has not been used by any
+	// request for this long. Zero disables the idle timeout.
+	IdleTimeout time.Duration
+
+	// AbsoluteTimeout expires a session this long after it was created,
+	// regardless of activity. Zero disables the absolute timeout.
+	AbsoluteTimeout time.Duration
+
+	// Clock is the clock source for deterministic testing.
+	// If nil, time.Now is used.
+	Clock func() time.Time
+}
+
 // Manager coordinates session and flash lifecycle across HTTP requests.
 // It is initialized with a Store and provides methods for loading
 // sessions from request cookies and saving them to response cookies.
 type Manager struct {
-	store Store
+	store  Store
+	config ManagerConfig
 }
 
-// NewManager creates a session Manager backed by the given Store.
+// NewManager creates a session Manager backed by the given Store, without
+// idle or absolute timeouts beyond the store's own expiry.
 func NewManager(store Store) *Manager {
-	return &Manager{store: store}
+	return NewManagerWithConfig(ManagerConfig{Store: store})
+}
+
+// NewManagerWithConfig creates a session Manager with the given
+// configuration.
+func NewManagerWithConfig(config ManagerConfig) *Manager {
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+	return &Manager{store: config.Store, config: config}
+}
+
+// Config returns the manager configuration.
+func (m *Manager) Config() ManagerConfig {
+	return m.config
 }
 
 // Store returns the underlying session store.
@@ -24,7 +69,10 @@ func (m *Manager) Store() Store {
 
 // LoadFromCookie extracts the session token from the named cookie
 // and loads the session from the store.
-// If the cookie is absent, a new empty session is returned.
+// If the cookie is absent or the token does not load, a new empty session
+// is returned. If the session exceeded the idle or absolute timeout, it is
+// deleted from the store and a new empty session is returned together
+// with ErrExpired.
 func (m *Manager) LoadFromCookie(ctx context.Context, cookieName, cookieValue string) (*Session, *Flash, error) {
 	if cookieValue == "" {
 		sess := NewSession(m.store)
@@ -37,10 +85,65 @@ func (m *Manager) LoadFromCookie(ctx context.Context, cookieName, cookieValue st
 		return sess, NewFlash(), nil
 	}
 
+	if m.expired(sess) {
+		// The store may not have an entry to delete (CookieStore);
+		// either way the old token must not be honored again.
+		_ = m.store.Delete(ctx, sess.ID())
+		return NewSession(m.store), NewFlash(), ErrExpired
+	}
+	m.touch(sess)
+
 	flash := LoadFlashFromSession(sess)
 	return sess, flash, nil
 }
 
+// expired reports whether the session exceeded the configured timeouts.
+func (m *Manager) expired(sess *Session) bool {
+	now := m.config.Clock()
+	if m.config.AbsoluteTimeout > 0 && !sess.CreatedAt().IsZero() &&
+		now.Sub(sess.CreatedAt()) >= m.config.AbsoluteTimeout {
+		return true
+	}
+	if m.config.IdleTimeout > 0 {
+		last, ok := lastActive(sess)
+		if !ok {
+			last = sess.CreatedAt()
+		}
+		if !last.IsZero() && now.Sub(last) >= m.config.IdleTimeout {
+			return true
+		}
+	}
+	return false
+}
+
+// touch records the request time as the session's last activity. To
+// avoid rewriting the session on every request, the timestamp is only
+// refreshed once a quarter of the idle timeout (at most a minute) has
+// passed.
+func (m *Manager) touch(sess *Session) {
+	if m.config.IdleTimeout <= 0 {
+		return
+	}
+	now := m.config.Clock()
+	granularity := min(m.config.IdleTimeout/4, time.Minute)
+	if last, ok := lastActive(sess); ok && now.Sub(last) < granularity {
+		return
+	}
+	sess.Set(lastActiveKey, now.UnixMilli())
+}
+
+// lastActive returns the last activity time recorded by touch.
+func lastActive(sess *Session) (time.Time, bool) {
+	switch v := sess.Get(lastActiveKey).(type) {
+	case int64:
+		return time.UnixMilli(v), true
+	case float64:
+		// JSON-backed stores decode numbers as float64.
+		return time.UnixMilli(int64(v)), true
+	}
+	return time.Time{}, false
+}
+
 // SaveToCookie persists the session and returns an http.Cookie to set
 // on the response. If the session is not dirty, the cookie is not
 // modified (returns nil).
@@ -0,0 +1,326 @@
+package session
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultUserIDKey is the session key MemoryStore reads the user ID from.
+const DefaultUserIDKey = "user_id"
+
+// MemoryStoreConfig configures a MemoryStore.
+type MemoryStoreConfig struct {
+	// IdleTimeout expires a session that has not been loaded or saved
+	// for this long.
+	// Default: 30 minutes.
+	IdleTimeout time.Duration
+
+	// AbsoluteTimeout expires a session this long after it was created,
+	// regardless of activity.
+	// Default: 24 hours.
+	AbsoluteTimeout time.Duration
+
+	// MaxEntries caps the number of stored sessions. When full, the least
+	// recently used session is evicted.
+	// Default: 10000.
+	MaxEntries int
+
+	// CleanupInterval is how often RunCleanup removes expired sessions.
+	// Default: 1 minute.
+	CleanupInterval time.Duration
+
+	// UserIDKey is the session key holding the user ID, used by
+	// ListByUser and RevokeUser. Sessions without a string value under
+	// this key are not indexed.
+	// Default: DefaultUserIDKey.
+	UserIDKey string
+
+	// Clock is the clock source for deterministic testing.
+	// If nil, time.Now is used.
+	Clock func() time.Time
+}
+
+// SessionInfo describes a stored session without its data.
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"userId"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastAccess time.Time `json:"lastAccess"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// MemoryStore implements Store in process memory. The token is the
+// session ID and the data stays on the server, so sessions are revocable
+// like with SQLStore, but they are lost on restart and not shared between
+// replicas. It suits single-node deployments and tests.
+type MemoryStore struct {
+	config MemoryStoreConfig
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // front is most recently used
+	byUser  map[string]map[string]struct{}
+}
+
+type memoryEntry struct {
+	id         string
+	userID     string
+	data       map[string]any
+	revision   uint64
+	createdAt  time.Time
+	lastAccess time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore(config MemoryStoreConfig) *MemoryStore {
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = 30 * time.Minute
+	}
+	if config.AbsoluteTimeout <= 0 {
+		config.AbsoluteTimeout = 24 * time.Hour
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = 10000
+	}
+	if config.CleanupInterval <= 0 {
+		config.CleanupInterval = time.Minute
+	}
+	if config.UserIDKey == "" {
+		config.UserIDKey = DefaultUserIDKey
+	}
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+	return &MemoryStore{
+		config:  config,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		byUser:  make(map[string]map[string]struct{}),
+	}
+}
+
+// Config returns the store configuration.
+func (s *MemoryStore) Config() MemoryStoreConfig {
+	return s.config
+}
+
+// expiresAt returns when the entry expires: the earlier of its idle and
+// absolute deadlines.
+func (s *MemoryStore) expiresAt(e *memoryEntry) time.Time {
+	idle := e.lastAccess.Add(s.config.IdleTimeout)
+	absolute := e.createdAt.Add(s.config.AbsoluteTimeout)
+	if absolute.Before(idle) {
+		return absolute
+	}
+	return idle
+}
+
+// Load returns an unexpired session and marks it as recently used.
+func (s *MemoryStore) Load(_ context.Context, token string) (*Session, error) {
+	if token == "" {
+		return nil, errors.New("session: empty token")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[token]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	e := el.Value.(*memoryEntry)
+	now := s.config.Clock()
+	if !now.Before(s.expiresAt(e)) {
+		s.removeLocked(el)
+		return nil, ErrNotFound
+	}
+	e.lastAccess = now
+	s.lru.MoveToFront(el)
+	return &Session{
+		id:             e.id,
+		data:           copyMap(e.data),
+		store:          s,
+		createdAt:      e.createdAt,
+		revision:       e.revision,
+		storedRevision: e.revision,
+	}, nil
+}
+
+// Save stores the session if it has not changed since it was loaded and
+// returns the session ID as the token. Saving a session that was revoked
+// or expired after it was loaded fails with ErrConflict.
+func (s *MemoryStore) Save(_ context.Context, sess *Session) (string, error) {
+	data := sess.Data()
+	userID, _ := data[s.config.UserIDKey].(string)
+	revision := sess.revision
+	if revision <= sess.storedRevision {
+		revision = sess.storedRevision + 1
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.config.Clock()
+	el, ok := s.entries[sess.id]
+	if sess.storedRevision != 0 {
+		if !ok || el.Value.(*memoryEntry).revision != sess.storedRevision {
+			return "", fmt.Errorf("%w: session %s", ErrConflict, sess.id)
+		}
+		if e := el.Value.(*memoryEntry); !now.Before(s.expiresAt(e)) {
+			s.removeLocked(el)
+			return "", fmt.Errorf("%w: session %s", ErrConflict, sess.id)
+		}
+	} else if ok {
+		return "", fmt.Errorf("%w: session %s", ErrConflict, sess.id)
+	}
+
+	if ok {
+		e := el.Value.(*memoryEntry)
+		s.unindexLocked(e)
+		e.data = data
+		e.userID = userID
+		e.revision = revision
+		e.lastAccess = now
+		s.indexLocked(e)
+		s.lru.MoveToFront(el)
+	} else {
+		e := &memoryEntry{
+			id:         sess.id,
+			userID:     userID,
+			data:       data,
+			revision:   revision,
+			createdAt:  sess.createdAt,
+			lastAccess: now,
+		}
+		s.entries[e.id] = s.lru.PushFront(e)
+		s.indexLocked(e)
+		for s.lru.Len() > s.config.MaxEntries {
+			s.removeLocked(s.lru.Back())
+		}
+	}
+	sess.storedRevision = revision
+	return sess.id, nil
+}
+
+// Delete removes the session, invalidating its token immediately.
+func (s *MemoryStore) Delete(_ context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[token]; ok {
+		s.removeLocked(el)
+	}
+	return nil
+}
+
+// Len returns the number of stored sessions, including expired sessions
+// not yet removed by Cleanup.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// ListByUser returns the unexpired sessions of a user, most recently used
+// first.
+func (s *MemoryStore) ListByUser(userID string) []SessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.config.Clock()
+	var infos []SessionInfo
+	for el := s.lru.Front(); el != nil; el = el.Next() {
+		e := el.Value.(*memoryEntry)
+		if e.userID != userID {
+			continue
+		}
+		expires := s.expiresAt(e)
+		if !now.Before(expires) {
+			continue
+		}
+		infos = append(infos, SessionInfo{
+			ID:         e.id,
+			UserID:     e.userID,
+			CreatedAt:  e.createdAt,
+			LastAccess: e.lastAccess,
+			ExpiresAt:  expires,
+		})
+	}
+	return infos
+}
+
+// RevokeUser deletes every session of a user ("log out all devices") and
+// returns how many were removed.
+func (s *MemoryStore) RevokeUser(userID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := s.byUser[userID]
+	n := 0
+	for id := range ids {
+		if el, ok := s.entries[id]; ok {
+			s.removeLocked(el)
+			n++
+		}
+	}
+	return n
+}
+
+// Cleanup removes expired sessions and returns how many were removed.
+func (s *MemoryStore) Cleanup(_ context.Context) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.config.Clock()
+	n := 0
+	for el := s.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if !now.Before(s.expiresAt(el.Value.(*memoryEntry))) {
+			s.removeLocked(el)
+			n++
+		}
+		el = prev
+	}
+	return n
+}
+
+// RunCleanup calls Cleanup every CleanupInterval until ctx is cancelled
+// and returns ctx.Err().
+func (s *MemoryStore) RunCleanup(ctx context.Context) error {
+	ticker := time.NewTicker(s.config.CleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			s.Cleanup(ctx)
+		}
+	}
+}
+
+func (s *MemoryStore) indexLocked(e *memoryEntry) {
+	if e.userID == "" {
+		return
+	}
+	ids, ok := s.byUser[e.userID]
+	if !ok {
+		ids = make(map[string]struct{})
+		s.byUser[e.userID] = ids
+	}
+	ids[e.id] = struct{}{}
+}
+
+func (s *MemoryStore) unindexLocked(e *memoryEntry) {
+	ids, ok := s.byUser[e.userID]
+	if !ok {
+		return
+	}
+	delete(ids, e.id)
+	if len(ids) == 0 {
+		delete(s.byUser, e.userID)
+	}
+}
+
+func (s *MemoryStore) removeLocked(el *list.Element) {
+	e := s.lru.Remove(el).(*memoryEntry)
+	delete(s.entries, e.id)
+	s.unindexLocked(e)
+}
@@ -0,0 +1,144 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newMemoryStore(config MemoryStoreConfig) (*MemoryStore, *fakeClock) {
+	clock := &fakeClock{now: time.UnixMilli(1700000000000)}
+	config.Clock = clock.Now
+	return NewMemoryStore(config), clock
+}
+
+func saveNew(t *testing.T, store Store, createdAt time.Time, userID string) *Session {
+	t.Helper()
+	sess := NewSession(store)
+	sess.createdAt = createdAt
+	if userID != "" {
+		sess.Set(DefaultUserIDKey, userID)
+	}
+	if _, err := sess.Save(context.Background()); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	return sess
+}
+
+func TestMemoryStore_Timeouts(t *testing.T) {
+	store, clock := newMemoryStore(MemoryStoreConfig{IdleTimeout: 10 * time.Minute, AbsoluteTimeout: time.Hour})
+	ctx := context.Background()
+	sess := saveNew(t, store, clock.now, "alice")
+
+	// Activity keeps the session alive past the idle timeout...
+	for i := 0; i < 5; i++ {
+		clock.Advance(9 * time.Minute)
+		if _, err := store.Load(ctx, sess.ID()); err != nil {
+			t.Fatalf("Load after %d idle periods: %v", i, err)
+		}
+	}
+	// ...but not past the absolute timeout.
+	clock.Advance(16 * time.Minute)
+	if _, err := store.Load(ctx, sess.ID()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected absolute expiry, got %v", err)
+	}
+
+	idle := saveNew(t, store, clock.now, "")
+	clock.Advance(10 * time.Minute)
+	if _, err := store.Load(ctx, idle.ID()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected idle expiry, got %v", err)
+	}
+}
+
+func TestMemoryStore_LRUAndCleanup(t *testing.T) {
+	store, clock := newMemoryStore(MemoryStoreConfig{MaxEntries: 2, IdleTimeout: time.Minute})
+	ctx := context.Background()
+	a := saveNew(t, store, clock.now, "")
+	b := saveNew(t, store, clock.now, "")
+	if _, err := store.Load(ctx, a.ID()); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	c := saveNew(t, store, clock.now, "")
+
+	// b was least recently used and is evicted.
+	if _, err := store.Load(ctx, b.ID()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected b evicted, got %v", err)
+	}
+	if store.Len() != 2 {
+		t.Fatalf("expected 2 entries, got %d", store.Len())
+	}
+
+	clock.Advance(30 * time.Second)
+	if _, err := store.Load(ctx, c.ID()); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	clock.Advance(45 * time.Second)
+	if n := store.Cleanup(ctx); n != 1 || store.Len() != 1 {
+		t.Fatalf("expected a swept, got n=%d len=%d", n, store.Len())
+	}
+}
+
+func TestMemoryStore_RevokeUser(t *testing.T) {
+	store, clock := newMemoryStore(MemoryStoreConfig{})
+	ctx := context.Background()
+	phone := saveNew(t, store, clock.now, "alice")
+	clock.Advance(time.Second)
+	laptop := saveNew(t, store, clock.now, "alice")
+	other := saveNew(t, store, clock.now, "bob")
+
+	infos := store.ListByUser("alice")
+	if len(infos) != 2 || infos[0].ID != laptop.ID() || infos[1].ID != phone.ID() {
+		t.Fatalf("unexpected sessions: %+v", infos)
+	}
+
+	if n := store.RevokeUser("alice"); n != 2 {
+		t.Fatalf("expected 2 revoked, got %d", n)
+	}
+	if len(store.ListByUser("alice")) != 0 {
+		t.Fatal("expected no sessions for alice")
+	}
+	if _, err := store.Load(ctx, phone.ID()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected revoked session, got %v", err)
+	}
+	if _, err := store.Load(ctx, other.ID()); err != nil {
+		t.Fatalf("other user's session must survive: %v", err)
+	}
+
+	// A request still holding a revoked session cannot resurrect it.
+	laptop.Set("cart", 1)
+	if _, err := laptop.Save(ctx); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+}
+
+func TestMemoryStore_Conflict(t *testing.T) {
+	store, clock := newMemoryStore(MemoryStoreConfig{})
+	ctx := context.Background()
+	sess := saveNew(t, store, clock.now, "alice")
+
+	first, _ := store.Load(ctx, sess.ID())
+	second, _ := store.Load(ctx, sess.ID())
+	first.Set("step", 1)
+	if _, err := first.Save(ctx); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	second.Set("step", 2)
+	if _, err := second.Save(ctx); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+
+	// Moving a session to another user updates the index.
+	first.Set(DefaultUserIDKey, "bob")
+	if _, err := first.Save(ctx); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if len(store.ListByUser("alice")) != 0 || len(store.ListByUser("bob")) != 1 {
+		t.Fatal("expected session reindexed to bob")
+	}
+}
@@ -1,6 +1,7 @@
 package session
 
 import (
+	"errors"
 	"log/slog"
 
 	"github.com/hmmftg/requestCore/webFramework"
@@ -76,8 +77,10 @@ func MiddlewareWithConfig(cfg MiddlewareConfig) func(next func(*v2wf.RequestCont
 				cookieValue = ctx.Parser.GetCookie(cookieName)
 			}
 
+			// An expired session is replaced by the fresh one
+			// LoadFromCookie returns; its cookie is overwritten on save.
 			sess, flash, err := manager.LoadFromCookie(ctx.Context, cookieName, cookieValue)
-			if err != nil {
+			if err != nil && !errors.Is(err, ErrExpired) {
 				// On load error, create a fresh session.
 				sess = NewSession(manager.Store())
 				flash = NewFlash()
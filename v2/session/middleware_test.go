@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"testing"
+	"time"
 
 	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
 )
@@ -245,3 +246,39 @@ func TestMiddleware_LoadErrorCreatesFreshSession(t *testing.T) {
 		t.Fatal("expected *Session type assertion to succeed")
 	}
 }
+
+// TestMiddleware_ExpiredSessionReplaced verifies that a session past the
+// manager's timeouts is dropped and replaced by a fresh session cookie.
+func TestMiddleware_ExpiredSessionReplaced(t *testing.T) {
+	store := NewMemoryStore(MemoryStoreConfig{})
+	sess := NewSession(store)
+	sess.createdAt = time.Now().Add(-2 * time.Hour)
+	token, err := sess.Save(context.Background())
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	manager := NewManagerWithConfig(ManagerConfig{Store: store, AbsoluteTimeout: time.Hour})
+
+	parser := v2wf.NewFakeParserV2()
+	parser.Cookies["sess"] = token
+	commit := &v2wf.CommitState{}
+	parser.SetCommitState(commit)
+	ctx := &v2wf.RequestContext{Parser: parser, Context: context.Background()}
+	ctx.SetCommitState(commit)
+
+	handler := Middleware(manager, "sess")(func(c *v2wf.RequestContext) error {
+		if got := FromContext(c); got == nil || got.ID() == token {
+			t.Fatal("expected a fresh session")
+		}
+		return c.RunBeforeCommitHooks()
+	})
+	if err := handler(ctx); err != nil {
+		t.Fatalf("middleware: %v", err)
+	}
+	if len(parser.SetCookies) != 1 || parser.SetCookies[0].Value == token {
+		t.Fatalf("expected a replacement cookie, got %+v", parser.SetCookies)
+	}
+	if _, err := store.Load(context.Background(), token); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected expired session deleted, got %v", err)
+	}
+}
@@ -29,10 +29,21 @@ type Session struct {
 	// if the revision has advanced since the snapshot was taken, the
 	// dirty flag is not cleared.
 	revision uint64
+
+	// storedRevision is the revision last loaded from or written to a
+	// server-side store. SQLStore uses it as the optimistic concurrency
+	// token when updating the session row.
+	storedRevision uint64
+
+	// replaced is the ID this session had before Regenerate. Save
+	// deletes it from the store once the new ID is persisted.
+	replaced string
 }
 
 // ID returns the opaque session identifier.
 func (s *Session) ID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.id
 }
 
@@ -100,6 +111,23 @@ func (s *Session) Clear() {
 	s.revision++
 }
 
+// Regenerate assigns the session a new random ID while keeping its data,
+// and marks it dirty. Call it after login or any privilege change so an
+// ID planted before authentication (session fixation) becomes useless.
+// The next Save persists the session under the new ID and deletes the old
+// one from the store.
+func (s *Session) Regenerate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.replaced == "" && s.storedRevision != 0 {
+		s.replaced = s.id
+	}
+	s.id = generateID()
+	s.storedRevision = 0
+	s.dirty = true
+	s.revision++
+}
+
 // IsDirty reports whether the session has unsaved changes.
 func (s *Session) IsDirty() bool {
 	s.mu.RLock()
@@ -121,11 +149,12 @@ func (s *Session) Save(ctx context.Context) (string, error) {
 	// Snapshot under the read lock.
 	s.mu.RLock()
 	snapshot := &Session{
-		id:        s.id,
-		data:      copyMap(s.data),
-		store:     s.store,
-		createdAt: s.createdAt,
-		revision:  s.revision,
+		id:             s.id,
+		data:           copyMap(s.data),
+		store:          s.store,
+		createdAt:      s.createdAt,
+		revision:       s.revision,
+		storedRevision: s.storedRevision,
 	}
 	s.mu.RUnlock()
 
@@ -136,12 +165,25 @@ func (s *Session) Save(ctx context.Context) (string, error) {
 	}
 
 	// Reacquire the write lock and clear dirty only if no concurrent
-	// mutations occurred during the save.
+	// mutations occurred during the save. If Regenerate ran concurrently,
+	// the stored revision and the pending deletion belong to the next save.
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	var replaced string
+	if s.id == snapshot.id {
+		s.storedRevision = snapshot.storedRevision
+		replaced, s.replaced = s.replaced, ""
+	}
 	if s.revision == snapshot.revision {
 		s.dirty = false
 	}
+	s.mu.Unlock()
+
+	// Invalidate the pre-Regenerate ID now that the new one is stored.
+	if replaced != "" {
+		if err := s.store.Delete(ctx, replaced); err != nil {
+			return "", fmt.Errorf("session: delete regenerated session: %w", err)
+		}
+	}
 	return token, nil
 }
 
@@ -156,7 +198,7 @@ func copyMap(m map[string]any) map[string]any {
 
 // Destroy deletes the session from its store.
 func (s *Session) Destroy(ctx context.Context) error {
-	return s.store.Delete(ctx, s.id)
+	return s.store.Delete(ctx, s.ID())
 }
 
 // Data returns a copy of the session data map.
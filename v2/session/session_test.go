@@ -6,8 +6,11 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"testing"
 	"time"
+
+	"github.com/hmmftg/requestCore/libParams"
 )
 
 func mustSecretKey(t *testing.T, n int) []byte {
@@ -706,3 +709,158 @@ func TestSession_SaveDoesNotHoldLockDuringStore(t *testing.T) {
 
 	<-done
 }
+
+func TestSession_Regenerate(t *testing.T) {
+	store, _ := newMemoryStore(MemoryStoreConfig{})
+	ctx := context.Background()
+	sess := saveNew(t, store, time.Now(), "alice")
+	oldID := sess.ID()
+
+	loaded, err := store.Load(ctx, oldID)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	loaded.Regenerate()
+	if loaded.ID() == oldID || !loaded.IsDirty() {
+		t.Fatalf("expected new dirty ID, got %q dirty=%v", loaded.ID(), loaded.IsDirty())
+	}
+	token, err := loaded.Save(ctx)
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if token != loaded.ID() {
+		t.Fatalf("expected token %q, got %q", loaded.ID(), token)
+	}
+	if _, err := store.Load(ctx, oldID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected old ID invalidated, got %v", err)
+	}
+	regenerated, err := store.Load(ctx, token)
+	if err != nil {
+		t.Fatalf("Load new ID: %v", err)
+	}
+	if regenerated.GetString(DefaultUserIDKey) != "alice" {
+		t.Fatal("expected data to survive regeneration")
+	}
+	if infos := store.ListByUser("alice"); len(infos) != 1 || infos[0].ID != token {
+		t.Fatalf("unexpected user sessions: %+v", infos)
+	}
+}
+
+func TestCookieStore_EncryptionKeyRotation(t *testing.T) {
+	signing := mustSecretKey(t, 32)
+	oldKey := mustSecretKey(t, 32)
+	newKey := mustSecretKey(t, 32)
+
+	oldStore, err := NewCookieStore(CookieStoreConfig{SecretKey: signing, EncryptionKey: oldKey})
+	if err != nil {
+		t.Fatalf("NewCookieStore: %v", err)
+	}
+	sess := NewSession(oldStore)
+	sess.Set("data", "value")
+	token, err := sess.Save(context.Background())
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	rotated, err := NewCookieStore(CookieStoreConfig{
+		SecretKey:      signing,
+		EncryptionKey:  newKey,
+		DecryptionKeys: [][]byte{oldKey},
+	})
+	if err != nil {
+		t.Fatalf("NewCookieStore: %v", err)
+	}
+	loaded, err := rotated.Load(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Load with rotated key: %v", err)
+	}
+	if loaded.GetString("data") != "value" {
+		t.Fatalf("expected 'value', got %q", loaded.GetString("data"))
+	}
+
+	// New tokens use the new key only.
+	newToken, err := loaded.Save(context.Background())
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := oldStore.Load(context.Background(), newToken); err == nil {
+		t.Fatal("expected old key to be unable to decrypt new token")
+	}
+
+	if _, err := NewCookieStore(CookieStoreConfig{SecretKey: signing, DecryptionKeys: [][]byte{oldKey}}); err == nil {
+		t.Fatal("expected error for decryption keys without encryption key")
+	}
+}
+
+func TestLoadCookieKeys(t *testing.T) {
+	key := func(b byte) string {
+		return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+	}
+	params := libParams.ApplicationParams[any]{
+		SecureParameterGroups: map[string]libParams.SecureParametersMap{
+			"session": {SecureParams: map[string]libParams.SecurityParam{
+				SigningKeyParam + "#1":    {Value: key('a')},
+				SigningKeyParam + "#2":    {Value: key('b')},
+				EncryptionKeyParam + "#1": {Value: key('c')},
+			}},
+		},
+	}
+	var config CookieStoreConfig
+	if err := LoadCookieKeys(params, "session", &config); err != nil {
+		t.Fatalf("LoadCookieKeys: %v", err)
+	}
+	if config.SecretKey[0] != 'b' || len(config.VerificationKeys) != 1 || config.VerificationKeys[0][0] != 'a' {
+		t.Fatal("expected newest signing key first")
+	}
+	if config.EncryptionKey[0] != 'c' || len(config.DecryptionKeys) != 0 {
+		t.Fatal("unexpected encryption keys")
+	}
+	if _, err := NewCookieStore(config); err != nil {
+		t.Fatalf("NewCookieStore: %v", err)
+	}
+
+	if err := LoadCookieKeys(params, "missing", &config); err == nil {
+		t.Fatal("expected error for group without signing keys")
+	}
+}
+
+func TestManager_Timeouts(t *testing.T) {
+	store := NewMemoryStore(MemoryStoreConfig{IdleTimeout: 24 * time.Hour})
+	clock := &fakeClock{now: time.Now()}
+	m := NewManagerWithConfig(ManagerConfig{
+		Store:           store,
+		IdleTimeout:     10 * time.Minute,
+		AbsoluteTimeout: time.Hour,
+		Clock:           clock.Now,
+	})
+	ctx := context.Background()
+	sess := saveNew(t, store, clock.now, "alice")
+
+	// Regular activity slides the idle deadline.
+	for i := 0; i < 5; i++ {
+		clock.Advance(8 * time.Minute)
+		loaded, _, err := m.LoadFromCookie(ctx, "s", sess.ID())
+		if err != nil || loaded.ID() != sess.ID() {
+			t.Fatalf("LoadFromCookie after %d periods: id=%q err=%v", i, loaded.ID(), err)
+		}
+		if _, err := loaded.Save(ctx); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	// The absolute timeout ends the session regardless of activity.
+	clock.Advance(25 * time.Minute)
+	fresh, _, err := m.LoadFromCookie(ctx, "s", sess.ID())
+	if !errors.Is(err, ErrExpired) || fresh.ID() == sess.ID() {
+		t.Fatalf("expected ErrExpired with a fresh session, got id=%q err=%v", fresh.ID(), err)
+	}
+	if store.Len() != 0 {
+		t.Fatal("expected expired session deleted from the store")
+	}
+
+	idle := saveNew(t, store, clock.now, "")
+	clock.Advance(10 * time.Minute)
+	if _, _, err := m.LoadFromCookie(ctx, "s", idle.ID()); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected idle expiry, got %v", err)
+	}
+}
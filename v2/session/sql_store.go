@@ -0,0 +1,259 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/hmmftg/requestCore/libQuery"
+)
+
+// ErrNotFound is returned by server-side stores when the session does not
+// exist, has expired, or was revoked.
+var ErrNotFound = errors.New("session: not found")
+
+// ErrConflict is returned by server-side stores when a session was
+// modified, revoked, or expired since it was loaded.
+var ErrConflict = errors.New("session: concurrent modification")
+
+// DefaultSQLTable is the table used by SQLStore when no table name is
+// configured.
+const DefaultSQLTable = "sessions"
+
+// SQLStoreConfig configures a SQLStore.
+type SQLStoreConfig struct {
+	// Table is the sessions table.
+	// Default: DefaultSQLTable.
+	Table string
+
+	// MaxAge is the session lifetime. Every save extends the expiry by
+	// MaxAge from the save time.
+	// Default: 24 hours.
+	MaxAge time.Duration
+
+	// CleanupInterval is how often RunCleanup deletes expired sessions.
+	// Default: 10 minutes.
+	CleanupInterval time.Duration
+
+	// Clock is the clock source for deterministic testing.
+	// If nil, time.Now is used.
+	Clock func() time.Time
+}
+
+// SQLStore implements Store with a database table accessed through
+// libQuery.QueryRunnerInterface. The token handed to the client is the
+// opaque random session ID; the data stays on the server, so sessions can
+// be large and are invalidated server-side by Session.Destroy. The
+// expected schema is:
+//
+//	CREATE TABLE sessions (
+//	    id          VARCHAR(64) PRIMARY KEY,
+//	    data        TEXT        NOT NULL,
+//	    revision    BIGINT      NOT NULL,
+//	    created_at  BIGINT      NOT NULL,
+//	    updated_at  BIGINT      NOT NULL,
+//	    expires_at  BIGINT      NOT NULL
+//	);
+//	CREATE INDEX sessions_expires_at ON sessions (expires_at);
+//
+// Timestamps are stored as Unix milliseconds and data as JSON text.
+// Updates are conditional on the revision that was loaded, so a save
+// based on stale data fails with ErrConflict instead of overwriting a
+// concurrent request's changes or resurrecting a revoked session.
+type SQLStore struct {
+	core   libQuery.QueryRunnerInterface
+	config SQLStoreConfig
+
+	load    libQuery.QueryCommand
+	insert  libQuery.DmlCommand
+	update  libQuery.DmlCommand
+	remove  libQuery.DmlCommand
+	cleanup libQuery.DmlCommand
+}
+
+// NewSQLStore creates a SQLStore on core.
+func NewSQLStore(core libQuery.QueryRunnerInterface, config SQLStoreConfig) *SQLStore {
+	if config.Table == "" {
+		config.Table = DefaultSQLTable
+	}
+	if config.MaxAge <= 0 {
+		config.MaxAge = 24 * time.Hour
+	}
+	if config.CleanupInterval <= 0 {
+		config.CleanupInterval = 10 * time.Minute
+	}
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+	t := config.Table
+	return &SQLStore{
+		core:   core,
+		config: config,
+		load: libQuery.QueryCommand{
+			Name:    "session-load",
+			Command: "SELECT id, data, revision, created_at FROM " + t + " WHERE id=$1 AND expires_at>$2",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "SELECT id, data, revision, created_at FROM " + t + " WHERE id=:1 AND expires_at>:2",
+				libQuery.MySql:  "SELECT id, data, revision, created_at FROM " + t + " WHERE id=? AND expires_at>?",
+				libQuery.Sqlite: "SELECT id, data, revision, created_at FROM " + t + " WHERE id=? AND expires_at>?",
+			},
+		},
+		insert: libQuery.DmlCommand{
+			Name:    "session-insert",
+			Command: "INSERT INTO " + t + " (id, data, revision, created_at, updated_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6)",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "INSERT INTO " + t + " (id, data, revision, created_at, updated_at, expires_at) VALUES (:1, :2, :3, :4, :5, :6)",
+				libQuery.MySql:  "INSERT INTO " + t + " (id, data, revision, created_at, updated_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+				libQuery.Sqlite: "INSERT INTO " + t + " (id, data, revision, created_at, updated_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+			},
+			Type: libQuery.Insert,
+		},
+		update: libQuery.DmlCommand{
+			Name:    "session-update",
+			Command: "UPDATE " + t + " SET data=$1, revision=$2, updated_at=$3, expires_at=$4 WHERE id=$5 AND revision=$6 AND expires_at>$7",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "UPDATE " + t + " SET data=:1, revision=:2, updated_at=:3, expires_at=:4 WHERE id=:5 AND revision=:6 AND expires_at>:7",
+				libQuery.MySql:  "UPDATE " + t + " SET data=?, revision=?, updated_at=?, expires_at=? WHERE id=? AND revision=? AND expires_at>?",
+				libQuery.Sqlite: "UPDATE " + t + " SET data=?, revision=?, updated_at=?, expires_at=? WHERE id=? AND revision=? AND expires_at>?",
+			},
+			Type: libQuery.Update,
+		},
+		remove: libQuery.DmlCommand{
+			Name:    "session-delete",
+			Command: "DELETE FROM " + t + " WHERE id=$1",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "DELETE FROM " + t + " WHERE id=:1",
+				libQuery.MySql:  "DELETE FROM " + t + " WHERE id=?",
+				libQuery.Sqlite: "DELETE FROM " + t + " WHERE id=?",
+			},
+			Type: libQuery.Delete,
+		},
+		cleanup: libQuery.DmlCommand{
+			Name:    "session-cleanup",
+			Command: "DELETE FROM " + t + " WHERE expires_at<=$1",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "DELETE FROM " + t + " WHERE expires_at<=:1",
+				libQuery.MySql:  "DELETE FROM " + t + " WHERE expires_at<=?",
+				libQuery.Sqlite: "DELETE FROM " + t + " WHERE expires_at<=?",
+			},
+			Type: libQuery.Delete,
+		},
+	}
+}
+
+// Config returns the store configuration.
+func (s *SQLStore) Config() SQLStoreConfig {
+	return s.config
+}
+
+// sessionRow is the database representation of a Session.
+type sessionRow struct {
+	ID        string `db:"id"`
+	Data      string `db:"data"`
+	Revision  int64  `db:"revision"`
+	CreatedAt int64  `db:"created_at"`
+}
+
+// Load reads an unexpired session by ID.
+func (s *SQLStore) Load(_ context.Context, token string) (*Session, error) {
+	if token == "" {
+		return nil, errors.New("session: empty token")
+	}
+	rows, err := libQuery.QueryToStruct[sessionRow](s.core, s.load.GetCommand(s.core.GetDbMode()),
+		token, s.config.Clock().UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("session: load: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, ErrNotFound
+	}
+	row := rows[0]
+	data := make(map[string]any)
+	if row.Data != "" {
+		if err := json.Unmarshal([]byte(row.Data), &data); err != nil {
+			return nil, fmt.Errorf("session: unmarshal data: %w", err)
+		}
+	}
+	return &Session{
+		id:             row.ID,
+		data:           data,
+		store:          s,
+		createdAt:      time.UnixMilli(row.CreatedAt),
+		revision:       uint64(row.Revision),
+		storedRevision: uint64(row.Revision),
+	}, nil
+}
+
+// Save inserts a new session or updates an existing one if it has not
+// changed since it was loaded, and returns the session ID as the token.
+func (s *SQLStore) Save(ctx context.Context, sess *Session) (string, error) {
+	data, err := json.Marshal(sess.Data())
+	if err != nil {
+		return "", fmt.Errorf("session: marshal data: %w", err)
+	}
+	now := s.config.Clock()
+	expires := now.Add(s.config.MaxAge).UnixMilli()
+	revision := sess.revision
+	if revision <= sess.storedRevision {
+		revision = sess.storedRevision + 1
+	}
+	mode := s.core.GetDbMode()
+
+	if sess.storedRevision == 0 {
+		_, err = s.core.Dml(ctx, "session", s.insert.Name, s.insert.GetCommand(mode),
+			sess.id, string(data), int64(revision), sess.createdAt.UnixMilli(), now.UnixMilli(), expires)
+		if err != nil {
+			return "", err
+		}
+		sess.storedRevision = revision
+		return sess.id, nil
+	}
+
+	result, err := s.core.Dml(ctx, "session", s.update.Name, s.update.GetCommand(mode),
+		string(data), int64(revision), now.UnixMilli(), expires, sess.id, int64(sess.storedRevision), now.UnixMilli())
+	if err != nil {
+		return "", err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return "", fmt.Errorf("%w: session %s", ErrConflict, sess.id)
+	}
+	sess.storedRevision = revision
+	return sess.id, nil
+}
+
+// Delete removes the session, invalidating its token immediately.
+func (s *SQLStore) Delete(ctx context.Context, token string) error {
+	_, err := s.core.Dml(ctx, "session", s.remove.Name, s.remove.GetCommand(s.core.GetDbMode()), token)
+	return err
+}
+
+// Cleanup deletes expired sessions and returns how many were removed.
+func (s *SQLStore) Cleanup(ctx context.Context) (int64, error) {
+	result, err := s.core.Dml(ctx, "session", s.cleanup.Name, s.cleanup.GetCommand(s.core.GetDbMode()),
+		s.config.Clock().UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+	return n, nil
+}
+
+// RunCleanup calls Cleanup every CleanupInterval until ctx is cancelled
+// and returns ctx.Err(). Failures are logged and retried on the next tick.
+func (s *SQLStore) RunCleanup(ctx context.Context) error {
+	ticker := time.NewTicker(s.config.CleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := s.Cleanup(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("session: cleanup failed", slog.Any("error", err))
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package session
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hmmftg/requestCore/libQuery"
+)
+
+func newSQLStore(t *testing.T, now time.Time) (*SQLStore, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	core := libQuery.Init(db, "prog", "mod", libQuery.Postgres)
+	core.Mode = libQuery.Postgres
+	store := NewSQLStore(core, SQLStoreConfig{
+		MaxAge: time.Hour,
+		Clock:  func() time.Time { return now },
+	})
+	return store, mock
+}
+
+// expectDml registers the audited transaction libQuery.Dml runs around
+// every statement.
+func expectDml(mock sqlmock.Sqlmock, pattern string, rows int64, args ...driver.Value) {
+	mock.ExpectBegin()
+	for i := 0; i < 4; i++ {
+		mock.ExpectExec("set_config").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	exec := mock.ExpectExec(pattern)
+	if len(args) > 0 {
+		exec = exec.WithArgs(args...)
+	}
+	exec.WillReturnResult(sqlmock.NewResult(0, rows))
+	mock.ExpectCommit()
+}
+
+func TestSQLStore_Lifecycle(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	store, mock := newSQLStore(t, now)
+	expires := now.Add(time.Hour).UnixMilli()
+
+	// A new session is inserted and its ID is the token.
+	sess := NewSession(store)
+	sess.Set("user", "alice")
+	expectDml(mock, "INSERT INTO sessions", 1,
+		sess.ID(), `{"user":"alice"}`, int64(1), sess.CreatedAt().UnixMilli(), now.UnixMilli(), expires)
+	token, err := sess.Save(context.Background())
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if token != sess.ID() || sess.IsDirty() {
+		t.Fatalf("unexpected token %q dirty=%v", token, sess.IsDirty())
+	}
+
+	// Later saves update conditionally on the stored revision.
+	sess.Set("role", "admin")
+	expectDml(mock, "UPDATE sessions SET data", 1,
+		`{"role":"admin","user":"alice"}`, int64(2), now.UnixMilli(), expires, sess.ID(), int64(1), now.UnixMilli())
+	if _, err := sess.Save(context.Background()); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	mock.ExpectPrepare("SELECT id, data, revision, created_at FROM sessions").ExpectQuery().
+		WithArgs(token, now.UnixMilli()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "data", "revision", "created_at"}).
+			AddRow(token, `{"role":"admin","user":"alice"}`, int64(2), int64(1600000000000)))
+	loaded, err := store.Load(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if loaded.GetString("user") != "alice" || loaded.storedRevision != 2 || loaded.CreatedAt().UnixMilli() != 1600000000000 {
+		t.Fatalf("unexpected session: %+v", loaded.Data())
+	}
+
+	// A concurrent request already moved the row on: the stale save fails.
+	loaded.Set("cart", 3)
+	expectDml(mock, "UPDATE sessions SET data", 0)
+	if _, err := loaded.Save(context.Background()); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	if !loaded.IsDirty() {
+		t.Fatal("failed save must keep the session dirty")
+	}
+
+	// Logout removes the row.
+	expectDml(mock, "DELETE FROM sessions WHERE id", 1, token)
+	if err := sess.Destroy(context.Background()); err != nil {
+		t.Fatalf("Destroy: %v", err)
+	}
+	mock.ExpectPrepare("SELECT id, data, revision, created_at FROM sessions").ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"id", "data", "revision", "created_at"}))
+	if _, err := store.Load(context.Background(), token); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	expectDml(mock, "DELETE FROM sessions WHERE expires_at", 5, now.UnixMilli())
+	if n, err := store.Cleanup(context.Background()); err != nil || n != 5 {
+		t.Fatalf("Cleanup: n=%d err=%v", n, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
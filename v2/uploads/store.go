@@ -0,0 +1,175 @@
+package uploads
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hmmftg/requestCore/libError"
+	"github.com/hmmftg/requestCore/response"
+	"github.com/hmmftg/requestCore/status"
+)
+
+// FileStore stores uploaded files under keys it chooses. Implementations
+// must be safe for concurrent use.
+type FileStore interface {
+	// Save stores the content read from r and returns its key. name is
+	// the client's cleaned file name, which implementations may use for
+	// metadata such as the extension but must not trust as a path. Save
+	// must return r's read errors wrapped, and store nothing on error.
+	Save(ctx context.Context, name string, r io.Reader) (key string, err error)
+
+	// Open returns the content stored under key.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete removes the content stored under key. Deleting a missing key
+	// is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// keyPattern matches the keys LocalStore generates: 32 hex digits and an
+// optional short extension.
+var keyPattern = regexp.MustCompile(`^[0-9a-f]{32}(\.[a-z0-9]{1,10})?$`)
+
+// LocalStore is a FileStore on the local disk. Files are named with
+// random keys, keeping only a sanitized extension of the client's name,
+// so uploads can neither choose nor overwrite paths, and keys are
+// validated before use, so a key from a request cannot escape the
+// directory.
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore creates a LocalStore in dir, creating the directory if
+// needed.
+func NewLocalStore(dir string) (*LocalStore, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(abs, 0o750); err != nil {
+		return nil, err
+	}
+	return &LocalStore{dir: abs}, nil
+}
+
+// Save implements FileStore. Content is written to a temporary file that
+// is renamed into place once complete, so partial uploads are never
+// visible under a key.
+func (s *LocalStore) Save(ctx context.Context, name string, r io.Reader) (string, error) {
+	var random [16]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		return "", err
+	}
+	key := hex.EncodeToString(random[:]) + extension(name)
+
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := io.Copy(tmp, contextReader{ctx: ctx, r: r}); err != nil {
+		_ = tmp.Close()
+		return "", fmt.Errorf("uploads: save %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0o640); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, key)); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// Open implements FileStore.
+func (s *LocalStore) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p) // #nosec G304 -- key validated by Path
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, notFound(key)
+	}
+	return f, err
+}
+
+// Delete implements FileStore.
+func (s *LocalStore) Delete(_ context.Context, key string) error {
+	p, err := s.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Path returns the path of the file stored under key. Keys not generated
+// by LocalStore fail with 404, so request input can be passed as is.
+func (s *LocalStore) Path(key string) (string, error) {
+	if !keyPattern.MatchString(key) {
+		return "", notFound(key)
+	}
+	return filepath.Join(s.dir, key), nil
+}
+
+// FileResponse returns the attachment response for the file stored under
+// key, downloaded as fileName; return it from a v2 Endpoint with a
+// *response.FileResponse response type, or pass it to the v1
+// Responder's OKWithAttachment. A missing file fails with 404.
+func (s *LocalStore) FileResponse(key, fileName string) (*response.FileResponse, error) {
+	p, err := s.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(p); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, notFound(key)
+		}
+		return nil, err
+	}
+	if fileName = cleanFilename(fileName); fileName == "" {
+		fileName = key
+	}
+	return &response.FileResponse{FileName: fileName, Path: p}, nil
+}
+
+func notFound(key string) error {
+	return libError.NewWithDescription(status.NotFound, "UPLOAD_NOT_FOUND", "no stored file %q", key)
+}
+
+// extension returns name's extension, lower-cased, if it is short and
+// alphanumeric.
+func extension(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if keyPattern.MatchString(strings.Repeat("0", 32) + ext) {
+		return ext
+	}
+	return ""
+}
+
+// contextReader fails reads once ctx is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
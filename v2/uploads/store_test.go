@@ -0,0 +1,64 @@
+package uploads_test
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v2response "github.com/hmmftg/requestCore/v2/response"
+	"github.com/hmmftg/requestCore/v2/uploads"
+)
+
+func TestLocalStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := uploads.NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := store.Save(t.Context(), "../../etc/Report.PDF", strings.NewReader("%PDF-1.7"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(key, ".pdf") || strings.Contains(key, "/") {
+		t.Fatalf("unexpected key %q", key)
+	}
+
+	file, err := store.FileResponse(key, "report.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.FileName != "report.pdf" || file.Path != filepath.Join(dir, key) {
+		t.Fatalf("unexpected file response %+v", file)
+	}
+
+	for _, bad := range []string{"../" + key, "/etc/passwd", key + "/..", "", strings.ToUpper(key)} {
+		if _, err := store.Open(t.Context(), bad); v2response.DefaultStatusResolver(err) != http.StatusNotFound {
+			t.Fatalf("expected 404 for key %q, got %v", bad, err)
+		}
+	}
+
+	if err := store.Delete(t.Context(), key); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(t.Context(), key); err != nil {
+		t.Fatalf("deleting a missing key: %v", err)
+	}
+	if _, err := store.FileResponse(key, "report.pdf"); v2response.DefaultStatusResolver(err) != http.StatusNotFound {
+		t.Fatalf("expected 404 for a deleted key, got %v", err)
+	}
+
+	// A failed save leaves nothing behind.
+	if _, err := store.Save(t.Context(), "a.txt", io.MultiReader(strings.NewReader("partial"), errReader{})); err == nil {
+		t.Fatal("expected the read error")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected an empty store, got %v", entries)
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, io.ErrUnexpectedEOF }
@@ -0,0 +1,493 @@
+// Package uploads binds multipart/form-data requests into typed structs
+// for v2 handlers, storing their files as they stream in.
+//
+// Unlike the v1 parser's FormValue and SaveFile, Bind enforces per-route
+// limits on body, file and field sizes and on the number of files while
+// reading, checks each file's type from its content (its magic bytes)
+// against an allowlist rather than trusting the client's Content-Type or
+// file name, and hashes each file with SHA-256 on the way to a FileStore:
+//
+//	type AvatarForm struct {
+//		Caption string        `form:"caption"`
+//		Avatar  *uploads.File `form:"avatar" validate:"required"`
+//	}
+//
+//	form, err := uploads.Bind[AvatarForm](ctx, uploads.Config{
+//		Store:        store,
+//		Limits:       uploads.Limits{MaxFileSize: 2 << 20, MaxFiles: 1},
+//		AllowedTypes: []string{"image/png", "image/jpeg"},
+//	})
+//
+// Stored files can be sent back with LocalStore.FileResponse and the
+// response.FileResponse attachment path.
+package uploads
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hmmftg/requestCore/libError"
+	"github.com/hmmftg/requestCore/libValidate"
+	"github.com/hmmftg/requestCore/response"
+	"github.com/hmmftg/requestCore/status"
+	"github.com/hmmftg/requestCore/v2/internal/native"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+// Defaults.
+const (
+	// DefaultMaxRequestSize is the default limit on the whole request
+	// body: 32 MiB.
+	DefaultMaxRequestSize = 32 << 20
+	// DefaultMaxFileSize is the default limit on each file: 10 MiB.
+	DefaultMaxFileSize = 10 << 20
+	// DefaultMaxFiles is the default limit on the number of files.
+	DefaultMaxFiles = 10
+	// DefaultMaxFieldSize is the default limit on each non-file value:
+	// 64 KiB.
+	DefaultMaxFieldSize = 64 << 10
+	// DefaultMaxFields is the default limit on the number of non-file
+	// values.
+	DefaultMaxFields = 100
+)
+
+// sniffLen is how many leading bytes are used to detect a file's type,
+// as in http.DetectContentType.
+const sniffLen = 512
+
+// Limits bounds an upload. Zero values select the defaults.
+type Limits struct {
+	// MaxRequestSize limits the whole request body, in bytes.
+	MaxRequestSize int64
+	// MaxFileSize limits each file, in bytes.
+	MaxFileSize int64
+	// MaxFiles limits the number of files.
+	MaxFiles int
+	// MaxFieldSize limits each non-file value, in bytes.
+	MaxFieldSize int64
+	// MaxFields limits the number of non-file values.
+	MaxFields int
+}
+
+// Config configures Bind. Each route passes its own Config, so limits
+// and allowed types are per route.
+type Config struct {
+	// Store receives the files. Required.
+	Store FileStore
+
+	Limits
+
+	// AllowedTypes lists the media types files may have, such as
+	// "application/pdf" or "image/*". Types are detected from the
+	// content. Empty allows any type.
+	AllowedTypes []string
+
+	// Detect, if set, replaces http.DetectContentType for detecting a
+	// file's media type from its first 512 bytes, for example to tell
+	// Office documents from other ZIP archives.
+	Detect func(head []byte) string
+}
+
+// File is an uploaded file, after it has been stored. Bind it with a
+// File, *File or []*File field.
+type File struct {
+	// Field is the form field the file was sent in.
+	Field string
+	// Filename is the client's base file name, for display only. It is
+	// never used to name the stored file.
+	Filename string
+	// ContentType is the media type detected from the content.
+	ContentType string
+	// Size is the file's size in bytes.
+	Size int64
+	// SHA256 is the hex-encoded SHA-256 of the content.
+	SHA256 string
+	// Key identifies the stored file in the FileStore.
+	Key string
+}
+
+var fileType = reflect.TypeOf(File{})
+
+// Bind reads ctx's multipart/form-data body into a new T, storing its
+// files in config.Store, and validates the result with libValidate
+// like libRequest.ParseRequest does. Fields are matched by their form
+// tag; parts without a matching field are discarded unread. Non-file
+// fields may be strings, bools, numbers, pointers to those or slices of
+// them.
+//
+// Failures go through the response registry: 415 for a body that is
+// not multipart or a disallowed file type, 413 when a limit is exceeded
+// and 400 for a malformed body or invalid fields. Files stored before a
+// failure are deleted. On success the files belong to the caller, who
+// should delete them if the request fails later.
+//
+// On Fiber, fasthttp reads the whole body before the handler runs, so
+// raise fiber.Config.BodyLimit (4 MiB by default) to MaxRequestSize.
+func Bind[T any](ctx *v2wf.RequestContext, config Config) (*T, error) {
+	target := new(T)
+	v := reflect.ValueOf(target).Elem()
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("uploads: Bind needs a struct, got %T", *target)
+	}
+	if config.Store == nil {
+		return nil, errors.New("uploads: Config.Store is required")
+	}
+	b := newBinder(ctx.Context, config, v)
+	if err := b.read(ctx); err != nil {
+		b.cleanup()
+		return nil, err
+	}
+	libValidate.Init()
+	errInvalid, errValidate := libValidate.ValidateStruct(target)
+	if errInvalid != nil {
+		b.cleanup()
+		return nil, errors.Join(libError.NewWithDescription(status.InternalServerError, "INVALID_VALIDATION", "invalid upload validation for %T", *target), errInvalid)
+	}
+	if errValidate != nil {
+		b.cleanup()
+		return nil, libError.New(status.BadRequest, "VALIDATION_FAILED", response.FormatErrorResp(errValidate, libValidate.GetTranslator()))
+	}
+	return target, nil
+}
+
+// binder carries the state of one Bind call.
+type binder struct {
+	ctx    context.Context
+	config Config
+	fields map[string]reflect.Value
+	stored []string
+	files  int
+	values int
+}
+
+func newBinder(ctx context.Context, config Config, v reflect.Value) *binder {
+	if config.MaxRequestSize <= 0 {
+		config.MaxRequestSize = DefaultMaxRequestSize
+	}
+	if config.MaxFileSize <= 0 {
+		config.MaxFileSize = DefaultMaxFileSize
+	}
+	if config.MaxFiles <= 0 {
+		config.MaxFiles = DefaultMaxFiles
+	}
+	if config.MaxFieldSize <= 0 {
+		config.MaxFieldSize = DefaultMaxFieldSize
+	}
+	if config.MaxFields <= 0 {
+		config.MaxFields = DefaultMaxFields
+	}
+	if config.Detect == nil {
+		config.Detect = http.DetectContentType
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	fields := map[string]reflect.Value{}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("form"), ",")
+		if !f.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		fields[name] = v.Field(i)
+	}
+	return &binder{ctx: ctx, config: config, fields: fields}
+}
+
+func (b *binder) read(ctx *v2wf.RequestContext) error {
+	mediaType, params, err := mime.ParseMediaType(ctx.Parser.GetHeaderValue("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return libError.NewWithDescription(status.UnsupportedMediaType, "UPLOAD_NOT_MULTIPART",
+			"expected multipart/form-data, got %q", ctx.Parser.GetHeaderValue("Content-Type"))
+	}
+	req, ok := native.FromContext(ctx)
+	if !ok {
+		return libError.NewWithDescription(status.InternalServerError, "UPLOAD_UNSUPPORTED",
+			"request body is not available")
+	}
+	body := &limitReader{r: req.Body(), n: b.config.MaxRequestSize, err: errRequestTooLarge}
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return b.readError(err)
+		}
+		err = b.readPart(part)
+		_ = part.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (b *binder) readPart(part *multipart.Part) error {
+	name := part.FormName()
+	field, ok := b.fields[name]
+	if !ok {
+		return nil
+	}
+	if part.FileName() == "" {
+		if isFileField(field.Type()) {
+			// Browsers send an empty part for an empty file input.
+			return nil
+		}
+		return b.readValue(name, field, part)
+	}
+	if !isFileField(field.Type()) {
+		return libError.NewWithDescription(status.BadRequest, "UPLOAD_UNEXPECTED_FILE",
+			"field %s does not accept files", name)
+	}
+	file, err := b.storeFile(name, part)
+	if err != nil {
+		return err
+	}
+	switch {
+	case field.Kind() == reflect.Slice:
+		field.Set(reflect.Append(field, reflect.ValueOf(file)))
+	case field.Kind() == reflect.Pointer:
+		field.Set(reflect.ValueOf(file))
+	default:
+		field.Set(reflect.ValueOf(*file))
+	}
+	return nil
+}
+
+func (b *binder) readValue(name string, field reflect.Value, part *multipart.Part) error {
+	b.values++
+	if b.values > b.config.MaxFields {
+		return libError.NewWithDescription(status.PayloadTooLarge, "UPLOAD_TOO_MANY_FIELDS",
+			"more than %d form fields", b.config.MaxFields)
+	}
+	raw, err := io.ReadAll(&limitReader{r: part, n: b.config.MaxFieldSize, err: errFieldTooLarge})
+	if err != nil {
+		return b.readError(err)
+	}
+	if err := setValue(field, string(raw)); err != nil {
+		return libError.NewWithDescription(status.BadRequest, "UPLOAD_INVALID_FIELD",
+			"field %s: %v", name, err)
+	}
+	return nil
+}
+
+func (b *binder) storeFile(name string, part *multipart.Part) (*File, error) {
+	b.files++
+	if b.files > b.config.MaxFiles {
+		return nil, libError.NewWithDescription(status.PayloadTooLarge, "UPLOAD_TOO_MANY_FILES",
+			"more than %d files", b.config.MaxFiles)
+	}
+	limited := &limitReader{r: part, n: b.config.MaxFileSize, err: errFileTooLarge}
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(limited, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, b.readError(err)
+	}
+	head = head[:n]
+	contentType, _, _ := mime.ParseMediaType(b.config.Detect(head))
+	if !b.allowed(contentType) {
+		return nil, libError.NewWithDescription(status.UnsupportedMediaType, "UPLOAD_TYPE_NOT_ALLOWED",
+			"file %s has type %s, allowed: %s", name, contentType, strings.Join(b.config.AllowedTypes, ", "))
+	}
+
+	file := &File{Field: name, Filename: cleanFilename(part.FileName()), ContentType: contentType}
+	hash := sha256.New()
+	counted := &countReader{r: io.MultiReader(bytes.NewReader(head), limited)}
+	key, err := b.config.Store.Save(b.ctx, file.Filename, io.TeeReader(counted, hash))
+	if err != nil {
+		if counted.err != nil {
+			// Reading the upload failed, not the store.
+			return nil, b.readError(counted.err)
+		}
+		return nil, errors.Join(err, libError.NewWithDescription(status.InternalServerError, "UPLOAD_STORE_FAILED",
+			"unable to store file %s", name))
+	}
+	b.stored = append(b.stored, key)
+	file.Key = key
+	file.Size = counted.n
+	file.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return file, nil
+}
+
+func (b *binder) allowed(contentType string) bool {
+	if len(b.config.AllowedTypes) == 0 {
+		return true
+	}
+	for _, allowed := range b.config.AllowedTypes {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == contentType || allowed == "*/*" ||
+			(strings.HasSuffix(allowed, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(allowed, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanup deletes the files stored by a failed Bind.
+func (b *binder) cleanup() {
+	ctx := context.WithoutCancel(b.ctx)
+	for _, key := range b.stored {
+		_ = b.config.Store.Delete(ctx, key)
+	}
+}
+
+// readError maps a failure reading the body to a libError.
+func (b *binder) readError(err error) error {
+	switch {
+	case errors.Is(err, errRequestTooLarge):
+		return libError.NewWithDescription(status.PayloadTooLarge, "UPLOAD_TOO_LARGE",
+			"request body exceeds %d bytes", b.config.MaxRequestSize)
+	case errors.Is(err, errFileTooLarge):
+		return libError.NewWithDescription(status.PayloadTooLarge, "UPLOAD_FILE_TOO_LARGE",
+			"file exceeds %d bytes", b.config.MaxFileSize)
+	case errors.Is(err, errFieldTooLarge):
+		return libError.NewWithDescription(status.PayloadTooLarge, "UPLOAD_FIELD_TOO_LARGE",
+			"form field exceeds %d bytes", b.config.MaxFieldSize)
+	}
+	return errors.Join(err, libError.NewWithDescription(status.BadRequest, "UPLOAD_MALFORMED",
+		"malformed multipart body"))
+}
+
+var (
+	errRequestTooLarge = errors.New("uploads: request too large")
+	errFileTooLarge    = errors.New("uploads: file too large")
+	errFieldTooLarge   = errors.New("uploads: field too large")
+)
+
+// limitReader reads at most n bytes from r and fails with err if r has
+// more.
+type limitReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, l.err
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n + int(l.n), l.err
+	}
+	return n, err
+}
+
+// countReader counts the bytes read through it and records the first
+// read error other than io.EOF.
+type countReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if err != nil && !errors.Is(err, io.EOF) && c.err == nil {
+		c.err = err
+	}
+	return n, err
+}
+
+func isFileField(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t == fileType
+}
+
+// setValue parses raw into v, appending to slices.
+func setValue(v reflect.Value, raw string) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if err := setValue(elem.Elem(), raw); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Slice:
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := setValue(elem, raw); err != nil {
+			return err
+		}
+		v.Set(reflect.Append(v, elem))
+		return nil
+	case reflect.String:
+		v.SetString(raw)
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+		return nil
+	}
+	return fmt.Errorf("unsupported field type %s", v.Type())
+}
+
+// cleanFilename reduces a client file name to a printable base name of
+// at most 255 bytes.
+func cleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
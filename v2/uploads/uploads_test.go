@@ -0,0 +1,205 @@
+package uploads_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hmmftg/requestCore/response"
+
+	v2libNetHttp "github.com/hmmftg/requestCore/v2/libNetHttp"
+	"github.com/hmmftg/requestCore/v2/renderers"
+	v2response "github.com/hmmftg/requestCore/v2/response"
+	"github.com/hmmftg/requestCore/v2/uploads"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+type avatarForm struct {
+	Caption string          `form:"caption" validate:"required"`
+	Tags    []string        `form:"tag"`
+	Avatar  *uploads.File   `form:"avatar" validate:"required"`
+	Extra   []*uploads.File `form:"extra"`
+}
+
+type part struct {
+	field, filename string
+	content         []byte
+}
+
+func multipartBody(t *testing.T, parts ...part) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, p := range parts {
+		var err error
+		if p.filename == "" {
+			err = w.WriteField(p.field, string(p.content))
+		} else {
+			var fw io.Writer
+			fw, err = w.CreateFormFile(p.field, p.filename)
+			if err == nil {
+				_, err = fw.Write(p.content)
+			}
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &body, w.FormDataContentType()
+}
+
+// newServer routes POST /avatar to Bind[avatarForm] and replies with the
+// bound form as JSON.
+func newServer(t *testing.T, config uploads.Config) http.Handler {
+	t.Helper()
+	router := v2libNetHttp.NewRouter()
+	registry := v2response.NewRegistry(nil)
+	registry.SetFallback(v2response.LegacyFallback(response.WebHanlder{
+		MessageDesc: make(map[string]string),
+		ErrorDesc:   make(map[string]string),
+	}))
+	router.SetErrorHandler(v2response.NewHandler(registry, renderers.JSONRenderer{}, response.WebHanlder{}))
+	_ = router.Post("/avatar", func(ctx *v2wf.RequestContext) error {
+		form, err := uploads.Bind[avatarForm](ctx, config)
+		if err != nil {
+			return err
+		}
+		body, _ := json.Marshal(form)
+		return ctx.Parser.SendResponse(http.StatusOK, "application/json", body)
+	})
+	return router.Native().(http.Handler)
+}
+
+func post(handler http.Handler, body *bytes.Buffer, contentType string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/avatar", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func storedFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestBind(t *testing.T) {
+	dir := t.TempDir()
+	store, err := uploads.NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := newServer(t, uploads.Config{Store: store, AllowedTypes: []string{"image/*"}})
+
+	content := append(bytes.Repeat(pngHeader, 100), "tail"...)
+	body, contentType := multipartBody(t,
+		part{field: "caption", content: []byte("me")},
+		part{field: "tag", content: []byte("a")},
+		part{field: "tag", content: []byte("b")},
+		part{field: "ignored", filename: "x.bin", content: []byte("not read")},
+		part{field: "avatar", filename: `..\..\evil.PNG`, content: content},
+	)
+	w := post(handler, body, contentType)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var form avatarForm
+	if err := json.Unmarshal(w.Body.Bytes(), &form); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+	a := form.Avatar
+	if form.Caption != "me" || strings.Join(form.Tags, ",") != "a,b" || a == nil ||
+		a.Filename != "evil.PNG" || a.ContentType != "image/png" || a.Size != int64(len(content)) ||
+		a.SHA256 != hex.EncodeToString(sum[:]) || !strings.HasSuffix(a.Key, ".png") {
+		t.Fatalf("unexpected form %+v / %+v", form, a)
+	}
+	rc, err := store.Open(t.Context(), a.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	var stored bytes.Buffer
+	_, _ = stored.ReadFrom(rc)
+	if !bytes.Equal(stored.Bytes(), content) {
+		t.Fatal("stored content differs")
+	}
+	if files := storedFiles(t, dir); len(files) != 1 {
+		t.Fatalf("expected only the avatar to be stored, got %v", files)
+	}
+}
+
+func TestBind_Rejected(t *testing.T) {
+	png := append(bytes.Repeat(pngHeader, 10), "x"...)
+	tests := []struct {
+		name   string
+		limits uploads.Limits
+		parts  []part
+		status int
+		code   string
+	}{
+		{"type sniffed from content", uploads.Limits{},
+			[]part{{field: "caption", content: []byte("me")}, {field: "avatar", filename: "a.png", content: []byte("<html><script>alert(1)</script>")}},
+			http.StatusUnsupportedMediaType, "UPLOAD-TYPE-NOT-ALLOWED"},
+		{"file too large", uploads.Limits{MaxFileSize: int64(len(png)) - 1},
+			[]part{{field: "caption", content: []byte("me")}, {field: "avatar", filename: "a.png", content: png}},
+			http.StatusRequestEntityTooLarge, "UPLOAD-FILE-TOO-LARGE"},
+		{"too many files", uploads.Limits{MaxFiles: 2},
+			[]part{{field: "avatar", filename: "a.png", content: png}, {field: "extra", filename: "b.png", content: png}, {field: "extra", filename: "c.png", content: png}},
+			http.StatusRequestEntityTooLarge, "UPLOAD-TOO-MANY-FILES"},
+		{"request too large", uploads.Limits{MaxRequestSize: 100},
+			[]part{{field: "caption", content: []byte("me")}, {field: "avatar", filename: "a.png", content: png}},
+			http.StatusRequestEntityTooLarge, "UPLOAD-TOO-LARGE"},
+		{"field too large", uploads.Limits{MaxFieldSize: 3},
+			[]part{{field: "caption", content: []byte("long caption")}},
+			http.StatusRequestEntityTooLarge, "UPLOAD-FIELD-TOO-LARGE"},
+		{"validation", uploads.Limits{},
+			[]part{{field: "avatar", filename: "a.png", content: png}},
+			http.StatusBadRequest, "REQUIRED-FIELD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := uploads.NewLocalStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			handler := newServer(t, uploads.Config{Store: store, Limits: tt.limits, AllowedTypes: []string{"image/png"}})
+			body, contentType := multipartBody(t, tt.parts...)
+			w := post(handler, body, contentType)
+			if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.code) {
+				t.Fatalf("expected %d %s, got %d: %s", tt.status, tt.code, w.Code, w.Body)
+			}
+			if files := storedFiles(t, dir); len(files) != 0 {
+				t.Fatalf("expected stored files to be deleted, got %v", files)
+			}
+		})
+	}
+
+	store, _ := uploads.NewLocalStore(t.TempDir())
+	w := post(newServer(t, uploads.Config{Store: store}), bytes.NewBufferString(`{}`), "application/json")
+	if w.Code != http.StatusUnsupportedMediaType || !strings.Contains(w.Body.String(), "UPLOAD-NOT-MULTIPART") {
+		t.Fatalf("expected 415 UPLOAD_NOT_MULTIPART, got %d: %s", w.Code, w.Body)
+	}
+}
@@ -1,6 +1,8 @@
 package webFramework
 
 import (
+	"bytes"
+	"context"
 	"net/http"
 
 	legacy "github.com/hmmftg/requestCore/webFramework"
@@ -136,3 +138,53 @@ func (f *FakeParserV2) Reset() {
 	f.ResponseWritten = false
 	f.SetCookies = nil
 }
+
+// StreamEvents runs fn synchronously and captures the encoded events in
+// ResponseBody. LastEventID is read from ReqHeader.
+func (f *FakeParserV2) StreamEvents(opts EventStreamOptions, fn func(EventStream) error) error {
+	if f.commitState != nil && f.commitState.Committed() {
+		return nil
+	}
+	if f.hookRunner != nil {
+		f.HooksRan = true
+		if err := f.hookRunner(); err != nil {
+			return err
+		}
+	}
+	SetEventStreamHeaders(f.SetRespHeader)
+	f.ResponseStatus = http.StatusOK
+	f.ResponseContentType = "text/event-stream"
+	f.ResponseWritten = true
+	if f.commitState != nil {
+		f.commitState.MarkCommitted(http.StatusOK)
+	}
+	var body bytes.Buffer
+	stream := NewEventStreamWriter(context.Background(), &body, nil, f.GetHeaderValue(HeaderLastEventID))
+	err := stream.Run(opts, fn)
+	f.ResponseBody = body.Bytes()
+	return err
+}
+
+// StreamResponse runs fn synchronously and captures what it writes in
+// ResponseBody.
+func (f *FakeParserV2) StreamResponse(status int, contentType string, fn func(ResponseStream) error) error {
+	if f.commitState != nil && f.commitState.Committed() {
+		return nil
+	}
+	if f.hookRunner != nil {
+		f.HooksRan = true
+		if err := f.hookRunner(); err != nil {
+			return err
+		}
+	}
+	f.ResponseStatus = status
+	f.ResponseContentType = contentType
+	f.ResponseWritten = true
+	if f.commitState != nil {
+		f.commitState.MarkCommitted(status)
+	}
+	var body bytes.Buffer
+	err := NewResponseStreamWriter(context.Background(), &body, nil).Run(fn)
+	f.ResponseBody = body.Bytes()
+	return err
+}
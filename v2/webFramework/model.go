@@ -9,6 +9,8 @@ package webFramework
 import (
 	"context"
 	"net/http"
+	"slices"
+	"strings"
 	"sync"
 
 	legacy "github.com/hmmftg/requestCore/webFramework"
@@ -60,6 +62,31 @@ type RequestParser interface {
 	// first invocation. Hook errors are logged inside the runner and do
 	// not block the write.
 	SetBeforeCommitHookRunner(fn func() error)
+
+	// StreamEvents responds with a text/event-stream and calls fn to send
+	// server-sent events until it returns or the client disconnects.
+	// Like SendResponse it returns nil without writing if the response
+	// is already committed, runs the before-commit hooks, and marks the
+	// CommitState committed with 200 before fn sends anything, so error
+	// dispatch never writes over a stream.
+	//
+	// On gin and net/http StreamEvents blocks until fn returns and
+	// returns its error. On Fiber, fasthttp streams bodies after the
+	// handler returns, so StreamEvents returns immediately and fn runs
+	// later; its error is logged. fn must therefore only use the
+	// EventStream, not the request's parser or context.
+	StreamEvents(opts EventStreamOptions, fn func(EventStream) error) error
+
+	// StreamResponse responds with status and contentType and calls fn to
+	// write the body incrementally, for responses too large to buffer.
+	// It follows StreamEvents' commit rules and, like it, runs fn after
+	// the handler returns on Fiber. Writes block while the client is not
+	// reading and fail once it disconnects.
+	//
+	// If fn fails after the status is sent, adapters abort the
+	// connection where the transport allows it, so clients see a
+	// truncated response instead of a complete one.
+	StreamResponse(status int, contentType string, fn func(ResponseStream) error) error
 }
 
 // RequestContext holds the per-request state passed through the v2
@@ -111,6 +138,9 @@ type RequestContext struct {
 	// per request, whether called from the parser's SendResponse or from
 	// response.Handler.commit.
 	hooksRan bool
+
+	// vary accumulates the Vary header fields added with AddVary.
+	vary []string
 }
 
 // LegacyWebFramework returns the v1 WebFramework for use with existing
@@ -119,9 +149,66 @@ func (c *RequestContext) LegacyWebFramework() legacy.WebFramework {
 	return c.Legacy
 }
 
+// ContextOrBackground returns Context, or context.Background when it is
+// nil, for middleware passing the request context to stores and lockers.
+func (c *RequestContext) ContextOrBackground() context.Context {
+	if c.Context != nil {
+		return c.Context
+	}
+	return context.Background()
+}
+
+// AddVary adds fields to the response's Vary header. Parsers can only
+// set whole headers, so middleware that each select on a different
+// request header (API versioning, content negotiation) must use AddVary
+// rather than SetRespHeader to avoid replacing each other's fields.
+func (c *RequestContext) AddVary(fields ...string) {
+	if c.Parser == nil {
+		return
+	}
+	for _, f := range fields {
+		if !slices.ContainsFunc(c.vary, func(v string) bool { return strings.EqualFold(v, f) }) {
+			c.vary = append(c.vary, f)
+		}
+	}
+	c.Parser.SetRespHeader("Vary", strings.Join(c.vary, ", "))
+}
+
 // WebFrameworkV2 is a convenience wrapper that bundles a RequestContext
 // with its parser and legacy framework. It is used by response handlers
 // and error handlers that need both v1 and v2 access.
 type WebFrameworkV2 struct {
 	RequestContext
 }
+
+// requestContextKey is the context key under which adapters store the
+// active RequestContext.
+type requestContextKey struct{}
+
+// NewContext returns a copy of ctx carrying rc. Adapters store the
+// RequestContext in its own Context so that code which only receives a
+// context.Context (for example workers.Worker.Submit) can still reach the
+// request's parser and headers.
+func NewContext(ctx context.Context, rc *RequestContext) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, rc)
+}
+
+// DetachContext returns ctx with the RequestContext stored by NewContext
+// hidden and every other value kept, for work that outlives the request.
+// The RequestContext wraps the framework's pooled context, which is
+// recycled once the response is written.
+func DetachContext(ctx context.Context) context.Context {
+	if _, ok := FromContext(ctx); !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, requestContextKey{}, (*RequestContext)(nil))
+}
+
+// FromContext returns the RequestContext stored in ctx by NewContext.
+func FromContext(ctx context.Context) (*RequestContext, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	rc, ok := ctx.Value(requestContextKey{}).(*RequestContext)
+	return rc, ok && rc != nil
+}
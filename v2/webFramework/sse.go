@@ -0,0 +1,198 @@
+package webFramework
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HeaderLastEventID is the request header in which reconnecting
+// EventSource clients send the ID of the last event they received.
+const HeaderLastEventID = "Last-Event-ID"
+
+// Event is a server-sent event. Empty fields are omitted from the
+// stream; an event with only Retry or Comment set does not dispatch a
+// message on the client.
+type Event struct {
+	// ID is stored by the client and sent back as Last-Event-ID when it
+	// reconnects.
+	ID string
+
+	// Event is the event type; clients dispatch untyped events as
+	// "message".
+	Event string
+
+	// Data is the payload. Multi-line data is sent as one data field per
+	// line and reassembled by the client.
+	Data string
+
+	// Retry sets the client's reconnection delay.
+	Retry time.Duration
+
+	// Comment is sent as a comment line, which clients ignore.
+	Comment string
+}
+
+// encode appends the wire format of e to b.
+func (e Event) encode(b *bytes.Buffer) {
+	if e.Comment != "" {
+		for _, line := range splitLines(e.Comment) {
+			b.WriteString(": ")
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	if e.ID != "" {
+		b.WriteString("id: ")
+		b.WriteString(singleLine(e.ID))
+		b.WriteByte('\n')
+	}
+	if e.Event != "" {
+		b.WriteString("event: ")
+		b.WriteString(singleLine(e.Event))
+		b.WriteByte('\n')
+	}
+	if e.Retry > 0 {
+		b.WriteString("retry: ")
+		b.WriteString(strconv.FormatInt(e.Retry.Milliseconds(), 10))
+		b.WriteByte('\n')
+	}
+	if e.Data != "" || e.Event != "" {
+		for _, line := range splitLines(e.Data) {
+			b.WriteString("data: ")
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	b.WriteByte('\n')
+}
+
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.Split(s, "\n")
+}
+
+// singleLine drops line breaks, which would end an id or event field
+// early.
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+// EventStreamOptions configures RequestParser.StreamEvents.
+type EventStreamOptions struct {
+	// Heartbeat is the interval at which a comment is sent to keep idle
+	// connections open through proxies and to detect disconnected
+	// clients. Zero disables heartbeats.
+	Heartbeat time.Duration
+
+	// Retry, if set, is sent before any event as the client's
+	// reconnection delay.
+	Retry time.Duration
+}
+
+// EventStream sends server-sent events to a client.
+type EventStream interface {
+	// Send writes and flushes one event. It fails once the client has
+	// disconnected.
+	Send(event Event) error
+
+	// Context is cancelled when the client disconnects, a write fails or
+	// the stream ends. Producers should stop when it is done.
+	Context() context.Context
+
+	// LastEventID returns the Last-Event-ID request header, so a
+	// reconnecting client can be resumed after the last event it saw.
+	LastEventID() string
+}
+
+// SetEventStreamHeaders sets the response headers of an event stream
+// with set. Adapters call it from StreamEvents before writing the
+// status.
+func SetEventStreamHeaders(set func(name, value string)) {
+	set("Content-Type", "text/event-stream")
+	set("Cache-Control", "no-cache")
+	// Disable response buffering in nginx.
+	set("X-Accel-Buffering", "no")
+}
+
+// EventStreamWriter implements EventStream over a ResponseStreamWriter.
+// Adapters use it to implement StreamEvents. It is safe for concurrent
+// use, so heartbeats may interleave with events.
+type EventStreamWriter struct {
+	mu          sync.Mutex
+	stream      *ResponseStreamWriter
+	buf         bytes.Buffer
+	lastEventID string
+}
+
+// NewEventStreamWriter creates an EventStreamWriter writing to w and
+// calling flush, if non-nil, after every event. Its context is derived
+// from ctx, which should be cancelled when the client disconnects.
+func NewEventStreamWriter(ctx context.Context, w io.Writer, flush func() error, lastEventID string) *EventStreamWriter {
+	return &EventStreamWriter{stream: NewResponseStreamWriter(ctx, w, flush), lastEventID: lastEventID}
+}
+
+// Send implements EventStream. A failed write cancels the context.
+func (s *EventStreamWriter) Send(event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf.Reset()
+	event.encode(&s.buf)
+	if _, err := s.stream.Write(s.buf.Bytes()); err != nil {
+		return err
+	}
+	return s.stream.Flush()
+}
+
+// Context implements EventStream.
+func (s *EventStreamWriter) Context() context.Context {
+	return s.stream.Context()
+}
+
+// LastEventID implements EventStream.
+func (s *EventStreamWriter) LastEventID() string {
+	return s.lastEventID
+}
+
+// Run flushes the response headers, sends opts.Retry, then calls fn with
+// heartbeats running in the background. When fn returns the heartbeats
+// stop and the context is cancelled; fn's error is returned.
+func (s *EventStreamWriter) Run(opts EventStreamOptions, fn func(EventStream) error) error {
+	return s.stream.Run(func(ResponseStream) error {
+		if opts.Retry > 0 {
+			if err := s.Send(Event{Retry: opts.Retry}); err != nil {
+				return err
+			}
+		}
+		if opts.Heartbeat > 0 {
+			done := make(chan struct{})
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ticker := time.NewTicker(opts.Heartbeat)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-done:
+						return
+					case <-s.Context().Done():
+						return
+					case <-ticker.C:
+						_ = s.Send(Event{Comment: "heartbeat"})
+					}
+				}
+			}()
+			defer func() {
+				close(done)
+				wg.Wait()
+			}()
+		}
+		return fn(s)
+	})
+}
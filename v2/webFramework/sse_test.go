@@ -0,0 +1,85 @@
+package webFramework
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvent_Encode(t *testing.T) {
+	cases := []struct {
+		event Event
+		want  string
+	}{
+		{Event{Data: "hello"}, "data: hello\n\n"},
+		{Event{ID: "1", Event: "status", Data: "a\nb\r\nc"}, "id: 1\nevent: status\ndata: a\ndata: b\ndata: c\n\n"},
+		{Event{Event: "ping"}, "event: ping\ndata: \n\n"},
+		{Event{ID: "2\n3", Retry: 1500 * time.Millisecond}, "id: 23\nretry: 1500\n\n"},
+		{Event{Comment: "keep"}, ": keep\n\n"},
+	}
+	for _, c := range cases {
+		var b bytes.Buffer
+		c.event.encode(&b)
+		if b.String() != c.want {
+			t.Errorf("encode(%+v) = %q, want %q", c.event, b.String(), c.want)
+		}
+	}
+}
+
+// failingWriter fails every write after the first n.
+type failingWriter struct {
+	bytes.Buffer
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errors.New("broken pipe")
+	}
+	w.n--
+	return w.Buffer.Write(p)
+}
+
+func TestEventStreamWriter_Run(t *testing.T) {
+	w := &failingWriter{n: 3}
+	stream := NewEventStreamWriter(context.Background(), w, nil, "41")
+	err := stream.Run(EventStreamOptions{Heartbeat: time.Millisecond}, func(s EventStream) error {
+		if s.LastEventID() != "41" {
+			t.Errorf("unexpected LastEventID %q", s.LastEventID())
+		}
+		if err := s.Send(Event{ID: "42", Data: "x"}); err != nil {
+			return err
+		}
+		<-s.Context().Done()
+		return context.Cause(s.Context())
+	})
+	if err == nil || err.Error() != "broken pipe" {
+		t.Fatalf("expected the write failure, got %v", err)
+	}
+	if !strings.Contains(w.String(), "id: 42\ndata: x\n\n") || !strings.Contains(w.String(), ": heartbeat\n\n") {
+		t.Fatalf("unexpected stream %q", w.String())
+	}
+	if stream.Send(Event{Data: "late"}) == nil {
+		t.Fatal("expected Send to fail after the stream ended")
+	}
+}
+
+func TestFakeParserV2_StreamEvents(t *testing.T) {
+	parser := NewFakeParserV2()
+	commit := &CommitState{}
+	parser.SetCommitState(commit)
+	parser.ReqHeader[HeaderLastEventID] = "7"
+	err := parser.StreamEvents(EventStreamOptions{}, func(s EventStream) error {
+		return s.Send(Event{ID: "8", Data: s.LastEventID()})
+	})
+	if err != nil || !commit.Committed() || parser.RespHeader["Content-Type"] != "text/event-stream" ||
+		string(parser.ResponseBody) != "id: 8\ndata: 7\n\n" {
+		t.Fatalf("unexpected stream %v %q %v", err, parser.ResponseBody, parser.RespHeader)
+	}
+	if err := parser.SendResponse(500, "text/plain", []byte("late")); err != nil || parser.ResponseStatus != 200 {
+		t.Fatalf("expected SendResponse to be skipped after streaming, got %d", parser.ResponseStatus)
+	}
+}
@@ -0,0 +1,91 @@
+package webFramework
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// ResponseStream is the writer RequestParser.StreamResponse hands its
+// callback. Writes block while the client is not reading, so producers
+// are slowed to the client's pace, and fail once it has disconnected.
+type ResponseStream interface {
+	io.Writer
+
+	// Flush sends buffered output to the client.
+	Flush() error
+
+	// Context is cancelled when the client disconnects, a write fails or
+	// the stream ends. Its cause is the failed write's error. Producers
+	// such as database queries should use it so they stop with the
+	// stream.
+	Context() context.Context
+}
+
+// ResponseStreamWriter implements ResponseStream over a response writer.
+// Adapters use it to implement StreamResponse and StreamEvents. It is
+// safe for concurrent use.
+type ResponseStreamWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	flush  func() error
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+}
+
+// NewResponseStreamWriter creates a ResponseStreamWriter writing to w and
+// calling flush, if non-nil, on Flush. Its context is derived from ctx,
+// which should be cancelled when the client disconnects.
+func NewResponseStreamWriter(ctx context.Context, w io.Writer, flush func() error) *ResponseStreamWriter {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &ResponseStreamWriter{w: w, flush: flush, ctx: ctx, cancel: cancel}
+}
+
+// Write implements io.Writer. A failed write cancels the context.
+func (s *ResponseStreamWriter) Write(p []byte) (int, error) {
+	if s.ctx.Err() != nil {
+		return 0, context.Cause(s.ctx)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, err := s.w.Write(p)
+	if err != nil {
+		s.cancel(err)
+	}
+	return n, err
+}
+
+// Flush implements ResponseStream. A failed flush cancels the context.
+func (s *ResponseStreamWriter) Flush() error {
+	if s.ctx.Err() != nil {
+		return context.Cause(s.ctx)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.flush == nil {
+		return nil
+	}
+	if err := s.flush(); err != nil {
+		s.cancel(err)
+		return err
+	}
+	return nil
+}
+
+// Context implements ResponseStream.
+func (s *ResponseStreamWriter) Context() context.Context {
+	return s.ctx
+}
+
+// Run flushes the response headers, calls fn and flushes what it wrote.
+// The context is cancelled when Run returns; fn's error is returned.
+func (s *ResponseStreamWriter) Run(fn func(ResponseStream) error) error {
+	defer s.cancel(context.Canceled)
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	if err := fn(s); err != nil {
+		return err
+	}
+	return s.Flush()
+}
@@ -0,0 +1,94 @@
+package workers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hmmftg/requestCore/v2/renderers"
+	"github.com/hmmftg/requestCore/v2/routing"
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+)
+
+// Failure describes a job that exhausted its attempts.
+type Failure struct {
+	Job       string    `json:"job"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+	FailedAt  time.Time `json:"failedAt"`
+}
+
+// FailureReporter is implemented by workers that keep a history of
+// recent terminal failures, such as InProcessWorker.
+type FailureReporter interface {
+	RecentFailures() []Failure
+}
+
+// failureRing is a fixed-capacity ring buffer of failures.
+type failureRing struct {
+	mu    sync.Mutex
+	items []Failure
+	next  int
+	full  bool
+}
+
+func newFailureRing(capacity int) *failureRing {
+	return &failureRing{items: make([]Failure, capacity)}
+}
+
+func (r *failureRing) add(f Failure) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.items[r.next] = f
+	r.next = (r.next + 1) % len(r.items)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns the buffered failures, newest first.
+func (r *failureRing) list() []Failure {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.next
+	if r.full {
+		n = len(r.items)
+	}
+	out := make([]Failure, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, r.items[(r.next-i+len(r.items))%len(r.items)])
+	}
+	return out
+}
+
+// RegisterAdminRoutes registers read-only JSON endpoints for the worker
+// pool on the given route group:
+//
+//	GET <group>/stats    pool Stats
+//	GET <group>/failures recent terminal failures (if the worker
+//	                     implements FailureReporter)
+//
+// The routes expose job names, error text, and request IDs; mount them on
+// a group protected by authentication middleware.
+func RegisterAdminRoutes(group routing.RouteGroup, worker Worker) error {
+	render := func(ctx *v2wf.RequestContext, data any) error {
+		body, err := renderers.JSONRenderer{}.Encode(data)
+		if err != nil {
+			return err
+		}
+		return ctx.Parser.SendResponse(http.StatusOK, renderers.JSONRenderer{}.ContentType(), body)
+	}
+	if err := group.Get("/stats", func(ctx *v2wf.RequestContext) error {
+		return render(ctx, worker.Stats())
+	}); err != nil {
+		return err
+	}
+	return group.Get("/failures", func(ctx *v2wf.RequestContext) error {
+		failures := []Failure{}
+		if r, ok := worker.(FailureReporter); ok {
+			failures = r.RecentFailures()
+		}
+		return render(ctx, failures)
+	})
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"sync"
 
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/hmmftg/requestCore/webFramework"
@@ -32,6 +34,11 @@ type BackgroundParser struct {
 
 	// ctx is the job context for tracing propagation.
 	ctx context.Context
+
+	// origin is the submitting request's identity, served as request
+	// headers so helpers that read Request-Id/User-Id/Program-Id from
+	// the parser keep working inside jobs.
+	origin Origin
 }
 
 // newBackgroundParser creates a BackgroundParser bound to the given sink
@@ -94,18 +101,35 @@ func (p *BackgroundParser) SetContext(ctx context.Context) {
 	p.ctx = ctx
 }
 
-// GetTraceContext returns the trace span context from the parser.
+// GetHeaderValue returns the submitting request's Request-Id, User-Id,
+// or Program-Id header captured at Submit time. Other headers are empty.
+func (p *BackgroundParser) GetHeaderValue(name string) string {
+	v, _ := p.origin.header(http.CanonicalHeaderKey(name))
+	return v
+}
+
+// GetHTTPHeader returns the captured request identity headers.
+func (p *BackgroundParser) GetHTTPHeader() http.Header {
+	h := http.Header{}
+	for _, name := range []string{RequestIDHeader, UserIDHeader, ProgramIDHeader} {
+		if v, ok := p.origin.header(name); ok {
+			h.Set(name, v)
+		}
+	}
+	return h
+}
+
+// GetTraceContext returns the span context of the job attempt's root span.
 func (p *BackgroundParser) GetTraceContext() trace.SpanContext {
-	return trace.SpanContext{}
+	return trace.SpanContextFromContext(p.GetContext())
 }
 
 // SetTraceContext is a no-op for the background parser.
 func (p *BackgroundParser) SetTraceContext(spanCtx trace.SpanContext) {}
 
-// StartSpan returns a no-op span for the background parser.
+// StartSpan starts a child span of the job attempt's root span.
 func (p *BackgroundParser) StartSpan(name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
-	ctx := p.GetContext()
-	return ctx, trace.SpanFromContext(ctx)
+	return otel.Tracer(tracerName).Start(p.GetContext(), name, opts...)
 }
 
 // Ensure BackgroundParser satisfies the root webFramework.RequestParser.
@@ -0,0 +1,27 @@
+package workers
+
+import (
+	"log/slog"
+
+	"github.com/hmmftg/requestCore/webFramework"
+)
+
+// LeaderChecker reports whether this instance is the elected leader.
+// locks.Elector implements it.
+type LeaderChecker interface {
+	IsLeader() bool
+}
+
+// LeaderOnly wraps a handler so it only runs on the leader instance. On
+// followers the job succeeds without running and records a skip entry,
+// so scheduled jobs submitted on every replica execute exactly once.
+func LeaderOnly(leader LeaderChecker, handler JobHandler) JobHandler {
+	return func(ctx *JobContext) error {
+		if !leader.IsLeader() {
+			webFramework.AddLog(ctx.WebFramework, webFramework.HandlerLogTag,
+				slog.String("skipped", "not leader"))
+			return nil
+		}
+		return handler(ctx)
+	}
+}
@@ -0,0 +1,159 @@
+package workers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsRecorder is the interface for recording per-job-name worker pool
+// metrics. Inject a custom implementation for testing through
+// Config.Metrics; the default is backed by Prometheus.
+type MetricsRecorder interface {
+	// Submitted is called before a job is sent to the queue, so a worker
+	// can never report Started for it first.
+	Submitted(job string)
+	// Rejected undoes Submitted for a job the queue did not accept
+	// because it was full, shutting down, or the submit context ended.
+	Rejected(job string)
+	// Started is called when a worker dequeues a job.
+	Started(job string)
+	// Attempt is called after each attempt with its outcome
+	// ("succeeded" or "failed") and duration.
+	Attempt(job, outcome string, duration time.Duration)
+	// Retried is called before an attempt is retried.
+	Retried(job string)
+	// Finished is called once per job after its final attempt.
+	Finished(job string, succeeded bool)
+}
+
+var (
+	workerJobsSubmitted *prometheus.CounterVec
+	workerJobsRejected  *prometheus.CounterVec
+	workerJobsSucceeded *prometheus.CounterVec
+	workerJobsFailed    *prometheus.CounterVec
+	workerJobsRetried   *prometheus.CounterVec
+	workerJobDuration   *prometheus.HistogramVec
+	workerQueueDepth    *prometheus.GaugeVec
+	workerInFlight      *prometheus.GaugeVec
+	metricsInitOnce     sync.Once
+
+	defaultMetricsRecorder MetricsRecorder
+)
+
+// InitMetrics registers the Prometheus worker pool metrics with the
+// default registerer. Safe to call multiple times; uses sync.Once to
+// prevent duplicate-registration panics.
+func InitMetrics() {
+	metricsInitOnce.Do(func() {
+		workerJobsSubmitted = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "worker_jobs_submitted_total",
+				Help: "Total number of jobs submitted to the worker queue by job name, including rejected ones.",
+			},
+			[]string{"job"},
+		)
+		workerJobsRejected = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "worker_jobs_rejected_total",
+				Help: "Total number of submitted jobs the worker queue did not accept by job name.",
+			},
+			[]string{"job"},
+		)
+		workerJobsSucceeded = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "worker_jobs_succeeded_total",
+				Help: "Total number of jobs that completed successfully by job name.",
+			},
+			[]string{"job"},
+		)
+		workerJobsFailed = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "worker_jobs_failed_total",
+				Help: "Total number of jobs that exhausted their attempts by job name.",
+			},
+			[]string{"job"},
+		)
+		workerJobsRetried = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "worker_jobs_retried_total",
+				Help: "Total number of job attempts that were retried by job name.",
+			},
+			[]string{"job"},
+		)
+		workerJobDuration = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "worker_job_duration_seconds",
+				Help:    "Duration of individual job attempts by job name and outcome.",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"job", "outcome"},
+		)
+		workerQueueDepth = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "worker_queue_depth",
+				Help: "Number of jobs waiting in the worker queue by job name.",
+			},
+			[]string{"job"},
+		)
+		workerInFlight = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "worker_in_flight",
+				Help: "Number of jobs currently executing by job name.",
+			},
+			[]string{"job"},
+		)
+
+		prometheus.MustRegister(workerJobsSubmitted)
+		prometheus.MustRegister(workerJobsRejected)
+		prometheus.MustRegister(workerJobsSucceeded)
+		prometheus.MustRegister(workerJobsFailed)
+		prometheus.MustRegister(workerJobsRetried)
+		prometheus.MustRegister(workerJobDuration)
+		prometheus.MustRegister(workerQueueDepth)
+		prometheus.MustRegister(workerInFlight)
+		defaultMetricsRecorder = prometheusMetricsRecorder{}
+	})
+}
+
+// DefaultMetricsRecorder returns the default Prometheus-backed recorder.
+func DefaultMetricsRecorder() MetricsRecorder {
+	InitMetrics()
+	return defaultMetricsRecorder
+}
+
+// prometheusMetricsRecorder is the default MetricsRecorder backed by Prometheus.
+type prometheusMetricsRecorder struct{}
+
+func (prometheusMetricsRecorder) Submitted(job string) {
+	workerJobsSubmitted.WithLabelValues(job).Inc()
+	workerQueueDepth.WithLabelValues(job).Inc()
+}
+
+func (prometheusMetricsRecorder) Rejected(job string) {
+	workerJobsRejected.WithLabelValues(job).Inc()
+	workerQueueDepth.WithLabelValues(job).Dec()
+}
+
+func (prometheusMetricsRecorder) Started(job string) {
+	workerQueueDepth.WithLabelValues(job).Dec()
+	workerInFlight.WithLabelValues(job).Inc()
+}
+
+func (prometheusMetricsRecorder) Attempt(job, outcome string, duration time.Duration) {
+	workerJobDuration.WithLabelValues(job, outcome).Observe(duration.Seconds())
+}
+
+func (prometheusMetricsRecorder) Retried(job string) {
+	workerJobsRetried.WithLabelValues(job).Inc()
+}
+
+func (prometheusMetricsRecorder) Finished(job string, succeeded bool) {
+	workerInFlight.WithLabelValues(job).Dec()
+	if succeeded {
+		workerJobsSucceeded.WithLabelValues(job).Inc()
+	} else {
+		workerJobsFailed.WithLabelValues(job).Inc()
+	}
+}
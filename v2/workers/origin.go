@@ -0,0 +1,180 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
+	"github.com/hmmftg/requestCore/webFramework"
+)
+
+// Header names captured from the submitting request. They match the
+// header tags of libRequest.RequestHeader.
+const (
+	RequestIDHeader = "Request-Id"
+	UserIDHeader    = "User-Id"
+	ProgramIDHeader = "Program-Id"
+)
+
+// Origin identifies the request that submitted a job. Submit captures it
+// automatically; the job's BackgroundParser serves the captured headers
+// and the job's root span links to SpanContext, so a search on a request
+// ID or trace ID also finds the request's async follow-ups.
+type Origin struct {
+	// RequestID is the submitting request's Request-Id header.
+	RequestID string
+	// User is the submitting request's User-Id header.
+	User string
+	// Program is the submitting request's Program-Id header.
+	Program string
+	// SpanContext is the active span of the submitting request.
+	SpanContext trace.SpanContext
+}
+
+// IsZero reports whether nothing was captured.
+func (o Origin) IsZero() bool {
+	return o.RequestID == "" && o.User == "" && o.Program == "" && !o.SpanContext.IsValid()
+}
+
+// header returns the captured value of a propagated header.
+func (o Origin) header(name string) (string, bool) {
+	switch name {
+	case RequestIDHeader:
+		return o.RequestID, o.RequestID != ""
+	case UserIDHeader:
+		return o.User, o.User != ""
+	case ProgramIDHeader:
+		return o.Program, o.Program != ""
+	}
+	return "", false
+}
+
+type originKey struct{}
+
+// WithOrigin returns a copy of ctx carrying an explicit Origin. Submit
+// prefers it over automatic capture. Use it when submitting from code
+// that holds a v1 WebFramework rather than a v2 RequestContext:
+//
+//	ctx := workers.WithOrigin(w.Ctx, workers.OriginFromWebFramework(w))
+//	err := worker.Submit(ctx, job)
+func WithOrigin(ctx context.Context, o Origin) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, originKey{}, o)
+}
+
+// OriginFromContext returns the Origin stored in ctx by WithOrigin. Inside
+// a job, JobContext.Context carries the job's Origin, so nested submits
+// keep pointing at the original request.
+func OriginFromContext(ctx context.Context) (Origin, bool) {
+	if ctx == nil {
+		return Origin{}, false
+	}
+	o, ok := ctx.Value(originKey{}).(Origin)
+	return o, ok
+}
+
+// OriginFromWebFramework captures the request identity and active span
+// from a v1 WebFramework.
+func OriginFromWebFramework(w webFramework.WebFramework) Origin {
+	var o Origin
+	if w.Parser != nil {
+		o.RequestID = w.Parser.GetHeaderValue(RequestIDHeader)
+		o.User = w.Parser.GetHeaderValue(UserIDHeader)
+		o.Program = w.Parser.GetHeaderValue(ProgramIDHeader)
+		o.SpanContext = w.Parser.GetTraceContext()
+	}
+	if sc := trace.SpanContextFromContext(w.Ctx); sc.IsValid() {
+		o.SpanContext = sc
+	}
+	return o
+}
+
+// captureOrigin resolves the Origin of a submission: an explicit Origin
+// set with WithOrigin, else the v2 RequestContext stored in ctx by the
+// router adapters, else only the active span of ctx.
+func captureOrigin(ctx context.Context) Origin {
+	if o, ok := OriginFromContext(ctx); ok {
+		return o
+	}
+	var o Origin
+	if rc, ok := v2wf.FromContext(ctx); ok {
+		o = OriginFromWebFramework(webFramework.WebFramework{Parser: rc.Parser, Ctx: rc.Context})
+	}
+	// The submit context may carry a more specific span (e.g. a handler
+	// span started after the adapter stored the RequestContext).
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		o.SpanContext = sc
+	}
+	return o
+}
+
+// logAttrs returns the origin fields for the worker transaction log, so
+// the worker-<name>-req entries share the request's Request-Id.
+func (o Origin) logAttrs() []slog.Attr {
+	var attrs []slog.Attr
+	if o.RequestID != "" {
+		attrs = append(attrs, slog.String("request_id", o.RequestID))
+	}
+	if o.User != "" {
+		attrs = append(attrs, slog.String("user_id", o.User))
+	}
+	if o.Program != "" {
+		attrs = append(attrs, slog.String("program_id", o.Program))
+	}
+	if o.SpanContext.IsValid() {
+		attrs = append(attrs, slog.String("origin_trace_id", o.SpanContext.TraceID().String()))
+	}
+	return attrs
+}
+
+// tracerName is the instrumentation scope of worker job spans. Spans use
+// the global tracer provider, which libTracing installs on initialization.
+const tracerName = "github.com/hmmftg/requestCore/v2/workers"
+
+// startJobSpan starts the root span of a job attempt. The span is a new
+// trace root (the request may have finished long ago) with a link to the
+// submitting request's span.
+func startJobSpan(ctx context.Context, name string, attempt int, origin Origin, attributes map[string]string) (context.Context, trace.Span) {
+	attrs := make([]attribute.KeyValue, 0, len(attributes)+5)
+	attrs = append(attrs,
+		attribute.String("worker.job", name),
+		attribute.Int("worker.attempt", attempt),
+	)
+	if origin.RequestID != "" {
+		attrs = append(attrs, attribute.String("request.id", origin.RequestID))
+	}
+	if origin.User != "" {
+		attrs = append(attrs, attribute.String("request.user", origin.User))
+	}
+	if origin.Program != "" {
+		attrs = append(attrs, attribute.String("request.program", origin.Program))
+	}
+	for k, v := range attributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	opts := []trace.SpanStartOption{
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attrs...),
+	}
+	if origin.SpanContext.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: origin.SpanContext}))
+	}
+	return otel.Tracer(tracerName).Start(ctx, "worker."+name, opts...)
+}
+
+// endJobSpan records the attempt outcome and ends the span.
+func endJobSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
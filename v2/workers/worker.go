@@ -18,6 +18,8 @@ import (
 	"time"
 
 	"github.com/hmmftg/requestCore/webFramework"
+
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
 )
 
 // JobHandler is the function executed by a worker job.
@@ -45,6 +47,11 @@ type JobContext struct {
 	// Attributes are tracing attributes for the job.
 	Attributes map[string]string
 
+	// Origin identifies the request that submitted the job. Its headers
+	// are served by the BackgroundParser and its span is linked from the
+	// job's root span.
+	Origin Origin
+
 	// transactionSink collects AddLog entries for this job attempt.
 	// It is flushed after each attempt to emit the mandatory
 	// worker-<name>-req / worker-<name>-req-failed log entries.
@@ -132,12 +139,16 @@ type JobOptions struct {
 
 // Stats holds worker pool statistics.
 type Stats struct {
-	Submitted  int64
-	Succeeded  int64
-	Failed     int64
-	InFlight   int64
-	QueueDepth int
-	Workers    int
+	Submitted  int64 `json:"submitted"`
+	Succeeded  int64 `json:"succeeded"`
+	Failed     int64 `json:"failed"`
+	Retried    int64 `json:"retried"`
+	InFlight   int64 `json:"inFlight"`
+	QueueDepth int   `json:"queueDepth"`
+	// QueueCapacity is the number of jobs the queue holds before Submit
+	// rejects new ones.
+	QueueCapacity int `json:"queueCapacity"`
+	Workers       int `json:"workers"`
 }
 
 // Worker is the interface for submitting and managing background jobs.
@@ -176,6 +187,15 @@ type Config struct {
 	// JitterSource is the jitter source for deterministic testing.
 	// If nil, a package-level locked random source is used.
 	JitterSource func(max int64) int64
+
+	// Metrics records per-job-name metrics.
+	// Default: DefaultMetricsRecorder() (Prometheus).
+	Metrics MetricsRecorder
+
+	// FailureHistory is the capacity of the recent failures ring buffer
+	// returned by RecentFailures.
+	// Default: 100.
+	FailureHistory int
 }
 
 // DefaultConfig returns a Config with sensible defaults.
@@ -222,6 +242,7 @@ const CallAPILogEntry string = "ApiCall"
 type jobEnvelope struct {
 	job       Job
 	submitCtx context.Context
+	origin    Origin
 }
 
 // InProcessWorker is a bounded goroutine pool implementation of Worker.
@@ -242,6 +263,9 @@ type InProcessWorker struct {
 	// clock and jitter sources for deterministic testing.
 	clock        func() time.Time
 	jitterSource func(max int64) int64
+
+	metrics  MetricsRecorder
+	failures *failureRing
 }
 
 // NewInProcessWorker creates a new InProcessWorker with the given configuration.
@@ -252,12 +276,20 @@ func NewInProcessWorker(config Config) *InProcessWorker {
 	if config.QueueSize <= 0 {
 		config.QueueSize = 100
 	}
+	if config.Metrics == nil {
+		config.Metrics = DefaultMetricsRecorder()
+	}
+	if config.FailureHistory <= 0 {
+		config.FailureHistory = 100
+	}
 	w := &InProcessWorker{
 		config:       config,
 		queue:        make(chan jobEnvelope, config.QueueSize),
 		shutDone:     make(chan struct{}),
 		clock:        config.Clock,
 		jitterSource: config.JitterSource,
+		metrics:      config.Metrics,
+		failures:     newFailureRing(config.FailureHistory),
 	}
 	if w.clock == nil {
 		w.clock = time.Now
@@ -280,6 +312,7 @@ func (w *InProcessWorker) workerLoop() {
 	defer w.wg.Done()
 	for env := range w.queue {
 		atomic.AddInt64(&w.stats.InFlight, 1)
+		w.metrics.Started(env.job.Name)
 		w.executeJob(env)
 		atomic.AddInt64(&w.stats.InFlight, -1)
 	}
@@ -304,40 +337,56 @@ func (w *InProcessWorker) executeJob(env jobEnvelope) {
 	// Derive the job context from the submission context.
 	// By default, use context.WithoutCancel so values/tracing survive
 	// without cancellation. When PropagateCancel is true, use the
-	// submit context directly so cancellation propagates.
+	// submit context directly so cancellation propagates. Either way
+	// the request's v2 RequestContext is detached: it wraps the pooled
+	// framework context, which is recycled once the response is sent.
 	var jobCtx context.Context
-	if opts.PropagateCancel {
-		jobCtx = env.submitCtx
-	} else {
-		jobCtx = context.WithoutCancel(env.submitCtx)
-	}
-	if jobCtx == nil {
+	if env.submitCtx == nil {
 		jobCtx = context.Background()
+	} else if opts.PropagateCancel {
+		jobCtx = v2wf.DetachContext(env.submitCtx)
+	} else {
+		jobCtx = v2wf.DetachContext(context.WithoutCancel(env.submitCtx))
 	}
+	// Carry the origin explicitly so jobs submitted from inside this job
+	// link back to the original request instead of re-capturing.
+	jobCtx = WithOrigin(jobCtx, env.origin)
 
 	var lastErr error
 	for attempt := 1; attempt <= opts.MaxAttempts; attempt++ {
+		// Each attempt runs under its own root span linked to the
+		// submitting request's span.
+		spanCtx, span := startJobSpan(jobCtx, job.Name, attempt, env.origin, opts.Attributes)
+
 		// Build the job context with a BackgroundParser and TransactionSink.
 		sink := NewTransactionSink()
-		bgParser := newBackgroundParser(sink, jobCtx)
+		bgParser := newBackgroundParser(sink, spanCtx)
+		bgParser.origin = env.origin
 
 		wf := webFramework.WebFramework{
 			Parser: bgParser,
-			Ctx:    jobCtx,
+			Ctx:    spanCtx,
 		}
 
 		jctx := &JobContext{
-			Context:         jobCtx,
+			Context:         spanCtx,
 			WebFramework:    wf,
 			JobName:         job.Name,
 			Attempt:         attempt,
 			Attributes:      opts.Attributes,
+			Origin:          env.origin,
 			transactionSink: sink,
 		}
 
 		start := w.clock()
 		err := w.runWithObservability(jctx, job.Handler)
 		elapsed := w.clock().Sub(start)
+		endJobSpan(span, err)
+		outcome := "succeeded"
+		if err != nil {
+			outcome = "failed"
+		}
+		w.metrics.Attempt(job.Name, outcome, elapsed)
 
 		// Collect logs from the parser into the transaction sink.
 		webFramework.CollectLogArrays(wf, webFramework.HandlerLogTag)
@@ -349,6 +398,7 @@ func (w *InProcessWorker) executeJob(env jobEnvelope) {
 
 		if err == nil {
 			atomic.AddInt64(&w.stats.Succeeded, 1)
+			w.metrics.Finished(job.Name, true)
 			return
 		}
 
@@ -359,10 +409,12 @@ func (w *InProcessWorker) executeJob(env jobEnvelope) {
 			timer := time.NewTimer(backoff)
 			select {
 			case <-timer.C:
+				atomic.AddInt64(&w.stats.Retried, 1)
+				w.metrics.Retried(job.Name)
 			case <-jobCtx.Done():
 				timer.Stop()
 				// Cancellation is a terminal failure.
-				atomic.AddInt64(&w.stats.Failed, 1)
+				w.recordFailure(env, jobCtx.Err(), attempt)
 				if opts.OnFailure != nil {
 					w.runOnFailure(opts.OnFailure, jobCtx.Err(), attempt)
 				}
@@ -371,12 +423,35 @@ func (w *InProcessWorker) executeJob(env jobEnvelope) {
 		}
 	}
 
-	atomic.AddInt64(&w.stats.Failed, 1)
+	w.recordFailure(env, lastErr, opts.MaxAttempts)
 	if opts.OnFailure != nil {
 		w.runOnFailure(opts.OnFailure, lastErr, opts.MaxAttempts)
 	}
 }
 
+// recordFailure updates failure statistics and metrics and appends the
+// job to the recent failures ring buffer.
+func (w *InProcessWorker) recordFailure(env jobEnvelope, err error, attempts int) {
+	atomic.AddInt64(&w.stats.Failed, 1)
+	w.metrics.Finished(env.job.Name, false)
+	f := Failure{
+		Job:       env.job.Name,
+		Attempts:  attempts,
+		RequestID: env.origin.RequestID,
+		FailedAt:  w.clock(),
+	}
+	if err != nil {
+		f.Error = err.Error()
+	}
+	w.failures.add(f)
+}
+
+// RecentFailures returns the most recent terminal job failures, newest
+// first, up to Config.FailureHistory entries.
+func (w *InProcessWorker) RecentFailures() []Failure {
+	return w.failures.list()
+}
+
 // flushTransaction emits the mandatory worker-<name>-req (success) or
 // worker-<name>-req-failed (failure) log entry with attempt, elapsed time,
 // terminal state, and collected transaction attributes.
@@ -397,9 +472,10 @@ func (w *InProcessWorker) flushTransaction(ctx *JobContext, err error, elapsed t
 	}
 
 	// Build the outcome attributes.
-	outcomeAttrs := make([]slog.Attr, 0, 4)
+	outcomeAttrs := make([]slog.Attr, 0, 8)
 	outcomeAttrs = append(outcomeAttrs, slog.Int("attempt", ctx.Attempt))
 	outcomeAttrs = append(outcomeAttrs, slog.String("elapsed", elapsed.String()))
+	outcomeAttrs = append(outcomeAttrs, ctx.Origin.logAttrs()...)
 	if err != nil {
 		outcomeAttrs = append(outcomeAttrs, slog.String("error", err.Error()))
 		outcomeAttrs = append(outcomeAttrs, slog.String("state", "failed"))
@@ -455,7 +531,7 @@ func (w *InProcessWorker) runOnFailure(fn func(error, int), err error, attempts
 
 // Submit enqueues a job for asynchronous execution. Returns an error if
 // the queue is full, the worker is shutting down, or the job is invalid.
-// Only accepted submissions increment the Submitted counter.
+// Only accepted submissions count towards Stats.Submitted.
 //
 // The shutdown check and queue send are synchronized under mu to prevent
 // a send on a closed channel when Shutdown runs concurrently.
@@ -467,7 +543,7 @@ func (w *InProcessWorker) Submit(ctx context.Context, job Job) error {
 		ctx = context.Background()
 	}
 
-	env := jobEnvelope{job: job, submitCtx: ctx}
+	env := jobEnvelope{job: job, submitCtx: ctx, origin: captureOrigin(ctx)}
 
 	// Synchronized admission: check shutdown under the same lock that
 	// guards the queue send. This prevents the check-then-close race
@@ -479,8 +555,14 @@ func (w *InProcessWorker) Submit(ctx context.Context, job Job) error {
 		return ErrShutdown
 	}
 
-	// Count only accepted submissions.
+	// Count the submission before the send so a worker cannot record
+	// Started first; the rejected paths below undo it.
 	atomic.AddInt64(&w.stats.Submitted, 1)
+	w.metrics.Submitted(job.Name)
+	reject := func() {
+		atomic.AddInt64(&w.stats.Submitted, -1)
+		w.metrics.Rejected(job.Name)
+	}
 
 	if w.config.BlockOnFull {
 		// BlockOnFull: send while holding the lock to prevent
@@ -492,8 +574,7 @@ func (w *InProcessWorker) Submit(ctx context.Context, job Job) error {
 			return nil
 		case <-ctx.Done():
 			w.mu.Unlock()
-			// Decrement since we counted it above but won't enqueue.
-			atomic.AddInt64(&w.stats.Submitted, -1)
+			reject()
 			return ctx.Err()
 		}
 	}
@@ -505,8 +586,7 @@ func (w *InProcessWorker) Submit(ctx context.Context, job Job) error {
 		return nil
 	default:
 		w.mu.Unlock()
-		// Decrement since we counted it above but won't enqueue.
-		atomic.AddInt64(&w.stats.Submitted, -1)
+		reject()
 		// Check if shutdown started while we were trying to send.
 		if w.shutdown.Load() {
 			return ErrShutdown
@@ -553,11 +633,13 @@ func (w *InProcessWorker) Shutdown(ctx context.Context) error {
 // Stats returns current worker pool statistics.
 func (w *InProcessWorker) Stats() Stats {
 	return Stats{
-		Submitted:  atomic.LoadInt64(&w.stats.Submitted),
-		Succeeded:  atomic.LoadInt64(&w.stats.Succeeded),
-		Failed:     atomic.LoadInt64(&w.stats.Failed),
-		InFlight:   atomic.LoadInt64(&w.stats.InFlight),
-		QueueDepth: len(w.queue),
-		Workers:    w.config.WorkerCount,
+		Submitted:     atomic.LoadInt64(&w.stats.Submitted),
+		Succeeded:     atomic.LoadInt64(&w.stats.Succeeded),
+		Failed:        atomic.LoadInt64(&w.stats.Failed),
+		Retried:       atomic.LoadInt64(&w.stats.Retried),
+		InFlight:      atomic.LoadInt64(&w.stats.InFlight),
+		QueueDepth:    len(w.queue),
+		QueueCapacity: cap(w.queue),
+		Workers:       w.config.WorkerCount,
 	}
 }
@@ -3,12 +3,17 @@ package workers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/hmmftg/requestCore/webFramework"
+
+	v2wf "github.com/hmmftg/requestCore/v2/webFramework"
 )
 
 func TestInProcessWorker_Success(t *testing.T) {
@@ -510,6 +515,93 @@ func TestInProcessWorker_ContextValuePropagation(t *testing.T) {
 	}
 }
 
+// TestInProcessWorker_DetachesRequestContext verifies that the request's
+// v2 RequestContext, which wraps the pooled framework context, is not
+// reachable from the job while other submit context values are.
+func TestInProcessWorker_DetachesRequestContext(t *testing.T) {
+	w := NewInProcessWorker(Config{WorkerCount: 1, QueueSize: 10})
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = w.Shutdown(ctx)
+	}()
+
+	type ctxKey string
+	submitCtx := context.WithValue(context.Background(), ctxKey("tenant"), "t1")
+	submitCtx = v2wf.NewContext(submitCtx, &v2wf.RequestContext{})
+
+	for _, propagate := range []bool{false, true} {
+		done := make(chan string, 1)
+		err := w.Submit(submitCtx, Job{
+			Name: "detach-test",
+			Handler: func(ctx *JobContext) error {
+				_, leaked := v2wf.FromContext(ctx.Context)
+				done <- fmt.Sprintf("%v %v", ctx.Context.Value(ctxKey("tenant")), leaked)
+				return nil
+			},
+			Options: JobOptions{PropagateCancel: propagate},
+		})
+		if err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+		select {
+		case got := <-done:
+			if got != "t1 false" {
+				t.Fatalf("PropagateCancel=%v: expected values without the RequestContext, got %q", propagate, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("job did not run")
+		}
+	}
+}
+
+// TestInProcessWorker_OriginPropagation verifies that an explicit Origin
+// reaches the job, its BackgroundParser headers, the transaction log, and
+// jobs submitted from inside the job.
+func TestInProcessWorker_OriginPropagation(t *testing.T) {
+	w := NewInProcessWorker(Config{
+		WorkerCount: 2,
+		QueueSize:   10,
+	})
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = w.Shutdown(ctx)
+	}()
+
+	origin := Origin{RequestID: "req-0000000001", User: "alice", Program: "payments"}
+	var nested atomic.Value
+	err := w.Submit(WithOrigin(context.Background(), origin), Job{
+		Name: "origin-parent",
+		Handler: func(ctx *JobContext) error {
+			if got := ctx.WebFramework.Parser.GetHeaderValue("request-id"); got != origin.RequestID {
+				return fmt.Errorf("unexpected Request-Id %q", got)
+			}
+			return w.Submit(ctx.Context, Job{
+				Name: "origin-child",
+				Handler: func(child *JobContext) error {
+					nested.Store(child.Origin)
+					return nil
+				},
+			})
+		},
+		Options: JobOptions{OnFailure: func(err error, _ int) { t.Errorf("parent failed: %v", err) }},
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	got, _ := nested.Load().(Origin)
+	if got.RequestID != origin.RequestID || got.User != origin.User || got.Program != origin.Program {
+		t.Fatalf("expected nested job to keep origin %+v, got %+v", origin, got)
+	}
+
+	attrs := origin.logAttrs()
+	if len(attrs) == 0 || attrs[0].Key != "request_id" || attrs[0].Value.String() != origin.RequestID {
+		t.Fatalf("expected request_id in transaction log attributes, got %v", attrs)
+	}
+}
+
 // TestInProcessWorker_CancelPropagation verifies that when PropagateCancel
 // is true, cancelling the submit context cancels the job.
 func TestInProcessWorker_CancelPropagation(t *testing.T) {
@@ -613,3 +705,172 @@ func TestInProcessWorker_RepeatedShutdown(t *testing.T) {
 		}
 	}
 }
+
+type recordingMetrics struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (m *recordingMetrics) add(e string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, e)
+}
+
+func (m *recordingMetrics) Submitted(job string) { m.add("submitted:" + job) }
+func (m *recordingMetrics) Rejected(job string)  { m.add("rejected:" + job) }
+func (m *recordingMetrics) Started(job string)   { m.add("started:" + job) }
+func (m *recordingMetrics) Attempt(job, outcome string, _ time.Duration) {
+	m.add("attempt:" + job + ":" + outcome)
+}
+func (m *recordingMetrics) Retried(job string) { m.add("retried:" + job) }
+func (m *recordingMetrics) Finished(job string, succeeded bool) {
+	m.add(fmt.Sprintf("finished:%s:%v", job, succeeded))
+}
+
+func TestInProcessWorker_Metrics(t *testing.T) {
+	metrics := &recordingMetrics{}
+	w := NewInProcessWorker(Config{
+		WorkerCount: 1,
+		QueueSize:   10,
+		Metrics:     metrics,
+	})
+
+	var calls atomic.Int32
+	err := w.Submit(context.Background(), Job{
+		Name: "flaky",
+		Handler: func(*JobContext) error {
+			if calls.Add(1) == 1 {
+				return errors.New("transient")
+			}
+			return nil
+		},
+		Options: JobOptions{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := w.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	want := []string{
+		"submitted:flaky",
+		"started:flaky",
+		"attempt:flaky:failed",
+		"retried:flaky",
+		"attempt:flaky:succeeded",
+		"finished:flaky:true",
+	}
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	if fmt.Sprint(metrics.events) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v", want, metrics.events)
+	}
+	if stats := w.Stats(); stats.Retried != 1 || stats.Succeeded != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+// TestInProcessWorker_MetricsRejected verifies that a submission the full
+// queue turns away is undone in the metrics and stats.
+func TestInProcessWorker_MetricsRejected(t *testing.T) {
+	metrics := &recordingMetrics{}
+	w := NewInProcessWorker(Config{WorkerCount: 1, QueueSize: 1, Metrics: metrics})
+	release := make(chan struct{})
+	started := make(chan struct{})
+	block := func(*JobContext) error {
+		close(started)
+		<-release
+		return nil
+	}
+	if err := w.Submit(context.Background(), Job{Name: "running", Handler: block}); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	<-started
+	noop := func(*JobContext) error { return nil }
+	if err := w.Submit(context.Background(), Job{Name: "queued", Handler: noop}); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if err := w.Submit(context.Background(), Job{Name: "overflow", Handler: noop}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	if stats := w.Stats(); stats.Submitted != 2 {
+		t.Fatalf("expected the rejected job not to count, got %+v", stats)
+	}
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := w.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	var overflow []string
+	for _, e := range metrics.events {
+		if strings.HasSuffix(e, ":overflow") {
+			overflow = append(overflow, e)
+		}
+	}
+	if fmt.Sprint(overflow) != "[submitted:overflow rejected:overflow]" {
+		t.Fatalf("expected the rejection to undo the submission, got %v", metrics.events)
+	}
+}
+
+func TestInProcessWorker_RecentFailures(t *testing.T) {
+	w := NewInProcessWorker(Config{
+		WorkerCount:    1,
+		QueueSize:      10,
+		FailureHistory: 2,
+	})
+	for _, name := range []string{"first", "second", "third"} {
+		err := w.Submit(WithOrigin(context.Background(), Origin{RequestID: "req-" + name}), Job{
+			Name:    name,
+			Handler: func(*JobContext) error { return errors.New("boom") },
+		})
+		if err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := w.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	failures := w.RecentFailures()
+	if len(failures) != 2 {
+		t.Fatalf("expected ring buffer to keep 2 failures, got %d", len(failures))
+	}
+	if failures[0].Job != "third" || failures[1].Job != "second" {
+		t.Fatalf("expected newest first, got %+v", failures)
+	}
+	if failures[0].RequestID != "req-third" || failures[0].Error != "boom" || failures[0].Attempts != 1 {
+		t.Fatalf("unexpected failure record: %+v", failures[0])
+	}
+}
+
+type staticLeader bool
+
+func (l staticLeader) IsLeader() bool { return bool(l) }
+
+func TestLeaderOnly(t *testing.T) {
+	var ran atomic.Int32
+	handler := func(*JobContext) error {
+		ran.Add(1)
+		return nil
+	}
+	ctx := &JobContext{WebFramework: webFramework.WebFramework{Parser: newBackgroundParser(NewTransactionSink(), context.Background())}}
+	if err := LeaderOnly(staticLeader(false), handler)(ctx); err != nil {
+		t.Fatalf("follower: %v", err)
+	}
+	if err := LeaderOnly(staticLeader(true), handler)(ctx); err != nil {
+		t.Fatalf("leader: %v", err)
+	}
+	if ran.Load() != 1 {
+		t.Fatalf("expected handler to run only on the leader, ran %d times", ran.Load())
+	}
+}
@@ -0,0 +1,452 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/hmmftg/requestCore/webFramework"
+)
+
+// WorkflowLogTag is the AddLog key under which workflow engine events
+// (step started, step completed, compensation) are recorded on the
+// job-owned WebFramework of each step.
+const WorkflowLogTag string = "workflow"
+
+// WorkflowStatus is the lifecycle state of a workflow run.
+type WorkflowStatus string
+
+const (
+	// WorkflowRunning means forward steps are still being executed.
+	WorkflowRunning WorkflowStatus = "running"
+	// WorkflowCompleted means every forward step succeeded.
+	WorkflowCompleted WorkflowStatus = "completed"
+	// WorkflowCompensating means a step failed terminally and the
+	// compensation handlers of completed steps are being executed.
+	WorkflowCompensating WorkflowStatus = "compensating"
+	// WorkflowCompensated means a step failed and every completed step
+	// was compensated successfully.
+	WorkflowCompensated WorkflowStatus = "compensated"
+	// WorkflowFailed means a compensation handler failed terminally.
+	// The run needs manual intervention.
+	WorkflowFailed WorkflowStatus = "failed"
+)
+
+// Terminal reports whether the status is a final state.
+func (s WorkflowStatus) Terminal() bool {
+	switch s {
+	case WorkflowCompleted, WorkflowCompensated, WorkflowFailed:
+		return true
+	}
+	return false
+}
+
+// StepHandler executes a workflow step (or its compensation). The
+// StepContext embeds the worker JobContext, so handlers use the same
+// job-owned WebFramework for mandatory AddLog calls as plain jobs.
+type StepHandler func(*StepContext) error
+
+// Step is a single unit of a workflow with an optional compensation
+// handler that undoes its effect when a later step fails.
+//
+// Steps are executed at least once: a crash between a step's side effect
+// and the persistence of its completion causes the step to run again on
+// resume. Step and compensation handlers must therefore be idempotent.
+type Step struct {
+	// Name identifies the step for logging and tracing. Names must be
+	// unique within a workflow.
+	Name string
+
+	// Run performs the step.
+	Run StepHandler
+
+	// Compensate undoes the step. Nil means the step has nothing to undo.
+	Compensate StepHandler
+
+	// Options configures retry and backoff for both Run and Compensate.
+	// OnFailure and PropagateCancel are managed by the engine and ignored.
+	Options JobOptions
+}
+
+// Workflow is a named, ordered list of steps.
+type Workflow struct {
+	// Name identifies the workflow. Runs reference it by name so that
+	// persisted runs can be resumed after the workflow is re-registered.
+	Name string
+
+	// Steps are executed in order.
+	Steps []Step
+}
+
+// WorkflowRun is the persisted state of a single workflow execution.
+type WorkflowRun struct {
+	// ID is the unique run identifier.
+	ID string
+	// Workflow is the name of the workflow definition.
+	Workflow string
+	// Status is the lifecycle state.
+	Status WorkflowStatus
+	// Step is the index of the step currently executing (running) or
+	// being compensated (compensating).
+	Step int
+	// Data is the run's shared state, visible to all steps. Values must
+	// be JSON-serializable for SQL-backed stores.
+	Data map[string]any
+	// Error records the terminal error of the failed step or compensation.
+	Error string
+	// CreatedAt and UpdatedAt track the run lifecycle.
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// clone returns a deep-enough copy of the run for store isolation.
+func (r *WorkflowRun) clone() *WorkflowRun {
+	c := *r
+	c.Data = make(map[string]any, len(r.Data))
+	for k, v := range r.Data {
+		c.Data[k] = v
+	}
+	return &c
+}
+
+// StepContext is passed to step and compensation handlers.
+type StepContext struct {
+	*JobContext
+
+	// RunID is the identifier of the workflow run.
+	RunID string
+
+	// Workflow is the workflow name.
+	Workflow string
+
+	// Step is the step name.
+	Step string
+
+	// Compensating reports whether the handler is a compensation.
+	Compensating bool
+
+	mu   sync.Mutex
+	data map[string]any
+}
+
+// Get returns a value from the run's shared data.
+func (s *StepContext) Get(key string) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data[key]
+}
+
+// GetString returns a string value from the run's shared data, or ""
+// if the key does not exist or is not a string.
+func (s *StepContext) GetString(key string) string {
+	v, _ := s.Get(key).(string)
+	return v
+}
+
+// Set stores a value in the run's shared data. The value is persisted
+// when the step completes successfully.
+func (s *StepContext) Set(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = value
+}
+
+// ErrWorkflowNotFound is returned when a run references an unregistered
+// workflow or a run ID does not exist in the store.
+var ErrWorkflowNotFound = errors.New("workers: workflow not found")
+
+// WorkflowEngine orchestrates multi-step workflows (sagas) on top of a
+// Worker. Each step and compensation runs as a separate worker job, so it
+// gets its own JobContext, retry policy, and worker-<name>-req AddLog trail.
+// Run state is persisted in a WorkflowStore after every transition so that
+// Resume can continue incomplete runs after a crash.
+type WorkflowEngine struct {
+	worker Worker
+	store  WorkflowStore
+	clock  func() time.Time
+
+	mu        sync.RWMutex
+	workflows map[string]Workflow
+}
+
+// NewWorkflowEngine creates a WorkflowEngine that submits steps to the
+// given worker and persists run state in the given store. If store is
+// nil, an in-memory store is used (no crash recovery).
+func NewWorkflowEngine(worker Worker, store WorkflowStore) *WorkflowEngine {
+	if store == nil {
+		store = NewMemoryWorkflowStore()
+	}
+	return &WorkflowEngine{
+		worker:    worker,
+		store:     store,
+		clock:     time.Now,
+		workflows: make(map[string]Workflow),
+	}
+}
+
+// Register adds a workflow definition. It returns an error if the
+// workflow has no name, no steps, a step without a name or Run handler,
+// duplicate step names, or is already registered.
+func (e *WorkflowEngine) Register(wf Workflow) error {
+	if wf.Name == "" || len(wf.Steps) == 0 {
+		return errors.New("workers: workflow must have a name and at least one step")
+	}
+	seen := make(map[string]bool, len(wf.Steps))
+	for i, step := range wf.Steps {
+		if step.Name == "" || step.Run == nil {
+			return fmt.Errorf("workers: workflow %q step %d must have a name and Run handler", wf.Name, i)
+		}
+		if seen[step.Name] {
+			return fmt.Errorf("workers: workflow %q has duplicate step %q", wf.Name, step.Name)
+		}
+		seen[step.Name] = true
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.workflows[wf.Name]; ok {
+		return fmt.Errorf("workers: workflow %q already registered", wf.Name)
+	}
+	e.workflows[wf.Name] = wf
+	return nil
+}
+
+// Start creates a new run of the named workflow with the given initial
+// data, persists it, and submits its first step. The run ID is returned
+// even if the submission fails, because the persisted run can still be
+// picked up by Resume.
+func (e *WorkflowEngine) Start(ctx context.Context, workflow string, data map[string]any) (string, error) {
+	if _, ok := e.lookup(workflow); !ok {
+		return "", fmt.Errorf("%w: %q", ErrWorkflowNotFound, workflow)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	now := e.clock()
+	run := &WorkflowRun{
+		ID:        newRunID(),
+		Workflow:  workflow,
+		Status:    WorkflowRunning,
+		Data:      make(map[string]any, len(data)),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for k, v := range data {
+		run.Data[k] = v
+	}
+	if err := e.store.Create(ctx, run); err != nil {
+		return "", fmt.Errorf("workers: save workflow run: %w", err)
+	}
+	return run.ID, e.submit(ctx, run)
+}
+
+// Get returns the persisted state of a run.
+func (e *WorkflowEngine) Get(ctx context.Context, runID string) (*WorkflowRun, error) {
+	return e.store.Load(ctx, runID)
+}
+
+// Resume re-submits the current step (or compensation) of every
+// non-terminal run in the store. Call it once at startup, after all
+// workflows are registered, to continue runs interrupted by a crash.
+// Runs of unregistered workflows are skipped and reported in the
+// returned error.
+func (e *WorkflowEngine) Resume(ctx context.Context) error {
+	runs, err := e.store.ListIncomplete(ctx)
+	if err != nil {
+		return fmt.Errorf("workers: list incomplete workflow runs: %w", err)
+	}
+	var errs []error
+	for _, run := range runs {
+		if err := e.submit(ctx, run); err != nil {
+			errs = append(errs, fmt.Errorf("run %s: %w", run.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (e *WorkflowEngine) lookup(name string) (Workflow, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	wf, ok := e.workflows[name]
+	return wf, ok
+}
+
+// submit enqueues the job for the run's current position.
+func (e *WorkflowEngine) submit(ctx context.Context, run *WorkflowRun) error {
+	wf, ok := e.lookup(run.Workflow)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrWorkflowNotFound, run.Workflow)
+	}
+	if run.Step < 0 || run.Step >= len(wf.Steps) {
+		return fmt.Errorf("workers: workflow %q run %s has invalid step %d", wf.Name, run.ID, run.Step)
+	}
+	step := wf.Steps[run.Step]
+	compensating := run.Status == WorkflowCompensating
+
+	opts := step.Options
+	opts.PropagateCancel = false
+	opts.Attributes = mergeAttributes(opts.Attributes, map[string]string{
+		"workflow":        wf.Name,
+		"workflow.run_id": run.ID,
+		"workflow.step":   step.Name,
+	})
+
+	name := "workflow-" + wf.Name + "-" + step.Name
+	handler := step.Run
+	if compensating {
+		name += "-compensate"
+		handler = step.Compensate
+	}
+
+	runID := run.ID
+	// OnFailure runs on the worker goroutine after the last attempt, so
+	// the step's context is set by then unless no attempt ran.
+	var stepCtx context.Context
+	opts.OnFailure = func(err error, _ int) {
+		ctx := context.Background()
+		if stepCtx != nil {
+			ctx = context.WithoutCancel(stepCtx)
+		}
+		e.onStepFailure(ctx, runID, compensating, err)
+	}
+	return e.worker.Submit(ctx, Job{
+		Name: name,
+		Handler: func(jctx *JobContext) error {
+			stepCtx = jctx.Context
+			return e.runStep(jctx, wf, runID, step, handler, compensating)
+		},
+		Options: opts,
+	})
+}
+
+// runStep loads the run, executes the handler, and on success persists
+// the transition and submits the next job.
+func (e *WorkflowEngine) runStep(jctx *JobContext, wf Workflow, runID string, step Step, handler StepHandler, compensating bool) error {
+	run, err := e.store.Load(jctx.Context, runID)
+	if err != nil {
+		return fmt.Errorf("workers: load workflow run %s: %w", runID, err)
+	}
+
+	sctx := &StepContext{
+		JobContext:   jctx,
+		RunID:        runID,
+		Workflow:     wf.Name,
+		Step:         step.Name,
+		Compensating: compensating,
+		data:         run.Data,
+	}
+	if sctx.data == nil {
+		sctx.data = make(map[string]any)
+	}
+
+	webFramework.AddLog(jctx.WebFramework, WorkflowLogTag, slog.Group("step",
+		slog.String("run_id", runID),
+		slog.String("workflow", wf.Name),
+		slog.String("step", step.Name),
+		slog.Bool("compensating", compensating),
+		slog.Int("attempt", jctx.Attempt),
+	))
+
+	if handler != nil {
+		if err := handler(sctx); err != nil {
+			return err
+		}
+	}
+
+	sctx.mu.Lock()
+	run.Data = sctx.data
+	sctx.mu.Unlock()
+	run.UpdatedAt = e.clock()
+
+	if compensating {
+		if run.Step == 0 {
+			run.Status = WorkflowCompensated
+		} else {
+			run.Step--
+		}
+	} else if run.Step == len(wf.Steps)-1 {
+		run.Status = WorkflowCompleted
+	} else {
+		run.Step++
+	}
+
+	if err := e.store.Save(jctx.Context, run); err != nil {
+		return fmt.Errorf("workers: save workflow run %s: %w", runID, err)
+	}
+	webFramework.AddLog(jctx.WebFramework, WorkflowLogTag, slog.Group("transition",
+		slog.String("status", string(run.Status)),
+		slog.Int("step", run.Step),
+	))
+
+	if run.Status.Terminal() {
+		return nil
+	}
+	// The transition is persisted; a failed submission leaves the run
+	// for Resume rather than re-running the step that already succeeded.
+	if err := e.submit(jctx.Context, run); err != nil {
+		webFramework.AddLog(jctx.WebFramework, WorkflowLogTag, slog.Any("submit-failed", err))
+	}
+	return nil
+}
+
+// onStepFailure is invoked when a step or compensation exhausts its
+// attempts. A failed step starts compensation of the previously
+// completed steps; a failed compensation marks the run failed. ctx is
+// the failed step's context without its cancellation, so compensation
+// keeps the run's Origin and trace link.
+func (e *WorkflowEngine) onStepFailure(ctx context.Context, runID string, compensating bool, stepErr error) {
+	run, err := e.store.Load(ctx, runID)
+	if err != nil {
+		slog.Error("workers: load workflow run after step failure",
+			slog.String("run_id", runID), slog.Any("error", err))
+		return
+	}
+	run.UpdatedAt = e.clock()
+	if stepErr != nil {
+		run.Error = stepErr.Error()
+	}
+
+	switch {
+	case compensating:
+		run.Status = WorkflowFailed
+	case run.Step == 0:
+		// Nothing completed before the first step; nothing to undo.
+		run.Status = WorkflowCompensated
+	default:
+		run.Status = WorkflowCompensating
+		run.Step--
+	}
+
+	if err := e.store.Save(ctx, run); err != nil {
+		slog.Error("workers: save workflow run after step failure",
+			slog.String("run_id", runID), slog.Any("error", err))
+		return
+	}
+	if run.Status.Terminal() {
+		slog.Warn("workers: workflow run ended",
+			slog.String("run_id", runID),
+			slog.String("workflow", run.Workflow),
+			slog.String("status", string(run.Status)),
+			slog.String("error", run.Error))
+		return
+	}
+	if err := e.submit(ctx, run); err != nil {
+		slog.Error("workers: submit workflow compensation",
+			slog.String("run_id", runID), slog.Any("error", err))
+	}
+}
+
+// mergeAttributes returns a new map with base and extra combined; extra
+// wins on conflicts.
+func mergeAttributes(base, extra map[string]string) map[string]string {
+	out := make(map[string]string, len(base)+len(extra))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range extra {
+		out[k] = v
+	}
+	return out
+}
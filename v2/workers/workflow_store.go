@@ -0,0 +1,252 @@
+package workers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hmmftg/requestCore/libQuery"
+)
+
+// WorkflowStore persists workflow run state.
+type WorkflowStore interface {
+	// Create inserts a new run.
+	Create(ctx context.Context, run *WorkflowRun) error
+	// Save replaces the state of a run added with Create.
+	Save(ctx context.Context, run *WorkflowRun) error
+	// Load returns the run with the given ID, or an error wrapping
+	// ErrWorkflowNotFound.
+	Load(ctx context.Context, runID string) (*WorkflowRun, error)
+	// ListIncomplete returns every run whose status is not terminal.
+	ListIncomplete(ctx context.Context) ([]*WorkflowRun, error)
+}
+
+// newRunID returns a cryptographically random 16-byte hex string.
+func newRunID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("workers: crypto/rand failed: %v", err))
+	}
+	return fmt.Sprintf("%x", b)
+}
+
+// MemoryWorkflowStore is an in-process WorkflowStore. Runs do not survive
+// a restart, so it is suitable for tests and for workflows that do not
+// need crash recovery.
+type MemoryWorkflowStore struct {
+	mu   sync.RWMutex
+	runs map[string]*WorkflowRun
+}
+
+// NewMemoryWorkflowStore creates an empty MemoryWorkflowStore.
+func NewMemoryWorkflowStore() *MemoryWorkflowStore {
+	return &MemoryWorkflowStore{runs: make(map[string]*WorkflowRun)}
+}
+
+// Create stores a copy of a new run.
+func (s *MemoryWorkflowStore) Create(_ context.Context, run *WorkflowRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.runs[run.ID]; ok {
+		return fmt.Errorf("workers: workflow run %q already exists", run.ID)
+	}
+	s.runs[run.ID] = run.clone()
+	return nil
+}
+
+// Save replaces the stored run with a copy.
+func (s *MemoryWorkflowStore) Save(_ context.Context, run *WorkflowRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.runs[run.ID]; !ok {
+		return fmt.Errorf("%w: run %q", ErrWorkflowNotFound, run.ID)
+	}
+	s.runs[run.ID] = run.clone()
+	return nil
+}
+
+// Load returns a copy of the run.
+func (s *MemoryWorkflowStore) Load(_ context.Context, runID string) (*WorkflowRun, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	run, ok := s.runs[runID]
+	if !ok {
+		return nil, fmt.Errorf("%w: run %q", ErrWorkflowNotFound, runID)
+	}
+	return run.clone(), nil
+}
+
+// ListIncomplete returns copies of all non-terminal runs.
+func (s *MemoryWorkflowStore) ListIncomplete(_ context.Context) ([]*WorkflowRun, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var runs []*WorkflowRun
+	for _, run := range s.runs {
+		if !run.Status.Terminal() {
+			runs = append(runs, run.clone())
+		}
+	}
+	return runs, nil
+}
+
+// DefaultWorkflowTable is the table used by SQLWorkflowStore when no
+// table name is configured.
+const DefaultWorkflowTable = "workflow_runs"
+
+// SQLWorkflowStore persists workflow runs through a
+// libQuery.QueryRunnerInterface, so writes go through the same audited
+// Dml path as request handlers. The expected schema is:
+//
+//	CREATE TABLE workflow_runs (
+//	    id          VARCHAR(64) PRIMARY KEY,
+//	    workflow    VARCHAR(200) NOT NULL,
+//	    status      VARCHAR(32)  NOT NULL,
+//	    step        INTEGER      NOT NULL,
+//	    data        TEXT,
+//	    error       TEXT,
+//	    created_at  BIGINT       NOT NULL,
+//	    updated_at  BIGINT       NOT NULL
+//	);
+//
+// Timestamps are stored as Unix milliseconds and data as JSON text, so
+// the schema is portable across the supported database modes.
+type SQLWorkflowStore struct {
+	core libQuery.QueryRunnerInterface
+
+	update     libQuery.DmlCommand
+	insert     libQuery.DmlCommand
+	load       libQuery.QueryCommand
+	incomplete libQuery.QueryCommand
+}
+
+// NewSQLWorkflowStore creates a SQLWorkflowStore on the given table. An
+// empty table name selects DefaultWorkflowTable.
+func NewSQLWorkflowStore(core libQuery.QueryRunnerInterface, table string) *SQLWorkflowStore {
+	if table == "" {
+		table = DefaultWorkflowTable
+	}
+	const columns = "id, workflow, status, step, data, error, created_at, updated_at"
+	return &SQLWorkflowStore{
+		core: core,
+		update: libQuery.DmlCommand{
+			Name:    "workflow-update",
+			Command: "UPDATE " + table + " SET status=$1, step=$2, data=$3, error=$4, updated_at=$5 WHERE id=$6",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "UPDATE " + table + " SET status=:1, step=:2, data=:3, error=:4, updated_at=:5 WHERE id=:6",
+				libQuery.MySql:  "UPDATE " + table + " SET status=?, step=?, data=?, error=?, updated_at=? WHERE id=?",
+				libQuery.Sqlite: "UPDATE " + table + " SET status=?, step=?, data=?, error=?, updated_at=? WHERE id=?",
+			},
+			Type: libQuery.Update,
+		},
+		insert: libQuery.DmlCommand{
+			Name:    "workflow-insert",
+			Command: "INSERT INTO " + table + " (" + columns + ") VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "INSERT INTO " + table + " (" + columns + ") VALUES (:1, :2, :3, :4, :5, :6, :7, :8)",
+				libQuery.MySql:  "INSERT INTO " + table + " (" + columns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+				libQuery.Sqlite: "INSERT INTO " + table + " (" + columns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			},
+			Type: libQuery.Insert,
+		},
+		load: libQuery.QueryCommand{
+			Name:    "workflow-load",
+			Command: "SELECT " + columns + " FROM " + table + " WHERE id=$1",
+			CommandMap: map[libQuery.DBMode]string{
+				libQuery.Oracle: "SELECT " + columns + " FROM " + table + " WHERE id=:1",
+				libQuery.MySql:  "SELECT " + columns + " FROM " + table + " WHERE id=?",
+				libQuery.Sqlite: "SELECT " + columns + " FROM " + table + " WHERE id=?",
+			},
+		},
+		incomplete: libQuery.QueryCommand{
+			Name:    "workflow-incomplete",
+			Command: "SELECT " + columns + " FROM " + table + " WHERE status IN ('running', 'compensating') ORDER BY created_at",
+		},
+	}
+}
+
+// workflowRow is the database representation of a WorkflowRun.
+type workflowRow struct {
+	ID        string `db:"id"`
+	Workflow  string `db:"workflow"`
+	Status    string `db:"status"`
+	Step      int    `db:"step"`
+	Data      string `db:"data"`
+	Error     string `db:"error"`
+	CreatedAt int64  `db:"created_at"`
+	UpdatedAt int64  `db:"updated_at"`
+}
+
+func (r workflowRow) toRun() (*WorkflowRun, error) {
+	run := &WorkflowRun{
+		ID:        r.ID,
+		Workflow:  r.Workflow,
+		Status:    WorkflowStatus(r.Status),
+		Step:      r.Step,
+		Data:      make(map[string]any),
+		Error:     r.Error,
+		CreatedAt: time.UnixMilli(r.CreatedAt),
+		UpdatedAt: time.UnixMilli(r.UpdatedAt),
+	}
+	if r.Data != "" {
+		if err := json.Unmarshal([]byte(r.Data), &run.Data); err != nil {
+			return nil, fmt.Errorf("workers: decode workflow run %s data: %w", r.ID, err)
+		}
+	}
+	return run, nil
+}
+
+// Create inserts the run row.
+func (s *SQLWorkflowStore) Create(ctx context.Context, run *WorkflowRun) error {
+	data, err := json.Marshal(run.Data)
+	if err != nil {
+		return fmt.Errorf("workers: encode workflow run %s data: %w", run.ID, err)
+	}
+	_, err = s.core.Dml(ctx, "workflow", s.insert.Name, s.insert.GetCommand(s.core.GetDbMode()),
+		run.ID, run.Workflow, string(run.Status), run.Step, string(data), run.Error,
+		run.CreatedAt.UnixMilli(), run.UpdatedAt.UnixMilli())
+	return err
+}
+
+// Save updates the run row. The affected row count is not checked:
+// MySQL reports 0 for an update that changes nothing.
+func (s *SQLWorkflowStore) Save(ctx context.Context, run *WorkflowRun) error {
+	data, err := json.Marshal(run.Data)
+	if err != nil {
+		return fmt.Errorf("workers: encode workflow run %s data: %w", run.ID, err)
+	}
+	_, err = s.core.Dml(ctx, "workflow", s.update.Name, s.update.GetCommand(s.core.GetDbMode()),
+		string(run.Status), run.Step, string(data), run.Error, run.UpdatedAt.UnixMilli(), run.ID)
+	return err
+}
+
+// Load reads a run by ID.
+func (s *SQLWorkflowStore) Load(_ context.Context, runID string) (*WorkflowRun, error) {
+	rows, err := libQuery.QueryToStruct[workflowRow](s.core, s.load.GetCommand(s.core.GetDbMode()), runID)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: run %q", ErrWorkflowNotFound, runID)
+	}
+	return rows[0].toRun()
+}
+
+// ListIncomplete reads all running and compensating runs.
+func (s *SQLWorkflowStore) ListIncomplete(_ context.Context) ([]*WorkflowRun, error) {
+	rows, err := libQuery.QueryToStruct[workflowRow](s.core, s.incomplete.GetCommand(s.core.GetDbMode()))
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]*WorkflowRun, 0, len(rows))
+	for _, row := range rows {
+		run, err := row.toRun()
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
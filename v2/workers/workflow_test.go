@@ -0,0 +1,314 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hmmftg/requestCore/libQuery"
+)
+
+func newWorkflowTestWorker(t *testing.T) *InProcessWorker {
+	t.Helper()
+	w := NewInProcessWorker(Config{WorkerCount: 2, QueueSize: 10})
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := w.Shutdown(ctx); err != nil {
+			t.Fatalf("Shutdown: %v", err)
+		}
+	})
+	return w
+}
+
+func waitForStatus(t *testing.T, e *WorkflowEngine, runID string, want WorkflowStatus) *WorkflowRun {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		run, err := e.Get(context.Background(), runID)
+		if err == nil && run.Status == want {
+			return run
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	run, _ := e.Get(context.Background(), runID)
+	t.Fatalf("run %s did not reach %q, last state %+v", runID, want, run)
+	return nil
+}
+
+type stepRecorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *stepRecorder) record(name string) StepHandler {
+	return func(*StepContext) error {
+		r.mu.Lock()
+		r.calls = append(r.calls, name)
+		r.mu.Unlock()
+		return nil
+	}
+}
+
+func (r *stepRecorder) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+func TestWorkflowEngine_Completes(t *testing.T) {
+	e := NewWorkflowEngine(newWorkflowTestWorker(t), nil)
+	rec := &stepRecorder{}
+	err := e.Register(Workflow{
+		Name: "transfer",
+		Steps: []Step{
+			{Name: "debit", Run: func(s *StepContext) error {
+				s.Set("debited", s.GetString("account"))
+				return rec.record("debit")(s)
+			}},
+			{Name: "partner", Run: rec.record("partner")},
+			{Name: "credit", Run: func(s *StepContext) error {
+				if s.GetString("debited") != "acc-1" {
+					t.Errorf("expected data from previous step, got %v", s.Get("debited"))
+				}
+				return rec.record("credit")(s)
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	runID, err := e.Start(context.Background(), "transfer", map[string]any{"account": "acc-1"})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	run := waitForStatus(t, e, runID, WorkflowCompleted)
+	if run.Data["debited"] != "acc-1" {
+		t.Fatalf("expected persisted data, got %v", run.Data)
+	}
+	if got := rec.list(); len(got) != 3 || got[0] != "debit" || got[2] != "credit" {
+		t.Fatalf("unexpected step order: %v", got)
+	}
+}
+
+func TestWorkflowEngine_CompensatesInReverse(t *testing.T) {
+	e := NewWorkflowEngine(newWorkflowTestWorker(t), nil)
+	rec := &stepRecorder{}
+	var creditAttempts atomic.Int32
+	err := e.Register(Workflow{
+		Name: "transfer",
+		Steps: []Step{
+			{Name: "debit", Run: rec.record("debit"), Compensate: rec.record("refund")},
+			{Name: "partner", Run: rec.record("partner"), Compensate: rec.record("cancel")},
+			{
+				Name: "credit",
+				Run: func(s *StepContext) error {
+					creditAttempts.Store(int32(s.Attempt))
+					return errors.New("credit rejected")
+				},
+				Compensate: rec.record("never"),
+				Options:    JobOptions{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	runID, err := e.Start(context.Background(), "transfer", nil)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	run := waitForStatus(t, e, runID, WorkflowCompensated)
+	if run.Error != "credit rejected" {
+		t.Fatalf("expected step error to be recorded, got %q", run.Error)
+	}
+	if n := creditAttempts.Load(); n != 2 {
+		t.Fatalf("expected per-step retries, got %d attempts", n)
+	}
+	want := []string{"debit", "partner", "cancel", "refund"}
+	got := rec.list()
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+// TestWorkflowEngine_CompensationKeepsOrigin verifies that compensating
+// steps run with the Origin of the request that started the run.
+func TestWorkflowEngine_CompensationKeepsOrigin(t *testing.T) {
+	e := NewWorkflowEngine(newWorkflowTestWorker(t), nil)
+	var seen atomic.Value
+	err := e.Register(Workflow{
+		Name: "transfer",
+		Steps: []Step{
+			{Name: "debit", Run: func(*StepContext) error { return nil }, Compensate: func(s *StepContext) error {
+				seen.Store(s.Origin)
+				return nil
+			}},
+			{Name: "credit", Run: func(*StepContext) error { return errors.New("credit rejected") }},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	origin := Origin{RequestID: "req-0000000042", User: "alice"}
+	runID, err := e.Start(WithOrigin(context.Background(), origin), "transfer", nil)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitForStatus(t, e, runID, WorkflowCompensated)
+	if got, _ := seen.Load().(Origin); got.RequestID != origin.RequestID || got.User != origin.User {
+		t.Fatalf("expected compensation to keep origin %+v, got %+v", origin, got)
+	}
+}
+
+func TestWorkflowEngine_CompensationFailure(t *testing.T) {
+	e := NewWorkflowEngine(newWorkflowTestWorker(t), nil)
+	err := e.Register(Workflow{
+		Name: "transfer",
+		Steps: []Step{
+			{Name: "debit", Run: func(*StepContext) error { return nil },
+				Compensate: func(*StepContext) error { return errors.New("refund failed") }},
+			{Name: "credit", Run: func(*StepContext) error { return errors.New("credit rejected") }},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	runID, err := e.Start(context.Background(), "transfer", nil)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	run := waitForStatus(t, e, runID, WorkflowFailed)
+	if run.Error != "refund failed" {
+		t.Fatalf("expected compensation error, got %q", run.Error)
+	}
+}
+
+func TestWorkflowEngine_Resume(t *testing.T) {
+	store := NewMemoryWorkflowStore()
+	now := time.Now()
+	if err := store.Create(context.Background(), &WorkflowRun{
+		ID: "run-1", Workflow: "transfer", Status: WorkflowRunning, Step: 1,
+		Data: map[string]any{}, CreatedAt: now, UpdatedAt: now,
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	e := NewWorkflowEngine(newWorkflowTestWorker(t), store)
+	rec := &stepRecorder{}
+	if err := e.Register(Workflow{
+		Name:  "transfer",
+		Steps: []Step{{Name: "debit", Run: rec.record("debit")}, {Name: "credit", Run: rec.record("credit")}},
+	}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := e.Resume(context.Background()); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	waitForStatus(t, e, "run-1", WorkflowCompleted)
+	if got := rec.list(); len(got) != 1 || got[0] != "credit" {
+		t.Fatalf("expected only the interrupted step to run, got %v", got)
+	}
+}
+
+func TestWorkflowEngine_RegisterValidation(t *testing.T) {
+	e := NewWorkflowEngine(NewInProcessWorker(DefaultConfig()), nil)
+	noop := func(*StepContext) error { return nil }
+	cases := []Workflow{
+		{Name: "", Steps: []Step{{Name: "a", Run: noop}}},
+		{Name: "empty"},
+		{Name: "no-run", Steps: []Step{{Name: "a"}}},
+		{Name: "dup", Steps: []Step{{Name: "a", Run: noop}, {Name: "a", Run: noop}}},
+	}
+	for _, wf := range cases {
+		if err := e.Register(wf); err == nil {
+			t.Fatalf("expected error registering %+v", wf)
+		}
+	}
+	if _, err := e.Start(context.Background(), "missing", nil); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Fatalf("expected ErrWorkflowNotFound, got %v", err)
+	}
+}
+
+func TestSQLWorkflowStore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+	core := libQuery.Init(db, "prog", "mod", libQuery.Postgres)
+	core.Mode = libQuery.Postgres
+	store := NewSQLWorkflowStore(core, "")
+
+	expectDml := func(pattern string, rows int64) {
+		mock.ExpectBegin()
+		for i := 0; i < 4; i++ {
+			mock.ExpectExec("set_config").WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		mock.ExpectExec(pattern).WillReturnResult(sqlmock.NewResult(0, rows))
+		mock.ExpectCommit()
+	}
+	expectDml("INSERT INTO workflow_runs", 1)
+	// An update that changes nothing affects no rows on MySQL; Save must
+	// not mistake that for a missing run.
+	expectDml("UPDATE workflow_runs", 0)
+
+	now := time.UnixMilli(1700000000000)
+	run := &WorkflowRun{
+		ID: "run-1", Workflow: "transfer", Status: WorkflowRunning, Step: 1,
+		Data: map[string]any{"account": "acc-1"}, CreatedAt: now, UpdatedAt: now,
+	}
+	if err := store.Create(context.Background(), run); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := store.Save(context.Background(), run); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	columns := []string{"id", "workflow", "status", "step", "data", "error", "created_at", "updated_at"}
+	mock.ExpectPrepare("SELECT .* FROM workflow_runs WHERE id").ExpectQuery().
+		WithArgs("run-1").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("run-1", "transfer", "running", 1, `{"account":"acc-1"}`, "", now.UnixMilli(), now.UnixMilli()))
+	loaded, err := store.Load(context.Background(), "run-1")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if loaded.Status != WorkflowRunning || loaded.Step != 1 || loaded.Data["account"] != "acc-1" || !loaded.CreatedAt.Equal(now) {
+		t.Fatalf("unexpected run: %+v", loaded)
+	}
+
+	mock.ExpectPrepare("SELECT .* FROM workflow_runs WHERE id").ExpectQuery().
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows(columns))
+	if _, err := store.Load(context.Background(), "missing"); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Fatalf("expected ErrWorkflowNotFound, got %v", err)
+	}
+
+	mock.ExpectPrepare("WHERE status IN").ExpectQuery().
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("run-1", "transfer", "compensating", 0, "", "boom", now.UnixMilli(), now.UnixMilli()))
+	runs, err := store.ListIncomplete(context.Background())
+	if err != nil {
+		t.Fatalf("ListIncomplete: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Status != WorkflowCompensating {
+		t.Fatalf("unexpected runs: %+v", runs)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}